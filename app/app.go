@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/mailer"
+	"viskatera-api-go/payments"
+	"viskatera-api-go/routes"
+	"viskatera-api-go/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+)
+
+const shutdownTimeout = 30 * time.Second
+
+// LoadEnv loads environment variables from .env, if there is one. It runs
+// before the role is read so APP_ROLE can be set there too.
+func LoadEnv() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+}
+
+// Run starts what role needs, the HTTP API and/or the background workers, and
+// blocks until SIGINT or SIGTERM, then shuts down gracefully
+func Run(role config.AppRole) {
+	// Set Gin mode based on environment
+	if os.Getenv("GIN_MODE") == "" {
+		env := os.Getenv("ENVIRONMENT")
+		if env == "production" || env == "prod" {
+			gin.SetMode(gin.ReleaseMode)
+		}
+	} else {
+		gin.SetMode(os.Getenv("GIN_MODE"))
+	}
+
+	log.Printf("Starting with role %s", role)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	// Connect to database; the API process owns the schema
+	config.ConnectDB()
+	if role.RunsAPI() {
+		config.MigrateDB()
+	}
+
+	// Connect to Redis cache
+	config.ConnectRedis()
+
+	// The API process answers health checks itself; a worker gets its own
+	// endpoints, up from the start but not ready until it consumes
+	var background *workers.Background
+	if role.RunsWorkers() {
+		background = workers.NewBackground()
+		if port := os.Getenv("WORKER_HEALTH_PORT"); port != "" || role == config.RoleWorker {
+			if port == "" {
+				port = "8081"
+			}
+			background.ServeHealth(":" + port)
+		}
+	}
+
+	// Connect to RabbitMQ. The connection is retried in the background; queued
+	// jobs wait in the outbox and consumers start once it is up.
+	if err := config.ConnectRabbitMQ(); err != nil {
+		log.Printf("Warning: Failed to connect to RabbitMQ: %v. Retrying in the background; queued emails wait in the outbox until it is reachable.", err)
+	}
+
+	// Select payment gateway
+	payments.InitGateways()
+
+	// Select mail transport
+	mailer.Init()
+
+	if background != nil {
+		background.Start()
+	}
+
+	var srv *http.Server
+	if role.RunsAPI() {
+		srv = startServer()
+	}
+
+	// Wait for interrupt signal to gracefully shutdown
+	<-quit
+
+	log.Println("Shutting down...")
+
+	// Graceful shutdown with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Server forced to shutdown: %v", err)
+		}
+	}
+
+	// Let running jobs finish; unfinished ones are redelivered after restart
+	if background != nil {
+		if err := background.Shutdown(ctx); err != nil {
+			log.Printf("Jobs did not finish before shutdown: %v", err)
+		}
+	}
+
+	closeConnections()
+
+	log.Println("Exited gracefully")
+}
+
+// startServer serves the HTTP API on PORT (default 8080)
+func startServer() *http.Server {
+	// Setup routes
+	r := routes.SetupRoutes()
+
+	// Get port from environment or use default
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	// Create HTTP server with timeouts for production
+	srv := &http.Server{
+		Addr:         ":" + port,
+		Handler:      r,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	// Start server in goroutine
+	go func() {
+		log.Printf("Server starting on port %s (mode: %s)", port, gin.Mode())
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	return srv
+}
+
+func closeConnections() {
+	// Close database connection
+	if err := config.CloseDB(); err != nil {
+		log.Printf("Error closing database: %v", err)
+	}
+
+	// Close Redis connection
+	if err := config.CloseRedis(); err != nil {
+		log.Printf("Error closing Redis: %v", err)
+	}
+
+	// Close RabbitMQ connection
+	if err := config.CloseRabbitMQ(); err != nil {
+		log.Printf("Error closing RabbitMQ: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+// Command worker runs only the background workers: the queue consumers, the
+// outbox relay and the scheduled jobs. Health endpoints are served on
+// WORKER_HEALTH_PORT (default 8081).
+package main
+
+import (
+	"viskatera-api-go/app"
+	"viskatera-api-go/config"
+)
+
+func main() {
+	app.LoadEnv()
+	app.Run(config.RoleWorker)
+}
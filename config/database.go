@@ -1,6 +1,7 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -80,17 +81,52 @@ func MigrateDB() {
 		&models.User{},
 		&models.Visa{},
 		&models.VisaOption{},
+		&models.Applicant{},
 		&models.VisaPurchase{},
+		&models.PurchaseApplicant{},
 		&models.PasswordResetToken{},
 		&models.OTP{},
 		&models.Payment{},
 		&models.ActivityLog{},
+		&models.PurchaseStatusHistory{},
+		&models.VisaDocumentRequirement{},
+		&models.PurchaseDocument{},
+		&models.RefreshToken{},
+		&models.UserPermission{},
+		&models.UserInvite{},
+		&models.WebhookEvent{},
+		&models.Refund{},
+		&models.ReconciliationRun{},
+		&models.ReconciliationItem{},
+		&models.ExchangeRate{},
+		&models.PromoCode{},
+		&models.PromoRedemption{},
+		&models.PricingRule{},
+		&models.PurchaseLineItem{},
+		&models.Invoice{},
+		&models.InvoiceSequence{},
+		&models.IdempotencyKey{},
+		&models.OutboxMessage{},
+		&models.EmailLog{},
 	)
 
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Map legacy purchase statuses onto the application lifecycle
+	DB.Model(&models.VisaPurchase{}).Where("status = ?", "pending").Update("status", models.PurchaseStatusDraft)
+	DB.Model(&models.VisaPurchase{}).Where("status = ?", "completed").Update("status", models.PurchaseStatusSubmitted)
+
+	// Purchases made before multi-currency pricing and promo codes were charged their undiscounted IDR price
+	DB.Model(&models.VisaPurchase{}).Where("original_total = 0 AND total_price <> 0").Update("original_total", gorm.Expr("total_price"))
+	DB.Model(&models.VisaPurchase{}).Where("subtotal = 0 AND total_price <> 0").Update("subtotal", gorm.Expr("total_price"))
+
+	// Purchases made before applicant snapshots show their applicants as they are now
+	DB.Exec(`UPDATE purchase_applicants SET full_name = a.full_name, passport_number = a.passport_number,
+		nationality = a.nationality, date_of_birth = a.date_of_birth, passport_expiry = a.passport_expiry
+		FROM applicants a WHERE a.id = purchase_applicants.applicant_id AND purchase_applicants.full_name = ''`)
+
 	log.Println("Database migration completed!")
 }
 
@@ -105,3 +141,35 @@ func CloseDB() error {
 	}
 	return nil
 }
+
+// WithAdvisoryLock runs fn while holding the Postgres advisory lock key, so
+// only one instance of the application runs it at a time. It returns false
+// without running fn when another session holds the lock.
+func WithAdvisoryLock(ctx context.Context, key int64, fn func() error) (bool, error) {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return false, err
+	}
+
+	// Session-level advisory locks belong to one connection, so pin one from the pool
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			log.Printf("Failed to release advisory lock %d: %v", key, err)
+		}
+	}()
+
+	return true, fn()
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DeadLetter is a message in a dead-letter queue
+type DeadLetter struct {
+	MessageID      string     `json:"message_id"`
+	Queue          string     `json:"queue"` // work queue it came from
+	Attempts       int        `json:"attempts"`
+	Error          string     `json:"error"`
+	DeadLetteredAt *time.Time `json:"dead_lettered_at"`
+	PublishedAt    *time.Time `json:"published_at"`
+	Body           []byte     `json:"-"`
+}
+
+// MessageAttempt returns the attempt a delivery is on, starting at 1
+func MessageAttempt(msg amqp.Delivery) int {
+	switch attempt := msg.Headers[HeaderAttempt].(type) {
+	case int32:
+		return int(attempt)
+	case int64:
+		return int(attempt)
+	case int:
+		return attempt
+	}
+	return 1
+}
+
+// RetryMessage schedules the next attempt of a failed delivery from queue.
+// The message waits in the retry queue for the backoff of its attempt and is
+// then handed back to queue by RabbitMQ. It returns once RabbitMQ has
+// confirmed the retry, so the delivery can then be acknowledged.
+func RetryMessage(queue string, msg amqp.Delivery, cause error) error {
+	attempt := MessageAttempt(msg)
+	delay := RetryDelays[len(RetryDelays)-1]
+	if attempt <= len(RetryDelays) {
+		delay = RetryDelays[attempt-1]
+	}
+
+	headers := copyHeaders(msg.Headers)
+	headers[HeaderAttempt] = int32(attempt + 1)
+	headers[HeaderError] = cause.Error()
+	return republish(retryQueue(queue, delay), msg, headers)
+}
+
+// DeadLetterMessage copies a delivery from queue that will not succeed to the
+// queue's dead-letter queue, recording why. It returns once RabbitMQ has
+// confirmed the copy, so the delivery can then be acknowledged.
+func DeadLetterMessage(queue string, msg amqp.Delivery, cause error) error {
+	headers := copyHeaders(msg.Headers)
+	headers[HeaderError] = cause.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[HeaderOriginalQueue] = queue
+	return republish(DeadLetterQueue(queue), msg, headers)
+}
+
+// PeekDeadLetters returns up to limit messages of queue's dead-letter queue,
+// oldest first, leaving them in the queue
+func PeekDeadLetters(queue string, limit int) ([]DeadLetter, int, error) {
+	ch, err := RabbitMQChannel()
+	if err != nil {
+		return nil, 0, err
+	}
+	// Closing the channel puts every message we fetched back unchanged
+	defer ch.Close()
+
+	info, err := ch.QueueInspect(DeadLetterQueue(queue))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	letters := []DeadLetter{}
+	for i := 0; i < info.Messages && len(letters) < limit; i++ {
+		msg, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, deadLetterFromDelivery(queue, msg))
+	}
+	return letters, info.Messages, nil
+}
+
+// FindDeadLetter returns the message with messageID from queue's dead-letter
+// queue, leaving it in the queue, or nil if there is none
+func FindDeadLetter(queue, messageID string) (*DeadLetter, error) {
+	var found *DeadLetter
+	err := scanDeadLetters(queue, func(ch *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		if msg.MessageId != messageID {
+			return false, nil
+		}
+		letter := deadLetterFromDelivery(queue, msg)
+		found = &letter
+		return false, nil
+	})
+	return found, err
+}
+
+// RequeueDeadLetters moves messages from queue's dead-letter queue back to
+// queue with a fresh attempt count. An empty messageID moves all of them.
+// It returns how many were moved.
+func RequeueDeadLetters(queue, messageID string) (int, error) {
+	moved := 0
+	err := scanDeadLetters(queue, func(ch *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		if messageID != "" && msg.MessageId != messageID {
+			return false, nil
+		}
+		headers := copyHeaders(msg.Headers)
+		delete(headers, HeaderError)
+		delete(headers, HeaderFailedAt)
+		delete(headers, HeaderOriginalQueue)
+		headers[HeaderAttempt] = int32(1)
+		if err := republish(queue, msg, headers); err != nil {
+			return false, err
+		}
+		moved++
+		return true, nil
+	})
+	return moved, err
+}
+
+// PurgeDeadLetters deletes messages from queue's dead-letter queue. An empty
+// messageID deletes all of them. It returns how many were deleted.
+func PurgeDeadLetters(queue, messageID string) (int, error) {
+	if messageID == "" {
+		ch, err := RabbitMQChannel()
+		if err != nil {
+			return 0, err
+		}
+		defer ch.Close()
+		return ch.QueuePurge(DeadLetterQueue(queue), false)
+	}
+
+	deleted := 0
+	err := scanDeadLetters(queue, func(ch *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		if msg.MessageId != messageID {
+			return false, nil
+		}
+		deleted++
+		return true, nil
+	})
+	return deleted, err
+}
+
+// GetDeadLetterStats returns the number of dead-lettered messages per work queue
+func GetDeadLetterStats() (map[string]int, error) {
+	ch, err := RabbitMQChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	stats := make(map[string]int)
+	for _, queueName := range WorkQueues() {
+		info, err := ch.QueueInspect(DeadLetterQueue(queueName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect queue: %w", err)
+		}
+		stats[queueName] = info.Messages
+	}
+	return stats, nil
+}
+
+// scanDeadLetters fetches every message currently in queue's dead-letter
+// queue once. Messages for which visit returns true are acknowledged and so
+// removed; the others go back to the queue when the channel closes.
+func scanDeadLetters(queue string, visit func(*amqp.Channel, amqp.Delivery) (bool, error)) error {
+	ch, err := RabbitMQChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	info, err := ch.QueueInspect(DeadLetterQueue(queue))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < info.Messages; i++ {
+		msg, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		remove, err := visit(ch, msg)
+		if err != nil {
+			return err
+		}
+		if remove {
+			if err := msg.Ack(false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deadLetterFromDelivery(queue string, msg amqp.Delivery) DeadLetter {
+	letter := DeadLetter{
+		MessageID: msg.MessageId,
+		Queue:     queue,
+		Attempts:  MessageAttempt(msg),
+		Body:      msg.Body,
+	}
+	if cause, ok := msg.Headers[HeaderError].(string); ok {
+		letter.Error = cause
+	} else {
+		// Rejected by a consumer or expired rather than dead-lettered by a worker
+		letter.Error = "rejected by consumer"
+	}
+	if failedAt, ok := msg.Headers[HeaderFailedAt].(string); ok {
+		if t, err := time.Parse(time.RFC3339, failedAt); err == nil {
+			letter.DeadLetteredAt = &t
+		}
+	}
+	if !msg.Timestamp.IsZero() {
+		publishedAt := msg.Timestamp
+		letter.PublishedAt = &publishedAt
+	}
+	return letter
+}
+
+// republish publishes a copy of msg to routingKey and waits for the confirm.
+// The delivery must only be acknowledged after it returns nil, or the message
+// is lost if RabbitMQ never stored the copy.
+func republish(routingKey string, msg amqp.Delivery, headers amqp.Table) error {
+	messageID := msg.MessageId
+	if messageID == "" {
+		messageID = newMessageID()
+	}
+	return publishConfirmed(routingKey, amqp.Publishing{
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageID,
+		Timestamp:    msg.Timestamp,
+		Headers:      headers,
+		Body:         msg.Body,
+	})
+}
+
+// copyHeaders copies the headers we set; RabbitMQ's own x-death history is dropped
+func copyHeaders(headers amqp.Table) amqp.Table {
+	copied := amqp.Table{}
+	for key, value := range headers {
+		if key != "x-death" {
+			copied[key] = value
+		}
+	}
+	return copied
+}
@@ -1,103 +1,108 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"sync"
+	"strconv"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-var (
-	RabbitMQConn *amqp.Connection
-	RabbitMQChan *amqp.Channel
-	queueOnce    sync.Once
-)
-
 // Queue names
 const (
 	QueueEmailInvoice        = "email_invoice"
 	QueueEmailPaymentSuccess = "email_payment_success"
 	QueueGeneratePDF         = "generate_pdf"
+	QueueEmailInvite         = "email_invite"
+	QueueEmailRefund         = "email_refund"
 )
 
-// ConnectRabbitMQ connects to RabbitMQ server
-func ConnectRabbitMQ() error {
-	var err error
+// Message headers used for retries and dead-lettering
+const (
+	HeaderAttempt       = "x-attempt"        // attempt the message is on, starting at 1
+	HeaderError         = "x-error"          // why the last attempt failed
+	HeaderFailedAt      = "x-failed-at"      // when the message was dead-lettered, RFC 3339
+	HeaderOriginalQueue = "x-original-queue" // work queue a dead-lettered message came from
+)
 
-	host := os.Getenv("RABBITMQ_HOST")
-	if host == "" {
-		host = "localhost"
-	}
+const defaultQueueMaxAttempts = 5
 
-	port := os.Getenv("RABBITMQ_PORT")
-	if port == "" {
-		port = "5672"
-	}
+// RetryDelays are the waits before the second, third, ... attempt of a failed
+// job. Each has its own retry queue; attempts beyond the list reuse the last one.
+var RetryDelays = []time.Duration{15 * time.Second, time.Minute, 4 * time.Minute, 16 * time.Minute}
 
-	user := os.Getenv("RABBITMQ_USER")
-	if user == "" {
-		user = "admin"
+// WorkQueues lists the queues jobs are published to
+func WorkQueues() []string {
+	return []string{
+		QueueEmailInvoice,
+		QueueEmailPaymentSuccess,
+		QueueGeneratePDF,
+		QueueEmailInvite,
+		QueueEmailRefund,
 	}
+}
 
-	pass := os.Getenv("RABBITMQ_PASS")
-	if pass == "" {
-		pass = "admin123"
+// IsWorkQueue reports whether name is one of the work queues
+func IsWorkQueue(name string) bool {
+	for _, queue := range WorkQueues() {
+		if queue == name {
+			return true
+		}
 	}
+	return false
+}
 
-	vhost := os.Getenv("RABBITMQ_VHOST")
-	if vhost == "" {
-		vhost = "/"
-	}
+// DeadLetterQueue is where jobs of queue end up once they cannot succeed
+func DeadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
 
-	// Build connection URL
-	amqpURL := fmt.Sprintf("amqp://%s:%s@%s:%s%s", user, pass, host, port, vhost)
+// retryQueue holds failed jobs of queue for delay, then hands them back to queue
+func retryQueue(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queue, delay)
+}
 
-	// Connect to RabbitMQ
-	RabbitMQConn, err = amqp.Dial(amqpURL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+// QueueMaxAttempts returns how often a job is tried before it is dead-lettered (QUEUE_MAX_ATTEMPTS, default 5)
+func QueueMaxAttempts() int {
+	if attempts, err := strconv.Atoi(os.Getenv("QUEUE_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		return attempts
 	}
+	return defaultQueueMaxAttempts
+}
 
-	// Create channel
-	RabbitMQChan, err = RabbitMQConn.Channel()
+// DeclareQueues declares every work queue with its retry queues and its
+// dead-letter queue on conn. Jobs rejected without requeue go to the
+// dead-letter queue. It runs on every (re)connect.
+func DeclareQueues(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
 	if err != nil {
-		RabbitMQConn.Close()
 		return fmt.Errorf("failed to open channel: %w", err)
 	}
+	defer func() { ch.Close() }()
 
-	log.Println("RabbitMQ connected successfully!")
-
-	// Declare queues
-	if err := DeclareQueues(); err != nil {
-		return fmt.Errorf("failed to declare queues: %w", err)
-	}
-
-	return nil
-}
-
-// DeclareQueues declares all required queues
-func DeclareQueues() error {
-	queues := []string{
-		QueueEmailInvoice,
-		QueueEmailPaymentSuccess,
-		QueueGeneratePDF,
-	}
-
-	for _, queueName := range queues {
-		_, err := RabbitMQChan.QueueDeclare(
-			queueName, // name
-			true,      // durable
-			false,     // delete when unused
-			false,     // exclusive
-			false,     // no-wait
-			amqp.Table{
-				"x-message-ttl": int32(3600000), // 1 hour TTL
-			}, // arguments
-		)
-		if err != nil {
-			return fmt.Errorf("failed to declare queue %s: %w", queueName, err)
+	for _, queueName := range WorkQueues() {
+		if err := declareQueue(conn, &ch, queueName, amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": DeadLetterQueue(queueName),
+		}); err != nil {
+			return err
+		}
+		if err := declareQueue(conn, &ch, DeadLetterQueue(queueName), nil); err != nil {
+			return err
+		}
+		for _, delay := range RetryDelays {
+			if err := declareQueue(conn, &ch, retryQueue(queueName, delay), amqp.Table{
+				"x-message-ttl":             int32(delay.Milliseconds()),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queueName,
+			}); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -105,68 +110,62 @@ func DeclareQueues() error {
 	return nil
 }
 
-// PublishMessage publishes a message to a queue
-func PublishMessage(queueName string, message []byte) error {
-	if RabbitMQChan == nil {
-		return fmt.Errorf("RabbitMQ channel not initialized")
-	}
-
-	err := RabbitMQChan.Publish(
-		"",        // exchange
-		queueName, // routing key
-		false,     // mandatory
-		false,     // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			DeliveryMode: amqp.Persistent, // Make message persistent
-			Body:         message,
-		},
+// declareQueue declares a durable queue. A queue declared earlier with other
+// arguments, like the 1 hour TTL of older versions, is recreated if it is
+// empty; otherwise it has to be drained first. A failed declare closes *ch,
+// so it is replaced with a new channel.
+func declareQueue(conn *amqp.Connection, ch **amqp.Channel, name string, args amqp.Table) error {
+	_, err := (*ch).QueueDeclare(
+		name,  // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		args,  // arguments
 	)
-
-	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+	if err == nil {
+		return nil
+	}
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.PreconditionFailed {
+		return fmt.Errorf("failed to declare queue %s: %w", name, err)
 	}
 
-	log.Printf("Message published to queue: %s", queueName)
-	return nil
-}
-
-// CloseRabbitMQ closes RabbitMQ connection
-func CloseRabbitMQ() error {
-	if RabbitMQChan != nil {
-		RabbitMQChan.Close()
+	// The failed declare closed the channel
+	if *ch, err = conn.Channel(); err != nil {
+		return fmt.Errorf("failed to reopen channel: %w", err)
+	}
+	if _, err := (*ch).QueueDelete(name, false, true, false); err != nil {
+		return fmt.Errorf("queue %s was declared with other arguments and still holds messages, drain it and restart: %w", name, err)
 	}
-	if RabbitMQConn != nil {
-		return RabbitMQConn.Close()
+	log.Printf("Recreating queue %s with new arguments", name)
+	if _, err := (*ch).QueueDeclare(name, true, false, false, false, args); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", name, err)
 	}
 	return nil
 }
 
-// GetQueueStats returns queue statistics
+// GetQueueStats returns the number of messages waiting in a queue
 func GetQueueStats(queueName string) (int, error) {
-	if RabbitMQChan == nil {
-		return 0, fmt.Errorf("RabbitMQ channel not initialized")
-	}
-
-	queue, err := RabbitMQChan.QueueInspect(queueName)
+	ch, err := RabbitMQChannel()
 	if err != nil {
-		return 0, fmt.Errorf("failed to inspect queue: %w", err)
+		return 0, err
 	}
-
-	return queue.Messages, nil
+	defer ch.Close()
+	return inspectQueue(ch, queueName)
 }
 
 // GetAllQueueStats returns statistics for all queues
 func GetAllQueueStats() (map[string]int, error) {
-	stats := make(map[string]int)
-	queues := []string{
-		QueueEmailInvoice,
-		QueueEmailPaymentSuccess,
-		QueueGeneratePDF,
+	ch, err := RabbitMQChannel()
+	if err != nil {
+		return nil, err
 	}
+	defer ch.Close()
 
-	for _, queueName := range queues {
-		count, err := GetQueueStats(queueName)
+	stats := make(map[string]int)
+	for _, queueName := range WorkQueues() {
+		count, err := inspectQueue(ch, queueName)
 		if err != nil {
 			return nil, err
 		}
@@ -175,3 +174,20 @@ func GetAllQueueStats() (map[string]int, error) {
 
 	return stats, nil
 }
+
+func inspectQueue(ch *amqp.Channel, queueName string) (int, error) {
+	queue, err := ch.QueueInspect(queueName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue: %w", err)
+	}
+	return queue.Messages, nil
+}
+
+// newMessageID returns a random ID so single messages can be found again, e.g. in a dead-letter queue
+func newMessageID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,389 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	rabbitMQRetryMin      = time.Second
+	rabbitMQRetryMax      = 30 * time.Second
+	publisherChannels     = 8               // idle publisher channels kept open
+	publishConfirmTimeout = 5 * time.Second // how long PublishMessage waits for the broker's confirm
+)
+
+// ErrRabbitMQUnavailable is returned while there is no connection to RabbitMQ
+var ErrRabbitMQUnavailable = errors.New("RabbitMQ connection not available")
+
+// RabbitMQStatus describes the supervised RabbitMQ connection
+type RabbitMQStatus struct {
+	Connected      bool       `json:"connected"`
+	ConnectedAt    *time.Time `json:"connected_at,omitempty"`
+	DisconnectedAt *time.Time `json:"disconnected_at,omitempty"`
+	Reconnects     int64      `json:"reconnects"`      // successful reconnects since startup
+	FailedAttempts int64      `json:"failed_attempts"` // failed connection attempts since startup
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// rabbitMQ holds the one connection of the process and replaces it with
+// backoff whenever it closes. Publishing goes through a pool of channels in
+// confirm mode, so no channel is used by two goroutines at once.
+type rabbitMQ struct {
+	dialing sync.Mutex // one connection attempt at a time
+
+	mu     sync.RWMutex
+	conn   *amqp.Connection
+	closed chan *amqp.Error
+	// ready is closed while connected and replaced when the connection is lost
+	ready          chan struct{}
+	connectedAt    time.Time
+	disconnectedAt time.Time
+	lastError      string
+
+	everConnected  bool
+	reconnects     atomic.Int64
+	failedAttempts atomic.Int64
+
+	publishers chan *amqp.Channel
+
+	supervise sync.Once
+	stop      chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+}
+
+var rabbit = &rabbitMQ{
+	ready:      make(chan struct{}),
+	publishers: make(chan *amqp.Channel, publisherChannels),
+	stop:       make(chan struct{}),
+	done:       make(chan struct{}),
+}
+
+// ConnectRabbitMQ connects to RabbitMQ and declares the queues, then keeps the
+// connection up: when it closes, or when this first attempt fails, it is
+// retried in the background with backoff and the queues are declared again
+func ConnectRabbitMQ() error {
+	err := rabbit.connect()
+	rabbit.supervise.Do(func() {
+		go rabbit.run()
+	})
+	return err
+}
+
+// CloseRabbitMQ stops reconnecting and closes the connection
+func CloseRabbitMQ() error {
+	rabbit.stopOnce.Do(func() {
+		close(rabbit.stop)
+	})
+	rabbit.supervise.Do(func() {
+		close(rabbit.done)
+	})
+	<-rabbit.done
+
+	rabbit.mu.Lock()
+	conn := rabbit.conn
+	rabbit.conn = nil
+	rabbit.mu.Unlock()
+	rabbit.drainPublishers()
+
+	if conn != nil && !conn.IsClosed() {
+		return conn.Close()
+	}
+	return nil
+}
+
+// RabbitMQConnected reports whether the connection to RabbitMQ is up
+func RabbitMQConnected() bool {
+	return rabbit.connection() != nil
+}
+
+// RabbitMQReady returns a channel that is closed once RabbitMQ is connected.
+// Call it again after the connection is lost to wait for the next one.
+func RabbitMQReady() <-chan struct{} {
+	rabbit.mu.RLock()
+	defer rabbit.mu.RUnlock()
+	return rabbit.ready
+}
+
+// RabbitMQChannel opens a channel of its own on the current connection. The
+// caller closes it; it is not replaced when the connection is lost.
+func RabbitMQChannel() (*amqp.Channel, error) {
+	conn := rabbit.connection()
+	if conn == nil {
+		return nil, ErrRabbitMQUnavailable
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	return ch, nil
+}
+
+// GetRabbitMQStatus returns the state of the connection and how often it was re-established
+func GetRabbitMQStatus() RabbitMQStatus {
+	rabbit.mu.RLock()
+	defer rabbit.mu.RUnlock()
+
+	status := RabbitMQStatus{
+		Connected:      rabbit.conn != nil && !rabbit.conn.IsClosed(),
+		Reconnects:     rabbit.reconnects.Load(),
+		FailedAttempts: rabbit.failedAttempts.Load(),
+		LastError:      rabbit.lastError,
+	}
+	if !rabbit.connectedAt.IsZero() {
+		connectedAt := rabbit.connectedAt
+		status.ConnectedAt = &connectedAt
+	}
+	if !rabbit.disconnectedAt.IsZero() {
+		disconnectedAt := rabbit.disconnectedAt
+		status.DisconnectedAt = &disconnectedAt
+	}
+	return status
+}
+
+// PublishMessage publishes a persistent message to a queue and waits until
+// RabbitMQ confirms it has taken the message. An error means the message may
+// not have been stored and should be published again.
+func PublishMessage(queueName string, message []byte) error {
+	if err := publishConfirmed(queueName, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent, // Make message persistent
+		MessageId:    newMessageID(),
+		Timestamp:    time.Now(),
+		Headers:      amqp.Table{HeaderAttempt: int32(1)},
+		Body:         message,
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("Message published to queue: %s", queueName)
+	return nil
+}
+
+// publishConfirmed publishes msg to queueName on a pooled confirm-mode
+// channel and waits for RabbitMQ's confirm
+func publishConfirmed(queueName string, msg amqp.Publishing) error {
+	ch, err := rabbit.publisher()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishConfirmTimeout)
+	defer cancel()
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(
+		ctx,
+		"",        // exchange
+		queueName, // routing key
+		false,     // mandatory
+		false,     // immediate
+		msg,
+	)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		// The confirm may still arrive; the channel is not reused so it cannot be mistaken for another
+		ch.Close()
+		return fmt.Errorf("no confirm for message to %s: %w", queueName, err)
+	}
+	if !acked {
+		// Pending confirms are negative when the channel closes
+		if ch.IsClosed() {
+			return fmt.Errorf("channel closed before message to %s was confirmed", queueName)
+		}
+		rabbit.releasePublisher(ch)
+		return fmt.Errorf("RabbitMQ rejected message to %s", queueName)
+	}
+	rabbit.releasePublisher(ch)
+	return nil
+}
+
+// connect dials RabbitMQ and declares the queues, unless already connected
+func (m *rabbitMQ) connect() error {
+	m.dialing.Lock()
+	defer m.dialing.Unlock()
+	if m.connection() != nil {
+		return nil
+	}
+
+	conn, err := amqp.Dial(rabbitMQURL())
+	if err != nil {
+		return m.connectFailed(fmt.Errorf("failed to connect to RabbitMQ: %w", err))
+	}
+	if err := DeclareQueues(conn); err != nil {
+		conn.Close()
+		return m.connectFailed(fmt.Errorf("failed to declare queues: %w", err))
+	}
+
+	m.mu.Lock()
+	m.conn = conn
+	m.closed = conn.NotifyClose(make(chan *amqp.Error, 1))
+	m.connectedAt = time.Now()
+	m.lastError = ""
+	if m.everConnected {
+		m.reconnects.Add(1)
+	}
+	m.everConnected = true
+	close(m.ready)
+	m.mu.Unlock()
+
+	log.Println("RabbitMQ connected successfully!")
+	return nil
+}
+
+func (m *rabbitMQ) connectFailed(err error) error {
+	m.failedAttempts.Add(1)
+	m.mu.Lock()
+	m.lastError = err.Error()
+	m.mu.Unlock()
+	return err
+}
+
+// run waits for the connection to close and reconnects with backoff until
+// CloseRabbitMQ is called
+func (m *rabbitMQ) run() {
+	defer close(m.done)
+
+	backoff := rabbitMQRetryMin
+	for {
+		m.mu.RLock()
+		closed := m.closed
+		connected := m.conn != nil
+		m.mu.RUnlock()
+
+		if connected {
+			select {
+			case amqpErr := <-closed:
+				m.disconnected(amqpErr)
+				backoff = rabbitMQRetryMin
+			case <-m.stop:
+				return
+			}
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-m.stop:
+			return
+		}
+		if err := m.connect(); err != nil {
+			backoff *= 2
+			if backoff > rabbitMQRetryMax {
+				backoff = rabbitMQRetryMax
+			}
+			log.Printf("RabbitMQ reconnect failed: %v. Retrying in %s", err, backoff)
+		}
+	}
+}
+
+// disconnected forgets a closed connection and its publisher channels
+func (m *rabbitMQ) disconnected(amqpErr *amqp.Error) {
+	m.mu.Lock()
+	m.conn = nil
+	m.closed = nil
+	m.ready = make(chan struct{})
+	m.disconnectedAt = time.Now()
+	if amqpErr != nil {
+		m.lastError = amqpErr.Error()
+	} else {
+		m.lastError = "connection closed"
+	}
+	cause := m.lastError
+	m.mu.Unlock()
+	m.drainPublishers()
+
+	log.Printf("RabbitMQ connection lost: %s. Reconnecting", cause)
+}
+
+func (m *rabbitMQ) connection() *amqp.Connection {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.conn == nil || m.conn.IsClosed() {
+		return nil
+	}
+	return m.conn
+}
+
+// publisher takes an idle publisher channel from the pool, or opens a new one
+func (m *rabbitMQ) publisher() (*amqp.Channel, error) {
+	for {
+		select {
+		case ch := <-m.publishers:
+			if !ch.IsClosed() {
+				return ch, nil
+			}
+		default:
+			ch, err := RabbitMQChannel()
+			if err != nil {
+				return nil, err
+			}
+			if err := ch.Confirm(false); err != nil {
+				ch.Close()
+				return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+			}
+			return ch, nil
+		}
+	}
+}
+
+// releasePublisher returns a channel to the pool, closing it when the pool is full
+func (m *rabbitMQ) releasePublisher(ch *amqp.Channel) {
+	select {
+	case m.publishers <- ch:
+	default:
+		ch.Close()
+	}
+}
+
+func (m *rabbitMQ) drainPublishers() {
+	for {
+		select {
+		case ch := <-m.publishers:
+			ch.Close()
+		default:
+			return
+		}
+	}
+}
+
+// rabbitMQURL builds the connection URL from the RABBITMQ_* variables
+func rabbitMQURL() string {
+	host := os.Getenv("RABBITMQ_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+
+	port := os.Getenv("RABBITMQ_PORT")
+	if port == "" {
+		port = "5672"
+	}
+
+	user := os.Getenv("RABBITMQ_USER")
+	if user == "" {
+		user = "admin"
+	}
+
+	pass := os.Getenv("RABBITMQ_PASS")
+	if pass == "" {
+		pass = "admin123"
+	}
+
+	vhost := os.Getenv("RABBITMQ_VHOST")
+	if vhost == "" {
+		vhost = "/"
+	}
+
+	return fmt.Sprintf("amqp://%s:%s@%s:%s%s", user, pass, host, port, vhost)
+}
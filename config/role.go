@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AppRole selects what a process runs
+type AppRole string
+
+const (
+	RoleAPI    AppRole = "api"    // HTTP API only
+	RoleWorker AppRole = "worker" // queue consumers, outbox relay and scheduled jobs only
+	RoleBoth   AppRole = "both"   // everything in one process
+)
+
+// AppRoleFromEnv returns the role set in APP_ROLE, RoleBoth when unset
+func AppRoleFromEnv() (AppRole, error) {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv("APP_ROLE")))
+	switch role := AppRole(value); role {
+	case "":
+		return RoleBoth, nil
+	case RoleAPI, RoleWorker, RoleBoth:
+		return role, nil
+	}
+	return "", fmt.Errorf("invalid APP_ROLE %q, must be api, worker or both", value)
+}
+
+// RunsAPI reports whether the process serves the HTTP API
+func (r AppRole) RunsAPI() bool {
+	return r == RoleAPI || r == RoleBoth
+}
+
+// RunsWorkers reports whether the process runs the background workers
+func (r AppRole) RunsWorkers() bool {
+	return r == RoleWorker || r == RoleBoth
+}
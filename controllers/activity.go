@@ -18,7 +18,7 @@ import (
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param user_id query int false "User ID (admin only, defaults to authenticated user)"
+// @Param user_id query int false "User ID (requires activities.read, defaults to authenticated user)"
 // @Param action query string false "Filter by action (create, update, delete)"
 // @Param entity_type query string false "Filter by entity type (user, visa, purchase, payment)"
 // @Param page query int false "Page number (default: 1)" default(1)
@@ -40,10 +40,10 @@ func GetUserActivities(c *gin.Context) {
 		return
 	}
 
-	// Check if user is admin and if user_id query param is provided
+	// Check if user may view others and if user_id query param is provided
 	var targetUserID uint = userID
 	if queryUserID := c.Query("user_id"); queryUserID != "" {
-		// Check if current user is admin
+		// Check if current user holds activities.read
 		var currentUser models.User
 		if err := config.DB.First(&currentUser, userID).Error; err != nil {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
@@ -54,11 +54,11 @@ func GetUserActivities(c *gin.Context) {
 			return
 		}
 
-		if currentUser.Role != models.RoleAdmin {
+		if !utils.HasPermission(c, currentUser, models.PermActivitiesRead) {
 			c.JSON(http.StatusForbidden, models.ErrorResponse(
 				"Access denied",
 				"ACCESS_DENIED",
-				"Only admins can view other users' activities",
+				"Only staff with activities.read can view other users' activities",
 			))
 			return
 		}
@@ -272,7 +272,7 @@ func GetPurchaseActivities(c *gin.Context) {
 		return
 	}
 
-	// Check if user is admin or owner of purchase
+	// Check if user is staff or owner of purchase
 	var currentUser models.User
 	if err := config.DB.First(&currentUser, userID).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
@@ -283,7 +283,7 @@ func GetPurchaseActivities(c *gin.Context) {
 		return
 	}
 
-	if currentUser.Role != models.RoleAdmin && purchase.UserID != userID {
+	if !utils.HasPermission(c, currentUser, models.PermActivitiesRead) && purchase.UserID != userID {
 		c.JSON(http.StatusForbidden, models.ErrorResponse(
 			"Access denied",
 			"ACCESS_DENIED",
@@ -397,7 +397,7 @@ func GetPaymentActivities(c *gin.Context) {
 		return
 	}
 
-	// Check if user is admin or owner of payment
+	// Check if user is staff or owner of payment
 	var currentUser models.User
 	if err := config.DB.First(&currentUser, userID).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
@@ -408,7 +408,7 @@ func GetPaymentActivities(c *gin.Context) {
 		return
 	}
 
-	if currentUser.Role != models.RoleAdmin && payment.UserID != userID {
+	if !utils.HasPermission(c, currentUser, models.PermActivitiesRead) && payment.UserID != userID {
 		c.JSON(http.StatusForbidden, models.ErrorResponse(
 			"Access denied",
 			"ACCESS_DENIED",
@@ -0,0 +1,377 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dateLayout is the date format accepted for applicant and travel dates
+const dateLayout = "2006-01-02"
+
+// CreateApplicantRequest represents request body for creating an applicant
+type CreateApplicantRequest struct {
+	FullName       string `json:"full_name" binding:"required,min=2" example:"Budi Santoso"`
+	PassportNumber string `json:"passport_number" binding:"required,alphanum,min=6,max=20" example:"C1234567"`
+	Nationality    string `json:"nationality" binding:"required" example:"Indonesia"`
+	DateOfBirth    string `json:"date_of_birth" binding:"required,datetime=2006-01-02" example:"1990-05-17"`
+	PassportExpiry string `json:"passport_expiry" binding:"required,datetime=2006-01-02" example:"2030-01-31"`
+}
+
+// UpdateApplicantRequest represents request body for updating an applicant
+type UpdateApplicantRequest struct {
+	FullName       string `json:"full_name" binding:"omitempty,min=2" example:"Budi Santoso"`
+	PassportNumber string `json:"passport_number" binding:"omitempty,alphanum,min=6,max=20" example:"C7654321"`
+	Nationality    string `json:"nationality" example:"Indonesia"`
+	DateOfBirth    string `json:"date_of_birth" binding:"omitempty,datetime=2006-01-02" example:"1990-05-17"`
+	PassportExpiry string `json:"passport_expiry" binding:"omitempty,datetime=2006-01-02" example:"2031-01-31"`
+}
+
+// GetApplicants godoc
+// @Summary Get applicants
+// @Description Get paginated list of traveler profiles owned by the authenticated user
+// @Tags Applicant
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)" default(1)
+// @Param per_page query int false "Items per page (default: 10, max: 100)" default(10)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /applicants [get]
+func GetApplicants(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			"User not authenticated",
+			"UNAUTHORIZED",
+			"Please login to view applicants",
+		))
+		return
+	}
+
+	// Get pagination parameters
+	page := c.DefaultQuery("page", "1")
+	perPage := c.DefaultQuery("per_page", "10")
+
+	pageInt, _ := strconv.Atoi(page)
+	perPageInt, _ := strconv.Atoi(perPage)
+	if pageInt < 1 {
+		pageInt = 1
+	}
+	if perPageInt < 1 || perPageInt > 100 {
+		perPageInt = 10
+	}
+
+	var total int64
+	config.DB.Model(&models.Applicant{}).Where("user_id = ?", userID).Count(&total)
+
+	var applicants []models.Applicant
+	offset := (pageInt - 1) * perPageInt
+	if err := config.DB.Where("user_id = ?", userID).
+		Order("full_name ASC").
+		Offset(offset).Limit(perPageInt).
+		Find(&applicants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch applicants",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Applicants retrieved successfully",
+		applicants,
+		pageInt,
+		perPageInt,
+		int(total),
+	))
+}
+
+// GetApplicantByID godoc
+// @Summary Get applicant by ID
+// @Description Get a traveler profile belonging to the authenticated user
+// @Tags Applicant
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Applicant ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /applicants/{id} [get]
+func GetApplicantByID(c *gin.Context) {
+	applicant, ok := findOwnedApplicant(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Applicant retrieved successfully",
+		applicant,
+	))
+}
+
+// CreateApplicant godoc
+// @Summary Create applicant
+// @Description Create a traveler profile that can be attached to visa purchases. Dates use YYYY-MM-DD.
+// @Tags Applicant
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateApplicantRequest true "Applicant data"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /applicants [post]
+func CreateApplicant(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			"User not authenticated",
+			"UNAUTHORIZED",
+			"Please login to create applicants",
+		))
+		return
+	}
+
+	var req CreateApplicantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// Dates are already validated by the binding tags
+	dateOfBirth, _ := time.Parse(dateLayout, req.DateOfBirth)
+	passportExpiry, _ := time.Parse(dateLayout, req.PassportExpiry)
+
+	applicant := models.Applicant{
+		UserID:         userID.(uint),
+		FullName:       req.FullName,
+		PassportNumber: strings.ToUpper(req.PassportNumber),
+		Nationality:    req.Nationality,
+		DateOfBirth:    dateOfBirth,
+		PassportExpiry: passportExpiry,
+	}
+
+	if details := validateApplicantDates(applicant); details != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid applicant data",
+			"VALIDATION_ERROR",
+			details,
+		))
+		return
+	}
+
+	if err := config.DB.Create(&applicant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create applicant",
+			"APPLICANT_CREATION_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	logUserID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogCreate(c, logUserID, models.EntityApplicant, applicant.ID, applicant.FullName, applicant)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Applicant created successfully",
+		applicant,
+	))
+}
+
+// UpdateApplicant godoc
+// @Summary Update applicant
+// @Description Update a traveler profile belonging to the authenticated user. All fields are optional.
+// @Tags Applicant
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Applicant ID"
+// @Param request body UpdateApplicantRequest true "Applicant update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /applicants/{id} [put]
+func UpdateApplicant(c *gin.Context) {
+	applicant, ok := findOwnedApplicant(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateApplicantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// Store old values for logging
+	oldValues := map[string]interface{}{
+		"full_name":       applicant.FullName,
+		"passport_number": applicant.PassportNumber,
+		"nationality":     applicant.Nationality,
+		"date_of_birth":   applicant.DateOfBirth.Format(dateLayout),
+		"passport_expiry": applicant.PassportExpiry.Format(dateLayout),
+	}
+
+	// Update fields if provided
+	if req.FullName != "" {
+		applicant.FullName = req.FullName
+	}
+	if req.PassportNumber != "" {
+		applicant.PassportNumber = strings.ToUpper(req.PassportNumber)
+	}
+	if req.Nationality != "" {
+		applicant.Nationality = req.Nationality
+	}
+	if req.DateOfBirth != "" {
+		applicant.DateOfBirth, _ = time.Parse(dateLayout, req.DateOfBirth)
+	}
+	if req.PassportExpiry != "" {
+		applicant.PassportExpiry, _ = time.Parse(dateLayout, req.PassportExpiry)
+	}
+
+	if details := validateApplicantDates(applicant); details != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid applicant data",
+			"VALIDATION_ERROR",
+			details,
+		))
+		return
+	}
+
+	if err := config.DB.Save(&applicant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to update applicant",
+			"APPLICANT_UPDATE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	newValues := map[string]interface{}{
+		"full_name":       applicant.FullName,
+		"passport_number": applicant.PassportNumber,
+		"nationality":     applicant.Nationality,
+		"date_of_birth":   applicant.DateOfBirth.Format(dateLayout),
+		"passport_expiry": applicant.PassportExpiry.Format(dateLayout),
+	}
+
+	// Log activity
+	logUserID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogUpdate(c, logUserID, models.EntityApplicant, applicant.ID, applicant.FullName, oldValues, newValues)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Applicant updated successfully",
+		applicant,
+	))
+}
+
+// DeleteApplicant godoc
+// @Summary Delete applicant
+// @Description Delete a traveler profile belonging to the authenticated user. Uses soft delete, so existing purchases keep their applicant details.
+// @Tags Applicant
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Applicant ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /applicants/{id} [delete]
+func DeleteApplicant(c *gin.Context) {
+	applicant, ok := findOwnedApplicant(c)
+	if !ok {
+		return
+	}
+
+	// Log activity before delete
+	logUserID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogDelete(c, logUserID, models.EntityApplicant, applicant.ID, applicant.FullName, applicant)
+
+	if err := config.DB.Delete(&applicant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to delete applicant",
+			"APPLICANT_DELETE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Applicant deleted successfully",
+		nil,
+	))
+}
+
+// findOwnedApplicant loads the applicant from the :id path parameter if it
+// belongs to the authenticated user, writing the error response otherwise
+func findOwnedApplicant(c *gin.Context) (models.Applicant, bool) {
+	var applicant models.Applicant
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			"User not authenticated",
+			"UNAUTHORIZED",
+			"Please login to manage applicants",
+		))
+		return applicant, false
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid applicant ID",
+			"INVALID_ID",
+			"Applicant ID must be a valid number",
+		))
+		return applicant, false
+	}
+
+	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&applicant).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Applicant not found",
+			"APPLICANT_NOT_FOUND",
+			"Applicant with this ID does not exist or does not belong to you",
+		))
+		return applicant, false
+	}
+
+	return applicant, true
+}
+
+// validateApplicantDates returns a description of the first date problem, or "" if valid
+func validateApplicantDates(applicant models.Applicant) string {
+	if !applicant.DateOfBirth.Before(time.Now()) {
+		return "date_of_birth must be in the past"
+	}
+	if !applicant.PassportExpiry.After(applicant.DateOfBirth) {
+		return "passport_expiry must be after date_of_birth"
+	}
+	return ""
+}
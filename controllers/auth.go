@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,6 +12,8 @@ import (
 	"strings"
 	"time"
 	"viskatera-api-go/config"
+	"viskatera-api-go/i18n"
+	"viskatera-api-go/mailer"
 	"viskatera-api-go/models"
 	"viskatera-api-go/utils"
 
@@ -20,7 +23,7 @@ import (
 
 // Register godoc
 // @Summary Register a new user
-// @Description Register a new user with email, password, name and optional role
+// @Description Register a new customer account with email, password and name
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -63,18 +66,16 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Set default role
-	role := models.RoleCustomer
-	if req.Role == "admin" {
-		role = models.RoleAdmin
-	}
-
-	// Create user
+	// Self-registration always creates customers; staff join through invitations
 	user := models.User{
 		Email:    req.Email,
 		Password: hashedPassword,
 		Name:     req.Name,
-		Role:     role,
+		Role:     models.RoleCustomer,
+		Language: string(utils.RequestLanguage(c)),
+	}
+	if req.Language != "" {
+		user.Language = req.Language
 	}
 
 	if err := config.DB.Create(&user).Error; err != nil {
@@ -159,8 +160,8 @@ func Login(c *gin.Context) {
 		log.Printf("Failed to update last login time: %v", err)
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID)
+	// Start a session with an access/refresh token pair
+	tokens, err := utils.IssueSession(c, user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
 			"Failed to generate token",
@@ -179,8 +180,11 @@ func Login(c *gin.Context) {
 	}
 
 	responseData := gin.H{
-		"token": token,
-		"user":  userData,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          userData,
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse(
@@ -189,6 +193,118 @@ func Login(c *gin.Context) {
 	))
 }
 
+// RefreshToken godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access/refresh token pair. Refresh tokens are single-use; presenting one that was already used revokes the whole session.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	tokens, _, err := utils.RotateRefreshToken(c, req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+				"Refresh token reuse detected",
+				"REFRESH_TOKEN_REUSED",
+				"This session has been revoked, please login again",
+			))
+		case errors.Is(err, utils.ErrRefreshTokenInvalid):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+				"Invalid refresh token",
+				"INVALID_REFRESH_TOKEN",
+				"Refresh token is invalid, expired or revoked",
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+				"Failed to refresh token",
+				"TOKEN_GENERATION_ERROR",
+				"Please try again later",
+			))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Token refreshed successfully",
+		tokens,
+	))
+}
+
+// Logout godoc
+// @Summary Logout current session
+// @Description Revoke the session of the access token used for this request, including its refresh token
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse("Unauthorized", "UNAUTHORIZED", ""))
+		return
+	}
+
+	if err := utils.RevokeSession(c.Request.Context(), sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to logout",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse("Logged out successfully", nil))
+}
+
+// LogoutAll godoc
+// @Summary Logout all sessions
+// @Description Revoke every session of the current user on all devices
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/logout-all [post]
+func LogoutAll(c *gin.Context) {
+	userID, exists := utils.GetUserIDFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse("Unauthorized", "UNAUTHORIZED", ""))
+		return
+	}
+
+	if err := utils.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to logout",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse("Logged out from all sessions successfully", nil))
+}
+
 // ForgotPassword godoc
 // @Summary Request password reset
 // @Description Sends password reset link to user's email
@@ -230,29 +346,11 @@ func ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	resetURL := fmt.Sprintf("%s/reset-password?token=%s", os.Getenv("APP_BASE_URL"), token)
-	emailSubject := "Password Reset Request"
-	emailBody := fmt.Sprintf(`
-		<html>
-			<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
-				<div style="max-width: 600px; margin: 0 auto; padding: 20px;">
-					<h2 style="color: #4CAF50;">Password Reset Request</h2>
-					<p>Hello,</p>
-					<p>You have requested to reset your password. Click the link below to reset it:</p>
-					<div style="text-align: center; margin: 30px 0;">
-						<a href="%s" style="background-color: #4CAF50; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; display: inline-block;">Reset Password</a>
-					</div>
-					<p>Or copy and paste this link into your browser:</p>
-					<p style="word-break: break-all; color: #666;">%s</p>
-					<p>This link will expire in 30 minutes.</p>
-					<p>If you didn't request a password reset, please ignore this email.</p>
-					<hr style="border: none; border-top: 1px solid #eee; margin: 20px 0;">
-					<p style="color: #666; font-size: 12px;">This is an automated email, please do not reply.</p>
-				</div>
-			</body>
-		</html>
-	`, resetURL, resetURL)
-	_ = utils.SendEmail(user.Email, emailSubject, emailBody)
+	lang := i18n.Of(user.Language)
+	_ = mailer.SendTemplate(c.Request.Context(), user.Email, lang, mailer.TemplatePasswordReset, mailer.PasswordResetEmail{
+		ResetURL:  fmt.Sprintf("%s/reset-password?token=%s", os.Getenv("APP_BASE_URL"), token),
+		ExpiresIn: i18n.T(lang, "duration.minutes", 30),
+	})
 
 	c.JSON(http.StatusOK, models.SuccessResponse("If the email exists, a reset link has been sent", nil))
 }
@@ -306,6 +404,11 @@ func ResetPassword(c *gin.Context) {
 	prt.Used = true
 	config.DB.Save(&prt)
 
+	// Sessions opened with the old password must not outlive it
+	if err := utils.RevokeAllSessions(c.Request.Context(), user.ID); err != nil {
+		log.Printf("Failed to revoke sessions after password reset for user %d: %v", user.ID, err)
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse("Password updated successfully", nil))
 }
 
@@ -348,6 +451,9 @@ func UpdateUser(c *gin.Context) {
 	if req.Email != "" {
 		user.Email = req.Email
 	}
+	if req.Language != "" {
+		user.Language = req.Language
+	}
 
 	if req.NewPassword != "" {
 		// require current password if user has a password (not Google-only)
@@ -369,7 +475,10 @@ func UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse("Failed to update user", "DATABASE_ERROR", ""))
 		return
 	}
-	c.JSON(http.StatusOK, models.SuccessResponse("User updated successfully", gin.H{"user": gin.H{"id": user.ID, "email": user.Email, "name": user.Name, "avatar_url": user.AvatarURL}}))
+	if req.Language != "" {
+		utils.ForgetUserLanguage(c.Request.Context(), user.ID)
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse("User updated successfully", gin.H{"user": gin.H{"id": user.ID, "email": user.Email, "name": user.Name, "avatar_url": user.AvatarURL, "language": user.Language}}))
 }
 
 // GoogleLogin godoc
@@ -423,7 +532,7 @@ func GoogleCallback(c *gin.Context) {
 	// Upsert user by google id or email
 	var user models.User
 	if err := config.DB.Where("google_id = ? OR email = ?", info.ID, info.Email).First(&user).Error; err != nil {
-		user = models.User{Email: info.Email, Name: info.Name, GoogleID: info.ID, Password: utils.MustHashPlaceholder()}
+		user = models.User{Email: info.Email, Name: info.Name, GoogleID: info.ID, Password: utils.MustHashPlaceholder(), Language: string(utils.RequestLanguage(c))}
 		_ = config.DB.Create(&user).Error
 	} else {
 		if user.GoogleID == "" {
@@ -440,7 +549,7 @@ func GoogleCallback(c *gin.Context) {
 		log.Printf("Failed to update last login time: %v", err)
 	}
 
-	jwt, err := utils.GenerateJWT(user.ID)
+	tokens, err := utils.IssueSession(c, user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse("Failed to generate token", "TOKEN_ERROR", ""))
 		return
@@ -455,8 +564,11 @@ func GoogleCallback(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse("Login successful", gin.H{
-		"token": jwt,
-		"user":  userData,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          userData,
 	}))
 }
 
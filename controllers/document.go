@@ -0,0 +1,580 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewDocumentRequest represents request body for approving or rejecting a document
+type ReviewDocumentRequest struct {
+	Status string `json:"status" binding:"required,oneof=approved rejected" example:"rejected"`
+	Reason string `json:"reason" binding:"required_if=Status rejected" example:"Passport scan is blurry"`
+}
+
+// UploadPurchaseDocument godoc
+// @Summary Upload application document
+// @Description Upload a document (passport scan, photo, bank statement, ...) for an entry of the purchase's document checklist. applicant_id is required for per-applicant requirements. Uploading again replaces a pending or rejected document.
+// @Tags Document
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Param requirement_id formData int true "Requirement ID"
+// @Param applicant_id formData int false "Applicant ID"
+// @Param file formData file true "Document file"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchases/{id}/documents [post]
+func UploadPurchaseDocument(c *gin.Context) {
+	purchase, ok := findOwnedPurchase(c)
+	if !ok {
+		return
+	}
+
+	if !documentsEditable(purchase.Status) {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Documents can no longer be changed",
+			"INVALID_PURCHASE_STATUS",
+			"Documents can only be uploaded before the application is submitted to the embassy",
+		))
+		return
+	}
+
+	requirementID, err := strconv.Atoi(c.PostForm("requirement_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid requirement ID",
+			"INVALID_ID",
+			"requirement_id must be a valid number",
+		))
+		return
+	}
+
+	var requirement models.VisaDocumentRequirement
+	if err := config.DB.Where("id = ? AND visa_id = ?", requirementID, purchase.VisaID).First(&requirement).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Requirement not found",
+			"REQUIREMENT_NOT_FOUND",
+			"Requirement with this ID does not exist for this visa",
+		))
+		return
+	}
+
+	// Per-applicant requirements need to know whose document this is
+	var applicantID *uint
+	if requirement.PerApplicant && len(purchase.Applicants) > 0 {
+		id, err := strconv.Atoi(c.PostForm("applicant_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Applicant ID required",
+				"VALIDATION_ERROR",
+				"applicant_id is required for this requirement",
+			))
+			return
+		}
+		for _, applicant := range purchase.Applicants {
+			if applicant.ApplicantID == uint(id) {
+				applicantID = &applicant.ApplicantID
+				break
+			}
+		}
+		if applicantID == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"Applicant not found",
+				"APPLICANT_NOT_FOUND",
+				"Applicant is not part of this purchase",
+			))
+			return
+		}
+	}
+
+	// Get file from form
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid file upload",
+			"FILE_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// Validate file size
+	maxSize := getEnvAsInt("MAX_UPLOAD_SIZE", 10485760) // 10MB default
+	if file.Size > int64(maxSize) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"File too large",
+			"FILE_TOO_LARGE",
+			fmt.Sprintf("File size must be less than %d bytes", maxSize),
+		))
+		return
+	}
+
+	// Validate file extension
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	allowedExts := []string{".pdf", ".jpg", ".jpeg", ".png"}
+	isAllowed := false
+	for _, allowedExt := range allowedExts {
+		if ext == allowedExt {
+			isAllowed = true
+			break
+		}
+	}
+
+	if !isAllowed {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid file type",
+			"INVALID_FILE_TYPE",
+			"Allowed file types: .pdf, .jpg, .jpeg, .png",
+		))
+		return
+	}
+
+	// An approved document is final; pending or rejected ones are replaced
+	var existing models.PurchaseDocument
+	query := config.DB.Where("purchase_id = ? AND requirement_id = ?", purchase.ID, requirement.ID)
+	if applicantID != nil {
+		query = query.Where("applicant_id = ?", *applicantID)
+	} else {
+		query = query.Where("applicant_id IS NULL")
+	}
+	hasExisting := query.First(&existing).Error == nil
+	if hasExisting && existing.Status == models.DocumentStatusApproved {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Document already approved",
+			"DOCUMENT_ALREADY_APPROVED",
+			"An approved document cannot be replaced",
+		))
+		return
+	}
+
+	// Documents are kept outside the public uploads directory
+	docDir := filepath.Join(getEnv("DOCUMENT_DIR", "./storage/documents"), fmt.Sprintf("purchase_%d", purchase.ID))
+	if err := os.MkdirAll(docDir, 0750); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create upload directory",
+			"DIRECTORY_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// Generate unique filename
+	filename := fmt.Sprintf("req_%d_%d%s", requirement.ID, time.Now().UnixNano(), ext)
+	if applicantID != nil {
+		filename = fmt.Sprintf("req_%d_applicant_%d_%d%s", requirement.ID, *applicantID, time.Now().UnixNano(), ext)
+	}
+	tempPath := filepath.Join(docDir, "temp_"+filename)
+	finalPath := filepath.Join(docDir, filename)
+
+	// Save file temporarily
+	if err := c.SaveUploadedFile(file, tempPath); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to save file",
+			"SAVE_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	if ext == ".pdf" {
+		os.Rename(tempPath, finalPath)
+	} else {
+		// Compress the image
+		if err := utils.CompressAndSave(tempPath, finalPath, utils.DefaultVisaDocConfig()); err != nil {
+			// If compression fails, use the original file
+			os.Rename(tempPath, finalPath)
+		} else {
+			// Remove temp file after successful compression
+			os.Remove(tempPath)
+		}
+	}
+
+	document := models.PurchaseDocument{
+		PurchaseID:    purchase.ID,
+		RequirementID: requirement.ID,
+		ApplicantID:   applicantID,
+		FilePath:      finalPath,
+		FileName:      filepath.Base(file.Filename),
+		FileSize:      file.Size,
+		Status:        models.DocumentStatusPending,
+	}
+
+	if err := config.DB.Create(&document).Error; err != nil {
+		os.Remove(finalPath)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to save document",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Replace the previous upload for this checklist slot
+	if hasExisting {
+		config.DB.Delete(&existing)
+		os.Remove(existing.FilePath)
+	}
+
+	document.Requirement = requirement
+
+	// Log activity
+	logUserID := utils.GetUserIDFromContextWithDefault(c)
+	entityName := fmt.Sprintf("Purchase #%d - %s", purchase.ID, requirement.Name)
+	utils.LogCreate(c, logUserID, models.EntityDocument, document.ID, entityName, document)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Document uploaded successfully",
+		document,
+	))
+}
+
+// GetPurchaseDocuments godoc
+// @Summary Get application document checklist
+// @Description Get the document checklist of a purchase belonging to the authenticated user, with the upload and review status of each entry
+// @Tags Document
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchases/{id}/documents [get]
+func GetPurchaseDocuments(c *gin.Context) {
+	purchase, ok := findOwnedPurchase(c)
+	if !ok {
+		return
+	}
+
+	respondDocumentChecklist(c, purchase)
+}
+
+// DownloadPurchaseDocument godoc
+// @Summary Download application document
+// @Description Download a document uploaded for a purchase belonging to the authenticated user
+// @Tags Document
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Param document_id path int true "Document ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /purchases/{id}/documents/{document_id}/file [get]
+func DownloadPurchaseDocument(c *gin.Context) {
+	purchase, ok := findOwnedPurchase(c)
+	if !ok {
+		return
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid document ID",
+			"INVALID_ID",
+			"Document ID must be a valid number",
+		))
+		return
+	}
+
+	var document models.PurchaseDocument
+	if err := config.DB.Where("id = ? AND purchase_id = ?", documentID, purchase.ID).First(&document).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Document not found",
+			"DOCUMENT_NOT_FOUND",
+			"Document with this ID does not exist for this purchase",
+		))
+		return
+	}
+
+	serveDocumentFile(c, document)
+}
+
+// AdminGetPurchaseDocuments godoc
+// @Summary Get application document checklist (admin)
+// @Description Get the document checklist of any purchase with the upload and review status of each entry (admin only)
+// @Tags Document
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/purchases/{id}/documents [get]
+func AdminGetPurchaseDocuments(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid purchase ID",
+			"INVALID_ID",
+			"Purchase ID must be a valid number",
+		))
+		return
+	}
+
+	var purchase models.VisaPurchase
+	if err := config.DB.First(&purchase, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Purchase not found",
+			"PURCHASE_NOT_FOUND",
+			"Purchase with this ID does not exist",
+		))
+		return
+	}
+
+	respondDocumentChecklist(c, purchase)
+}
+
+// AdminDownloadPurchaseDocument godoc
+// @Summary Download application document (admin)
+// @Description Download any uploaded application document (admin only)
+// @Tags Document
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param id path int true "Document ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/documents/{id}/file [get]
+func AdminDownloadPurchaseDocument(c *gin.Context) {
+	document, ok := findDocument(c)
+	if !ok {
+		return
+	}
+
+	serveDocumentFile(c, document)
+}
+
+// ReviewPurchaseDocument godoc
+// @Summary Review application document
+// @Description Approve or reject an uploaded document (admin only). A reason is required when rejecting.
+// @Tags Document
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Document ID"
+// @Param request body ReviewDocumentRequest true "Review decision"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/documents/{id}/review [put]
+func ReviewPurchaseDocument(c *gin.Context) {
+	document, ok := findDocument(c)
+	if !ok {
+		return
+	}
+
+	var req ReviewDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	var purchase models.VisaPurchase
+	if err := config.DB.First(&purchase, document.PurchaseID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Purchase not found",
+			"PURCHASE_NOT_FOUND",
+			"Purchase for this document does not exist",
+		))
+		return
+	}
+
+	if !documentsEditable(purchase.Status) {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Documents can no longer be changed",
+			"INVALID_PURCHASE_STATUS",
+			"Documents can only be reviewed before the application is submitted to the embassy",
+		))
+		return
+	}
+
+	oldValues := map[string]interface{}{
+		"status":           document.Status,
+		"rejection_reason": document.RejectionReason,
+	}
+
+	reviewerID := utils.GetUserIDFromContextWithDefault(c)
+	now := time.Now()
+	document.Status = models.DocumentStatus(req.Status)
+	document.RejectionReason = ""
+	if document.Status == models.DocumentStatusRejected {
+		document.RejectionReason = req.Reason
+	}
+	document.ReviewedByID = &reviewerID
+	document.ReviewedAt = &now
+
+	if err := config.DB.Save(&document).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to review document",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	newValues := map[string]interface{}{
+		"status":           document.Status,
+		"rejection_reason": document.RejectionReason,
+	}
+
+	// Log activity
+	entityName := fmt.Sprintf("Purchase #%d - %s", purchase.ID, document.Requirement.Name)
+	utils.LogUpdate(c, reviewerID, models.EntityDocument, document.ID, entityName, oldValues, newValues)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Document reviewed successfully",
+		document,
+	))
+}
+
+// findOwnedPurchase loads the purchase from the :id path parameter with its
+// applicants if it belongs to the authenticated user, writing the error response otherwise
+func findOwnedPurchase(c *gin.Context) (models.VisaPurchase, bool) {
+	var purchase models.VisaPurchase
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			"User not authenticated",
+			"UNAUTHORIZED",
+			"Please login to access purchase",
+		))
+		return purchase, false
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid purchase ID",
+			"INVALID_ID",
+			"Purchase ID must be a valid number",
+		))
+		return purchase, false
+	}
+
+	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).
+		Preload("Applicants").
+		First(&purchase).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Purchase not found",
+			"PURCHASE_NOT_FOUND",
+			"Purchase with this ID does not exist or does not belong to you",
+		))
+		return purchase, false
+	}
+
+	return purchase, true
+}
+
+// findDocument loads the document from the :id path parameter, writing the error response otherwise
+func findDocument(c *gin.Context) (models.PurchaseDocument, bool) {
+	var document models.PurchaseDocument
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid document ID",
+			"INVALID_ID",
+			"Document ID must be a valid number",
+		))
+		return document, false
+	}
+
+	if err := config.DB.Preload("Requirement", utils.Unscoped).First(&document, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Document not found",
+			"DOCUMENT_NOT_FOUND",
+			"Document with this ID does not exist",
+		))
+		return document, false
+	}
+
+	return document, true
+}
+
+// respondDocumentChecklist writes the checklist of a purchase and whether it is complete
+func respondDocumentChecklist(c *gin.Context, purchase models.VisaPurchase) {
+	items, err := utils.BuildDocumentChecklist(config.DB, purchase)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch documents",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	complete := true
+	for _, item := range items {
+		if item.Requirement.IsRequired && !item.Satisfied {
+			complete = false
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Documents retrieved successfully",
+		gin.H{
+			"purchase_id": purchase.ID,
+			"status":      purchase.Status,
+			"complete":    complete,
+			"checklist":   items,
+		},
+	))
+}
+
+// serveDocumentFile streams a stored document as an attachment
+func serveDocumentFile(c *gin.Context, document models.PurchaseDocument) {
+	if _, err := os.Stat(document.FilePath); err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"File not found",
+			"FILE_NOT_FOUND",
+			"The requested file does not exist",
+		))
+		return
+	}
+
+	c.FileAttachment(document.FilePath, document.FileName)
+}
+
+// documentsEditable reports whether documents may still be uploaded or reviewed
+func documentsEditable(status models.PurchaseStatus) bool {
+	switch status {
+	case models.PurchaseStatusDraft, models.PurchaseStatusSubmitted, models.PurchaseStatusDocumentsReview:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEmailLogs godoc
+// @Summary List sent emails
+// @Description List every email handed to the mail transport, newest first, with its status and the provider's message ID. Retried emails appear once per attempt. Bodies are not stored.
+// @Tags Monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (sent or failed)"
+// @Param template query string false "Filter by template, e.g. invoice or otp"
+// @Param recipient query string false "Filter by recipient address (partial match)"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/email-logs [get]
+func GetEmailLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := config.DB.Model(&models.EmailLog{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if template := c.Query("template"); template != "" {
+		query = query.Where("template = ?", template)
+	}
+	if recipient := c.Query("recipient"); recipient != "" {
+		query = query.Where("LOWER(recipient) LIKE ?", "%"+strings.ToLower(recipient)+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var logs []models.EmailLog
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch email logs",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Email logs retrieved successfully",
+		logs,
+		page,
+		perPage,
+		int(total),
+	))
+}
+
+// GetEmailLog godoc
+// @Summary Get sent email
+// @Description Get one email log entry, including the error of a failed attempt
+// @Tags Monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Email log ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/email-logs/{id} [get]
+func GetEmailLog(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid email log ID",
+			"INVALID_ID",
+			"Email log ID must be a valid number",
+		))
+		return
+	}
+
+	var entry models.EmailLog
+	if err := config.DB.First(&entry, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Email log not found",
+			"EMAIL_LOG_NOT_FOUND",
+			"Email log with the given ID does not exist",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Email log retrieved successfully",
+		entry,
+	))
+}
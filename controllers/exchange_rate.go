@@ -0,0 +1,330 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetExchangeRates godoc
+// @Summary List exchange rates
+// @Description List exchange rates, newest first. Each rate applies from effective_at until a newer rate for the same pair takes effect.
+// @Tags Exchange Rates
+// @Produce json
+// @Security BearerAuth
+// @Param base query string false "Filter by base currency"
+// @Param quote query string false "Filter by quote currency"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/exchange-rates [get]
+func GetExchangeRates(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := config.DB.Model(&models.ExchangeRate{})
+	if base := c.Query("base"); base != "" {
+		query = query.Where("base_currency = ?", strings.ToUpper(base))
+	}
+	if quote := c.Query("quote"); quote != "" {
+		query = query.Where("quote_currency = ?", strings.ToUpper(quote))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var rates []models.ExchangeRate
+	if err := query.Order("effective_at DESC, id DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch exchange rates",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Exchange rates retrieved successfully",
+		rates,
+		page,
+		perPage,
+		int(total),
+	))
+}
+
+// CreateExchangeRate godoc
+// @Summary Add exchange rate
+// @Description Add a rate for a currency pair. Rate is the number of quote currency units per base currency unit (1 USD = 15750 IDR). New purchases use the newest rate already in effect.
+// @Tags Exchange Rates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ExchangeRateRequest true "Exchange rate"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/exchange-rates [post]
+func CreateExchangeRate(c *gin.Context) {
+	var req models.ExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	base, ok := parseCurrency(c, req.BaseCurrency, "")
+	if !ok {
+		return
+	}
+	quote, ok := parseCurrency(c, req.QuoteCurrency, "")
+	if !ok {
+		return
+	}
+	if base == quote {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid currency pair",
+			"VALIDATION_ERROR",
+			"Base and quote currency must differ",
+		))
+		return
+	}
+
+	effectiveAt, err := utils.ParseEffectiveAt(req.EffectiveAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid effective_at",
+			"VALIDATION_ERROR",
+			"effective_at must be RFC 3339 or YYYY-MM-DD",
+		))
+		return
+	}
+
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	rate := models.ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          req.Rate,
+		EffectiveAt:   effectiveAt,
+		Source:        "manual",
+		CreatedByID:   &userID,
+	}
+	if err := config.DB.Create(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create exchange rate",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	utils.LogCreate(c, userID, models.EntityExchangeRate, rate.ID, exchangeRateName(rate), rate)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Exchange rate created successfully",
+		rate,
+	))
+}
+
+// ImportExchangeRates godoc
+// @Summary Import exchange rates
+// @Description Import rates from a CSV file with the columns base_currency, quote_currency, rate and optionally effective_at (RFC 3339 or YYYY-MM-DD, defaults to now). The file is imported completely or not at all.
+// @Tags Exchange Rates
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "Exchange rates (CSV)"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/exchange-rates/import [post]
+func ImportExchangeRates(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid file upload",
+			"FILE_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	maxSize := getEnvAsInt("MAX_UPLOAD_SIZE", 10485760) // 10MB default
+	if file.Size > int64(maxSize) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"File too large",
+			"FILE_TOO_LARGE",
+			fmt.Sprintf("File size must be less than %d bytes", maxSize),
+		))
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to read file",
+			"FILE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+	defer src.Close()
+
+	rates, err := utils.ParseExchangeRateCSV(src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid exchange rate file",
+			"INVALID_EXCHANGE_RATE_FILE",
+			err.Error(),
+		))
+		return
+	}
+
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	source := filepath.Base(file.Filename)
+	for i := range rates {
+		rates[i].Source = source
+		rates[i].CreatedByID = &userID
+	}
+	if err := config.DB.CreateInBatches(&rates, 200).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to import exchange rates",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	for _, rate := range rates {
+		utils.LogCreate(c, userID, models.EntityExchangeRate, rate.ID, exchangeRateName(rate), rate)
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		fmt.Sprintf("%d exchange rates imported successfully", len(rates)),
+		rates,
+	))
+}
+
+// DeleteExchangeRate godoc
+// @Summary Delete exchange rate
+// @Description Delete a mistaken exchange rate. Rates already used by a purchase cannot be deleted; add a newer rate instead.
+// @Tags Exchange Rates
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Exchange rate ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/exchange-rates/{id} [delete]
+func DeleteExchangeRate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid exchange rate ID",
+			"INVALID_ID",
+			"Exchange rate ID must be a valid number",
+		))
+		return
+	}
+
+	var rate models.ExchangeRate
+	if err := config.DB.First(&rate, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"Exchange rate not found",
+				"EXCHANGE_RATE_NOT_FOUND",
+				"Exchange rate with this ID does not exist",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Database error",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	var used int64
+	config.DB.Unscoped().Model(&models.VisaPurchase{}).Where("exchange_rate_id = ?", rate.ID).Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Exchange rate in use",
+			"EXCHANGE_RATE_IN_USE",
+			"Purchases were charged at this rate; add a newer rate instead",
+		))
+		return
+	}
+
+	if err := config.DB.Delete(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to delete exchange rate",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogDelete(c, userID, models.EntityExchangeRate, rate.ID, exchangeRateName(rate), rate)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Exchange rate deleted successfully",
+		nil,
+	))
+}
+
+// parseCurrency validates an ISO currency code, using fallback when it is empty
+func parseCurrency(c *gin.Context, code, fallback string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = fallback
+	}
+	if !models.IsSupportedCurrency(code) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Unsupported currency",
+			"INVALID_CURRENCY",
+			"Unknown or unsupported currency code: "+code,
+		))
+		return "", false
+	}
+	return code, true
+}
+
+func exchangeRateName(rate models.ExchangeRate) string {
+	return fmt.Sprintf("%s/%s %s", rate.BaseCurrency, rate.QuoteCurrency, strconv.FormatFloat(rate.Rate, 'f', -1, 64))
+}
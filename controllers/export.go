@@ -3,9 +3,11 @@ package controllers
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 	"viskatera-api-go/config"
 	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jung-kurt/gofpdf"
@@ -29,7 +31,8 @@ func ExportVisasExcel(c *gin.Context) {
 	query := config.DB.
 		Preload("User", "role = ?", "customer").
 		Preload("Visa").
-		Preload("VisaOption").
+		Preload("VisaOption", utils.Unscoped).
+		Preload("Applicants").
 		Joins("JOIN users ON visa_purchases.user_id = users.id").
 		Where("users.role = ?", "customer")
 
@@ -65,7 +68,7 @@ func ExportVisasExcel(c *gin.Context) {
 	f.SetActiveSheet(index)
 
 	// Set headers
-	headers := []string{"ID", "User Name", "User Email", "Country", "Visa Type", "Description", "Price", "Option", "Total Price", "Status", "Created At"}
+	headers := []string{"ID", "User Name", "User Email", "Country", "Visa Type", "Description", "Price", "Option", "Applicants", "Original Total", "Original Currency", "Exchange Rate", "Charged Total", "Charged Currency", "Status", "Created At"}
 	for i, header := range headers {
 		cell := fmt.Sprintf("%c1", 'A'+i)
 		f.SetCellValue(sheetName, cell, header)
@@ -81,7 +84,7 @@ func ExportVisasExcel(c *gin.Context) {
 		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), purchase.Visa.Country)
 		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), purchase.Visa.Type)
 		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), purchase.Visa.Description)
-		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), purchase.Visa.Price)
+		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), models.FormatAmount(purchase.Visa.Price, purchase.Visa.Currency))
 
 		optionName := ""
 		if purchase.VisaOption != nil {
@@ -89,9 +92,10 @@ func ExportVisasExcel(c *gin.Context) {
 		}
 		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), optionName)
 
-		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), purchase.TotalPrice)
-		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), purchase.Status)
-		f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), purchase.CreatedAt.Format("2006-01-02 15:04:05"))
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), applicantNames(purchase))
+		setAmountCells(f, sheetName, "J", row, purchase)
+		f.SetCellValue(sheetName, fmt.Sprintf("O%d", row), purchase.Status)
+		f.SetCellValue(sheetName, fmt.Sprintf("P%d", row), purchase.CreatedAt.Format("2006-01-02 15:04:05"))
 	}
 
 	// Set column widths
@@ -132,7 +136,9 @@ func ExportPurchasesExcel(c *gin.Context) {
 	query := config.DB.
 		Preload("User", "role = ?", "customer").
 		Preload("Visa").
-		Preload("VisaOption").
+		Preload("VisaOption", utils.Unscoped).
+		Preload("Applicants").
+		Preload("LineItems", utils.LineItemsInOrder).
 		Joins("JOIN users ON visa_purchases.user_id = users.id").
 		Where("users.role = ?", "customer")
 
@@ -167,7 +173,7 @@ func ExportPurchasesExcel(c *gin.Context) {
 	f.SetActiveSheet(index)
 
 	// Set headers
-	headers := []string{"Purchase ID", "User ID", "User Name", "User Email", "Visa ID", "Country", "Visa Type", "Total Price", "Status", "Created At"}
+	headers := []string{"Purchase ID", "User ID", "User Name", "User Email", "Visa ID", "Country", "Visa Type", "Applicants", "Travel Date", "Original Total", "Original Currency", "Exchange Rate", "Charged Total", "Charged Currency", "Status", "Created At"}
 	for i, header := range headers {
 		cell := fmt.Sprintf("%c1", 'A'+i)
 		f.SetCellValue(sheetName, cell, header)
@@ -184,9 +190,11 @@ func ExportPurchasesExcel(c *gin.Context) {
 		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), purchase.VisaID)
 		f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), purchase.Visa.Country)
 		f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), purchase.Visa.Type)
-		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), purchase.TotalPrice)
-		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), purchase.Status)
-		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), purchase.CreatedAt.Format("2006-01-02 15:04:05"))
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), applicantNames(purchase))
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), formatTravelDate(purchase))
+		setAmountCells(f, sheetName, "J", row, purchase)
+		f.SetCellValue(sheetName, fmt.Sprintf("O%d", row), purchase.Status)
+		f.SetCellValue(sheetName, fmt.Sprintf("P%d", row), purchase.CreatedAt.Format("2006-01-02 15:04:05"))
 	}
 
 	// Set column widths
@@ -195,6 +203,44 @@ func ExportPurchasesExcel(c *gin.Context) {
 		f.SetColWidth(sheetName, col, col, 20)
 	}
 
+	// Price breakdown of every purchase on its own sheet
+	lineSheet := "Line Items"
+	if _, err := f.NewSheet(lineSheet); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create Excel sheet",
+			"EXCEL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	lineHeaders := []string{"Purchase ID", "Kind", "Description", "Quantity", "Unit Price", "Amount", "Currency"}
+	for i, header := range lineHeaders {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(lineSheet, cell, header)
+		f.SetCellStyle(lineSheet, cell, cell, getHeaderStyle(f))
+	}
+
+	row := 2
+	for _, purchase := range purchases {
+		for _, line := range utils.PurchaseLineItems(purchase) {
+			f.SetCellValue(lineSheet, fmt.Sprintf("A%d", row), purchase.ID)
+			f.SetCellValue(lineSheet, fmt.Sprintf("B%d", row), line.Kind)
+			f.SetCellValue(lineSheet, fmt.Sprintf("C%d", row), line.Description)
+			f.SetCellValue(lineSheet, fmt.Sprintf("D%d", row), line.Quantity)
+			f.SetCellValue(lineSheet, fmt.Sprintf("E%d", row), models.MinorToMajor(line.UnitPrice, line.Currency))
+			f.SetCellValue(lineSheet, fmt.Sprintf("F%d", row), models.MinorToMajor(line.Amount, line.Currency))
+			f.SetCellValue(lineSheet, fmt.Sprintf("G%d", row), line.Currency)
+			row++
+		}
+	}
+
+	for i := range lineHeaders {
+		col := string(rune('A' + i))
+		f.SetColWidth(lineSheet, col, col, 20)
+	}
+	f.SetColWidth(lineSheet, "C", "C", 40)
+
 	filename := fmt.Sprintf("purchases_export_%s.xlsx", time.Now().Format("20060102_150405"))
 	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
@@ -209,6 +255,107 @@ func ExportPurchasesExcel(c *gin.Context) {
 	}
 }
 
+// ExportReconciliationExcel godoc
+// @Summary Export reconciliation run to Excel
+// @Description Export the flagged items of a reconciliation run to Excel format
+// @Tags Export
+// @Accept json
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param id path int true "Reconciliation run ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /exports/reconciliations/{id}/excel [get]
+func ExportReconciliationExcel(c *gin.Context) {
+	run, ok := findReconciliationRun(c)
+	if !ok {
+		return
+	}
+
+	var items []models.ReconciliationItem
+	if err := config.DB.Where("run_id = ?", run.ID).Order("issue ASC, id ASC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch reconciliation items",
+			"DATABASE_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// Create Excel file
+	f := excelize.NewFile()
+	defer func() {
+		if err := f.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	sheetName := "Reconciliation"
+	index, err := f.NewSheet(sheetName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create Excel sheet",
+			"EXCEL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// Set active sheet
+	f.SetActiveSheet(index)
+
+	// Set headers
+	headers := []string{"Issue", "Payment ID", "Invoice ID", "External ID", "Currency", "Our Amount", "Gateway Amount", "Our Status", "Gateway Status", "Details"}
+	for i, header := range headers {
+		cell := fmt.Sprintf("%c1", 'A'+i)
+		f.SetCellValue(sheetName, cell, header)
+		f.SetCellStyle(sheetName, cell, cell, getHeaderStyle(f))
+	}
+
+	// Add data
+	for i, item := range items {
+		row := i + 2
+		f.SetCellValue(sheetName, fmt.Sprintf("A%d", row), item.Issue)
+		if item.PaymentID != nil {
+			f.SetCellValue(sheetName, fmt.Sprintf("B%d", row), *item.PaymentID)
+		}
+		f.SetCellValue(sheetName, fmt.Sprintf("C%d", row), item.InvoiceID)
+		f.SetCellValue(sheetName, fmt.Sprintf("D%d", row), item.ExternalID)
+		f.SetCellValue(sheetName, fmt.Sprintf("E%d", row), item.Currency)
+		if item.OurAmount != nil {
+			f.SetCellValue(sheetName, fmt.Sprintf("F%d", row), models.MinorToMajor(*item.OurAmount, item.Currency))
+		}
+		if item.GatewayAmount != nil {
+			f.SetCellValue(sheetName, fmt.Sprintf("G%d", row), models.MinorToMajor(*item.GatewayAmount, item.Currency))
+		}
+		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), item.OurStatus)
+		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), item.GatewayStatus)
+		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), item.Details)
+	}
+
+	// Set column widths
+	for i := range headers {
+		col := string(rune('A' + i))
+		f.SetColWidth(sheetName, col, col, 20)
+	}
+
+	filename := fmt.Sprintf("reconciliation_%d_%s.xlsx", run.ID, run.CreatedAt.Format("20060102_150405"))
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	if err := f.Write(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to write Excel file",
+			"EXCEL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+}
+
 // ExportVisasPDF godoc
 // @Summary Export visas to PDF
 // @Description Export all visas filtered by customer role purchases to PDF format
@@ -225,7 +372,8 @@ func ExportVisasPDF(c *gin.Context) {
 	query := config.DB.
 		Preload("User", "role = ?", "customer").
 		Preload("Visa").
-		Preload("VisaOption").
+		Preload("VisaOption", utils.Unscoped).
+		Preload("Applicants").
 		Joins("JOIN users ON visa_purchases.user_id = users.id").
 		Where("users.role = ?", "customer")
 
@@ -247,8 +395,8 @@ func ExportVisasPDF(c *gin.Context) {
 
 	// Table header
 	pdf.SetFont("Arial", "B", 8)
-	headers := []string{"ID", "User", "Email", "Country", "Type", "Price", "Total", "Status", "Date"}
-	widths := []float64{15, 40, 50, 35, 35, 25, 25, 20, 40}
+	headers := []string{"ID", "User", "Email", "Country", "Type", "Applicants", "Price", "Original Total", "Charged Total", "Status", "Date"}
+	widths := []float64{12, 28, 38, 22, 22, 36, 26, 30, 30, 18, 18}
 
 	for i, header := range headers {
 		pdf.CellFormat(widths[i], 10, header, "1", 0, "C", false, 0, "")
@@ -264,9 +412,11 @@ func ExportVisasPDF(c *gin.Context) {
 			purchase.User.Email,
 			purchase.Visa.Country,
 			purchase.Visa.Type,
-			fmt.Sprintf("%.2f", purchase.Visa.Price),
-			fmt.Sprintf("%.2f", purchase.TotalPrice),
-			purchase.Status,
+			applicantNames(purchase),
+			models.FormatAmount(purchase.Visa.Price, purchase.Visa.Currency),
+			models.FormatAmount(purchase.OriginalTotal, purchase.OriginalCurrency),
+			models.FormatAmount(purchase.TotalPrice, purchase.Currency),
+			string(purchase.Status),
 			purchase.CreatedAt.Format("2006-01-02"),
 		}
 
@@ -306,7 +456,9 @@ func ExportPurchasesPDF(c *gin.Context) {
 	query := config.DB.
 		Preload("User", "role = ?", "customer").
 		Preload("Visa").
-		Preload("VisaOption").
+		Preload("VisaOption", utils.Unscoped).
+		Preload("Applicants").
+		Preload("LineItems", utils.LineItemsInOrder).
 		Joins("JOIN users ON visa_purchases.user_id = users.id").
 		Where("users.role = ?", "customer")
 
@@ -328,8 +480,12 @@ func ExportPurchasesPDF(c *gin.Context) {
 
 	// Table header
 	pdf.SetFont("Arial", "B", 8)
-	headers := []string{"ID", "User ID", "User Name", "Email", "Visa ID", "Country", "Type", "Total Price", "Status", "Date"}
-	widths := []float64{12, 15, 40, 50, 12, 30, 30, 30, 25, 40}
+	headers := []string{"ID", "User ID", "User Name", "Email", "Visa ID", "Country", "Type", "Applicants", "Original Total", "Charged Total", "Status", "Date"}
+	widths := []float64{10, 12, 28, 38, 12, 22, 22, 38, 30, 30, 20, 18}
+	indent := 0.0 // width of the columns before Applicants
+	for _, width := range widths[:7] {
+		indent += width
+	}
 
 	for i, header := range headers {
 		pdf.CellFormat(widths[i], 10, header, "1", 0, "C", false, 0, "")
@@ -347,8 +503,10 @@ func ExportPurchasesPDF(c *gin.Context) {
 			fmt.Sprintf("%d", purchase.VisaID),
 			purchase.Visa.Country,
 			purchase.Visa.Type,
-			fmt.Sprintf("%.2f", purchase.TotalPrice),
-			purchase.Status,
+			applicantNames(purchase),
+			models.FormatAmount(purchase.OriginalTotal, purchase.OriginalCurrency),
+			models.FormatAmount(purchase.TotalPrice, purchase.Currency),
+			string(purchase.Status),
 			purchase.CreatedAt.Format("2006-01-02"),
 		}
 
@@ -356,6 +514,16 @@ func ExportPurchasesPDF(c *gin.Context) {
 			pdf.CellFormat(widths[i], 8, data, "1", 0, "L", false, 0, "")
 		}
 		pdf.Ln(-1)
+
+		// Price breakdown below the purchase, aligned with the total columns
+		pdf.SetFont("Arial", "I", 6)
+		for _, line := range utils.PurchaseLineItems(purchase) {
+			pdf.CellFormat(indent, 5, "", "", 0, "", false, 0, "")
+			pdf.CellFormat(widths[7]+widths[8], 5, fmt.Sprintf("%s (%d x %s)", line.Description, line.Quantity, models.FormatAmount(line.UnitPrice, line.Currency)), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(widths[9], 5, models.FormatAmount(line.Amount, line.Currency), "1", 0, "R", false, 0, "")
+			pdf.Ln(-1)
+		}
+		pdf.SetFont("Arial", "", 7)
 	}
 
 	filename := fmt.Sprintf("purchases_export_%s.pdf", time.Now().Format("20060102_150405"))
@@ -372,6 +540,38 @@ func ExportPurchasesPDF(c *gin.Context) {
 	}
 }
 
+// applicantNames joins the full names of a purchase's applicants
+func applicantNames(purchase models.VisaPurchase) string {
+	names := make([]string, 0, len(purchase.Applicants))
+	for _, applicant := range purchase.Applicants {
+		names = append(names, applicant.FullName)
+	}
+	return strings.Join(names, ", ")
+}
+
+// formatTravelDate formats a purchase's travel date, or "" if none was given
+func formatTravelDate(purchase models.VisaPurchase) string {
+	if purchase.TravelDate == nil {
+		return ""
+	}
+	return purchase.TravelDate.Format("2006-01-02")
+}
+
+// setAmountCells writes the original total, its currency, the exchange rate,
+// the charged total and its currency into five columns starting at col
+func setAmountCells(f *excelize.File, sheetName, col string, row int, purchase models.VisaPurchase) {
+	values := []interface{}{
+		models.MinorToMajor(purchase.OriginalTotal, purchase.OriginalCurrency),
+		purchase.OriginalCurrency,
+		purchase.ExchangeRate,
+		models.MinorToMajor(purchase.TotalPrice, purchase.Currency),
+		purchase.Currency,
+	}
+	for i, value := range values {
+		f.SetCellValue(sheetName, fmt.Sprintf("%c%d", col[0]+byte(i), row), value)
+	}
+}
+
 func getHeaderStyle(f *excelize.File) int {
 	styleID, err := f.NewStyle(&excelize.Style{
 		Font: &excelize.Font{
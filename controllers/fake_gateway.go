@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFakeInvoice godoc
+// @Summary Get fake gateway invoice
+// @Description Show an invoice of the in-process fake payment gateway. Only available when PAYMENT_GATEWAY=fake; this is the payment_url handed out for fake payments.
+// @Tags Fake Gateway
+// @Produce json
+// @Param id path string true "Fake invoice ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /dev/fake-gateway/invoices/{id} [get]
+func GetFakeInvoice(c *gin.Context) {
+	fake, ok := payments.Fake()
+	if !ok {
+		respondFakeGatewayDisabled(c)
+		return
+	}
+
+	invoice, err := fake.GetInvoice(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondGatewayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Invoice retrieved successfully",
+		invoice,
+	))
+}
+
+// SimulateFakeInvoice godoc
+// @Summary Settle fake gateway invoice
+// @Description Settle a pending fake invoice as paid, expired or failed. The fake gateway then posts the webhook to /webhooks/fake exactly like a real gateway. Only available when PAYMENT_GATEWAY=fake.
+// @Tags Fake Gateway
+// @Produce json
+// @Param id path string true "Fake invoice ID"
+// @Param outcome path string true "Outcome" Enums(paid, expired, failed)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /dev/fake-gateway/invoices/{id}/{outcome} [post]
+func SimulateFakeInvoice(c *gin.Context) {
+	fake, ok := payments.Fake()
+	if !ok {
+		respondFakeGatewayDisabled(c)
+		return
+	}
+
+	outcome := c.Param("outcome")
+	switch outcome {
+	case "paid", "expired", "failed":
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid outcome",
+			"INVALID_OUTCOME",
+			"Outcome must be paid, expired or failed",
+		))
+		return
+	}
+
+	invoice, err := fake.Simulate(c.Param("id"), outcome)
+	if err != nil {
+		if errors.Is(err, payments.ErrInvoiceNotPending) {
+			c.JSON(http.StatusConflict, models.ErrorResponse(
+				"Invoice is not pending",
+				"INVOICE_NOT_PENDING",
+				err.Error(),
+			))
+			return
+		}
+		respondGatewayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Invoice settled, webhook is being delivered",
+		invoice,
+	))
+}
+
+func respondFakeGatewayDisabled(c *gin.Context) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse(
+		"Fake gateway disabled",
+		"FAKE_GATEWAY_DISABLED",
+		"Set PAYMENT_GATEWAY=fake to use the fake payment gateway",
+	))
+}
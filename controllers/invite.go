@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetInvites godoc
+// @Summary List invitations
+// @Description Get paginated list of staff invitations
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (pending, accepted, revoked, expired)"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/invites [get]
+func GetInvites(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	perPage := c.DefaultQuery("per_page", "20")
+
+	// Parse pagination
+	pageInt, _ := strconv.Atoi(page)
+	perPageInt, _ := strconv.Atoi(perPage)
+	if pageInt < 1 {
+		pageInt = 1
+	}
+	if perPageInt < 1 || perPageInt > 100 {
+		perPageInt = 20
+	}
+
+	query := config.DB.Model(&models.UserInvite{})
+
+	now := time.Now()
+	switch models.InviteStatus(c.Query("status")) {
+	case "":
+	case models.InviteStatusPending:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	case models.InviteStatusAccepted:
+		query = query.Where("accepted_at IS NOT NULL")
+	case models.InviteStatusRevoked:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NOT NULL")
+	case models.InviteStatusExpired:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid status filter",
+			"VALIDATION_ERROR",
+			"Status must be one of pending, accepted, revoked, expired",
+		))
+		return
+	}
+
+	// Get total count
+	var total int64
+	query.Count(&total)
+
+	var invites []models.UserInvite
+	offset := (pageInt - 1) * perPageInt
+	if err := query.
+		Preload("InvitedBy", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, email, name")
+		}).
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(perPageInt).
+		Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch invitations",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Invitations retrieved successfully",
+		inviteResponses(invites),
+		pageInt,
+		perPageInt,
+		int(total),
+	))
+}
+
+// CreateInvite godoc
+// @Summary Invite staff member
+// @Description Invite someone to create a staff account with the given role. A signed, expiring link is emailed through the queue.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateInviteRequest true "Invitation data"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/invites [post]
+func CreateInvite(c *gin.Context) {
+	var req models.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var existingUser models.User
+	if err := config.DB.Unscoped().Where("LOWER(email) = ?", email).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"User with this email already exists",
+			"USER_EXISTS",
+			"Change the existing user's role instead of inviting them",
+		))
+		return
+	}
+
+	var pending int64
+	config.DB.Model(&models.UserInvite{}).
+		Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", email, time.Now()).
+		Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Invitation already pending",
+			"INVITE_PENDING",
+			"Revoke the pending invitation before sending a new one",
+		))
+		return
+	}
+
+	// The email worker reissues the token when it sends the link, so this one is
+	// never sent; it only fills the unique token hash until then
+	expiresAt := time.Now().Add(utils.InviteTTL())
+	token, err := utils.GenerateInviteToken(email, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create invitation",
+			"TOKEN_GENERATION_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	invite := models.UserInvite{
+		Email:       email,
+		Name:        req.Name,
+		Role:        req.Role,
+		TokenHash:   utils.HashInviteToken(token),
+		ExpiresAt:   expiresAt,
+		InvitedByID: actorID,
+	}
+	// Email the link through the outbox, so the invite is never saved without its email
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invite).Error; err != nil {
+			return err
+		}
+		return utils.EnqueueJob(tx, config.QueueEmailInvite, map[string]interface{}{
+			"invite_id": invite.ID,
+			"email":     invite.Email,
+		})
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create invitation",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	utils.LogCreate(c, actorID, models.EntityInvite, invite.ID, invite.Email, gin.H{
+		"email":      invite.Email,
+		"role":       invite.Role,
+		"expires_at": invite.ExpiresAt,
+	})
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Invitation created successfully",
+		gin.H{
+			"invite": inviteResponse(invite),
+		},
+	))
+}
+
+// RevokeInvite godoc
+// @Summary Revoke invitation
+// @Description Revoke a pending invitation so its link can no longer be used
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/invites/{id} [delete]
+func RevokeInvite(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid invitation ID",
+			"INVALID_ID",
+			"Invitation ID must be a valid number",
+		))
+		return
+	}
+
+	var invite models.UserInvite
+	if err := config.DB.First(&invite, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Invitation not found",
+			"INVITE_NOT_FOUND",
+			"Invitation with this ID does not exist",
+		))
+		return
+	}
+
+	if status := invite.Status(); status != models.InviteStatusPending {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Invitation cannot be revoked",
+			"INVITE_NOT_PENDING",
+			"Invitation is already "+string(status),
+		))
+		return
+	}
+
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	now := time.Now()
+	result := config.DB.Model(&models.UserInvite{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invite.ID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_by_id": actorID})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to revoke invitation",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Invitation cannot be revoked",
+			"INVITE_NOT_PENDING",
+			"Invitation was accepted or revoked in the meantime",
+		))
+		return
+	}
+	invite.RevokedAt = &now
+	invite.RevokedByID = &actorID
+
+	// Log activity
+	utils.LogActivity(c, actorID, models.ActionUpdate, models.EntityInvite, invite.ID, invite.Email,
+		"Revoked invitation: "+invite.Email,
+		map[string]interface{}{"status": map[string]models.InviteStatus{"old": models.InviteStatusPending, "new": models.InviteStatusRevoked}},
+	)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Invitation revoked successfully",
+		inviteResponse(invite),
+	))
+}
+
+// AcceptInvite godoc
+// @Summary Accept invitation
+// @Description Accept a staff invitation by setting a password. Creates the account with the invited role.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.AcceptInviteRequest true "Invite token and password"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/invites/accept [post]
+func AcceptInvite(c *gin.Context) {
+	var req models.AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	email, err := utils.ParseInviteToken(req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid or expired invitation",
+			"INVALID_INVITE",
+			"Ask an administrator for a new invitation",
+		))
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to process invitation",
+			"PASSWORD_HASH_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	errInviteUnusable := errors.New("invite unusable")
+	errUserExists := errors.New("user exists")
+
+	var invite models.UserInvite
+	var user models.User
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND email = ?", utils.HashInviteToken(req.Token), email).
+			First(&invite).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInviteUnusable
+			}
+			return err
+		}
+		if invite.Status() != models.InviteStatusPending {
+			return errInviteUnusable
+		}
+
+		var existing int64
+		tx.Unscoped().Model(&models.User{}).Where("LOWER(email) = ?", invite.Email).Count(&existing)
+		if existing > 0 {
+			return errUserExists
+		}
+
+		name := req.Name
+		if name == "" {
+			name = invite.Name
+		}
+		if name == "" {
+			name = invite.Email
+		}
+
+		user = models.User{
+			Email:    invite.Email,
+			Password: hashedPassword,
+			Name:     name,
+			Role:     invite.Role,
+			Language: string(utils.RequestLanguage(c)),
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		invite.AcceptedAt = &now
+		invite.AcceptedUserID = &user.ID
+		return tx.Model(&invite).Updates(map[string]interface{}{
+			"accepted_at":      now,
+			"accepted_user_id": user.ID,
+		}).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errInviteUnusable):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Invalid or expired invitation",
+				"INVALID_INVITE",
+				"This invitation was revoked, already used or has expired",
+			))
+		case errors.Is(err, errUserExists):
+			c.JSON(http.StatusConflict, models.ErrorResponse(
+				"User with this email already exists",
+				"USER_EXISTS",
+				"Please login instead",
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+				"Failed to accept invitation",
+				"DATABASE_ERROR",
+				"Please try again later",
+			))
+		}
+		return
+	}
+
+	// Log activity as the new user
+	utils.LogActivity(c, user.ID, models.ActionUpdate, models.EntityInvite, invite.ID, invite.Email,
+		"Accepted invitation: "+invite.Email,
+		map[string]interface{}{"user_id": user.ID, "role": user.Role},
+	)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Invitation accepted successfully",
+		gin.H{"user": gin.H{
+			"id":    user.ID,
+			"email": user.Email,
+			"name":  user.Name,
+			"role":  user.Role,
+		}},
+	))
+}
+
+// inviteResponse adds the derived status to an invite
+func inviteResponse(invite models.UserInvite) gin.H {
+	return gin.H{
+		"id":               invite.ID,
+		"email":            invite.Email,
+		"name":             invite.Name,
+		"role":             invite.Role,
+		"status":           invite.Status(),
+		"expires_at":       invite.ExpiresAt,
+		"invited_by_id":    invite.InvitedByID,
+		"invited_by":       invite.InvitedBy,
+		"accepted_at":      invite.AcceptedAt,
+		"accepted_user_id": invite.AcceptedUserID,
+		"revoked_at":       invite.RevokedAt,
+		"revoked_by_id":    invite.RevokedByID,
+		"created_at":       invite.CreatedAt,
+	}
+}
+
+func inviteResponses(invites []models.UserInvite) []gin.H {
+	responses := make([]gin.H, 0, len(invites))
+	for _, invite := range invites {
+		responses = append(responses, inviteResponse(invite))
+	}
+	return responses
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetPurchaseInvoice godoc
+// @Summary Download purchase invoice
+// @Description Download the PDF invoice of a purchase belonging to the authenticated user. Invoices are issued once the purchase is paid.
+// @Tags Invoice
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchases/{id}/invoice [get]
+func GetPurchaseInvoice(c *gin.Context) {
+	purchase, ok := findOwnedPurchase(c)
+	if !ok {
+		return
+	}
+
+	var invoice models.Invoice
+	if err := config.DB.Where("purchase_id = ? AND type = ?", purchase.ID, models.InvoiceTypeInvoice).
+		Order("issued_at DESC, id DESC").
+		First(&invoice).Error; err != nil {
+		respondInvoiceLookupError(c, err, "No invoice has been issued for this purchase yet")
+		return
+	}
+
+	serveInvoiceFile(c, &invoice)
+}
+
+// GetPurchaseInvoices godoc
+// @Summary List purchase invoices
+// @Description List the invoices and credit notes issued for a purchase belonging to the authenticated user
+// @Tags Invoice
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchases/{id}/invoices [get]
+func GetPurchaseInvoices(c *gin.Context) {
+	purchase, ok := findOwnedPurchase(c)
+	if !ok {
+		return
+	}
+
+	var invoices []models.Invoice
+	if err := config.DB.Where("purchase_id = ?", purchase.ID).Order("issued_at ASC, id ASC").Find(&invoices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch invoices",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Invoices retrieved successfully",
+		invoices,
+	))
+}
+
+// DownloadPurchaseInvoice godoc
+// @Summary Download purchase invoice or credit note
+// @Description Download one of the invoices or credit notes of a purchase belonging to the authenticated user
+// @Tags Invoice
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Param invoice_id path int true "Invoice ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchases/{id}/invoices/{invoice_id}/file [get]
+func DownloadPurchaseInvoice(c *gin.Context) {
+	purchase, ok := findOwnedPurchase(c)
+	if !ok {
+		return
+	}
+
+	invoiceID, err := strconv.Atoi(c.Param("invoice_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid invoice ID",
+			"INVALID_ID",
+			"Invoice ID must be a valid number",
+		))
+		return
+	}
+
+	var invoice models.Invoice
+	if err := config.DB.Where("id = ? AND purchase_id = ?", invoiceID, purchase.ID).First(&invoice).Error; err != nil {
+		respondInvoiceLookupError(c, err, "Invoice with this ID does not exist for this purchase")
+		return
+	}
+
+	serveInvoiceFile(c, &invoice)
+}
+
+// GetInvoices godoc
+// @Summary List invoices
+// @Description List issued invoices and credit notes, newest first
+// @Tags Invoice
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "Filter by type (invoice or credit_note)"
+// @Param year query int false "Filter by year"
+// @Param purchase_id query int false "Filter by purchase"
+// @Param search query string false "Search by number (partial match)"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/invoices [get]
+func GetInvoices(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := config.DB.Model(&models.Invoice{})
+	if invoiceType := c.Query("type"); invoiceType != "" {
+		query = query.Where("type = ?", invoiceType)
+	}
+	if year, err := strconv.Atoi(c.Query("year")); err == nil {
+		query = query.Where("year = ?", year)
+	}
+	if purchaseID, err := strconv.Atoi(c.Query("purchase_id")); err == nil {
+		query = query.Where("purchase_id = ?", purchaseID)
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("number LIKE ?", "%"+strings.ToUpper(search)+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var invoices []models.Invoice
+	if err := query.Order("issued_at DESC, id DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&invoices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch invoices",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Invoices retrieved successfully",
+		invoices,
+		page,
+		perPage,
+		int(total),
+	))
+}
+
+// AdminDownloadInvoice godoc
+// @Summary Download invoice or credit note (admin)
+// @Description Download the PDF of any issued invoice or credit note
+// @Tags Invoice
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Invoice ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/invoices/{id}/file [get]
+func AdminDownloadInvoice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid invoice ID",
+			"INVALID_ID",
+			"Invoice ID must be a valid number",
+		))
+		return
+	}
+
+	var invoice models.Invoice
+	if err := config.DB.First(&invoice, id).Error; err != nil {
+		respondInvoiceLookupError(c, err, "Invoice with this ID does not exist")
+		return
+	}
+
+	serveInvoiceFile(c, &invoice)
+}
+
+func respondInvoiceLookupError(c *gin.Context, err error, notFoundDetails string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Invoice not found",
+			"INVOICE_NOT_FOUND",
+			notFoundDetails,
+		))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+		"Database error",
+		"DATABASE_ERROR",
+		"Please try again later",
+	))
+}
+
+// serveInvoiceFile streams the stored PDF of an invoice, rendering it first if needed
+func serveInvoiceFile(c *gin.Context, invoice *models.Invoice) {
+	path, err := utils.InvoicePDF(config.DB, invoice)
+	if err != nil {
+		log.Printf("Failed to render invoice %s: %v", invoice.Number, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to generate invoice",
+			"PDF_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.FileAttachment(path, strings.ReplaceAll(invoice.Number, "/", "-")+".pdf")
+}
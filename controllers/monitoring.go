@@ -1,9 +1,14 @@
 package controllers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"viskatera-api-go/config"
 	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -50,7 +55,7 @@ func GetQueueStats(c *gin.Context) {
 
 // GetQueueHealth godoc
 // @Summary Get RabbitMQ health status
-// @Description Get health status of RabbitMQ connection including connection status and total messages in all queues
+// @Description Get health status of RabbitMQ connection including connection status, how often it was re-established, and total messages in all queues
 // @Tags Monitoring
 // @Accept json
 // @Produce json
@@ -61,11 +66,16 @@ func GetQueueStats(c *gin.Context) {
 // @Failure 500 {object} models.APIResponse "Failed to get queue statistics"
 // @Router /monitoring/queues/health [get]
 func GetQueueHealth(c *gin.Context) {
-	if config.RabbitMQConn == nil || config.RabbitMQConn.IsClosed() {
+	connection := config.GetRabbitMQStatus()
+	if !connection.Connected {
+		details := "RabbitMQ connection is closed or not initialized"
+		if connection.LastError != "" {
+			details = fmt.Sprintf("%s (reconnecting, %d failed attempts so far)", connection.LastError, connection.FailedAttempts)
+		}
 		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse(
 			"RabbitMQ connection is not available",
 			"QUEUE_UNAVAILABLE",
-			"RabbitMQ connection is closed or not initialized",
+			details,
 		))
 		return
 	}
@@ -91,8 +101,266 @@ func GetQueueHealth(c *gin.Context) {
 		gin.H{
 			"status":         "healthy",
 			"connected":      true,
+			"connection":     connection,
 			"total_messages": totalMessages,
 			"queues":         stats,
 		},
 	))
 }
+
+// GetDeadLetterStats godoc
+// @Summary Get dead-letter queue statistics
+// @Description Get the number of dead-lettered messages per work queue. Jobs are dead-lettered when they fail permanently or run out of attempts.
+// @Tags Monitoring
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /monitoring/dead-letters [get]
+func GetDeadLetterStats(c *gin.Context) {
+	stats, err := config.GetDeadLetterStats()
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+
+	total := 0
+	for _, count := range stats {
+		total += count
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Dead-letter statistics retrieved successfully",
+		gin.H{
+			"queues":         stats,
+			"total_messages": total,
+		},
+	))
+}
+
+// GetDeadLetters godoc
+// @Summary List dead-lettered messages
+// @Description List the oldest messages in a work queue's dead-letter queue with their attempts and last error. Messages stay in the queue.
+// @Tags Monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Work queue, e.g. email_invoice"
+// @Param limit query int false "Maximum messages (max 100)" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /monitoring/dead-letters/{queue} [get]
+func GetDeadLetters(c *gin.Context) {
+	queue, ok := deadLetterQueueParam(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	letters, total, err := config.PeekDeadLetters(queue, limit)
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+
+	messages := make([]gin.H, 0, len(letters))
+	for _, letter := range letters {
+		messages = append(messages, deadLetterResponse(letter))
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Dead-lettered messages retrieved successfully",
+		gin.H{
+			"queue":       queue,
+			"dead_letter": config.DeadLetterQueue(queue),
+			"total":       total,
+			"messages":    messages,
+		},
+	))
+}
+
+// GetDeadLetter godoc
+// @Summary Inspect dead-lettered message
+// @Description Get one message from a work queue's dead-letter queue by message ID, including its body. The message stays in the queue.
+// @Tags Monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Work queue, e.g. email_invoice"
+// @Param message_id path string true "Message ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /monitoring/dead-letters/{queue}/{message_id} [get]
+func GetDeadLetter(c *gin.Context) {
+	queue, ok := deadLetterQueueParam(c)
+	if !ok {
+		return
+	}
+
+	letter, err := config.FindDeadLetter(queue, c.Param("message_id"))
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+	if letter == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Message not found",
+			"MESSAGE_NOT_FOUND",
+			"No dead-lettered message with this ID in "+config.DeadLetterQueue(queue),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Dead-lettered message retrieved successfully",
+		deadLetterResponse(*letter),
+	))
+}
+
+// RequeueDeadLetters godoc
+// @Summary Requeue dead-lettered messages
+// @Description Move dead-lettered messages back to their work queue with a fresh attempt count, e.g. after fixing SMTP settings. Moves a single message when message_id is given, otherwise all of them.
+// @Tags Monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Work queue, e.g. email_invoice"
+// @Param message_id query string false "Only requeue this message"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /monitoring/dead-letters/{queue}/requeue [post]
+func RequeueDeadLetters(c *gin.Context) {
+	queue, ok := deadLetterQueueParam(c)
+	if !ok {
+		return
+	}
+	messageID := c.Query("message_id")
+
+	moved, err := config.RequeueDeadLetters(queue, messageID)
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+	if messageID != "" && moved == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Message not found",
+			"MESSAGE_NOT_FOUND",
+			"No dead-lettered message with this ID in "+config.DeadLetterQueue(queue),
+		))
+		return
+	}
+
+	utils.LogActivity(c, utils.GetUserIDFromContextWithDefault(c), models.ActionUpdate, models.EntityDeadLetter, 0, config.DeadLetterQueue(queue),
+		fmt.Sprintf("Requeued %d dead-lettered messages to %s", moved, queue),
+		gin.H{"queue": queue, "message_id": messageID, "count": moved},
+	)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Messages requeued successfully",
+		gin.H{"queue": queue, "requeued": moved},
+	))
+}
+
+// PurgeDeadLetters godoc
+// @Summary Purge dead-lettered messages
+// @Description Delete dead-lettered messages for good. Deletes a single message when message_id is given, otherwise empties the dead-letter queue.
+// @Tags Monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Work queue, e.g. email_invoice"
+// @Param message_id query string false "Only delete this message"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 503 {object} models.APIResponse
+// @Router /monitoring/dead-letters/{queue} [delete]
+func PurgeDeadLetters(c *gin.Context) {
+	queue, ok := deadLetterQueueParam(c)
+	if !ok {
+		return
+	}
+	messageID := c.Query("message_id")
+
+	deleted, err := config.PurgeDeadLetters(queue, messageID)
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+	if messageID != "" && deleted == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Message not found",
+			"MESSAGE_NOT_FOUND",
+			"No dead-lettered message with this ID in "+config.DeadLetterQueue(queue),
+		))
+		return
+	}
+
+	utils.LogActivity(c, utils.GetUserIDFromContextWithDefault(c), models.ActionDelete, models.EntityDeadLetter, 0, config.DeadLetterQueue(queue),
+		fmt.Sprintf("Purged %d dead-lettered messages from %s", deleted, config.DeadLetterQueue(queue)),
+		gin.H{"queue": queue, "message_id": messageID, "count": deleted},
+	)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Messages purged successfully",
+		gin.H{"queue": queue, "purged": deleted},
+	))
+}
+
+// deadLetterQueueParam returns the work queue named in the path, or responds 404
+func deadLetterQueueParam(c *gin.Context) (string, bool) {
+	queue := c.Param("queue")
+	if !config.IsWorkQueue(queue) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Queue not found",
+			"QUEUE_NOT_FOUND",
+			"Queue must be one of: "+strings.Join(config.WorkQueues(), ", "),
+		))
+		return "", false
+	}
+	return queue, true
+}
+
+func respondDeadLetterError(c *gin.Context, err error) {
+	c.JSON(http.StatusServiceUnavailable, models.ErrorResponse(
+		"Failed to access dead-letter queue",
+		"QUEUE_UNAVAILABLE",
+		err.Error(),
+	))
+}
+
+// deadLetterResponse shows a dead-lettered message. Invite tokens in bodies
+// queued by earlier versions are redacted, as they would let anyone accept the
+// invitation.
+func deadLetterResponse(letter config.DeadLetter) gin.H {
+	var body interface{} = string(letter.Body)
+	var job map[string]interface{}
+	if err := json.Unmarshal(letter.Body, &job); err == nil {
+		if _, ok := job["token"]; ok {
+			job["token"] = "[redacted]"
+		}
+		body = job
+	}
+
+	return gin.H{
+		"message_id":       letter.MessageID,
+		"queue":            letter.Queue,
+		"attempts":         letter.Attempts,
+		"error":            letter.Error,
+		"dead_lettered_at": letter.DeadLetteredAt,
+		"published_at":     letter.PublishedAt,
+		"body":             body,
+	}
+}
@@ -1,11 +1,12 @@
 package controllers
 
 import (
-	"fmt"
 	"log"
 	"net/http"
 	"time"
 	"viskatera-api-go/config"
+	"viskatera-api-go/i18n"
+	"viskatera-api-go/mailer"
 	"viskatera-api-go/models"
 	"viskatera-api-go/utils"
 
@@ -89,27 +90,10 @@ func RequestOTP(c *gin.Context) {
 	}
 
 	// Send OTP via email
-	emailSubject := "Your Login OTP Code"
-	emailBody := fmt.Sprintf(`
-		<html>
-			<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
-				<div style="max-width: 600px; margin: 0 auto; padding: 20px;">
-					<h2 style="color: #4CAF50;">Your OTP Code</h2>
-					<p>Hello,</p>
-					<p>Your OTP code for login is:</p>
-					<div style="background-color: #f4f4f4; padding: 20px; text-align: center; margin: 20px 0; border-radius: 5px;">
-						<h1 style="color: #4CAF50; margin: 0; font-size: 32px; letter-spacing: 5px;">%s</h1>
-					</div>
-					<p>This code will expire in 10 minutes.</p>
-					<p>If you didn't request this code, please ignore this email.</p>
-					<hr style="border: none; border-top: 1px solid #eee; margin: 20px 0;">
-					<p style="color: #666; font-size: 12px;">This is an automated email, please do not reply.</p>
-				</div>
-			</body>
-		</html>
-	`, otpCode)
-
-	if err := utils.SendEmail(req.Email, emailSubject, emailBody); err != nil {
+	if err := mailer.SendTemplate(c.Request.Context(), req.Email, i18n.Of(user.Language), mailer.TemplateOTP, mailer.OTPEmail{
+		Code:             otpCode,
+		ExpiresInMinutes: 10,
+	}); err != nil {
 		// Log error but don't reveal it to user for security
 		c.JSON(http.StatusOK, models.SuccessResponse(
 			"If the email exists, an OTP code has been sent",
@@ -200,8 +184,8 @@ func VerifyOTP(c *gin.Context) {
 		log.Printf("Failed to update last login time: %v", err)
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID)
+	// Start a session with an access/refresh token pair
+	tokens, err := utils.IssueSession(c, user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
 			"Failed to generate token",
@@ -220,8 +204,11 @@ func VerifyOTP(c *gin.Context) {
 	}
 
 	responseData := gin.H{
-		"token": token,
-		"user":  userData,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          userData,
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse(
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOutboxMessages godoc
+// @Summary List outbox messages
+// @Description List queue jobs waiting in the transactional outbox, oldest first, with a summary of pending and stuck messages. A message is stuck when it has been pending for more than 5 minutes, usually because RabbitMQ is unreachable; last_error shows why.
+// @Tags Monitoring
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (pending or sent)" default(pending)
+// @Param queue query string false "Filter by queue"
+// @Param stuck query bool false "Only stuck messages"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/outbox [get]
+func GetOutboxMessages(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	stuckBefore := time.Now().Add(-models.OutboxStuckAfter)
+
+	var summary struct {
+		Pending         int64      `json:"pending"`
+		Stuck           int64      `json:"stuck"`
+		OldestPendingAt *time.Time `json:"oldest_pending_at"`
+	}
+	if err := config.DB.Model(&models.OutboxMessage{}).
+		Select("COUNT(*) AS pending, COUNT(*) FILTER (WHERE created_at < ?) AS stuck, MIN(created_at) AS oldest_pending_at", stuckBefore).
+		Where("status = ?", models.OutboxStatusPending).
+		Scan(&summary).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch outbox messages",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	query := config.DB.Model(&models.OutboxMessage{}).
+		Where("status = ?", c.DefaultQuery("status", string(models.OutboxStatusPending)))
+	if queue := c.Query("queue"); queue != "" {
+		query = query.Where("queue = ?", queue)
+	}
+	if c.Query("stuck") == "true" {
+		query = query.Where("status = ? AND created_at < ?", models.OutboxStatusPending, stuckBefore)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var messages []models.OutboxMessage
+	if err := query.Order("id ASC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch outbox messages",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Outbox messages retrieved successfully",
+		gin.H{
+			"summary":  summary,
+			"messages": messages,
+			"pagination": gin.H{
+				"page":        page,
+				"per_page":    perPage,
+				"total":       total,
+				"total_pages": (int(total) + perPage - 1) / perPage,
+			},
+		},
+	))
+}
@@ -1,94 +1,44 @@
 package controllers
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 	"viskatera-api-go/config"
 	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
 	"viskatera-api-go/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type XenditRequest struct {
-	PurchaseID    uint    `json:"purchase_id" binding:"required"`
-	PaymentMethod string  `json:"payment_method" binding:"required,oneof=virtual_account qris"`
-	Amount        float64 `json:"amount"`
-	BankCode      string  `json:"bank_code,omitempty"` // For virtual account
-	CustomerName  string  `json:"customer_name,omitempty"`
-	CustomerEmail string  `json:"customer_email,omitempty"`
-}
-
-type XenditInvoiceRequest struct {
-	ExternalID                     string                       `json:"external_id"`
-	Amount                         float64                      `json:"amount"`
-	Description                    string                       `json:"description"`
-	Currency                       string                       `json:"currency"`
-	Customer                       *XenditCustomer              `json:"customer,omitempty"`
-	CustomerNotificationPreference XenditNotificationPreference `json:"customer_notification_preference,omitempty"`
-	PaymentMethods                 []string                     `json:"payment_methods,omitempty"`
-	SuccessRedirectURL             string                       `json:"success_redirect_url,omitempty"`
-	FailureRedirectURL             string                       `json:"failure_redirect_url,omitempty"`
-	Items                          []XenditItem                 `json:"items,omitempty"`
-}
-
-type XenditCustomer struct {
-	GivenNames   string `json:"given_names"`
-	Email        string `json:"email"`
-	MobileNumber string `json:"mobile_number,omitempty"`
-}
-
-type XenditNotificationPreference struct {
-	InvoiceCreated  []string `json:"invoice_created,omitempty"`
-	InvoiceReminder []string `json:"invoice_reminder,omitempty"`
-	InvoicePaid     []string `json:"invoice_paid,omitempty"`
-}
-
-type XenditItem struct {
-	Name     string  `json:"name"`
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"`
-}
-
-type XenditResponse struct {
-	ID                string   `json:"id"`
-	ExternalID        string   `json:"external_id"`
-	UserID            string   `json:"user_id"`
-	Status            string   `json:"status"`
-	MerchantName      string   `json:"merchant_name"`
-	Amount            float64  `json:"amount"`
-	Currency          string   `json:"currency"`
-	InvoiceURL        string   `json:"invoice_url"`
-	AvailableBanks    []Bank   `json:"available_banks,omitempty"`
-	AvailableEwallets []Wallet `json:"available_ewallets,omitempty"`
-}
-
-type Bank struct {
-	BankCode string `json:"bank_code"`
-	Name     string `json:"name"`
-}
-
-type Wallet struct {
-	EWalletType string `json:"ewallet_type"`
+	PurchaseID    uint   `json:"purchase_id" binding:"required"`
+	PaymentMethod string `json:"payment_method" binding:"required,oneof=virtual_account qris"`
+	BankCode      string `json:"bank_code,omitempty"` // For virtual account
+	CustomerName  string `json:"customer_name,omitempty"`
+	CustomerEmail string `json:"customer_email,omitempty"`
 }
 
 // CreatePayment godoc
-// @Summary Create payment with Xendit
-// @Description Create payment using virtual account or QRIS
+// @Summary Create payment
+// @Description Create payment using virtual account or QRIS through the configured payment gateway (PAYMENT_GATEWAY). The purchase total is charged in the currency and at the exchange rate captured when the purchase was made.
 // @Tags Payment
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Client-generated key; retries with the same key and body replay the first response"
 // @Param request body XenditRequest true "Payment request"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 422 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /payments [post]
 func CreatePayment(c *gin.Context) {
@@ -114,7 +64,10 @@ func CreatePayment(c *gin.Context) {
 
 	// Get purchase details
 	var purchase models.VisaPurchase
-	if err := config.DB.Where("id = ? AND user_id = ?", req.PurchaseID, userID).First(&purchase).Error; err != nil {
+	if err := config.DB.Where("id = ? AND user_id = ?", req.PurchaseID, userID).
+		Preload("Visa").Preload("VisaOption", utils.Unscoped).Preload("Applicants").
+		Preload("LineItems", utils.LineItemsInOrder).
+		First(&purchase).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse(
 			"Purchase not found",
 			"PURCHASE_NOT_FOUND",
@@ -123,6 +76,16 @@ func CreatePayment(c *gin.Context) {
 		return
 	}
 
+	// Only draft applications are awaiting payment
+	if purchase.Status != models.PurchaseStatusDraft {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Purchase is not awaiting payment",
+			"INVALID_PURCHASE_STATUS",
+			"Payments can only be created for draft purchases",
+		))
+		return
+	}
+
 	// Get user details
 	var user models.User
 	if err := config.DB.First(&user, userID).Error; err != nil {
@@ -134,19 +97,8 @@ func CreatePayment(c *gin.Context) {
 		return
 	}
 
-	// Get payment amount
-	amount := req.Amount
-	if amount == 0 {
-		amount = purchase.TotalPrice
-	}
-
 	// Create invoice
-	xenditSecretKey := os.Getenv("XENDIT_SECRET_KEY")
-	xenditAPIURL := os.Getenv("XENDIT_API_URL")
-	if xenditAPIURL == "" {
-		xenditAPIURL = "https://api.xendit.co"
-	}
-
+	gateway := payments.Default()
 	externalID := fmt.Sprintf("payment_%d_%d", purchase.ID, time.Now().Unix())
 	description := fmt.Sprintf("Payment for visa purchase %d", purchase.ID)
 
@@ -160,98 +112,19 @@ func CreatePayment(c *gin.Context) {
 		customerEmail = user.Email
 	}
 
-	paymentMethods := []string{}
-	if req.PaymentMethod == "virtual_account" {
-		paymentMethods = append(paymentMethods, "BANK_TRANSFER")
-		if req.BankCode != "" {
-			paymentMethods = append(paymentMethods, req.BankCode)
-		}
-	} else if req.PaymentMethod == "qris" {
-		paymentMethods = append(paymentMethods, "EWALLET")
-	}
-
-	invoiceReq := XenditInvoiceRequest{
-		ExternalID:  externalID,
-		Amount:      amount,
-		Description: description,
-		Currency:    "IDR",
-		Customer: &XenditCustomer{
-			GivenNames: customerName,
-			Email:      customerEmail,
-		},
-		PaymentMethods: paymentMethods,
-		Items: []XenditItem{
-			{
-				Name:     purchase.Visa.Country + " - " + purchase.Visa.Type,
-				Quantity: 1,
-				Price:    amount,
-			},
-		},
-	}
-
-	// Call Xendit API
-	jsonData, err := json.Marshal(invoiceReq)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to create payment request",
-			"MARSHAL_ERROR",
-			err.Error(),
-		))
-		return
-	}
-
-	xenditURL := fmt.Sprintf("%s/v2/invoices", xenditAPIURL)
-	httpReq, err := http.NewRequest("POST", xenditURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to create payment request",
-			"REQUEST_ERROR",
-			err.Error(),
-		))
-		return
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.SetBasicAuth(xenditSecretKey, "")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to connect to payment gateway",
-			"PAYMENT_GATEWAY_ERROR",
-			err.Error(),
-		))
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	invoice, err := gateway.CreateInvoice(c.Request.Context(), payments.InvoiceRequest{
+		ExternalID:    externalID,
+		Description:   description,
+		Amount:        models.MinorToMajor(purchase.TotalPrice, purchase.Currency),
+		Currency:      purchase.Currency,
+		PaymentMethod: req.PaymentMethod,
+		BankCode:      req.BankCode,
+		CustomerName:  customerName,
+		CustomerEmail: customerEmail,
+		Items:         invoiceItems(purchase),
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to read payment response",
-			"READ_ERROR",
-			err.Error(),
-		))
-		return
-	}
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse(
-			"Payment gateway error",
-			"PAYMENT_ERROR",
-			string(body),
-		))
-		return
-	}
-
-	var xenditResp XenditResponse
-	if err := json.Unmarshal(body, &xenditResp); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to parse payment response",
-			"PARSE_ERROR",
-			err.Error(),
-		))
+		respondGatewayError(c, err)
 		return
 	}
 
@@ -260,10 +133,14 @@ func CreatePayment(c *gin.Context) {
 		UserID:        userID.(uint),
 		PurchaseID:    purchase.ID,
 		PaymentMethod: req.PaymentMethod,
-		Amount:        amount,
-		Status:        xenditResp.Status,
-		XenditID:      xenditResp.ID,
-		PaymentURL:    xenditResp.InvoiceURL,
+		Amount:        purchase.TotalPrice,
+		Currency:      purchase.Currency,
+		Status:        utils.NormalizePaymentStatus(invoice.Status),
+		Gateway:       gateway.Name(),
+		XenditID:      invoice.ID,
+		ExternalID:    externalID,
+		PaymentURL:    invoice.PaymentURL,
+		ExpiresAt:     invoice.ExpiresAt,
 	}
 
 	if err := config.DB.Create(&payment).Error; err != nil {
@@ -275,10 +152,6 @@ func CreatePayment(c *gin.Context) {
 		return
 	}
 
-	// Update purchase status to pending
-	purchase.Status = "pending"
-	config.DB.Save(&purchase)
-
 	// Log activity
 	logUserID := utils.GetUserIDFromContextWithDefault(c)
 	entityName := "Payment #" + strconv.Itoa(int(payment.ID)) + " - " + payment.PaymentMethod
@@ -286,9 +159,10 @@ func CreatePayment(c *gin.Context) {
 
 	responseData := gin.H{
 		"payment":     payment,
-		"payment_url": xenditResp.InvoiceURL,
-		"xendit_id":   xenditResp.ID,
-		"status":      xenditResp.Status,
+		"payment_url": invoice.PaymentURL,
+		"xendit_id":   invoice.ID,
+		"gateway":     gateway.Name(),
+		"status":      payment.Status,
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse(
@@ -299,7 +173,7 @@ func CreatePayment(c *gin.Context) {
 
 // GetPaymentStatus godoc
 // @Summary Get payment status
-// @Description Get payment status from Xendit
+// @Description Get the latest payment status from the payment gateway that issued the invoice
 // @Tags Payment
 // @Accept json
 // @Produce json
@@ -341,108 +215,146 @@ func GetPaymentStatus(c *gin.Context) {
 		return
 	}
 
-	// Call Xendit API to get latest status
-	xenditSecretKey := os.Getenv("XENDIT_SECRET_KEY")
-	xenditAPIURL := os.Getenv("XENDIT_API_URL")
-	if xenditAPIURL == "" {
-		xenditAPIURL = "https://api.xendit.co"
-	}
-
-	xenditURL := fmt.Sprintf("%s/v2/invoices/%s", xenditAPIURL, payment.XenditID)
-	httpReq, err := http.NewRequest("GET", xenditURL, nil)
+	// Ask the gateway that issued the invoice for the latest status
+	gateway, err := payments.Get(payment.Gateway)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to create request",
-			"REQUEST_ERROR",
-			err.Error(),
-		))
+		respondGatewayError(c, err)
 		return
 	}
 
-	httpReq.SetBasicAuth(xenditSecretKey, "")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(httpReq)
+	invoice, err := gateway.GetInvoice(c.Request.Context(), payment.XenditID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to connect to payment gateway",
-			"PAYMENT_GATEWAY_ERROR",
-			err.Error(),
-		))
+		respondGatewayError(c, err)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	// Apply the gateway status the same way the webhook does
+	var change utils.PaymentStatusChange
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, payment.ID).Error; err != nil {
+			return err
+		}
+		change, err = utils.ApplyGatewayPaymentStatus(tx, &payment, invoice.Status, 0, "Payment confirmed by status check")
+		return err
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to read payment response",
-			"READ_ERROR",
-			err.Error(),
+			"Failed to update payment status",
+			"DATABASE_ERROR",
+			"Please try again later",
 		))
 		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	utils.RecordPaymentStatusChange(c, utils.GetUserIDFromContextWithDefault(c), &payment, change)
+
+	config.DB.Preload("User").Preload("Purchase").First(&payment, payment.ID)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Payment status retrieved successfully",
+		payment,
+	))
+}
+
+// respondGatewayError writes the response for a failed payment gateway call
+func respondGatewayError(c *gin.Context, err error) {
+	var apiErr *payments.APIError
+	switch {
+	case errors.As(err, &apiErr):
 		c.JSON(http.StatusBadRequest, models.ErrorResponse(
 			"Payment gateway error",
 			"PAYMENT_ERROR",
-			string(body),
+			apiErr.Body,
 		))
-		return
-	}
-
-	var xenditResp XenditResponse
-	if err := json.Unmarshal(body, &xenditResp); err != nil {
+	case errors.Is(err, payments.ErrInvoiceNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Invoice not found",
+			"INVOICE_NOT_FOUND",
+			"The payment gateway does not know this invoice",
+		))
+	case errors.Is(err, payments.ErrUnknownGateway):
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse(
+			"Payment gateway unavailable",
+			"PAYMENT_GATEWAY_UNAVAILABLE",
+			err.Error(),
+		))
+	default:
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
-			"Failed to parse payment response",
-			"PARSE_ERROR",
+			"Failed to connect to payment gateway",
+			"PAYMENT_GATEWAY_ERROR",
 			err.Error(),
 		))
-		return
 	}
+}
 
-	// Store old status for logging
-	oldStatus := payment.Status
-
-	// Update payment status in database
-	payment.Status = xenditResp.Status
-	if xenditResp.Status == "PAID" || xenditResp.Status == "paid" {
-		payment.Status = "paid"
-
-		// Update purchase status
-		var purchase models.VisaPurchase
-		if err := config.DB.First(&purchase, payment.PurchaseID).Error; err == nil {
-			oldPurchaseStatus := purchase.Status
-			purchase.Status = "completed"
-			config.DB.Save(&purchase)
-
-			// Log purchase status update
-			logUserID := utils.GetUserIDFromContextWithDefault(c)
-			purchaseEntityName := "Purchase #" + strconv.Itoa(int(purchase.ID))
-			oldPurchaseValues := map[string]interface{}{"status": oldPurchaseStatus}
-			newPurchaseValues := map[string]interface{}{"status": purchase.Status}
-			utils.LogUpdate(c, logUserID, models.EntityPurchase, purchase.ID, purchaseEntityName, oldPurchaseValues, newPurchaseValues)
-		}
-	} else if xenditResp.Status == "EXPIRED" || xenditResp.Status == "expired" {
-		payment.Status = "expired"
+// invoiceItems turns a purchase's line items into gateway invoice items
+func invoiceItems(purchase models.VisaPurchase) []payments.InvoiceItem {
+	lines := utils.PurchaseLineItems(purchase)
+	items := make([]payments.InvoiceItem, 0, len(lines))
+	for _, line := range lines {
+		items = append(items, payments.InvoiceItem{
+			Name:     line.Description,
+			Quantity: line.Quantity,
+			Price:    models.MinorToMajor(line.UnitPrice, line.Currency),
+		})
 	}
+	return items
+}
 
-	config.DB.Save(&payment)
+// GetPayments godoc
+// @Summary List payments
+// @Description List payments across all purchases, newest first. refund_required=true lists payments received for purchases that could not take them, which must be refunded.
+// @Tags Payment
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (pending, paid, expired, failed, refunded)"
+// @Param refund_required query bool false "Only payments flagged for refund"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/payments [get]
+func GetPayments(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
 
-	config.DB.Preload("User").Preload("Purchase").First(&payment, payment.ID)
+	query := config.DB.Model(&models.Payment{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if refundRequired, err := strconv.ParseBool(c.Query("refund_required")); err == nil {
+		query = query.Where("refund_required = ?", refundRequired)
+	}
+
+	var total int64
+	query.Count(&total)
 
-	// Log payment status update
-	if oldStatus != payment.Status {
-		logUserID := utils.GetUserIDFromContextWithDefault(c)
-		entityName := "Payment #" + strconv.Itoa(int(payment.ID)) + " - " + payment.PaymentMethod
-		oldValues := map[string]interface{}{"status": oldStatus}
-		newValues := map[string]interface{}{"status": payment.Status}
-		utils.LogUpdate(c, logUserID, models.EntityPayment, payment.ID, entityName, oldValues, newValues)
+	var list []models.Payment
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch payments",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse(
-		"Payment status retrieved successfully",
-		payment,
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Payments retrieved successfully",
+		list,
+		page,
+		perPage,
+		int(total),
 	))
 }
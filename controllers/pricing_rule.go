@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetPricingRules godoc
+// @Summary List pricing rules
+// @Description List service fee and tax rules
+// @Tags Pricing Rules
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "Filter by type (service_fee or tax)"
+// @Param active query bool false "Filter by is_active"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/pricing-rules [get]
+func GetPricingRules(c *gin.Context) {
+	query := config.DB.Model(&models.PricingRule{})
+	if ruleType := c.Query("type"); ruleType != "" {
+		query = query.Where("type = ?", ruleType)
+	}
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	var rules []models.PricingRule
+	if err := query.Order("type ASC, country ASC, visa_type ASC, id DESC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch pricing rules",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Pricing rules retrieved successfully",
+		rules,
+	))
+}
+
+// CreatePricingRule godoc
+// @Summary Create pricing rule
+// @Description Create a service fee or tax rule. The charge is percentage of the base amount plus fixed_amount (minor units of currency, optionally per applicant). Empty country/visa_type match every visa; the most specific active rule of each type applies, so a zero rule exempts matching visas from a broader one. Purchases keep the amounts they were charged.
+// @Tags Pricing Rules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.PricingRuleRequest true "Pricing rule"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/pricing-rules [post]
+func CreatePricingRule(c *gin.Context) {
+	var req models.PricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	rule := models.PricingRule{CreatedByID: &userID, IsActive: true}
+	if !applyPricingRuleRequest(c, &rule, req) {
+		return
+	}
+
+	if err := config.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create pricing rule",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	utils.LogCreate(c, userID, models.EntityPricingRule, rule.ID, rule.Name, rule)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Pricing rule created successfully",
+		rule,
+	))
+}
+
+// UpdatePricingRule godoc
+// @Summary Update pricing rule
+// @Description Replace a pricing rule. Only purchases made afterwards are affected.
+// @Tags Pricing Rules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Pricing rule ID"
+// @Param request body models.PricingRuleRequest true "Pricing rule"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/pricing-rules/{id} [put]
+func UpdatePricingRule(c *gin.Context) {
+	rule, ok := findPricingRuleByParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.PricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	old := rule
+	if !applyPricingRuleRequest(c, &rule, req) {
+		return
+	}
+
+	if err := config.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to update pricing rule",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogUpdate(c, userID, models.EntityPricingRule, rule.ID, rule.Name, old, rule)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Pricing rule updated successfully",
+		rule,
+	))
+}
+
+// DeletePricingRule godoc
+// @Summary Delete pricing rule
+// @Description Delete a pricing rule. Existing purchases keep their fees and taxes.
+// @Tags Pricing Rules
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Pricing rule ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/pricing-rules/{id} [delete]
+func DeletePricingRule(c *gin.Context) {
+	rule, ok := findPricingRuleByParam(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to delete pricing rule",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogDelete(c, userID, models.EntityPricingRule, rule.ID, rule.Name, rule)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Pricing rule deleted successfully",
+		nil,
+	))
+}
+
+// applyPricingRuleRequest copies a validated request onto rule, writing the error response otherwise
+func applyPricingRuleRequest(c *gin.Context, rule *models.PricingRule, req models.PricingRuleRequest) bool {
+	currency, ok := parseCurrency(c, req.Currency, utils.ChargeCurrency())
+	if !ok {
+		return false
+	}
+
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Type = req.Type
+	rule.Country = strings.TrimSpace(req.Country)
+	rule.VisaType = strings.TrimSpace(req.VisaType)
+	rule.Percentage = req.Percentage
+	rule.FixedAmount = req.FixedAmount
+	rule.Currency = currency
+	rule.PerApplicant = req.PerApplicant
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	return true
+}
+
+// findPricingRuleByParam loads the pricing rule from the :id path parameter, writing the error response otherwise
+func findPricingRuleByParam(c *gin.Context) (models.PricingRule, bool) {
+	var rule models.PricingRule
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid pricing rule ID",
+			"INVALID_ID",
+			"Pricing rule ID must be a valid number",
+		))
+		return rule, false
+	}
+
+	if err := config.DB.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"Pricing rule not found",
+				"PRICING_RULE_NOT_FOUND",
+				"Pricing rule with this ID does not exist",
+			))
+			return rule, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Database error",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return rule, false
+	}
+
+	return rule, true
+}
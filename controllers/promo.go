@@ -0,0 +1,441 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetPromoCodes godoc
+// @Summary List promo codes
+// @Description List promo codes, newest first
+// @Tags Promo Codes
+// @Produce json
+// @Security BearerAuth
+// @Param active query bool false "Filter by is_active"
+// @Param search query string false "Search by code (partial match)"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/promo-codes [get]
+func GetPromoCodes(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := config.DB.Model(&models.PromoCode{})
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("code LIKE ?", "%"+utils.NormalizePromoCode(search)+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var promos []models.PromoCode
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&promos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch promo codes",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Promo codes retrieved successfully",
+		promos,
+		page,
+		perPage,
+		int(total),
+	))
+}
+
+// GetPromoCode godoc
+// @Summary Get promo code
+// @Description Get a promo code with its redemption totals
+// @Tags Promo Codes
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promo code ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/promo-codes/{id} [get]
+func GetPromoCode(c *gin.Context) {
+	promo, ok := findPromoCodeByParam(c)
+	if !ok {
+		return
+	}
+
+	var totals struct {
+		Customers int
+		Discount  int64
+	}
+	config.DB.Model(&models.PromoRedemption{}).
+		Select("COUNT(DISTINCT user_id) AS customers, COALESCE(SUM(discount_amount), 0) AS discount").
+		Where("promo_code_id = ?", promo.ID).
+		Scan(&totals)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Promo code retrieved successfully",
+		gin.H{
+			"promo_code":     promo,
+			"customers":      totals.Customers,
+			"total_discount": totals.Discount,
+		},
+	))
+}
+
+// CreatePromoCode godoc
+// @Summary Create promo code
+// @Description Create a percentage or fixed discount code. Fixed amounts and the minimum spend are in minor units of currency (default: the charge currency). valid_until is the moment the code stops working. Empty countries/visa_types apply to every visa.
+// @Tags Promo Codes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreatePromoCodeRequest true "Promo code"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/promo-codes [post]
+func CreatePromoCode(c *gin.Context) {
+	var req models.CreatePromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	currency, ok := parseCurrency(c, req.Currency, utils.ChargeCurrency())
+	if !ok {
+		return
+	}
+
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	promo := models.PromoCode{
+		Code:           utils.NormalizePromoCode(req.Code),
+		Description:    req.Description,
+		DiscountType:   req.DiscountType,
+		DiscountValue:  req.DiscountValue,
+		Currency:       currency,
+		MinSpend:       req.MinSpend,
+		MaxRedemptions: req.MaxRedemptions,
+		MaxPerUser:     req.MaxPerUser,
+		Countries:      cleanList(req.Countries),
+		VisaTypes:      cleanList(req.VisaTypes),
+		IsActive:       req.IsActive == nil || *req.IsActive,
+		CreatedByID:    &userID,
+	}
+	if !setPromoValidity(c, &promo, &req.ValidFrom, &req.ValidUntil) || !validatePromoCode(c, promo) {
+		return
+	}
+
+	// Codes stay reserved after deletion so old redemptions remain unambiguous
+	var existing int64
+	config.DB.Unscoped().Model(&models.PromoCode{}).Where("code = ?", promo.Code).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Promo code already exists",
+			"PROMO_CODE_EXISTS",
+			"Choose a different code",
+		))
+		return
+	}
+
+	if err := config.DB.Create(&promo).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create promo code",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	utils.LogCreate(c, userID, models.EntityPromoCode, promo.ID, promo.Code, promo)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Promo code created successfully",
+		promo,
+	))
+}
+
+// UpdatePromoCode godoc
+// @Summary Update promo code
+// @Description Update a promo code. The code, discount type and currency cannot change once created. Omitted fields stay unchanged; an empty valid_from/valid_until removes that bound.
+// @Tags Promo Codes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promo code ID"
+// @Param request body models.UpdatePromoCodeRequest true "Fields to update"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/promo-codes/{id} [put]
+func UpdatePromoCode(c *gin.Context) {
+	promo, ok := findPromoCodeByParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdatePromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	old := promo
+	if req.Description != nil {
+		promo.Description = *req.Description
+	}
+	if req.DiscountValue != nil {
+		promo.DiscountValue = *req.DiscountValue
+	}
+	if req.MinSpend != nil {
+		promo.MinSpend = *req.MinSpend
+	}
+	if req.MaxRedemptions != nil {
+		promo.MaxRedemptions = *req.MaxRedemptions
+	}
+	if req.MaxPerUser != nil {
+		promo.MaxPerUser = *req.MaxPerUser
+	}
+	if req.Countries != nil {
+		promo.Countries = cleanList(*req.Countries)
+	}
+	if req.VisaTypes != nil {
+		promo.VisaTypes = cleanList(*req.VisaTypes)
+	}
+	if req.IsActive != nil {
+		promo.IsActive = *req.IsActive
+	}
+	if !setPromoValidity(c, &promo, req.ValidFrom, req.ValidUntil) || !validatePromoCode(c, promo) {
+		return
+	}
+
+	// Only the editable columns, so a concurrent redemption count update is not overwritten
+	if err := config.DB.Model(&promo).Select(
+		"description", "discount_value", "min_spend", "valid_from", "valid_until",
+		"max_redemptions", "max_per_user", "countries", "visa_types", "is_active",
+	).Updates(&promo).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to update promo code",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+	config.DB.First(&promo, promo.ID)
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogUpdate(c, userID, models.EntityPromoCode, promo.ID, promo.Code, old, promo)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Promo code updated successfully",
+		promo,
+	))
+}
+
+// DeletePromoCode godoc
+// @Summary Delete promo code
+// @Description Delete a promo code so it can no longer be redeemed. Existing purchases keep their discount.
+// @Tags Promo Codes
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promo code ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/promo-codes/{id} [delete]
+func DeletePromoCode(c *gin.Context) {
+	promo, ok := findPromoCodeByParam(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&promo).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to delete promo code",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogDelete(c, userID, models.EntityPromoCode, promo.ID, promo.Code, promo)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Promo code deleted successfully",
+		nil,
+	))
+}
+
+// respondPromoError writes the response for promo code errors from checkout
+// and reports whether err was one
+func respondPromoError(c *gin.Context, err error) bool {
+	status, code, message := 0, "", ""
+	switch {
+	case errors.Is(err, utils.ErrPromoNotFound):
+		status, code, message = http.StatusBadRequest, "PROMO_CODE_INVALID", "Promo code not found"
+	case errors.Is(err, utils.ErrPromoNotActive):
+		status, code, message = http.StatusBadRequest, "PROMO_CODE_INACTIVE", "Promo code is not active"
+	case errors.Is(err, utils.ErrPromoNotApplicable):
+		status, code, message = http.StatusBadRequest, "PROMO_CODE_NOT_APPLICABLE", "Promo code does not apply to this purchase"
+	case errors.Is(err, utils.ErrPromoMinSpend):
+		status, code, message = http.StatusBadRequest, "PROMO_MIN_SPEND_NOT_MET", "Minimum spend for this promo code not reached"
+	case errors.Is(err, utils.ErrPromoExhausted):
+		status, code, message = http.StatusConflict, "PROMO_CODE_EXHAUSTED", "Promo code has been fully redeemed"
+	case errors.Is(err, utils.ErrPromoUserLimit):
+		status, code, message = http.StatusConflict, "PROMO_CODE_ALREADY_USED", "You have already used this promo code"
+	default:
+		return false
+	}
+
+	c.JSON(status, models.ErrorResponse(
+		message,
+		code,
+		err.Error(),
+	))
+	return true
+}
+
+// setPromoValidity applies validity bounds given as RFC 3339 or YYYY-MM-DD.
+// A nil value leaves the bound unchanged, an empty one removes it.
+func setPromoValidity(c *gin.Context, promo *models.PromoCode, from, until *string) bool {
+	for _, bound := range []struct {
+		value  *string
+		target **time.Time
+		field  string
+	}{
+		{from, &promo.ValidFrom, "valid_from"},
+		{until, &promo.ValidUntil, "valid_until"},
+	} {
+		if bound.value == nil {
+			continue
+		}
+		if *bound.value == "" {
+			*bound.target = nil
+			continue
+		}
+		t, err := utils.ParseEffectiveAt(*bound.value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Invalid "+bound.field,
+				"VALIDATION_ERROR",
+				bound.field+" must be RFC 3339 or YYYY-MM-DD",
+			))
+			return false
+		}
+		*bound.target = &t
+	}
+	return true
+}
+
+func validatePromoCode(c *gin.Context, promo models.PromoCode) bool {
+	message := ""
+	switch {
+	case promo.DiscountType == models.PromoPercentage && promo.DiscountValue > 100:
+		message = "A percentage discount cannot exceed 100"
+	case promo.ValidFrom != nil && promo.ValidUntil != nil && !promo.ValidUntil.After(*promo.ValidFrom):
+		message = "valid_until must be after valid_from"
+	}
+	if message != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid promo code",
+			"VALIDATION_ERROR",
+			message,
+		))
+		return false
+	}
+	return true
+}
+
+// cleanList trims entries and drops empty ones
+func cleanList(values []string) []string {
+	cleaned := []string{}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			cleaned = append(cleaned, value)
+		}
+	}
+	return cleaned
+}
+
+// findPromoCodeByParam loads the promo code from the :id path parameter, writing the error response otherwise
+func findPromoCodeByParam(c *gin.Context) (models.PromoCode, bool) {
+	var promo models.PromoCode
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid promo code ID",
+			"INVALID_ID",
+			"Promo code ID must be a valid number",
+		))
+		return promo, false
+	}
+
+	if err := config.DB.First(&promo, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"Promo code not found",
+				"PROMO_CODE_NOT_FOUND",
+				"Promo code with this ID does not exist",
+			))
+			return promo, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Database error",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return promo, false
+	}
+
+	return promo, true
+}
@@ -1,35 +1,43 @@
 package controllers
 
 import (
-	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 	"viskatera-api-go/config"
 	"viskatera-api-go/models"
 	"viskatera-api-go/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // UpdatePurchaseStatusRequest represents request body for updating purchase status
 type UpdatePurchaseStatusRequest struct {
-	Status string `json:"status" binding:"required,oneof=pending completed cancelled" example:"completed"`
+	Status models.PurchaseStatus `json:"status" binding:"required" example:"cancelled"`
+	Note   string                `json:"note" example:"Customer changed travel plans"`
 }
 
 // PurchaseVisa godoc
 // @Summary Purchase a visa
-// @Description Create a new visa purchase. Automatically calculates total price including optional visa option. Sends invoice email via RabbitMQ asynchronously.
+// @Description Create a new visa purchase as a draft application for one or more applicants. Every applicant's passport must be valid for at least six months after the travel date. Total price is the visa price plus optional visa option, multiplied by the number of applicants, converted to the charge currency at the current exchange rate; the original total and the rate are kept on the purchase. An optional promo_code is applied to the converted subtotal, then the service fee and tax of the matching pricing rules are added; the line item breakdown is stored on the purchase. The invoice email is queued through the transactional outbox.
 // @Tags Purchase
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Client-generated key; retries with the same key and body replay the first response"
 // @Param request body models.PurchaseRequest true "Purchase request data"
 // @Success 201 {object} models.APIResponse "Visa purchase created successfully"
 // @Failure 400 {object} models.APIResponse "Invalid request data"
 // @Failure 401 {object} models.APIResponse "User not authenticated"
-// @Failure 404 {object} models.APIResponse "Visa not found or inactive"
+// @Failure 404 {object} models.APIResponse "Visa or applicant not found"
+// @Failure 409 {object} models.APIResponse "Promo code fully redeemed or already used, or idempotent request still in progress"
+// @Failure 422 {object} models.APIResponse "Idempotency-Key reused with a different request"
 // @Failure 500 {object} models.APIResponse "Failed to create purchase"
+// @Failure 503 {object} models.APIResponse "No exchange rate for the visa currency"
 // @Router /purchases [post]
 func PurchaseVisa(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -63,13 +71,48 @@ func PurchaseVisa(c *gin.Context) {
 		return
 	}
 
-	// Calculate total price
-	totalPrice := visa.Price
+	// Travel date is already validated by the binding tags
+	travelDate, _ := time.Parse(dateLayout, req.TravelDate)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if travelDate.Before(today) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid travel date",
+			"VALIDATION_ERROR",
+			"travel_date must not be in the past",
+		))
+		return
+	}
+
+	// Load applicants, which must all belong to the purchasing user
+	applicantIDs := uniqueIDs(req.ApplicantIDs)
+	var applicants []models.Applicant
+	if err := config.DB.Where("id IN ? AND user_id = ?", applicantIDs, userID).Find(&applicants).Error; err != nil || len(applicants) != len(applicantIDs) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Applicant not found",
+			"APPLICANT_NOT_FOUND",
+			"One or more applicants do not exist or do not belong to you",
+		))
+		return
+	}
+
+	// Embassies require passports to stay valid well beyond the travel date
+	for _, applicant := range applicants {
+		if !applicant.PassportValidFor(travelDate) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Passport expires too soon",
+				"PASSPORT_EXPIRY_TOO_SOON",
+				fmt.Sprintf("Passport of %s must be valid for at least %d months after the travel date", applicant.FullName, models.PassportValidityMonths),
+			))
+			return
+		}
+	}
 
 	// Check if visa option is provided and valid
+	var option *models.VisaOption
 	if req.VisaOptionID != nil {
-		var option models.VisaOption
-		if err := config.DB.Where("id = ? AND visa_id = ? AND is_active = ?", *req.VisaOptionID, req.VisaID, true).First(&option).Error; err != nil {
+		option = &models.VisaOption{}
+		if err := config.DB.Where("id = ? AND visa_id = ? AND is_active = ?", *req.VisaOptionID, req.VisaID, true).First(option).Error; err != nil {
 			c.JSON(http.StatusNotFound, models.ErrorResponse(
 				"Visa option not found or inactive",
 				"VISA_OPTION_NOT_FOUND",
@@ -77,19 +120,110 @@ func PurchaseVisa(c *gin.Context) {
 			))
 			return
 		}
-		totalPrice += option.Price
 	}
 
-	// Create purchase record
+	// Visa and option are charged per applicant, converted to the charge currency
+	// with the rate snapshotted on the purchase
+	pricedAt := time.Now()
+	pricing, err := utils.PriceVisa(config.DB, visa, option, len(applicants), pricedAt)
+	if err != nil {
+		if respondExchangeRateError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create purchase",
+			"PURCHASE_CREATION_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Applicant details are copied onto the purchase, so editing a profile later
+	// does not change what this purchase and its invoice show
+	purchaseApplicants := make([]models.PurchaseApplicant, 0, len(applicants))
+	for _, applicant := range applicants {
+		purchaseApplicants = append(purchaseApplicants, models.NewPurchaseApplicant(applicant))
+	}
+
+	// Create purchase record as a draft application
 	purchase := models.VisaPurchase{
 		UserID:       userID.(uint),
 		VisaID:       req.VisaID,
 		VisaOptionID: req.VisaOptionID,
-		TotalPrice:   totalPrice,
-		Status:       "pending",
+		Applicants:   purchaseApplicants,
+		TravelDate:   &travelDate,
+		Status:       models.PurchaseStatusDraft,
 	}
 
-	if err := config.DB.Create(&purchase).Error; err != nil {
+	customerID := userID.(uint)
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// The promo code stays locked until commit, so its limits hold under concurrent checkouts
+		var promo *models.PromoCode
+		if req.PromoCode != "" {
+			var discount int64
+			var err error
+			promo, discount, err = utils.ReservePromoCode(tx, req.PromoCode, customerID, visa, pricing.Subtotal, pricing.Currency)
+			if err != nil {
+				return err
+			}
+			purchase.PromoCodeID = &promo.ID
+			purchase.PromoCode = promo.Code
+			pricing.ApplyDiscount(discount, utils.PromoDiscountDescription(promo.Code))
+		}
+
+		// Service fee and tax are charged on the discounted price
+		if err := pricing.ApplyPricingRules(tx, visa, len(applicants), pricedAt); err != nil {
+			return err
+		}
+		pricing.ApplyTo(&purchase)
+
+		if err := tx.Create(&purchase).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PurchaseStatusHistory{
+			PurchaseID:  purchase.ID,
+			ToStatus:    models.PurchaseStatusDraft,
+			Actor:       models.ActorCustomer,
+			ActorUserID: &customerID,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Invoice email goes out through the outbox once the purchase is committed
+		var user models.User
+		if err := tx.First(&user, customerID).Error; err != nil {
+			return err
+		}
+		if err := utils.EnqueueJob(tx, config.QueueEmailInvoice, map[string]interface{}{
+			"purchase_id": purchase.ID,
+			"user_id":     customerID,
+			"email":       user.Email,
+			"type":        "invoice",
+		}); err != nil {
+			return err
+		}
+
+		if promo == nil {
+			return nil
+		}
+
+		if err := utils.RedeemPromoCode(tx, promo, purchase); err != nil {
+			return err
+		}
+		// Nothing left to pay: the application goes straight to review
+		if purchase.TotalPrice == 0 {
+			if err := utils.TransitionPurchase(tx, &purchase, models.PurchaseStatusSubmitted, models.ActorSystem, nil, "Fully covered by promo code "+promo.Code); err != nil {
+				return err
+			}
+			_, err := utils.IssueInvoice(tx, purchase, nil)
+			return err
+		}
+		return nil
+	})
+	if respondPromoError(c, err) || respondExchangeRateError(c, err) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
 			"Failed to create purchase",
 			"PURCHASE_CREATION_ERROR",
@@ -99,31 +233,13 @@ func PurchaseVisa(c *gin.Context) {
 	}
 
 	// Load related data for response
-	config.DB.Preload("Visa").Preload("VisaOption").First(&purchase, purchase.ID)
-
-	// Get user details for email
-	var user models.User
-	config.DB.First(&user, userID)
+	config.DB.Preload("Visa").Preload("VisaOption", utils.Unscoped).Preload("Applicants").Preload("LineItems", utils.LineItemsInOrder).First(&purchase, purchase.ID)
 
 	// Log activity
 	logUserID := utils.GetUserIDFromContextWithDefault(c)
 	entityName := "Purchase #" + strconv.Itoa(int(purchase.ID)) + " - " + purchase.Visa.Country
 	utils.LogCreate(c, logUserID, models.EntityPurchase, purchase.ID, entityName, purchase)
 
-	// Send invoice email via RabbitMQ
-	go func() {
-		job := map[string]interface{}{
-			"purchase_id": purchase.ID,
-			"user_id":     userID.(uint),
-			"email":       user.Email,
-			"type":        "invoice",
-		}
-		jobJSON, _ := json.Marshal(job)
-		if err := config.PublishMessage(config.QueueEmailInvoice, jobJSON); err != nil {
-			log.Printf("[ERROR] Failed to publish invoice email job: %v", err)
-		}
-	}()
-
 	c.JSON(http.StatusCreated, models.SuccessResponse(
 		"Visa purchase created successfully",
 		purchase,
@@ -175,7 +291,7 @@ func GetUserPurchases(c *gin.Context) {
 	var purchases []models.VisaPurchase
 	offset := (pageInt - 1) * perPageInt
 	if err := config.DB.Where("user_id = ?", userID).
-		Preload("Visa").Preload("VisaOption").
+		Preload("Visa").Preload("VisaOption", utils.Unscoped).Preload("Applicants").Preload("LineItems", utils.LineItemsInOrder).
 		Offset(offset).Limit(perPageInt).
 		Find(&purchases).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
@@ -231,7 +347,7 @@ func GetPurchaseByID(c *gin.Context) {
 
 	var purchase models.VisaPurchase
 	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).
-		Preload("Visa").Preload("VisaOption").
+		Preload("Visa").Preload("VisaOption", utils.Unscoped).Preload("Applicants").Preload("LineItems", utils.LineItemsInOrder).
 		First(&purchase).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrorResponse(
 			"Purchase not found",
@@ -249,7 +365,7 @@ func GetPurchaseByID(c *gin.Context) {
 
 // UpdatePurchaseStatus godoc
 // @Summary Update purchase status
-// @Description Update the status of a purchase. Allowed values: pending, completed, cancelled
+// @Description Move the authenticated user's purchase to a new application status. Customers may only cancel draft applications; every other transition is performed by admins or the payment webhook.
 // @Tags Purchase
 // @Accept json
 // @Produce json
@@ -260,6 +376,7 @@ func GetPurchaseByID(c *gin.Context) {
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /purchases/{id}/status [put]
 func UpdatePurchaseStatus(c *gin.Context) {
@@ -303,31 +420,230 @@ func UpdatePurchaseStatus(c *gin.Context) {
 		return
 	}
 
-	// Store old status for logging
+	if !changePurchaseStatus(c, &purchase, req.Status, models.ActorCustomer, req.Note) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Purchase status updated successfully",
+		purchase,
+	))
+}
+
+// AdminUpdatePurchaseStatus godoc
+// @Summary Update purchase status (admin)
+// @Description Move any purchase through the visa application workflow (admin only). Allowed transitions: draft -> submitted/cancelled, submitted -> documents_review/cancelled, documents_review -> submitted_to_embassy/rejected, submitted_to_embassy -> approved/rejected, approved -> issued. Leaving documents_review for submitted_to_embassy requires every required document to be approved.
+// @Tags Purchase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Param request body UpdatePurchaseStatusRequest true "Status update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/purchases/{id}/status [put]
+func AdminUpdatePurchaseStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid purchase ID",
+			"INVALID_ID",
+			"Purchase ID must be a valid number",
+		))
+		return
+	}
+
+	var req UpdatePurchaseStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	var purchase models.VisaPurchase
+	if err := config.DB.First(&purchase, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Purchase not found",
+			"PURCHASE_NOT_FOUND",
+			"Purchase with this ID does not exist",
+		))
+		return
+	}
+
+	if !changePurchaseStatus(c, &purchase, req.Status, models.ActorAdmin, req.Note) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Purchase status updated successfully",
+		purchase,
+	))
+}
+
+// GetPurchaseHistory godoc
+// @Summary Get purchase status history
+// @Description Get the status transition history of a purchase belonging to the authenticated user
+// @Tags Purchase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchases/{id}/history [get]
+func GetPurchaseHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			"User not authenticated",
+			"UNAUTHORIZED",
+			"Please login to view purchase history",
+		))
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid purchase ID",
+			"INVALID_ID",
+			"Purchase ID must be a valid number",
+		))
+		return
+	}
+
+	var purchase models.VisaPurchase
+	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&purchase).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Purchase not found",
+			"PURCHASE_NOT_FOUND",
+			"Purchase with this ID does not exist or does not belong to you",
+		))
+		return
+	}
+
+	var history []models.PurchaseStatusHistory
+	if err := config.DB.Where("purchase_id = ?", purchase.ID).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch purchase history",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Purchase history retrieved successfully",
+		gin.H{
+			"purchase_id": purchase.ID,
+			"status":      purchase.Status,
+			"allowed":     models.AllowedTransitions(purchase.Status, models.ActorCustomer),
+			"history":     history,
+		},
+	))
+}
+
+// changePurchaseStatus applies a lifecycle transition, logs it and writes the
+// error response itself. It returns false when the handler should stop.
+func changePurchaseStatus(c *gin.Context, purchase *models.VisaPurchase, to models.PurchaseStatus, actor models.TransitionActor, note string) bool {
+	if !to.IsValid() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid purchase status",
+			"INVALID_STATUS",
+			"Unknown status: "+string(to),
+		))
+		return false
+	}
+
 	oldStatus := purchase.Status
+	var actorUserID *uint
+	if id, ok := utils.GetUserIDFromContext(c); ok {
+		actorUserID = &id
+	}
 
-	purchase.Status = req.Status
-	if err := config.DB.Save(&purchase).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return utils.TransitionPurchase(tx, purchase, to, actor, actorUserID, note)
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidTransition) {
+			allowed := []string{}
+			for _, s := range models.AllowedTransitions(oldStatus, actor) {
+				allowed = append(allowed, string(s))
+			}
+			details := "No further status changes are allowed"
+			if len(allowed) > 0 {
+				details = "Allowed statuses: " + strings.Join(allowed, ", ")
+			}
+			c.JSON(http.StatusConflict, models.ErrorResponse(
+				"Status transition from "+string(oldStatus)+" to "+string(to)+" is not allowed",
+				"INVALID_STATUS_TRANSITION",
+				details,
+			))
+			return false
+		}
+		if errors.Is(err, utils.ErrDocumentsIncomplete) {
+			c.JSON(http.StatusConflict, models.ErrorResponse(
+				"Required documents are not approved yet",
+				"DOCUMENTS_INCOMPLETE",
+				err.Error(),
+			))
+			return false
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
 			"Failed to update purchase",
 			"PURCHASE_UPDATE_ERROR",
 			"Please try again later",
 		))
-		return
+		return false
 	}
 
 	// Load related data for response
-	config.DB.Preload("Visa").Preload("VisaOption").First(&purchase, purchase.ID)
+	config.DB.Preload("Visa").Preload("VisaOption", utils.Unscoped).Preload("Applicants").Preload("LineItems", utils.LineItemsInOrder).First(purchase, purchase.ID)
 
 	// Log activity
 	userIDVal := utils.GetUserIDFromContextWithDefault(c)
 	entityName := "Purchase #" + strconv.Itoa(int(purchase.ID)) + " - " + purchase.Visa.Country
 	oldValues := map[string]interface{}{"status": oldStatus}
-	newValues := map[string]interface{}{"status": purchase.Status}
+	newValues := map[string]interface{}{"status": purchase.Status, "note": note}
 	utils.LogUpdate(c, userIDVal, models.EntityPurchase, purchase.ID, entityName, oldValues, newValues)
 
-	c.JSON(http.StatusOK, models.SuccessResponse(
-		"Purchase status updated successfully",
-		purchase,
+	return true
+}
+
+// uniqueIDs returns ids with duplicates removed, preserving order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// respondExchangeRateError writes the response for prices that cannot be
+// converted to the charge currency and reports whether err was one
+func respondExchangeRateError(c *gin.Context, err error) bool {
+	if !errors.Is(err, utils.ErrExchangeRateNotFound) {
+		return false
+	}
+	c.JSON(http.StatusServiceUnavailable, models.ErrorResponse(
+		"Price currently unavailable",
+		"EXCHANGE_RATE_UNAVAILABLE",
+		err.Error(),
 	))
+	return true
 }
@@ -0,0 +1,417 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxReconciliationPeriod limits how far a single gateway reconciliation may reach
+const maxReconciliationPeriod = 92 * 24 * time.Hour
+
+// CreateSettlementReconciliation godoc
+// @Summary Reconcile settlement file
+// @Description Upload a Xendit settlement or invoice report (CSV) and compare it with our payments by invoice ID or external ID. When from/to are given, settled payments of that period missing from the file are flagged too. The run completes in the background.
+// @Tags Reconciliation
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "Settlement report (CSV)"
+// @Param from formData string false "Period start (YYYY-MM-DD)"
+// @Param to formData string false "Period end, inclusive (YYYY-MM-DD)"
+// @Param gateway formData string false "Gateway the report comes from (defaults to the active gateway)"
+// @Success 202 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/reconciliations/settlements [post]
+func CreateSettlementReconciliation(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid file upload",
+			"FILE_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	maxSize := getEnvAsInt("MAX_UPLOAD_SIZE", 10485760) // 10MB default
+	if file.Size > int64(maxSize) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"File too large",
+			"FILE_TOO_LARGE",
+			fmt.Sprintf("File size must be less than %d bytes", maxSize),
+		))
+		return
+	}
+	if ext := strings.ToLower(filepath.Ext(file.Filename)); ext != ".csv" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid file type",
+			"INVALID_FILE_TYPE",
+			"Settlement reports must be CSV files",
+		))
+		return
+	}
+
+	var from, to *time.Time
+	if c.PostForm("from") != "" || c.PostForm("to") != "" {
+		start, end, ok := parseReconciliationPeriod(c, c.PostForm("from"), c.PostForm("to"))
+		if !ok {
+			return
+		}
+		from, to = &start, &end
+	}
+
+	gateway, ok := reconciliationGateway(c, c.PostForm("gateway"))
+	if !ok {
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to read file",
+			"FILE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+	defer src.Close()
+
+	records, err := utils.ParseSettlementCSV(src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid settlement file",
+			"INVALID_SETTLEMENT_FILE",
+			err.Error(),
+		))
+		return
+	}
+
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	run := models.ReconciliationRun{
+		Source:      models.ReconciliationSourceSettlement,
+		Gateway:     gateway,
+		FileName:    filepath.Base(file.Filename),
+		PeriodFrom:  from,
+		PeriodTo:    to,
+		Status:      models.ReconciliationRunning,
+		CreatedByID: &userID,
+	}
+	if !createReconciliationRun(c, &run) {
+		return
+	}
+
+	pending := run
+	go utils.ReconcileSettlement(&pending, records)
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse(
+		"Reconciliation started",
+		run,
+	))
+}
+
+// CreateGatewayReconciliation godoc
+// @Summary Reconcile against gateway
+// @Description Look up every payment created in the period at the payment gateway and flag amount and status differences and invoices the gateway does not know. The run completes in the background.
+// @Tags Reconciliation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.GatewayReconciliationRequest true "Reconciliation period"
+// @Success 202 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/reconciliations/gateway [post]
+func CreateGatewayReconciliation(c *gin.Context) {
+	var req models.GatewayReconciliationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	from, to, ok := parseReconciliationPeriod(c, req.From, req.To)
+	if !ok {
+		return
+	}
+	if to.Sub(from) > maxReconciliationPeriod {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Period too long",
+			"VALIDATION_ERROR",
+			"Gateway reconciliation covers at most 92 days",
+		))
+		return
+	}
+
+	gateway, ok := reconciliationGateway(c, req.Gateway)
+	if !ok {
+		return
+	}
+
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	run := models.ReconciliationRun{
+		Source:      models.ReconciliationSourceGateway,
+		Gateway:     gateway,
+		PeriodFrom:  &from,
+		PeriodTo:    &to,
+		Status:      models.ReconciliationRunning,
+		CreatedByID: &userID,
+	}
+	if !createReconciliationRun(c, &run) {
+		return
+	}
+
+	pending := run
+	go utils.ReconcileGateway(context.Background(), &pending)
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse(
+		"Reconciliation started",
+		run,
+	))
+}
+
+// GetReconciliations godoc
+// @Summary List reconciliation runs
+// @Description List reconciliation runs, newest first
+// @Tags Reconciliation
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (running, completed, failed)"
+// @Param source query string false "Filter by source (settlement_file, gateway)"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/reconciliations [get]
+func GetReconciliations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := config.DB.Model(&models.ReconciliationRun{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var runs []models.ReconciliationRun
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch reconciliation runs",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Reconciliation runs retrieved successfully",
+		runs,
+		page,
+		perPage,
+		int(total),
+	))
+}
+
+// GetReconciliation godoc
+// @Summary Get reconciliation run
+// @Description Get a reconciliation run with a count per issue and its flagged items, paginated
+// @Tags Reconciliation
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Reconciliation run ID"
+// @Param issue query string false "Filter items by issue (amount_mismatch, status_mismatch, missing_in_gateway, missing_in_payments)"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/reconciliations/{id} [get]
+func GetReconciliation(c *gin.Context) {
+	run, ok := findReconciliationRun(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	var counts []struct {
+		Issue models.ReconciliationIssue
+		Count int
+	}
+	config.DB.Model(&models.ReconciliationItem{}).
+		Select("issue, COUNT(*) AS count").
+		Where("run_id = ?", run.ID).
+		Group("issue").
+		Scan(&counts)
+	summary := gin.H{}
+	for _, row := range counts {
+		summary[string(row.Issue)] = row.Count
+	}
+
+	query := config.DB.Model(&models.ReconciliationItem{}).Where("run_id = ?", run.ID)
+	if issue := c.Query("issue"); issue != "" {
+		query = query.Where("issue = ?", issue)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.ReconciliationItem
+	if err := query.Order("id ASC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch reconciliation items",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Reconciliation run retrieved successfully",
+		gin.H{
+			"run":     run,
+			"summary": summary,
+			"items":   items,
+			"pagination": gin.H{
+				"page":        page,
+				"per_page":    perPage,
+				"total":       total,
+				"total_pages": (int(total) + perPage - 1) / perPage,
+			},
+		},
+	))
+}
+
+// parseReconciliationPeriod parses an inclusive YYYY-MM-DD period into [from, to)
+func parseReconciliationPeriod(c *gin.Context, fromValue, toValue string) (time.Time, time.Time, bool) {
+	from, errFrom := time.Parse(dateLayout, fromValue)
+	to, errTo := time.Parse(dateLayout, toValue)
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid period",
+			"VALIDATION_ERROR",
+			"from and to must both be dates in YYYY-MM-DD format",
+		))
+		return from, to, false
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid period",
+			"VALIDATION_ERROR",
+			"to must not be before from",
+		))
+		return from, to, false
+	}
+	return from, to.AddDate(0, 0, 1), true
+}
+
+// reconciliationGateway resolves the requested gateway name, defaulting to the active gateway
+func reconciliationGateway(c *gin.Context, name string) (string, bool) {
+	if name == "" {
+		return payments.Default().Name(), true
+	}
+	if _, err := payments.Get(name); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Unknown payment gateway",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return "", false
+	}
+	return name, true
+}
+
+func createReconciliationRun(c *gin.Context, run *models.ReconciliationRun) bool {
+	if err := config.DB.Create(run).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to start reconciliation",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return false
+	}
+	return true
+}
+
+// findReconciliationRun loads the run from the :id path parameter, writing the error response otherwise
+func findReconciliationRun(c *gin.Context) (models.ReconciliationRun, bool) {
+	var run models.ReconciliationRun
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid reconciliation ID",
+			"INVALID_ID",
+			"Reconciliation ID must be a valid number",
+		))
+		return run, false
+	}
+
+	if err := config.DB.First(&run, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"Reconciliation run not found",
+				"RECONCILIATION_NOT_FOUND",
+				"Reconciliation run with this ID does not exist",
+			))
+			return run, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Database error",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return run, false
+	}
+
+	return run, true
+}
@@ -0,0 +1,423 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetRefunds godoc
+// @Summary List refunds
+// @Description List refunds across all payments, newest first
+// @Tags Refunds
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (pending, succeeded, failed)"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/refunds [get]
+func GetRefunds(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := config.DB.Model(&models.Refund{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var refunds []models.Refund
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&refunds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch refunds",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Refunds retrieved successfully",
+		refunds,
+		page,
+		perPage,
+		int(total),
+	))
+}
+
+// GetPaymentRefunds godoc
+// @Summary List payment refunds
+// @Description List the refunds of a payment together with the amount that can still be refunded
+// @Tags Refunds
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Payment ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/payments/{id}/refunds [get]
+func GetPaymentRefunds(c *gin.Context) {
+	payment, ok := findPaymentByParam(c)
+	if !ok {
+		return
+	}
+
+	var refunds []models.Refund
+	if err := config.DB.Where("payment_id = ?", payment.ID).Order("created_at ASC").Find(&refunds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch refunds",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	refundable := int64(0)
+	if payment.Status == "paid" {
+		refundable, _ = utils.RefundableAmount(config.DB, payment)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Refunds retrieved successfully",
+		gin.H{
+			"payment_id":        payment.ID,
+			"payment_status":    payment.Status,
+			"currency":          payment.Currency,
+			"amount":            payment.Amount,
+			"refunded_amount":   payment.RefundedAmount,
+			"refundable_amount": refundable,
+			"refunds":           refunds,
+		},
+	))
+}
+
+// CreateRefund godoc
+// @Summary Refund payment
+// @Description Refund all or part of a paid payment through its payment gateway. Amount is in minor units of the payment currency; omit it to refund the remaining amount. The refund stays pending until the gateway confirms it (directly or by webhook); when the gateway gives no definite answer it is returned with 202 and can be retried. A completed full refund marks the payment and the purchase as refunded, and the customer receives a refund confirmation email.
+// @Tags Refunds
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Payment ID"
+// @Param request body models.CreateRefundRequest true "Refund data"
+// @Success 201 {object} models.APIResponse
+// @Success 202 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/payments/{id}/refunds [post]
+func CreateRefund(c *gin.Context) {
+	payment, ok := findPaymentByParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	gateway, err := payments.Get(payment.Gateway)
+	if err != nil {
+		respondGatewayError(c, err)
+		return
+	}
+
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+
+	// Reserve the amount first so concurrent requests cannot over-refund
+	var refund *models.Refund
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		refund, err = utils.ReserveRefund(tx, payment.ID, req.Amount, req.Reason, &actorID)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrPaymentNotRefundable):
+			c.JSON(http.StatusConflict, models.ErrorResponse(
+				"Payment cannot be refunded",
+				"PAYMENT_NOT_REFUNDABLE",
+				err.Error(),
+			))
+		case errors.Is(err, utils.ErrRefundAmountExceeded):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Refund amount too large",
+				"REFUND_AMOUNT_EXCEEDED",
+				err.Error(),
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+				"Failed to create refund",
+				"DATABASE_ERROR",
+				"Please try again later",
+			))
+		}
+		return
+	}
+
+	// Log activity
+	utils.LogCreate(c, actorID, models.EntityRefund, refund.ID, refundEntityName(*refund), refund)
+
+	submitRefund(c, gateway, payment, refund, actorID, http.StatusCreated)
+}
+
+// RetryRefund godoc
+// @Summary Retry refund
+// @Description Send a pending refund to its payment gateway again, with the same reference as before so the gateway cannot carry it out twice. Use it when the first request ended without a definite answer from the gateway.
+// @Tags Refunds
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Refund ID"
+// @Success 200 {object} models.APIResponse
+// @Success 202 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/refunds/{id}/retry [post]
+func RetryRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid refund ID",
+			"INVALID_ID",
+			"Refund ID must be a valid number",
+		))
+		return
+	}
+
+	var refund models.Refund
+	if err := config.DB.Preload("Payment").First(&refund, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"Refund not found",
+				"REFUND_NOT_FOUND",
+				"Refund with this ID does not exist",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Database error",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+	if refund.Status != models.RefundStatusPending {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Refund is not pending",
+			"REFUND_NOT_PENDING",
+			fmt.Sprintf("Refund is already %s", refund.Status),
+		))
+		return
+	}
+
+	gateway, err := payments.Get(refund.Payment.Gateway)
+	if err != nil {
+		respondGatewayError(c, err)
+		return
+	}
+
+	submitRefund(c, gateway, refund.Payment, &refund, utils.GetUserIDFromContextWithDefault(c), http.StatusOK)
+}
+
+// submitRefund sends a reserved refund to the gateway and settles it with the
+// answer. Only a definitive rejection fails it: after a timeout, connection
+// error or 5xx the gateway may still have carried it out, so it stays pending
+// for the refund webhook or a retry with the same reference.
+func submitRefund(c *gin.Context, gateway payments.Gateway, payment models.Payment, refund *models.Refund, actorID uint, successStatus int) {
+	gatewayRefund, gatewayErr := gateway.Refund(c.Request.Context(), payments.RefundRequest{
+		InvoiceID:   payment.XenditID,
+		ReferenceID: refundReference(refund.ID),
+		Amount:      models.MinorToMajor(refund.Amount, payment.Currency),
+		Reason:      refund.Reason,
+	})
+
+	status, failureReason := "", ""
+	switch {
+	case gatewayErr == nil:
+		status = gatewayRefund.Status
+		if err := config.DB.Model(refund).Update("gateway_refund_id", gatewayRefund.ID).Error; err != nil {
+			log.Printf("Failed to store gateway reference of refund %d: %v", refund.ID, err)
+		}
+	case payments.IsRejected(gatewayErr):
+		status, failureReason = string(models.RefundStatusFailed), gatewayErr.Error()
+	default:
+		log.Printf("Refund %d outcome unknown, left pending: %v", refund.ID, gatewayErr)
+		c.JSON(http.StatusAccepted, models.SuccessResponse(
+			"Refund pending: the payment gateway did not confirm the request. It completes when the gateway reports it, or retry it with POST /admin/refunds/{id}/retry",
+			refund,
+		))
+		return
+	}
+
+	change, err := settleRefund(refund.ID, status, failureReason, &actorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to update refund",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+	applyRefundEffects(c, actorID, change)
+
+	if gatewayErr != nil {
+		respondGatewayError(c, gatewayErr)
+		return
+	}
+
+	c.JSON(successStatus, models.SuccessResponse(
+		"Refund requested successfully",
+		change.Refund,
+	))
+}
+
+// settleRefund applies a gateway refund status to a stored refund in its own transaction
+func settleRefund(refundID uint, gatewayStatus, failureReason string, actorUserID *uint) (utils.RefundChange, error) {
+	var change utils.RefundChange
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var refund models.Refund
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, refundID).Error; err != nil {
+			return err
+		}
+		var err error
+		change, err = utils.ApplyRefundStatus(tx, &refund, gatewayStatus, failureReason, actorUserID)
+		return err
+	})
+	return change, err
+}
+
+// applyRefundEffects logs a committed refund status change
+func applyRefundEffects(c *gin.Context, actorID uint, change utils.RefundChange) {
+	if !change.Changed() {
+		return
+	}
+	refund := change.Refund
+
+	// Log refund status update
+	utils.LogUpdate(c, actorID, models.EntityRefund, refund.ID, refundEntityName(*refund),
+		map[string]interface{}{"status": change.OldStatus},
+		map[string]interface{}{"status": refund.Status, "failure_reason": refund.FailureReason},
+	)
+
+	if change.Payment != nil {
+		// Log payment refund
+		payment := change.Payment
+		entityName := "Payment #" + strconv.Itoa(int(payment.ID)) + " - " + payment.PaymentMethod
+		utils.LogUpdate(c, actorID, models.EntityPayment, payment.ID, entityName,
+			map[string]interface{}{"status": change.OldPaymentStatus, "refunded_amount": payment.RefundedAmount - refund.Amount},
+			map[string]interface{}{"status": payment.Status, "refunded_amount": payment.RefundedAmount},
+		)
+	}
+
+	if change.Purchase != nil {
+		// Log purchase status update
+		purchaseEntityName := "Purchase #" + strconv.Itoa(int(change.Purchase.ID))
+		utils.LogUpdate(c, actorID, models.EntityPurchase, change.Purchase.ID, purchaseEntityName,
+			map[string]interface{}{"status": change.OldPurchaseStatus},
+			map[string]interface{}{"status": change.Purchase.Status},
+		)
+	}
+}
+
+// findRefundForWebhook locates the refund a gateway refund event refers to,
+// by gateway reference or, if the event raced our own bookkeeping, by the
+// reference we sent with the request
+func findRefundForWebhook(tx *gorm.DB, gateway string, notification *payments.WebhookEvent) (*models.Refund, error) {
+	var refund models.Refund
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("gateway = ?", gateway)
+
+	var refundID uint
+	if _, err := fmt.Sscanf(notification.ReferenceID, "refund_%d", &refundID); err == nil && refundID > 0 {
+		query = query.Where("id = ?", refundID)
+	} else {
+		query = query.Where("gateway_refund_id = ?", notification.RefundID)
+	}
+
+	if err := query.First(&refund).Error; err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// findPaymentByParam loads the payment from the :id path parameter, writing the error response otherwise
+func findPaymentByParam(c *gin.Context) (models.Payment, bool) {
+	var payment models.Payment
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid payment ID",
+			"INVALID_ID",
+			"Payment ID must be a valid number",
+		))
+		return payment, false
+	}
+
+	if err := config.DB.First(&payment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"Payment not found",
+				"PAYMENT_NOT_FOUND",
+				"Payment with this ID does not exist",
+			))
+			return payment, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Database error",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return payment, false
+	}
+
+	return payment, true
+}
+
+func refundReference(refundID uint) string {
+	return fmt.Sprintf("refund_%d", refundID)
+}
+
+func refundEntityName(refund models.Refund) string {
+	return fmt.Sprintf("Refund #%d - Payment #%d", refund.ID, refund.PaymentID)
+}
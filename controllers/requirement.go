@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateRequirementRequest represents request body for adding a document requirement to a visa
+type CreateRequirementRequest struct {
+	Name         string `json:"name" binding:"required" example:"Passport scan"`
+	DocumentType string `json:"document_type" binding:"required,oneof=passport_scan photo bank_statement other" example:"passport_scan"`
+	Description  string `json:"description" example:"Colour scan of the passport bio page"`
+	IsRequired   *bool  `json:"is_required" example:"true"`
+	PerApplicant bool   `json:"per_applicant" example:"true"`
+	SortOrder    int    `json:"sort_order" example:"1"`
+}
+
+// UpdateRequirementRequest represents request body for updating a document requirement
+type UpdateRequirementRequest struct {
+	Name         string `json:"name" example:"Passport scan"`
+	DocumentType string `json:"document_type" binding:"omitempty,oneof=passport_scan photo bank_statement other" example:"passport_scan"`
+	Description  string `json:"description" example:"Colour scan of the passport bio page"`
+	IsRequired   *bool  `json:"is_required" example:"true"`
+	PerApplicant *bool  `json:"per_applicant" example:"true"`
+	SortOrder    *int   `json:"sort_order" example:"2"`
+}
+
+// GetVisaRequirements godoc
+// @Summary Get visa document checklist
+// @Description Get the list of documents required to apply for a visa
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Param id path int true "Visa ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /visas/{id}/requirements [get]
+func GetVisaRequirements(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid visa ID",
+			"INVALID_ID",
+			"Visa ID must be a valid number",
+		))
+		return
+	}
+
+	var visa models.Visa
+	if err := config.DB.Where("id = ? AND is_active = ?", id, true).First(&visa).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Visa not found",
+			"VISA_NOT_FOUND",
+			"Visa with this ID does not exist or is inactive",
+		))
+		return
+	}
+
+	var requirements []models.VisaDocumentRequirement
+	if err := config.DB.Where("visa_id = ?", visa.ID).Order("sort_order ASC, id ASC").Find(&requirements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch requirements",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Visa requirements retrieved successfully",
+		requirements,
+	))
+}
+
+// CreateVisaRequirement godoc
+// @Summary Add document requirement
+// @Description Add a document to a visa's required-documents checklist (admin only). is_required defaults to true.
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Visa ID"
+// @Param request body CreateRequirementRequest true "Requirement data"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/visas/{id}/requirements [post]
+func CreateVisaRequirement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid visa ID",
+			"INVALID_ID",
+			"Visa ID must be a valid number",
+		))
+		return
+	}
+
+	var visa models.Visa
+	if err := config.DB.First(&visa, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Visa not found",
+			"VISA_NOT_FOUND",
+			"Visa with this ID does not exist",
+		))
+		return
+	}
+
+	var req CreateRequirementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	isRequired := true
+	if req.IsRequired != nil {
+		isRequired = *req.IsRequired
+	}
+
+	requirement := models.VisaDocumentRequirement{
+		VisaID:       visa.ID,
+		Name:         req.Name,
+		DocumentType: models.DocumentType(req.DocumentType),
+		Description:  req.Description,
+		IsRequired:   isRequired,
+		PerApplicant: req.PerApplicant,
+		SortOrder:    req.SortOrder,
+	}
+
+	if err := config.DB.Create(&requirement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create requirement",
+			"REQUIREMENT_CREATION_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	invalidateVisaCache(c, visa.ID)
+
+	// Log activity as a change to the visa
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	entityName := visa.Country + " - " + visa.Type
+	newValues := map[string]interface{}{"requirement_added": requirement}
+	utils.LogUpdate(c, userID, models.EntityVisa, visa.ID, entityName, nil, newValues)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Requirement created successfully",
+		requirement,
+	))
+}
+
+// UpdateVisaRequirement godoc
+// @Summary Update document requirement
+// @Description Update an entry of a visa's required-documents checklist (admin only). All fields are optional.
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Visa ID"
+// @Param requirement_id path int true "Requirement ID"
+// @Param request body UpdateRequirementRequest true "Requirement update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/visas/{id}/requirements/{requirement_id} [put]
+func UpdateVisaRequirement(c *gin.Context) {
+	visa, requirement, ok := findVisaRequirement(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateRequirementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	oldValues := requirement
+
+	// Update fields if provided
+	if req.Name != "" {
+		requirement.Name = req.Name
+	}
+	if req.DocumentType != "" {
+		requirement.DocumentType = models.DocumentType(req.DocumentType)
+	}
+	if req.Description != "" {
+		requirement.Description = req.Description
+	}
+	if req.IsRequired != nil {
+		requirement.IsRequired = *req.IsRequired
+	}
+	if req.PerApplicant != nil {
+		requirement.PerApplicant = *req.PerApplicant
+	}
+	if req.SortOrder != nil {
+		requirement.SortOrder = *req.SortOrder
+	}
+
+	if err := config.DB.Save(&requirement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to update requirement",
+			"REQUIREMENT_UPDATE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	invalidateVisaCache(c, visa.ID)
+
+	// Log activity as a change to the visa
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	entityName := visa.Country + " - " + visa.Type
+	utils.LogUpdate(c, userID, models.EntityVisa, visa.ID, entityName,
+		map[string]interface{}{"requirement": oldValues},
+		map[string]interface{}{"requirement": requirement},
+	)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Requirement updated successfully",
+		requirement,
+	))
+}
+
+// DeleteVisaRequirement godoc
+// @Summary Delete document requirement
+// @Description Remove an entry from a visa's required-documents checklist (admin only). Uses soft delete.
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Visa ID"
+// @Param requirement_id path int true "Requirement ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/visas/{id}/requirements/{requirement_id} [delete]
+func DeleteVisaRequirement(c *gin.Context) {
+	visa, requirement, ok := findVisaRequirement(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&requirement).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to delete requirement",
+			"REQUIREMENT_DELETE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	invalidateVisaCache(c, visa.ID)
+
+	// Log activity as a change to the visa
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	entityName := visa.Country + " - " + visa.Type
+	oldValues := map[string]interface{}{"requirement_removed": requirement}
+	utils.LogUpdate(c, userID, models.EntityVisa, visa.ID, entityName, oldValues, nil)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Requirement deleted successfully",
+		nil,
+	))
+}
+
+// findVisaRequirement loads the visa and requirement from the :id and
+// :requirement_id path parameters, writing the error response otherwise
+func findVisaRequirement(c *gin.Context) (models.Visa, models.VisaDocumentRequirement, bool) {
+	var visa models.Visa
+	var requirement models.VisaDocumentRequirement
+
+	visaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid visa ID",
+			"INVALID_ID",
+			"Visa ID must be a valid number",
+		))
+		return visa, requirement, false
+	}
+
+	requirementID, err := strconv.Atoi(c.Param("requirement_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid requirement ID",
+			"INVALID_ID",
+			"Requirement ID must be a valid number",
+		))
+		return visa, requirement, false
+	}
+
+	if err := config.DB.First(&visa, visaID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Visa not found",
+			"VISA_NOT_FOUND",
+			"Visa with this ID does not exist",
+		))
+		return visa, requirement, false
+	}
+
+	if err := config.DB.Where("id = ? AND visa_id = ?", requirementID, visa.ID).First(&requirement).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Requirement not found",
+			"REQUIREMENT_NOT_FOUND",
+			"Requirement with this ID does not exist for this visa",
+		))
+		return visa, requirement, false
+	}
+
+	return visa, requirement, true
+}
@@ -0,0 +1,356 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AssignRoleRequest represents request body for changing a user's role
+type AssignRoleRequest struct {
+	Role models.UserRole `json:"role" binding:"required" example:"visa_officer"`
+}
+
+// SetPermissionRequest represents request body for a per-user permission override
+type SetPermissionRequest struct {
+	Permission models.Permission `json:"permission" binding:"required" example:"payments.manage"`
+	Granted    *bool             `json:"granted" binding:"required" example:"true"`
+}
+
+// GetRoles godoc
+// @Summary List roles
+// @Description List every role with its default permissions, plus all known permissions
+// @Tags Roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/roles [get]
+func GetRoles(c *gin.Context) {
+	roles := []gin.H{}
+	for _, role := range models.AllRoles() {
+		roles = append(roles, gin.H{
+			"role":        role,
+			"permissions": models.RolePermissions(role),
+		})
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Roles retrieved successfully",
+		gin.H{
+			"roles":       roles,
+			"permissions": models.AllPermissions,
+		},
+	))
+}
+
+// GetUserPermissions godoc
+// @Summary Get user permissions
+// @Description Get a user's role, per-user permission overrides and resulting effective permissions
+// @Tags Roles
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/permissions [get]
+func GetUserPermissions(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	respondUserPermissions(c, user, "User permissions retrieved successfully")
+}
+
+// AssignUserRole godoc
+// @Summary Assign role
+// @Description Change a user's role. Admins cannot change their own role and the last active admin cannot be demoted.
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body AssignRoleRequest true "New role"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/role [put]
+func AssignUserRole(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid role",
+			"INVALID_ROLE",
+			"Unknown role: "+string(req.Role),
+		))
+		return
+	}
+
+	if !ensureNotSelf(c, user) {
+		return
+	}
+
+	oldRole := user.Role
+	if oldRole == models.RoleAdmin && req.Role != models.RoleAdmin && isLastActiveAdmin(user) {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Cannot demote last admin",
+			"LAST_ADMIN",
+			"At least one active admin must remain",
+		))
+		return
+	}
+
+	if err := config.DB.Model(&user).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to assign role",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogUpdate(c, userID, models.EntityUser, user.ID, user.Email,
+		map[string]interface{}{"role": oldRole},
+		map[string]interface{}{"role": req.Role},
+	)
+
+	respondUserPermissions(c, user, "Role assigned successfully")
+}
+
+// SetUserPermission godoc
+// @Summary Set permission override
+// @Description Grant (granted=true) or revoke (granted=false) a single permission for a user regardless of their role
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body SetPermissionRequest true "Permission override"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/permissions [put]
+func SetUserPermission(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	var req SetPermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	if !req.Permission.IsValid() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid permission",
+			"INVALID_PERMISSION",
+			"Unknown permission: "+string(req.Permission),
+		))
+		return
+	}
+
+	if !ensureNotSelf(c, user) {
+		return
+	}
+
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	override := models.UserPermission{
+		UserID:      user.ID,
+		Permission:  req.Permission,
+		Granted:     *req.Granted,
+		CreatedByID: &actorID,
+	}
+	if err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "permission"}},
+		DoUpdates: clause.AssignmentColumns([]string{"granted", "created_by_id", "updated_at"}),
+	}).Create(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to set permission",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	utils.LogUpdate(c, actorID, models.EntityUser, user.ID, user.Email, nil,
+		map[string]interface{}{"permission": req.Permission, "granted": *req.Granted},
+	)
+
+	respondUserPermissions(c, user, "Permission override saved successfully")
+}
+
+// DeleteUserPermission godoc
+// @Summary Remove permission override
+// @Description Remove a per-user permission override so the role default applies again
+// @Tags Roles
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param permission path string true "Permission"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/permissions/{permission} [delete]
+func DeleteUserPermission(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	if !ensureNotSelf(c, user) {
+		return
+	}
+
+	permission := models.Permission(c.Param("permission"))
+	result := config.DB.Where("user_id = ? AND permission = ?", user.ID, permission).Delete(&models.UserPermission{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to remove permission override",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Permission override not found",
+			"PERMISSION_OVERRIDE_NOT_FOUND",
+			"This user has no override for the given permission",
+		))
+		return
+	}
+
+	// Log activity
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogUpdate(c, actorID, models.EntityUser, user.ID, user.Email,
+		map[string]interface{}{"permission_override_removed": permission}, nil,
+	)
+
+	respondUserPermissions(c, user, "Permission override removed successfully")
+}
+
+// findUserByParam loads the user from the :id path parameter, writing the error response otherwise
+func findUserByParam(c *gin.Context) (models.User, bool) {
+	var user models.User
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid user ID",
+			"INVALID_ID",
+			"User ID must be a valid number",
+		))
+		return user, false
+	}
+
+	if err := config.DB.First(&user, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"User not found",
+				"USER_NOT_FOUND",
+				"User with this ID does not exist",
+			))
+			return user, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Database error",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return user, false
+	}
+
+	return user, true
+}
+
+// ensureNotSelf stops staff from changing their own access, which could lock them out
+func ensureNotSelf(c *gin.Context, user models.User) bool {
+	if user.ID == utils.GetUserIDFromContextWithDefault(c) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse(
+			"Cannot change own access",
+			"CANNOT_MODIFY_SELF",
+			"Ask another administrator to change your own account",
+		))
+		return false
+	}
+	return true
+}
+
+// isLastActiveAdmin reports whether user is the only remaining active admin
+func isLastActiveAdmin(user models.User) bool {
+	var count int64
+	config.DB.Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND id <> ?", models.RoleAdmin, true, user.ID).
+		Count(&count)
+	return count == 0
+}
+
+func respondUserPermissions(c *gin.Context, user models.User, message string) {
+	// Reload so the role reflects any update made by the caller
+	config.DB.First(&user, user.ID)
+
+	var overrides []models.UserPermission
+	if err := config.DB.Where("user_id = ?", user.ID).Order("permission ASC").Find(&overrides).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch permissions",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		message,
+		gin.H{
+			"user_id":     user.ID,
+			"role":        user.Role,
+			"overrides":   overrides,
+			"permissions": models.EffectivePermissions(user.Role, overrides),
+		},
+	))
+}
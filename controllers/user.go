@@ -0,0 +1,438 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/i18n"
+	"viskatera-api-go/mailer"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forcedResetTokenTTL is how long the link from an admin-initiated password reset stays valid
+const forcedResetTokenTTL = 24 * time.Hour
+
+// DeactivateUserRequest represents request body for deactivating a user
+type DeactivateUserRequest struct {
+	Reason string `json:"reason" example:"Account compromised"`
+}
+
+// GetUsers godoc
+// @Summary List users
+// @Description Get paginated list of users with search and filters
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param search query string false "Search by name or email"
+// @Param role query string false "Filter by role"
+// @Param is_active query bool false "Filter by active status"
+// @Param last_login_from query string false "Last login on or after (YYYY-MM-DD)"
+// @Param last_login_to query string false "Last login on or before (YYYY-MM-DD)"
+// @Param never_logged_in query bool false "Only users who never logged in"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users [get]
+func GetUsers(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	perPage := c.DefaultQuery("per_page", "20")
+
+	// Parse pagination
+	pageInt, _ := strconv.Atoi(page)
+	perPageInt, _ := strconv.Atoi(perPage)
+	if pageInt < 1 {
+		pageInt = 1
+	}
+	if perPageInt < 1 || perPageInt > 100 {
+		perPageInt = 20
+	}
+
+	query := config.DB.Model(&models.User{})
+
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		like := "%" + strings.ToLower(search) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", like, like)
+	}
+	if role := c.Query("role"); role != "" {
+		if !models.UserRole(role).IsValid() {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Invalid role",
+				"INVALID_ROLE",
+				"Unknown role: "+role,
+			))
+			return
+		}
+		query = query.Where("role = ?", role)
+	}
+	if isActive := c.Query("is_active"); isActive != "" {
+		active, err := strconv.ParseBool(isActive)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Invalid is_active filter",
+				"VALIDATION_ERROR",
+				"is_active must be true or false",
+			))
+			return
+		}
+		query = query.Where("is_active = ?", active)
+	}
+	if c.Query("never_logged_in") == "true" {
+		query = query.Where("last_login_at IS NULL")
+	}
+	if from := c.Query("last_login_from"); from != "" {
+		fromDate, err := time.Parse(dateLayout, from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Invalid last_login_from filter",
+				"VALIDATION_ERROR",
+				"Date must be in YYYY-MM-DD format",
+			))
+			return
+		}
+		query = query.Where("last_login_at >= ?", fromDate)
+	}
+	if to := c.Query("last_login_to"); to != "" {
+		toDate, err := time.Parse(dateLayout, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Invalid last_login_to filter",
+				"VALIDATION_ERROR",
+				"Date must be in YYYY-MM-DD format",
+			))
+			return
+		}
+		query = query.Where("last_login_at < ?", toDate.AddDate(0, 0, 1))
+	}
+
+	// Get total count
+	var total int64
+	query.Count(&total)
+
+	var users []models.User
+	offset := (pageInt - 1) * perPageInt
+	if err := query.Order("created_at DESC").Offset(offset).Limit(perPageInt).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch users",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Users retrieved successfully",
+		users,
+		pageInt,
+		perPageInt,
+		int(total),
+	))
+}
+
+// GetUserByID godoc
+// @Summary Get user
+// @Description Get a single user by ID
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id} [get]
+func GetUserByID(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"User retrieved successfully",
+		user,
+	))
+}
+
+// DeactivateUser godoc
+// @Summary Deactivate user
+// @Description Deactivate a user account and immediately revoke all of its sessions
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body DeactivateUserRequest false "Deactivation reason"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/deactivate [post]
+func DeactivateUser(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	var req DeactivateUserRequest
+	// Body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	if !ensureNotSelf(c, user) {
+		return
+	}
+
+	if !user.IsActive {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"User already inactive",
+			"USER_ALREADY_INACTIVE",
+			"This user account is already deactivated",
+		))
+		return
+	}
+
+	if user.Role == models.RoleAdmin && isLastActiveAdmin(user) {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Cannot deactivate last admin",
+			"LAST_ADMIN",
+			"At least one active admin must remain",
+		))
+		return
+	}
+
+	if err := config.DB.Model(&user).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to deactivate user",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	if err := utils.RevokeAllSessions(c.Request.Context(), user.ID); err != nil {
+		log.Printf("Failed to revoke sessions of deactivated user %d: %v", user.ID, err)
+	}
+
+	// Log activity
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	description := "Deactivated user: " + user.Email
+	if req.Reason != "" {
+		description += " (" + req.Reason + ")"
+	}
+	utils.LogActivity(c, actorID, models.ActionUpdate, models.EntityUser, user.ID, user.Email, description,
+		map[string]interface{}{"is_active": map[string]bool{"old": true, "new": false}, "reason": req.Reason},
+	)
+
+	user.IsActive = false
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"User deactivated successfully",
+		user,
+	))
+}
+
+// ReactivateUser godoc
+// @Summary Reactivate user
+// @Description Reactivate a deactivated user account
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/reactivate [post]
+func ReactivateUser(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	if user.IsActive {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"User already active",
+			"USER_ALREADY_ACTIVE",
+			"This user account is already active",
+		))
+		return
+	}
+
+	if err := config.DB.Model(&user).Update("is_active", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to reactivate user",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// Log activity
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogActivity(c, actorID, models.ActionUpdate, models.EntityUser, user.ID, user.Email, "Reactivated user: "+user.Email,
+		map[string]interface{}{"is_active": map[string]bool{"old": false, "new": true}},
+	)
+
+	user.IsActive = true
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"User reactivated successfully",
+		user,
+	))
+}
+
+// ForceUserPasswordReset godoc
+// @Summary Force password reset
+// @Description Invalidate the user's password and sessions and email them a link to choose a new password
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id}/reset-password [post]
+func ForceUserPasswordReset(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	// Replace the password with a random one nobody knows
+	randomPassword, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse("Failed to create token", "TOKEN_ERROR", ""))
+		return
+	}
+	hashed, err := utils.HashPassword(randomPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse("Failed to hash password", "HASH_ERROR", ""))
+		return
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse("Failed to create token", "TOKEN_ERROR", ""))
+		return
+	}
+
+	tx := config.DB.Begin()
+	if err := tx.Model(&user).Update("password", hashed).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse("Failed to reset password", "DATABASE_ERROR", ""))
+		return
+	}
+	prt := models.PasswordResetToken{
+		UserID:    user.ID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(forcedResetTokenTTL),
+	}
+	if err := tx.Create(&prt).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse("Failed to save token", "DATABASE_ERROR", ""))
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse("Failed to reset password", "DATABASE_ERROR", ""))
+		return
+	}
+
+	if err := utils.RevokeAllSessions(c.Request.Context(), user.ID); err != nil {
+		log.Printf("Failed to revoke sessions after forced password reset for user %d: %v", user.ID, err)
+	}
+
+	lang := i18n.Of(user.Language)
+	if err := mailer.SendTemplate(c.Request.Context(), user.Email, lang, mailer.TemplatePasswordResetForced, mailer.PasswordResetEmail{
+		Name:      user.Name,
+		ResetURL:  fmt.Sprintf("%s/reset-password?token=%s", os.Getenv("APP_BASE_URL"), token),
+		ExpiresIn: i18n.T(lang, "duration.hours", int(forcedResetTokenTTL.Hours())),
+	}); err != nil {
+		log.Printf("Failed to send forced password reset email to %s: %v", user.Email, err)
+	}
+
+	// Log activity
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogActivity(c, actorID, models.ActionUpdate, models.EntityUser, user.ID, user.Email, "Forced password reset for user: "+user.Email, nil)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Password reset link sent to user",
+		nil,
+	))
+}
+
+// DeleteUser godoc
+// @Summary Delete user
+// @Description Delete a user account (soft delete) and revoke all of its sessions
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{id} [delete]
+func DeleteUser(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	if !ensureNotSelf(c, user) {
+		return
+	}
+
+	if user.Role == models.RoleAdmin && user.IsActive && isLastActiveAdmin(user) {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Cannot delete last admin",
+			"LAST_ADMIN",
+			"At least one active admin must remain",
+		))
+		return
+	}
+
+	if err := config.DB.Delete(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to delete user",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	if err := utils.RevokeAllSessions(c.Request.Context(), user.ID); err != nil {
+		log.Printf("Failed to revoke sessions of deleted user %d: %v", user.ID, err)
+	}
+
+	// Log activity
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	utils.LogActivity(c, actorID, models.ActionDelete, models.EntityUser, user.ID, user.Email, "Deleted user: "+user.Email,
+		map[string]interface{}{"action": "delete", "entity": user},
+	)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"User deleted successfully",
+		nil,
+	))
+}
@@ -11,26 +11,29 @@ import (
 	"viskatera-api-go/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateVisaRequest represents request body for creating a visa
 type CreateVisaRequest struct {
-	Country     string  `json:"country" binding:"required" example:"Japan"`
-	Type        string  `json:"type" binding:"required" example:"Tourist"`
-	Description string  `json:"description" example:"Tourist visa for Japan with 30 days validity"`
-	Price       float64 `json:"price" binding:"required,min=0" example:"500000"`
-	Duration    int     `json:"duration" binding:"required,min=1" example:"30"`
-	IsActive    bool    `json:"is_active" example:"true"`
+	Country     string `json:"country" binding:"required" example:"Japan"`
+	Type        string `json:"type" binding:"required" example:"Tourist"`
+	Description string `json:"description" example:"Tourist visa for Japan with 30 days validity"`
+	Price       int64  `json:"price" binding:"required,min=0" example:"500000"` // minor units of currency
+	Currency    string `json:"currency" example:"IDR"`                          // ISO 4217 code, defaults to IDR
+	Duration    int    `json:"duration" binding:"required,min=1" example:"30"`
+	IsActive    bool   `json:"is_active" example:"true"`
 }
 
 // UpdateVisaRequest represents request body for updating a visa
 type UpdateVisaRequest struct {
-	Country     string  `json:"country" example:"Japan"`
-	Type        string  `json:"type" example:"Business"`
-	Description string  `json:"description" example:"Updated description"`
-	Price       float64 `json:"price" binding:"min=0" example:"600000"`
-	Duration    int     `json:"duration" binding:"min=1" example:"60"`
-	IsActive    *bool   `json:"is_active" example:"true"`
+	Country     string `json:"country" example:"Japan"`
+	Type        string `json:"type" example:"Business"`
+	Description string `json:"description" example:"Updated description"`
+	Price       int64  `json:"price" binding:"min=0" example:"600000"` // minor units of currency
+	Currency    string `json:"currency" example:"USD"`                 // also moves the visa's options to this currency
+	Duration    int    `json:"duration" binding:"min=1" example:"60"`
+	IsActive    *bool  `json:"is_active" example:"true"`
 }
 
 // GetVisas godoc
@@ -139,7 +142,7 @@ func GetVisas(c *gin.Context) {
 
 // GetVisaByID godoc
 // @Summary Get visa by ID
-// @Description Get detailed information about a specific visa including its options
+// @Description Get detailed information about a specific visa including its options and required-documents checklist
 // @Tags Visa
 // @Accept json
 // @Produce json
@@ -166,15 +169,17 @@ func GetVisaByID(c *gin.Context) {
 	// Try to get from cache
 	if config.CacheEnabled {
 		var cachedData struct {
-			Visa    models.Visa         `json:"visa"`
-			Options []models.VisaOption `json:"options"`
+			Visa         models.Visa                      `json:"visa"`
+			Options      []models.VisaOption              `json:"options"`
+			Requirements []models.VisaDocumentRequirement `json:"requirements"`
 		}
 		if err := config.CacheGet(ctx, cacheKey, &cachedData); err == nil {
 			c.JSON(http.StatusOK, models.SuccessResponse(
 				"Visa retrieved successfully (cached)",
 				gin.H{
-					"visa":    cachedData.Visa,
-					"options": cachedData.Options,
+					"visa":         cachedData.Visa,
+					"options":      cachedData.Options,
+					"requirements": cachedData.Requirements,
 				},
 			))
 			return
@@ -195,19 +200,26 @@ func GetVisaByID(c *gin.Context) {
 	var options []models.VisaOption
 	config.DB.Where("visa_id = ? AND is_active = ?", visa.ID, true).Order("price ASC").Find(&options)
 
+	// Get required-documents checklist
+	var requirements []models.VisaDocumentRequirement
+	config.DB.Where("visa_id = ?", visa.ID).Order("sort_order ASC, id ASC").Find(&requirements)
+
 	responseData := gin.H{
-		"visa":    visa,
-		"options": options,
+		"visa":         visa,
+		"options":      options,
+		"requirements": requirements,
 	}
 
 	// Cache the result
 	if config.CacheEnabled {
 		cacheData := struct {
-			Visa    models.Visa         `json:"visa"`
-			Options []models.VisaOption `json:"options"`
+			Visa         models.Visa                      `json:"visa"`
+			Options      []models.VisaOption              `json:"options"`
+			Requirements []models.VisaDocumentRequirement `json:"requirements"`
 		}{
-			Visa:    visa,
-			Options: options,
+			Visa:         visa,
+			Options:      options,
+			Requirements: requirements,
 		}
 		config.CacheSet(ctx, cacheKey, cacheData, cacheTTL)
 	}
@@ -220,7 +232,7 @@ func GetVisaByID(c *gin.Context) {
 
 // CreateVisa godoc
 // @Summary Create new visa
-// @Description Create a new visa (admin only)
+// @Description Create a new visa (admin only). Price is in minor units of currency (IDR has none, so 500000 is IDR 500,000; USD 12500 is USD 125.00).
 // @Tags Visa
 // @Accept json
 // @Produce json
@@ -244,11 +256,17 @@ func CreateVisa(c *gin.Context) {
 		return
 	}
 
+	currency, ok := parseCurrency(c, req.Currency, models.DefaultCurrency)
+	if !ok {
+		return
+	}
+
 	visa := models.Visa{
 		Country:     req.Country,
 		Type:        req.Type,
 		Description: req.Description,
 		Price:       req.Price,
+		Currency:    currency,
 		Duration:    req.Duration,
 		IsActive:    req.IsActive,
 	}
@@ -322,6 +340,7 @@ func UpdateVisa(c *gin.Context) {
 		"type":        visa.Type,
 		"description": visa.Description,
 		"price":       visa.Price,
+		"currency":    visa.Currency,
 		"duration":    visa.Duration,
 		"is_active":   visa.IsActive,
 	}
@@ -350,6 +369,11 @@ func UpdateVisa(c *gin.Context) {
 	if req.Price > 0 {
 		visa.Price = req.Price
 	}
+	currency, ok := parseCurrency(c, req.Currency, visa.Currency)
+	if !ok {
+		return
+	}
+	visa.Currency = currency
 	if req.Duration > 0 {
 		visa.Duration = req.Duration
 	}
@@ -357,7 +381,16 @@ func UpdateVisa(c *gin.Context) {
 		visa.IsActive = *req.IsActive
 	}
 
-	if err := config.DB.Save(&visa).Error; err != nil {
+	// Options are always priced in the visa's currency
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&visa).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.VisaOption{}).
+			Where("visa_id = ? AND currency <> ?", visa.ID, visa.Currency).
+			Update("currency", visa.Currency).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
 			"Failed to update visa",
 			"VISA_UPDATE_ERROR",
@@ -372,6 +405,7 @@ func UpdateVisa(c *gin.Context) {
 		"type":        visa.Type,
 		"description": visa.Description,
 		"price":       visa.Price,
+		"currency":    visa.Currency,
 		"duration":    visa.Duration,
 		"is_active":   visa.IsActive,
 	}
@@ -456,3 +490,12 @@ func DeleteVisa(c *gin.Context) {
 		nil,
 	))
 }
+
+// invalidateVisaCache drops the cached detail and listing responses for a visa
+func invalidateVisaCache(c *gin.Context, visaID uint) {
+	if config.CacheEnabled {
+		ctx := c.Request.Context()
+		config.CacheDelete(ctx, fmt.Sprintf("visa:%d", visaID))
+		config.CacheDeletePattern(ctx, "visas:*")
+	}
+}
@@ -0,0 +1,397 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateVisaOptionRequest represents request body for adding an option to a visa
+type CreateVisaOptionRequest struct {
+	Name        string `json:"name" binding:"required,max=255" example:"Express Processing"`
+	Description string `json:"description" example:"Processed within 3 working days"`
+	Price       int64  `json:"price" binding:"min=0" example:"250000"` // minor units of the visa's currency
+	IsActive    *bool  `json:"is_active" example:"true"`
+}
+
+// UpdateVisaOptionRequest represents request body for updating a visa option
+type UpdateVisaOptionRequest struct {
+	Name        string `json:"name" binding:"omitempty,max=255" example:"Express Processing"`
+	Description string `json:"description" example:"Processed within 3 working days"`
+	Price       *int64 `json:"price" binding:"omitempty,min=0" example:"300000"`
+	IsActive    *bool  `json:"is_active" example:"false"`
+}
+
+// GetVisaOptions godoc
+// @Summary Get visa options
+// @Description Get the active add-on options of a visa, cheapest first
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Param id path int true "Visa ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /visas/{id}/options [get]
+func GetVisaOptions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid visa ID",
+			"INVALID_ID",
+			"Visa ID must be a valid number",
+		))
+		return
+	}
+
+	var visa models.Visa
+	if err := config.DB.Where("id = ? AND is_active = ?", id, true).First(&visa).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Visa not found",
+			"VISA_NOT_FOUND",
+			"Visa with this ID does not exist or is inactive",
+		))
+		return
+	}
+
+	var options []models.VisaOption
+	if err := config.DB.Where("visa_id = ? AND is_active = ?", visa.ID, true).Order("price ASC").Find(&options).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch visa options",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Visa options retrieved successfully",
+		options,
+	))
+}
+
+// AdminGetVisaOptions godoc
+// @Summary Get all visa options
+// @Description Get every option of a visa including inactive ones (admin only)
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Visa ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/visas/{id}/options [get]
+func AdminGetVisaOptions(c *gin.Context) {
+	visa, ok := findVisaForOptions(c)
+	if !ok {
+		return
+	}
+
+	var options []models.VisaOption
+	if err := config.DB.Where("visa_id = ?", visa.ID).Order("price ASC, id ASC").Find(&options).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch visa options",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Visa options retrieved successfully",
+		options,
+	))
+}
+
+// CreateVisaOption godoc
+// @Summary Create visa option
+// @Description Add a paid add-on option to a visa (admin only). is_active defaults to true.
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Visa ID"
+// @Param request body CreateVisaOptionRequest true "Visa option data"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/visas/{id}/options [post]
+func CreateVisaOption(c *gin.Context) {
+	visa, ok := findVisaForOptions(c)
+	if !ok {
+		return
+	}
+
+	var req CreateVisaOptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	if visaOptionNameTaken(visa.ID, req.Name, 0) {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Visa option already exists",
+			"VISA_OPTION_EXISTS",
+			"This visa already has an option with the same name",
+		))
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	option := models.VisaOption{
+		VisaID:      visa.ID,
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+		Currency:    visa.Currency,
+		IsActive:    isActive,
+	}
+
+	if err := config.DB.Create(&option).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to create visa option",
+			"VISA_OPTION_CREATION_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	// is_active has a database default of true, so gorm skips a false value on insert
+	if !isActive {
+		if err := config.DB.Model(&option).Update("is_active", false).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+				"Failed to create visa option",
+				"VISA_OPTION_CREATION_ERROR",
+				"Please try again later",
+			))
+			return
+		}
+	}
+
+	invalidateVisaCache(c, visa.ID)
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	entityName := visa.Country + " - " + visa.Type + " - " + option.Name
+	utils.LogCreate(c, userID, models.EntityVisaOption, option.ID, entityName, option)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Visa option created successfully",
+		option,
+	))
+}
+
+// UpdateVisaOption godoc
+// @Summary Update visa option
+// @Description Update a visa option (admin only). All fields are optional.
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Visa ID"
+// @Param option_id path int true "Visa option ID"
+// @Param request body UpdateVisaOptionRequest true "Visa option update data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/visas/{id}/options/{option_id} [put]
+func UpdateVisaOption(c *gin.Context) {
+	visa, option, ok := findVisaOption(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateVisaOptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid request data",
+			"VALIDATION_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	if req.Name != "" && req.Name != option.Name && visaOptionNameTaken(visa.ID, req.Name, option.ID) {
+		c.JSON(http.StatusConflict, models.ErrorResponse(
+			"Visa option already exists",
+			"VISA_OPTION_EXISTS",
+			"This visa already has an option with the same name",
+		))
+		return
+	}
+
+	oldValues := option
+
+	// Update fields if provided
+	if req.Name != "" {
+		option.Name = req.Name
+	}
+	if req.Description != "" {
+		option.Description = req.Description
+	}
+	if req.Price != nil {
+		option.Price = *req.Price
+	}
+	if req.IsActive != nil {
+		option.IsActive = *req.IsActive
+	}
+
+	if err := config.DB.Save(&option).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to update visa option",
+			"VISA_OPTION_UPDATE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	invalidateVisaCache(c, visa.ID)
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	entityName := visa.Country + " - " + visa.Type + " - " + option.Name
+	utils.LogUpdate(c, userID, models.EntityVisaOption, option.ID, entityName, oldValues, option)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Visa option updated successfully",
+		option,
+	))
+}
+
+// DeleteVisaOption godoc
+// @Summary Delete visa option
+// @Description Delete a visa option (admin only). Uses soft delete, so existing purchases keep their option.
+// @Tags Visa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Visa ID"
+// @Param option_id path int true "Visa option ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/visas/{id}/options/{option_id} [delete]
+func DeleteVisaOption(c *gin.Context) {
+	visa, option, ok := findVisaOption(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&option).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to delete visa option",
+			"VISA_OPTION_DELETE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	invalidateVisaCache(c, visa.ID)
+
+	// Log activity
+	userID := utils.GetUserIDFromContextWithDefault(c)
+	entityName := visa.Country + " - " + visa.Type + " - " + option.Name
+	utils.LogDelete(c, userID, models.EntityVisaOption, option.ID, entityName, option)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Visa option deleted successfully",
+		nil,
+	))
+}
+
+// findVisaForOptions loads the visa from the :id path parameter, writing the
+// error response otherwise. Inactive visas are included so admins can prepare
+// options before publishing.
+func findVisaForOptions(c *gin.Context) (models.Visa, bool) {
+	var visa models.Visa
+
+	visaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid visa ID",
+			"INVALID_ID",
+			"Visa ID must be a valid number",
+		))
+		return visa, false
+	}
+
+	if err := config.DB.First(&visa, visaID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Visa not found",
+			"VISA_NOT_FOUND",
+			"Visa with this ID does not exist",
+		))
+		return visa, false
+	}
+
+	return visa, true
+}
+
+// findVisaOption loads the visa and option from the :id and :option_id path
+// parameters, writing the error response otherwise
+func findVisaOption(c *gin.Context) (models.Visa, models.VisaOption, bool) {
+	var option models.VisaOption
+
+	visa, ok := findVisaForOptions(c)
+	if !ok {
+		return visa, option, false
+	}
+
+	optionID, err := strconv.Atoi(c.Param("option_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid visa option ID",
+			"INVALID_ID",
+			"Visa option ID must be a valid number",
+		))
+		return visa, option, false
+	}
+
+	if err := config.DB.Where("id = ? AND visa_id = ?", optionID, visa.ID).First(&option).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Visa option not found",
+			"VISA_OPTION_NOT_FOUND",
+			"Visa option with this ID does not exist for this visa",
+		))
+		return visa, option, false
+	}
+
+	return visa, option, true
+}
+
+// visaOptionNameTaken reports whether another live option of the visa already uses name
+func visaOptionNameTaken(visaID uint, name string, excludeID uint) bool {
+	var count int64
+	config.DB.Model(&models.VisaOption{}).
+		Where("visa_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", visaID, name, excludeID).
+		Count(&count)
+	return count > 0
+}
@@ -1,43 +1,81 @@
 package controllers
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 	"viskatera-api-go/config"
 	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
 	"viskatera-api-go/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// XenditWebhookPayload represents Xendit webhook payload
-type XenditWebhookPayload struct {
-	ID         string  `json:"id"`
-	ExternalID string  `json:"external_id"`
-	Status     string  `json:"status"`
-	Amount     float64 `json:"amount"`
-	Currency   string  `json:"currency"`
-	Created    string  `json:"created"`
-	Updated    string  `json:"updated"`
+var (
+	errWebhookPaymentNotFound = errors.New("payment not found for webhook")
+	errWebhookRefundNotFound  = errors.New("refund not found for webhook")
+)
+
+// webhookResult is the outcome of processing one stored webhook event
+type webhookResult struct {
+	Event     models.WebhookEvent
+	Duplicate bool
+	Payment   *models.Payment
+	Change    utils.PaymentStatusChange
+	Refund    utils.RefundChange
 }
 
-// XenditWebhook handles Xendit payment webhook
-// @Summary Xendit payment webhook
-// @Description Handle Xendit payment webhook notifications. Automatically updates payment status, purchase status, and sends payment success email with PDF invoice via RabbitMQ when payment is successful.
+// changed reports whether applying the event changed anything
+func (r *webhookResult) changed() bool {
+	return r.Change.Changed() || r.Refund.Changed()
+}
+
+// PaymentWebhook handles payment gateway webhooks
+// @Summary Payment gateway webhook
+// @Description Handle payment notifications from a payment gateway (xendit, or fake when PAYMENT_GATEWAY=fake). Requests must carry the gateway's x-callback-token (XENDIT_CALLBACK_TOKEN for Xendit). Every delivery is stored by event ID; payment and purchase are updated in one transaction, and repeated deliveries of an event return 200 without side effects. When the payment becomes paid the draft application is submitted and the payment success email is queued.
 // @Tags Webhook
 // @Accept json
 // @Produce json
-// @Param payload body XenditWebhookPayload true "Xendit webhook payload"
+// @Param provider path string true "Payment gateway" Enums(xendit, fake)
+// @Param x-callback-token header string true "Callback verification token"
+// @Param webhook-id header string false "Xendit event ID"
+// @Param payload body payments.XenditWebhookPayload true "Xendit webhook payload"
 // @Success 200 {object} models.APIResponse "Webhook processed successfully"
 // @Failure 400 {object} models.APIResponse "Invalid webhook payload"
-// @Failure 404 {object} models.APIResponse "Payment not found"
+// @Failure 401 {object} models.APIResponse "Invalid callback token"
+// @Failure 404 {object} models.APIResponse "Unknown gateway or payment not found"
+// @Failure 422 {object} models.APIResponse "Paid amount does not match payment"
 // @Failure 500 {object} models.APIResponse "Internal server error"
-// @Router /webhooks/xendit [post]
-func XenditWebhook(c *gin.Context) {
-	var payload XenditWebhookPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+// @Router /webhooks/{provider} [post]
+func PaymentWebhook(c *gin.Context) {
+	gateway, err := payments.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Unknown payment gateway",
+			"GATEWAY_NOT_FOUND",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := gateway.VerifyWebhook(c.Request.Header); err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+			"Invalid callback token",
+			"INVALID_CALLBACK_TOKEN",
+			"Webhook could not be verified",
+		))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse(
 			"Invalid webhook payload",
 			"VALIDATION_ERROR",
@@ -46,79 +84,412 @@ func XenditWebhook(c *gin.Context) {
 		return
 	}
 
-	log.Printf("[WEBHOOK] Received Xendit webhook: ID=%s, Status=%s, ExternalID=%s", payload.ID, payload.Status, payload.ExternalID)
-
-	// Find payment by Xendit ID
-	var payment models.Payment
-	if err := config.DB.Where("xendit_id = ?", payload.ID).First(&payment).Error; err != nil {
-		log.Printf("[WEBHOOK] Payment not found for Xendit ID: %s", payload.ID)
-		c.JSON(http.StatusNotFound, models.ErrorResponse(
-			"Payment not found",
-			"PAYMENT_NOT_FOUND",
-			"Payment with this Xendit ID does not exist",
+	notification, err := gateway.ParseWebhook(c.Request.Header, body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid webhook payload",
+			"VALIDATION_ERROR",
+			err.Error(),
 		))
 		return
 	}
 
-	// Update payment status
-	oldStatus := payment.Status
-	payment.Status = payload.Status
-
-	// Handle payment success
-	if payload.Status == "PAID" || payload.Status == "paid" {
-		payment.Status = "paid"
-
-		// Update purchase status to completed
-		var purchase models.VisaPurchase
-		if err := config.DB.First(&purchase, payment.PurchaseID).Error; err == nil {
-			oldPurchaseStatus := purchase.Status
-			purchase.Status = "completed"
-			config.DB.Save(&purchase)
-
-			// Log purchase status update
-			purchaseEntityName := "Purchase #" + strconv.Itoa(int(purchase.ID))
-			oldPurchaseValues := map[string]interface{}{"status": oldPurchaseStatus}
-			newPurchaseValues := map[string]interface{}{"status": purchase.Status}
-			utils.LogUpdate(c, 0, models.EntityPurchase, purchase.ID, purchaseEntityName, oldPurchaseValues, newPurchaseValues)
-
-			// Get user details
-			var user models.User
-			if err := config.DB.First(&user, payment.UserID).Error; err == nil {
-				// Send payment success email with PDF via RabbitMQ
-				job := map[string]interface{}{
-					"purchase_id": purchase.ID,
-					"user_id":     user.ID,
-					"email":       user.Email,
-					"type":        "payment_success",
-				}
-				jobJSON, _ := json.Marshal(job)
-				if err := config.PublishMessage(config.QueueEmailPaymentSuccess, jobJSON); err != nil {
-					log.Printf("[WEBHOOK] Failed to publish payment success email job: %v", err)
-				} else {
-					log.Printf("[WEBHOOK] Payment success email job published for purchase ID: %d", purchase.ID)
-				}
-			}
+	log.Printf("[WEBHOOK] Received %s webhook: ID=%s, Status=%s, ExternalID=%s", gateway.Name(), notification.InvoiceID, notification.Status, notification.ExternalID)
+
+	eventType := strings.ToUpper(notification.Status)
+	if notification.Kind == payments.WebhookKindRefund {
+		eventType = "REFUND_" + eventType
+	}
+
+	event := models.WebhookEvent{
+		Provider:   gateway.Name(),
+		EventID:    notification.EventID,
+		EventType:  eventType,
+		ResourceID: notification.InvoiceID,
+		Payload:    string(body),
+		Status:     models.WebhookEventReceived,
+	}
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to store webhook event",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+	if result.RowsAffected == 0 {
+		if err := config.DB.Where("provider = ? AND event_id = ?", event.Provider, event.EventID).First(&event).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+				"Failed to load webhook event",
+				"DATABASE_ERROR",
+				"Please try again later",
+			))
+			return
 		}
-	} else if payload.Status == "EXPIRED" || payload.Status == "expired" {
-		payment.Status = "expired"
 	}
 
-	// Save payment status
-	config.DB.Save(&payment)
+	outcome, err := processWebhookEvent(c, event.ID, false, 0)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
 
-	// Log payment status update
-	if oldStatus != payment.Status {
-		paymentEntityName := "Payment #" + strconv.Itoa(int(payment.ID)) + " - " + payment.PaymentMethod
-		oldValues := map[string]interface{}{"status": oldStatus}
-		newValues := map[string]interface{}{"status": payment.Status}
-		utils.LogUpdate(c, 0, models.EntityPayment, payment.ID, paymentEntityName, oldValues, newValues)
+	if outcome.Duplicate {
+		log.Printf("[WEBHOOK] Duplicate delivery of event %s ignored", event.EventID)
+		c.JSON(http.StatusOK, models.SuccessResponse(
+			"Webhook already processed",
+			gin.H{
+				"event_id":  event.EventID,
+				"duplicate": true,
+			},
+		))
+		return
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse(
 		"Webhook processed successfully",
 		gin.H{
-			"payment_id": payment.ID,
-			"status":     payment.Status,
+			"event_id":   event.EventID,
+			"payment_id": outcome.Payment.ID,
+			"status":     outcome.Payment.Status,
+		},
+	))
+}
+
+// GetWebhookEvents godoc
+// @Summary List webhook events
+// @Description List stored payment gateway webhook events, newest first
+// @Tags Webhook
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (received, processed, ignored, failed)"
+// @Param provider query string false "Filter by provider"
+// @Param resource_id query string false "Filter by gateway invoice ID"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(20)
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/webhooks/events [get]
+func GetWebhookEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := config.DB.Model(&models.WebhookEvent{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if provider := c.Query("provider"); provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if resourceID := c.Query("resource_id"); resourceID != "" {
+		query = query.Where("resource_id = ?", resourceID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var events []models.WebhookEvent
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to fetch webhook events",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse(
+		"Webhook events retrieved successfully",
+		events,
+		page,
+		perPage,
+		int(total),
+	))
+}
+
+// GetWebhookEvent godoc
+// @Summary Get webhook event
+// @Description Get a stored webhook event including its raw payload
+// @Tags Webhook
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook event ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/webhooks/events/{id} [get]
+func GetWebhookEvent(c *gin.Context) {
+	event, ok := findWebhookEvent(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Webhook event retrieved successfully",
+		event,
+	))
+}
+
+// ReplayWebhookEvent godoc
+// @Summary Replay webhook event
+// @Description Process a stored webhook event again, e.g. after fixing the cause of a failure. Replays are safe: a payment that already reached the reported status is left untouched and no email is sent twice.
+// @Tags Webhook
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook event ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 422 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/webhooks/events/{id}/replay [post]
+func ReplayWebhookEvent(c *gin.Context) {
+	event, ok := findWebhookEvent(c)
+	if !ok {
+		return
+	}
+
+	if _, err := payments.Get(event.Provider); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Unsupported provider",
+			"UNSUPPORTED_PROVIDER",
+			"Events from "+event.Provider+" cannot be replayed",
+		))
+		return
+	}
+
+	actorID := utils.GetUserIDFromContextWithDefault(c)
+	outcome, err := processWebhookEvent(c, event.ID, true, actorID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Webhook event replayed successfully",
+		gin.H{
+			"event":          outcome.Event,
+			"payment_id":     outcome.Payment.ID,
+			"payment_status": outcome.Payment.Status,
+			"changed":        outcome.changed(),
 		},
 	))
 }
+
+// processWebhookEvent applies a stored gateway event. The event row and the
+// payment are locked and updated together with the purchase in one
+// transaction; emails and activity logs follow only after it commits, and
+// only for changes this call actually made. Events that were already handled
+// are reported as duplicates unless replay is set.
+func processWebhookEvent(c *gin.Context, eventID uint, replay bool, actorID uint) (*webhookResult, error) {
+	outcome := &webhookResult{}
+	var processErr error
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		event := &outcome.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(event, eventID).Error; err != nil {
+			return err
+		}
+
+		if !replay && (event.Status == models.WebhookEventProcessed || event.Status == models.WebhookEventIgnored) {
+			outcome.Duplicate = true
+			return nil
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{"attempts": event.Attempts + 1}
+		if replay {
+			updates["last_replayed_at"] = now
+			updates["last_replayed_by_id"] = actorID
+		}
+
+		processErr = applyWebhookEvent(tx, event, outcome)
+		if processErr != nil {
+			if !errors.Is(processErr, errWebhookPaymentNotFound) && !errors.Is(processErr, errWebhookRefundNotFound) &&
+				!errors.Is(processErr, utils.ErrPaymentAmountMismatch) {
+				return processErr
+			}
+			// Keep the failure on record; the event can be replayed once resolved
+			updates["status"] = models.WebhookEventFailed
+			updates["error"] = processErr.Error()
+		} else {
+			updates["status"] = models.WebhookEventProcessed
+			if !outcome.changed() {
+				updates["status"] = models.WebhookEventIgnored
+			}
+			updates["error"] = ""
+			updates["processed_at"] = now
+		}
+
+		if err := tx.Model(event).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(event, event.ID).Error
+	})
+	if err != nil {
+		// The transaction rolled back, so record the failure separately
+		config.DB.Model(&models.WebhookEvent{}).Where("id = ?", eventID).Updates(map[string]interface{}{
+			"status":   models.WebhookEventFailed,
+			"error":    err.Error(),
+			"attempts": gorm.Expr("attempts + 1"),
+		})
+		log.Printf("[WEBHOOK] Failed to process event %d: %v", eventID, err)
+		return nil, err
+	}
+	if processErr != nil {
+		log.Printf("[WEBHOOK] Event %d not applied: %v", eventID, processErr)
+		return nil, processErr
+	}
+	if outcome.Duplicate {
+		return outcome, nil
+	}
+
+	utils.RecordPaymentStatusChange(c, actorID, outcome.Payment, outcome.Change)
+	applyRefundEffects(c, actorID, outcome.Refund)
+	return outcome, nil
+}
+
+// applyWebhookEvent updates the payment, refund and purchase referenced by a gateway event
+func applyWebhookEvent(tx *gorm.DB, event *models.WebhookEvent, outcome *webhookResult) error {
+	gateway, err := payments.Get(event.Provider)
+	if err != nil {
+		return err
+	}
+
+	// Stored events were verified on receipt, so only the body is parsed again
+	notification, err := gateway.ParseWebhook(nil, []byte(event.Payload))
+	if err != nil {
+		return err
+	}
+
+	if notification.Kind == payments.WebhookKindRefund {
+		return applyRefundWebhookEvent(tx, event, notification, outcome)
+	}
+
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("gateway = ? AND xendit_id = ?", event.Provider, notification.InvoiceID).
+		First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", errWebhookPaymentNotFound, notification.InvoiceID)
+		}
+		return err
+	}
+	outcome.Payment = &payment
+
+	change, err := utils.ApplyGatewayPaymentStatus(tx, &payment, notification.Status, notification.PaidAmount, "Payment received via "+gateway.Name())
+	if err != nil {
+		return err
+	}
+	outcome.Change = change
+	return nil
+}
+
+// applyRefundWebhookEvent settles the refund a gateway refund event reports on
+func applyRefundWebhookEvent(tx *gorm.DB, event *models.WebhookEvent, notification *payments.WebhookEvent, outcome *webhookResult) error {
+	refund, err := findRefundForWebhook(tx, event.Provider, notification)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", errWebhookRefundNotFound, notification.RefundID)
+		}
+		return err
+	}
+
+	if refund.GatewayRefundID == "" && notification.RefundID != "" {
+		if err := tx.Model(refund).Update("gateway_refund_id", notification.RefundID).Error; err != nil {
+			return err
+		}
+	}
+
+	change, err := utils.ApplyRefundStatus(tx, refund, notification.Status, notification.FailureReason, refund.RequestedByID)
+	if err != nil {
+		return err
+	}
+	outcome.Refund = change
+
+	var payment models.Payment
+	if err := tx.First(&payment, refund.PaymentID).Error; err != nil {
+		return err
+	}
+	outcome.Payment = &payment
+	return nil
+}
+
+func respondWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errWebhookRefundNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Refund not found",
+			"REFUND_NOT_FOUND",
+			"No refund matches this gateway refund",
+		))
+	case errors.Is(err, errWebhookPaymentNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse(
+			"Payment not found",
+			"PAYMENT_NOT_FOUND",
+			"No payment matches this gateway invoice",
+		))
+	case errors.Is(err, utils.ErrPaymentAmountMismatch):
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse(
+			"Paid amount does not match payment",
+			"AMOUNT_MISMATCH",
+			err.Error(),
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Failed to process webhook",
+			"WEBHOOK_ERROR",
+			"Please try again later",
+		))
+	}
+}
+
+// findWebhookEvent loads the webhook event from the :id path parameter, writing the error response otherwise
+func findWebhookEvent(c *gin.Context) (models.WebhookEvent, bool) {
+	var event models.WebhookEvent
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse(
+			"Invalid webhook event ID",
+			"INVALID_ID",
+			"Webhook event ID must be a valid number",
+		))
+		return event, false
+	}
+
+	if err := config.DB.First(&event, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse(
+				"Webhook event not found",
+				"WEBHOOK_EVENT_NOT_FOUND",
+				"Webhook event with this ID does not exist",
+			))
+			return event, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+			"Database error",
+			"DATABASE_ERROR",
+			"Please try again later",
+		))
+		return event, false
+	}
+
+	return event, true
+}
@@ -0,0 +1,9 @@
+package i18n
+
+// catalog maps each language to its messages. Keys are dotted, e.g.
+// "email.otp.subject"; API errors use "error." followed by the error code,
+// which stays stable when the English message is reworded or formatted.
+var catalog = map[Language]map[string]string{
+	English:    english,
+	Indonesian: indonesian,
+}
@@ -0,0 +1,127 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Language is a supported language, as an ISO 639-1 code
+type Language string
+
+const (
+	Indonesian Language = "id"
+	English    Language = "en"
+)
+
+// Languages lists the supported languages
+var Languages = []Language{Indonesian, English}
+
+// Default is used for customer emails and PDFs when no supported language was
+// asked for; most customers are Indonesian. API errors default to English.
+const Default = Indonesian
+
+// Parse returns the supported language of a tag like "id", "en-US" or "EN"
+func Parse(tag string) (Language, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	switch lang := Language(tag); lang {
+	case Indonesian, English:
+		return lang, true
+	}
+	return "", false
+}
+
+// Of returns the supported language of tag, or Default
+func Of(tag string) Language {
+	if lang, ok := Parse(tag); ok {
+		return lang
+	}
+	return Default
+}
+
+// Negotiate picks the supported language the client prefers most from an
+// Accept-Language header, e.g. "en-US,en;q=0.9,id;q=0.8", or Default
+func Negotiate(acceptLanguage string) Language {
+	if lang, ok := Match(acceptLanguage); ok {
+		return lang
+	}
+	return Default
+}
+
+// Match picks the supported language the client prefers most from an
+// Accept-Language header, and reports whether it asked for any
+func Match(acceptLanguage string) (Language, bool) {
+	type candidate struct {
+		lang    Language
+		quality float64
+		order   int
+	}
+	var candidates []candidate
+	for i, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		lang, ok := Parse(tag)
+		if !ok {
+			continue
+		}
+		quality := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{lang, quality, i})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].lang, true
+}
+
+// T returns the message for key in lang, formatted with args like fmt.Sprintf.
+// Messages missing in lang fall back to English, and to the key itself.
+func T(lang Language, key string, args ...interface{}) string {
+	message, ok := catalog[lang][key]
+	if !ok {
+		if message, ok = catalog[English][key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Lookup returns the message for key in lang only, without falling back to English
+func Lookup(lang Language, key string) (string, bool) {
+	message, ok := catalog[lang][key]
+	return message, ok
+}
+
+var indonesianMonths = [...]string{
+	"Januari", "Februari", "Maret", "April", "Mei", "Juni",
+	"Juli", "Agustus", "September", "Oktober", "November", "Desember",
+}
+
+// FormatDate formats a date as "January 2, 2006" or "2 Januari 2006"
+func FormatDate(lang Language, t time.Time) string {
+	if lang == Indonesian {
+		return fmt.Sprintf("%d %s %d", t.Day(), indonesianMonths[t.Month()-1], t.Year())
+	}
+	return t.Format("January 2, 2006")
+}
+
+// FormatDateTime formats a date and time with the time zone
+func FormatDateTime(lang Language, t time.Time) string {
+	return FormatDate(lang, t) + " " + t.Format("15:04 MST")
+}
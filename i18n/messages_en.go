@@ -0,0 +1,102 @@
+package i18n
+
+// english holds every key; other languages fall back to it
+var english = map[string]string{
+	// Shared email text
+	"email.dear":                 "Dear %s,",
+	"email.hello":                "Hello %s,",
+	"email.hello.anyone":         "Hello,",
+	"email.regards":              "Best regards,",
+	"email.team":                 "Viskatera Team",
+	"email.footer":               "This is an automated email, please do not reply.",
+	"email.copy_link":            "Or copy and paste this link into your browser:",
+	"email.field.visa":           "Visa",
+	"email.field.total":          "Total Price",
+	"email.field.status":         "Status",
+	"email.field.purchase":       "Purchase ID",
+	"email.field.paid":           "Amount Paid",
+	"email.field.method":         "Payment Method",
+	"email.field.refunded":       "Amount Refunded",
+	"email.field.total_refunded": "Total Refunded",
+	"email.field.reason":         "Reason",
+
+	"email.invoice.subject":    "Invoice for Visa Purchase - %s",
+	"email.invoice.title":      "Invoice for Visa Purchase",
+	"email.invoice.intro":      "Thank you for your purchase. Here are your order details:",
+	"email.invoice.pay_link":   "Payment Link:",
+	"email.invoice.pay_action": "Click here to pay",
+	"email.invoice.pay_here":   "Pay here: %s",
+	"email.invoice.discount":   "%s after %s discount (%s)",
+	"email.invoice.converted":  "%s (%s at 1 %s = %s %s)",
+
+	"email.payment_success.subject": "Payment Successful - Invoice %s",
+	"email.payment_success.title":   "Payment Successful!",
+	"email.payment_success.intro":   "Your payment has been successfully processed. Please find your invoice %s attached.",
+	"email.payment_success.outro":   "Your visa application is now being processed. We will notify you once it's ready.",
+
+	"email.invite.subject": "You're invited to join Viskatera",
+	"email.invite.title":   "You're invited to Viskatera",
+	"email.invite.intro":   "%s has invited you to join Viskatera as %s. Open the link below to set your password and activate your account:",
+	"email.invite.action":  "Accept invitation",
+	"email.invite.expires": "This invitation expires on %s.",
+
+	"email.refund.subject": "Refund Processed - Purchase #%d",
+	"email.refund.title":   "Refund Processed",
+	"email.refund.intro":   "We have refunded your payment for the visa application below. Depending on your bank or payment provider it may take a few days before the money is back in your account.",
+
+	"email.otp.subject": "Your Login OTP Code",
+	"email.otp.title":   "Your OTP Code",
+	"email.otp.intro":   "Your OTP code for login is:",
+	"email.otp.expires": "This code will expire in %d minutes.",
+	"email.otp.ignore":  "If you didn't request this code, please ignore this email.",
+
+	"email.password_reset.subject": "Password Reset Request",
+	"email.password_reset.intro":   "You have requested to reset your password. Open the link below to reset it:",
+	"email.password_reset.action":  "Reset Password",
+	"email.password_reset.expires": "This link will expire in %s.",
+	"email.password_reset.ignore":  "If you didn't request a password reset, please ignore this email.",
+
+	"email.password_reset_forced.subject": "Password Reset Required",
+	"email.password_reset_forced.intro":   "An administrator has reset the password of your account and signed you out of all devices. Open the link below to choose a new password:",
+	"email.password_reset_forced.action":  "Choose New Password",
+
+	"duration.minutes": "%d minutes",
+	"duration.hours":   "%d hours",
+
+	// Purchase statuses, as shown to customers
+	"purchase.status.draft":                "Awaiting payment",
+	"purchase.status.submitted":            "Submitted",
+	"purchase.status.documents_review":     "Documents under review",
+	"purchase.status.submitted_to_embassy": "Submitted to embassy",
+	"purchase.status.approved":             "Approved",
+	"purchase.status.rejected":             "Rejected",
+	"purchase.status.issued":               "Issued",
+	"purchase.status.cancelled":            "Cancelled",
+	"purchase.status.refunded":             "Refunded",
+
+	// Invoice and credit note PDFs
+	"pdf.invoice":         "Invoice",
+	"pdf.credit_note":     "Credit Note",
+	"pdf.number":          "%s Number: %s",
+	"pdf.date":            "Date: %s",
+	"pdf.bill_to":         "Bill To:",
+	"pdf.applicants":      "Applicants:",
+	"pdf.full_name":       "Full Name",
+	"pdf.passport_number": "Passport Number",
+	"pdf.nationality":     "Nationality",
+	"pdf.travel_date":     "Travel Date: %s",
+	"pdf.description":     "Description",
+	"pdf.quantity":        "Quantity",
+	"pdf.price":           "Price",
+	"pdf.total":           "Total",
+	"pdf.subtotal":        "Subtotal:",
+	"pdf.grand_total":     "Total:",
+	"pdf.converted":       "Prices converted from %s at 1 %s = %s %s",
+	"pdf.payment_method":  "Payment Method: %s",
+	"pdf.status":          "Status: %s",
+	"pdf.status.paid":     "paid",
+	"pdf.status.refunded": "refunded",
+	"pdf.thanks":          "Thank you for your business!",
+	"pdf.refund_for":      "Refund for purchase #%d",
+	"pdf.credits_invoice": "Credits invoice %s",
+}
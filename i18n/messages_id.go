@@ -0,0 +1,227 @@
+package i18n
+
+// indonesian holds the Indonesian messages. API errors are only translated
+// here: English error messages come from the handlers themselves, and codes
+// missing here keep the English message.
+var indonesian = map[string]string{
+	// Shared email text
+	"email.dear":                 "Yth. %s,",
+	"email.hello":                "Halo %s,",
+	"email.hello.anyone":         "Halo,",
+	"email.regards":              "Salam hangat,",
+	"email.team":                 "Tim Viskatera",
+	"email.footer":               "Email ini dikirim otomatis, mohon tidak membalas.",
+	"email.copy_link":            "Atau salin dan tempel tautan ini di browser Anda:",
+	"email.field.visa":           "Visa",
+	"email.field.total":          "Total Harga",
+	"email.field.status":         "Status",
+	"email.field.purchase":       "ID Pembelian",
+	"email.field.paid":           "Jumlah Dibayar",
+	"email.field.method":         "Metode Pembayaran",
+	"email.field.refunded":       "Jumlah Dikembalikan",
+	"email.field.total_refunded": "Total Dikembalikan",
+	"email.field.reason":         "Alasan",
+
+	"email.invoice.subject":    "Tagihan Pembelian Visa - %s",
+	"email.invoice.title":      "Tagihan Pembelian Visa",
+	"email.invoice.intro":      "Terima kasih atas pembelian Anda. Berikut rincian pesanan Anda:",
+	"email.invoice.pay_link":   "Tautan Pembayaran:",
+	"email.invoice.pay_action": "Klik di sini untuk membayar",
+	"email.invoice.pay_here":   "Bayar di sini: %s",
+	"email.invoice.discount":   "%s setelah diskon %s (%s)",
+	"email.invoice.converted":  "%s (%s dengan kurs 1 %s = %s %s)",
+
+	"email.payment_success.subject": "Pembayaran Berhasil - Faktur %s",
+	"email.payment_success.title":   "Pembayaran Berhasil!",
+	"email.payment_success.intro":   "Pembayaran Anda telah berhasil diproses. Faktur %s terlampir pada email ini.",
+	"email.payment_success.outro":   "Permohonan visa Anda sedang diproses. Kami akan memberi tahu Anda setelah selesai.",
+
+	"email.invite.subject": "Undangan bergabung dengan Viskatera",
+	"email.invite.title":   "Anda diundang ke Viskatera",
+	"email.invite.intro":   "%s mengundang Anda bergabung dengan Viskatera sebagai %s. Buka tautan di bawah untuk membuat kata sandi dan mengaktifkan akun Anda:",
+	"email.invite.action":  "Terima undangan",
+	"email.invite.expires": "Undangan ini berlaku hingga %s.",
+
+	"email.refund.subject": "Pengembalian Dana Diproses - Pembelian #%d",
+	"email.refund.title":   "Pengembalian Dana Diproses",
+	"email.refund.intro":   "Kami telah mengembalikan pembayaran Anda untuk permohonan visa di bawah ini. Tergantung bank atau penyedia pembayaran Anda, dana mungkin baru masuk ke rekening Anda dalam beberapa hari.",
+
+	"email.otp.subject": "Kode OTP Login Anda",
+	"email.otp.title":   "Kode OTP Anda",
+	"email.otp.intro":   "Kode OTP untuk login Anda adalah:",
+	"email.otp.expires": "Kode ini berlaku selama %d menit.",
+	"email.otp.ignore":  "Jika Anda tidak meminta kode ini, abaikan email ini.",
+
+	"email.password_reset.subject": "Permintaan Atur Ulang Kata Sandi",
+	"email.password_reset.intro":   "Anda meminta untuk mengatur ulang kata sandi. Buka tautan di bawah untuk mengaturnya ulang:",
+	"email.password_reset.action":  "Atur Ulang Kata Sandi",
+	"email.password_reset.expires": "Tautan ini berlaku selama %s.",
+	"email.password_reset.ignore":  "Jika Anda tidak meminta pengaturan ulang kata sandi, abaikan email ini.",
+
+	"email.password_reset_forced.subject": "Kata Sandi Perlu Diatur Ulang",
+	"email.password_reset_forced.intro":   "Administrator telah mengatur ulang kata sandi akun Anda dan mengeluarkan Anda dari semua perangkat. Buka tautan di bawah untuk memilih kata sandi baru:",
+	"email.password_reset_forced.action":  "Pilih Kata Sandi Baru",
+
+	"duration.minutes": "%d menit",
+	"duration.hours":   "%d jam",
+
+	// Purchase statuses, as shown to customers
+	"purchase.status.draft":                "Menunggu pembayaran",
+	"purchase.status.submitted":            "Diajukan",
+	"purchase.status.documents_review":     "Dokumen sedang diperiksa",
+	"purchase.status.submitted_to_embassy": "Diajukan ke kedutaan",
+	"purchase.status.approved":             "Disetujui",
+	"purchase.status.rejected":             "Ditolak",
+	"purchase.status.issued":               "Terbit",
+	"purchase.status.cancelled":            "Dibatalkan",
+	"purchase.status.refunded":             "Dana dikembalikan",
+
+	// Invoice and credit note PDFs
+	"pdf.invoice":         "Faktur",
+	"pdf.credit_note":     "Nota Kredit",
+	"pdf.number":          "Nomor %s: %s",
+	"pdf.date":            "Tanggal: %s",
+	"pdf.bill_to":         "Ditagihkan Kepada:",
+	"pdf.applicants":      "Pemohon:",
+	"pdf.full_name":       "Nama Lengkap",
+	"pdf.passport_number": "Nomor Paspor",
+	"pdf.nationality":     "Kewarganegaraan",
+	"pdf.travel_date":     "Tanggal Perjalanan: %s",
+	"pdf.description":     "Deskripsi",
+	"pdf.quantity":        "Jumlah",
+	"pdf.price":           "Harga",
+	"pdf.total":           "Total",
+	"pdf.subtotal":        "Subtotal:",
+	"pdf.grand_total":     "Total:",
+	"pdf.converted":       "Harga dikonversi dari %s dengan kurs 1 %s = %s %s",
+	"pdf.payment_method":  "Metode Pembayaran: %s",
+	"pdf.status":          "Status: %s",
+	"pdf.status.paid":     "lunas",
+	"pdf.status.refunded": "dikembalikan",
+	"pdf.thanks":          "Terima kasih atas kepercayaan Anda!",
+	"pdf.refund_for":      "Pengembalian dana pembelian #%d",
+	"pdf.credits_invoice": "Mengkredit faktur %s",
+
+	// API error messages by error code. Codes shared by several errors get a
+	// message that fits all of them; details stay in English.
+	"error.ACCESS_DENIED":                   "Akses ditolak",
+	"error.AMOUNT_MISMATCH":                 "Jumlah yang dibayar tidak sesuai dengan tagihan",
+	"error.APPLICANT_CREATION_ERROR":        "Gagal menambahkan pemohon",
+	"error.APPLICANT_DELETE_ERROR":          "Gagal menghapus pemohon",
+	"error.APPLICANT_NOT_FOUND":             "Pemohon tidak ditemukan",
+	"error.APPLICANT_UPDATE_ERROR":          "Gagal memperbarui pemohon",
+	"error.CANNOT_MODIFY_SELF":              "Tidak dapat mengubah akses sendiri",
+	"error.DATABASE_ERROR":                  "Terjadi kesalahan pada basis data, silakan coba lagi",
+	"error.DIRECTORY_ERROR":                 "Gagal membuat direktori unggahan",
+	"error.DOCUMENTS_INCOMPLETE":            "Dokumen yang diperlukan belum disetujui",
+	"error.DOCUMENT_ALREADY_APPROVED":       "Dokumen sudah disetujui",
+	"error.DOCUMENT_NOT_FOUND":              "Dokumen tidak ditemukan",
+	"error.EMAIL_LOG_NOT_FOUND":             "Log email tidak ditemukan",
+	"error.EXCEL_ERROR":                     "Gagal membuat file Excel",
+	"error.EXCHANGE_RATE_IN_USE":            "Kurs sedang digunakan",
+	"error.EXCHANGE_RATE_NOT_FOUND":         "Kurs tidak ditemukan",
+	"error.EXCHANGE_RATE_UNAVAILABLE":       "Kurs mata uang belum tersedia, silakan coba lagi nanti",
+	"error.FAKE_GATEWAY_DISABLED":           "Gateway pembayaran palsu tidak aktif",
+	"error.FILE_ERROR":                      "Gagal memproses file",
+	"error.FILE_NOT_FOUND":                  "File tidak ditemukan",
+	"error.FILE_TOO_LARGE":                  "Ukuran file terlalu besar",
+	"error.GATEWAY_NOT_FOUND":               "Gateway pembayaran tidak dikenal",
+	"error.HASH_ERROR":                      "Gagal memproses kata sandi",
+	"error.IDEMPOTENCY_KEY_MISMATCH":        "Idempotency-Key sudah digunakan untuk permintaan lain",
+	"error.IDEMPOTENCY_REQUEST_IN_PROGRESS": "Permintaan dengan Idempotency-Key ini sedang diproses",
+	"error.IDEMPOTENCY_UNAVAILABLE":         "Pemeriksaan Idempotency-Key sedang tidak tersedia",
+	"error.INVALID_AUTH_FORMAT":             "Format header Authorization tidak valid",
+	"error.INVALID_CALLBACK_TOKEN":          "Token callback tidak valid",
+	"error.INVALID_CLAIMS":                  "Token tidak valid",
+	"error.INVALID_CREDENTIALS":             "Email atau kata sandi salah",
+	"error.INVALID_CURRENCY":                "Mata uang tidak didukung",
+	"error.INVALID_EXCHANGE_RATE_FILE":      "File kurs tidak valid",
+	"error.INVALID_FILE_TYPE":               "Jenis file tidak didukung",
+	"error.INVALID_ID":                      "ID tidak valid",
+	"error.INVALID_IDEMPOTENCY_KEY":         "Idempotency-Key tidak valid",
+	"error.INVALID_INVITE":                  "Undangan tidak valid atau telah kedaluwarsa",
+	"error.INVALID_OTP":                     "Kode OTP tidak valid atau telah kedaluwarsa",
+	"error.INVALID_OUTCOME":                 "Hasil tidak valid",
+	"error.INVALID_PASSWORD":                "Kata sandi saat ini salah",
+	"error.INVALID_PATH":                    "Path file tidak valid",
+	"error.INVALID_PERMISSION":              "Izin tidak valid",
+	"error.INVALID_PURCHASE_STATUS":         "Status pembelian tidak memungkinkan tindakan ini",
+	"error.INVALID_REFRESH_TOKEN":           "Refresh token tidak valid",
+	"error.INVALID_ROLE":                    "Peran tidak valid",
+	"error.INVALID_SETTLEMENT_FILE":         "File settlement tidak valid",
+	"error.INVALID_STATE":                   "Sesi login Google tidak valid, silakan coba lagi",
+	"error.INVALID_STATUS":                  "Status pembelian tidak valid",
+	"error.INVALID_STATUS_TRANSITION":       "Perubahan status ini tidak diperbolehkan",
+	"error.INVALID_TOKEN":                   "Token tidak valid atau telah kedaluwarsa",
+	"error.INVALID_USER_ID":                 "Token tidak valid",
+	"error.INVITE_NOT_FOUND":                "Undangan tidak ditemukan",
+	"error.INVITE_NOT_PENDING":              "Undangan tidak dapat dicabut",
+	"error.INVITE_PENDING":                  "Undangan masih menunggu",
+	"error.INVOICE_NOT_FOUND":               "Faktur tidak ditemukan",
+	"error.INVOICE_NOT_PENDING":             "Tagihan ini sudah tidak menunggu pembayaran",
+	"error.LAST_ADMIN":                      "Tidak dapat mengubah akses admin terakhir",
+	"error.MESSAGE_NOT_FOUND":               "Pesan tidak ditemukan",
+	"error.MISSING_AUTH_HEADER":             "Header Authorization wajib diisi",
+	"error.NOT_READY":                       "Worker belum siap",
+	"error.OAUTH_ERROR":                     "Login dengan Google gagal",
+	"error.OTP_EXPIRED":                     "Kode OTP telah kedaluwarsa",
+	"error.OTP_GENERATION_ERROR":            "Gagal membuat kode OTP",
+	"error.PASSPORT_EXPIRY_TOO_SOON":        "Masa berlaku paspor terlalu singkat untuk tanggal perjalanan",
+	"error.PASSWORD_HASH_ERROR":             "Gagal memproses kata sandi",
+	"error.PAYMENT_ERROR":                   "Gateway pembayaran menolak permintaan",
+	"error.PAYMENT_GATEWAY_ERROR":           "Gagal terhubung ke gateway pembayaran",
+	"error.PAYMENT_GATEWAY_UNAVAILABLE":     "Gateway pembayaran sedang tidak tersedia",
+	"error.PAYMENT_NOT_FOUND":               "Pembayaran tidak ditemukan",
+	"error.PAYMENT_NOT_REFUNDABLE":          "Pembayaran tidak dapat dikembalikan",
+	"error.PDF_ERROR":                       "Gagal membuat PDF",
+	"error.PERMISSION_DENIED":               "Anda tidak memiliki izin untuk tindakan ini",
+	"error.PERMISSION_OVERRIDE_NOT_FOUND":   "Pengecualian izin tidak ditemukan",
+	"error.PRICING_RULE_NOT_FOUND":          "Aturan harga tidak ditemukan",
+	"error.PROMO_CODE_ALREADY_USED":         "Anda sudah menggunakan kode promo ini",
+	"error.PROMO_CODE_EXHAUSTED":            "Kuota kode promo telah habis",
+	"error.PROMO_CODE_EXISTS":               "Kode promo sudah ada",
+	"error.PROMO_CODE_INACTIVE":             "Kode promo tidak aktif",
+	"error.PROMO_CODE_INVALID":              "Kode promo tidak ditemukan",
+	"error.PROMO_CODE_NOT_APPLICABLE":       "Kode promo tidak berlaku untuk pembelian ini",
+	"error.PROMO_CODE_NOT_FOUND":            "Kode promo tidak ditemukan",
+	"error.PROMO_MIN_SPEND_NOT_MET":         "Pembelian belum memenuhi minimum untuk kode promo ini",
+	"error.PURCHASE_CREATION_ERROR":         "Gagal membuat pembelian",
+	"error.PURCHASE_NOT_FOUND":              "Pembelian tidak ditemukan",
+	"error.PURCHASE_UPDATE_ERROR":           "Gagal memperbarui pembelian",
+	"error.QUEUE_ERROR":                     "Gagal mengambil statistik antrean",
+	"error.QUEUE_NOT_FOUND":                 "Antrean tidak ditemukan",
+	"error.QUEUE_UNAVAILABLE":               "Antrean sedang tidak tersedia",
+	"error.RECONCILIATION_NOT_FOUND":        "Proses rekonsiliasi tidak ditemukan",
+	"error.REFRESH_TOKEN_REUSED":            "Refresh token sudah pernah digunakan, silakan login kembali",
+	"error.REFUND_AMOUNT_EXCEEDED":          "Jumlah pengembalian dana terlalu besar",
+	"error.REFUND_NOT_FOUND":                "Pengembalian dana tidak ditemukan",
+	"error.REFUND_NOT_PENDING":              "Pengembalian dana tidak sedang diproses",
+	"error.REQUIREMENT_CREATION_ERROR":      "Gagal membuat persyaratan",
+	"error.REQUIREMENT_DELETE_ERROR":        "Gagal menghapus persyaratan",
+	"error.REQUIREMENT_NOT_FOUND":           "Persyaratan tidak ditemukan",
+	"error.REQUIREMENT_UPDATE_ERROR":        "Gagal memperbarui persyaratan",
+	"error.SAVE_ERROR":                      "Gagal menyimpan file",
+	"error.SESSION_REVOKED":                 "Sesi Anda telah berakhir, silakan login kembali",
+	"error.TOKEN_ERROR":                     "Gagal membuat token",
+	"error.TOKEN_EXPIRED":                   "Token telah kedaluwarsa",
+	"error.TOKEN_GENERATION_ERROR":          "Gagal membuat token",
+	"error.UNAUTHORIZED":                    "Anda harus login terlebih dahulu",
+	"error.UNSUPPORTED_PROVIDER":            "Penyedia login tidak didukung",
+	"error.USER_ALREADY_ACTIVE":             "Pengguna sudah aktif",
+	"error.USER_ALREADY_INACTIVE":           "Pengguna sudah tidak aktif",
+	"error.USER_CREATION_ERROR":             "Gagal membuat pengguna",
+	"error.USER_EXISTS":                     "Email sudah terdaftar",
+	"error.USER_NOT_FOUND":                  "Pengguna tidak ditemukan",
+	"error.VALIDATION_ERROR":                "Data yang dikirim tidak valid",
+	"error.VISA_CREATION_ERROR":             "Gagal membuat visa",
+	"error.VISA_DELETE_ERROR":               "Gagal menghapus visa",
+	"error.VISA_NOT_FOUND":                  "Visa tidak ditemukan",
+	"error.VISA_OPTION_CREATION_ERROR":      "Gagal membuat opsi visa",
+	"error.VISA_OPTION_DELETE_ERROR":        "Gagal menghapus opsi visa",
+	"error.VISA_OPTION_EXISTS":              "Opsi visa sudah ada",
+	"error.VISA_OPTION_NOT_FOUND":           "Opsi visa tidak ditemukan",
+	"error.VISA_OPTION_UPDATE_ERROR":        "Gagal memperbarui opsi visa",
+	"error.VISA_UPDATE_ERROR":               "Gagal memperbarui visa",
+	"error.WEBHOOK_ERROR":                   "Gagal memproses webhook",
+	"error.WEBHOOK_EVENT_NOT_FOUND":         "Event webhook tidak ditemukan",
+}
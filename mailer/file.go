@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes each email as an .eml file instead of sending it, for
+// local development and staging
+type FileMailer struct {
+	dir  string
+	from mail.Address
+}
+
+// NewFileMailer writes emails to dir, storage/emails when empty
+func NewFileMailer(dir string) *FileMailer {
+	if dir == "" {
+		dir = "storage/emails"
+	}
+	return &FileMailer{dir: dir, from: sender()}
+}
+
+func (m *FileMailer) Name() string { return TransportFile }
+
+// Send writes msg to <dir>/<time>-<id>.eml and returns its Message-ID
+func (m *FileMailer) Send(ctx context.Context, msg Message) (string, error) {
+	messageID := newMessageID(m.from.Address)
+	data, err := msg.build(m.from, messageID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	id := strings.Trim(messageID, "<>")
+	id = id[:strings.Index(id, "@")]
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), id)
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write email: %w", err)
+	}
+	return messageID, nil
+}
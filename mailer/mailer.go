@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"sync"
+	"viskatera-api-go/config"
+	"viskatera-api-go/i18n"
+	"viskatera-api-go/models"
+)
+
+// Transport names, recorded on each email log
+const (
+	TransportSMTP   = "smtp"
+	TransportFile   = "file"
+	TransportMemory = "memory"
+)
+
+// Message is a rendered email. HTML and Text are alternatives of the same
+// content; mail clients show the one they support.
+type Message struct {
+	To          string
+	Subject     string
+	HTML        string
+	Text        string
+	Template    string // template the message was rendered from, for the email log
+	Attachments []Attachment
+}
+
+// Attachment is a file sent along with a message
+type Attachment struct {
+	Path        string // read when the message is sent
+	Filename    string // defaults to the base name of Path
+	ContentType string // defaults to application/pdf
+}
+
+// Mailer is a mail transport
+type Mailer interface {
+	Name() string
+	// Send delivers msg and returns the provider's ID for it
+	Send(ctx context.Context, msg Message) (string, error)
+}
+
+var (
+	mu        sync.RWMutex
+	transport Mailer
+)
+
+// Init sets up the mail transport selected by MAIL_TRANSPORT: smtp (default),
+// file, which writes .eml files to MAIL_FILE_DIR, or memory, which keeps sent
+// messages in the process
+func Init() {
+	var m Mailer
+	switch name := strings.ToLower(os.Getenv("MAIL_TRANSPORT")); name {
+	case "", TransportSMTP:
+		m = NewSMTPMailer()
+	case TransportFile:
+		m = NewFileMailer(os.Getenv("MAIL_FILE_DIR"))
+		log.Println("Using file mail transport: emails are written to disk, not sent")
+	case TransportMemory:
+		m = NewMemoryMailer()
+		log.Println("Using memory mail transport: emails are kept in memory, not sent")
+	default:
+		log.Printf("Warning: unknown MAIL_TRANSPORT %q, using smtp", name)
+		m = NewSMTPMailer()
+	}
+	SetDefault(m)
+}
+
+// SetDefault replaces the mail transport, e.g. with a MemoryMailer in tests
+func SetDefault(m Mailer) {
+	mu.Lock()
+	defer mu.Unlock()
+	transport = m
+}
+
+// Default returns the mail transport in use
+func Default() Mailer {
+	mu.RLock()
+	m := transport
+	mu.RUnlock()
+	if m == nil {
+		Init()
+		return Default()
+	}
+	return m
+}
+
+// sender is the From address of every email: SMTP_FROM, named SMTP_FROM_NAME
+func sender() mail.Address {
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "noreply@viskatera.com"
+	}
+	name := os.Getenv("SMTP_FROM_NAME")
+	if name == "" {
+		name = "Viskatera"
+	}
+	return mail.Address{Name: name, Address: from}
+}
+
+// Send sends msg through the configured transport and records the attempt in
+// the email log, whether it succeeded or not
+func Send(ctx context.Context, msg Message) error {
+	m := Default()
+	messageID, err := m.Send(ctx, msg)
+	recordEmail(ctx, m.Name(), msg, messageID, err)
+	if err != nil {
+		log.Printf("[EMAIL-ERROR] Failed to send %s email to %s: %v", msg.Template, msg.To, err)
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	log.Printf("[EMAIL] %s email sent successfully to %s", msg.Template, msg.To)
+	return nil
+}
+
+// SendTemplate renders a template in lang and sends the result
+func SendTemplate(ctx context.Context, to string, lang i18n.Language, name string, data interface{}, attachments ...Attachment) error {
+	msg, err := NewMessage(to, lang, name, data)
+	if err != nil {
+		return err
+	}
+	msg.Attachments = attachments
+	return Send(ctx, msg)
+}
+
+// recordEmail writes the email log row. It outlives ctx so failures caused by
+// a cancelled job are recorded too.
+func recordEmail(ctx context.Context, transportName string, msg Message, messageID string, sendErr error) {
+	if config.DB == nil {
+		return
+	}
+	entry := models.EmailLog{
+		Recipient:         msg.To,
+		Subject:           msg.Subject,
+		Template:          msg.Template,
+		Transport:         transportName,
+		Status:            models.EmailStatusSent,
+		ProviderMessageID: messageID,
+		Attachments:       len(msg.Attachments),
+	}
+	if sendErr != nil {
+		entry.Status = models.EmailStatusFailed
+		entry.Error = sendErr.Error()
+	}
+	if err := config.DB.WithContext(context.WithoutCancel(ctx)).Create(&entry).Error; err != nil {
+		log.Printf("[EMAIL-ERROR] Failed to record email to %s: %v", msg.To, err)
+	}
+}
@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// memoryMailerLimit is how many messages a MemoryMailer keeps
+const memoryMailerLimit = 100
+
+// SentMessage is a message kept by a MemoryMailer
+type SentMessage struct {
+	Message
+	MessageID string
+	SentAt    time.Time
+}
+
+// MemoryMailer keeps sent messages in memory instead of sending them, for
+// tests and demos. Only the latest 100 are kept. Set Err to make sending fail.
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []SentMessage
+	next int
+	Err  error
+}
+
+// NewMemoryMailer returns a memory mailer without messages
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Name() string { return TransportMemory }
+
+// Send keeps msg and returns a sequential ID
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return "", m.Err
+	}
+
+	m.next++
+	sent := SentMessage{Message: msg, MessageID: fmt.Sprintf("memory-%d", m.next), SentAt: time.Now()}
+	m.sent = append(m.sent, sent)
+	if len(m.sent) > memoryMailerLimit {
+		m.sent = m.sent[len(m.sent)-memoryMailerLimit:]
+	}
+	return sent.MessageID, nil
+}
+
+// Sent returns the kept messages, oldest first
+func (m *MemoryMailer) Sent() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SentMessage(nil), m.sent...)
+}
+
+// Reset forgets all kept messages
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
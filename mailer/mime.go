@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// build encodes msg as a MIME message: a multipart/alternative text and HTML
+// body, wrapped in multipart/mixed when there are attachments. Non-ASCII
+// headers are encoded as RFC 2047 words.
+func (msg Message) build(from mail.Address, messageID string) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	writeHeader("From", from.String())
+	writeHeader("To", to.String())
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("Message-ID", messageID)
+	writeHeader("MIME-Version", "1.0")
+
+	if len(msg.Attachments) == 0 {
+		body := multipart.NewWriter(&buf)
+		writeHeader("Content-Type", "multipart/alternative; boundary="+strconv.Quote(body.Boundary()))
+		buf.WriteString("\r\n")
+		if err := writeAlternatives(body, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	writeHeader("Content-Type", "multipart/mixed; boundary="+strconv.Quote(mixed.Boundary()))
+	buf.WriteString("\r\n")
+
+	var alternatives bytes.Buffer
+	body := multipart.NewWriter(&alternatives)
+	if err := writeAlternatives(body, msg); err != nil {
+		return nil, err
+	}
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + strconv.Quote(body.Boundary())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(alternatives.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range msg.Attachments {
+		if err := writeAttachment(mixed, attachment); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeAlternatives(w *multipart.Writer, msg Message) error {
+	for _, alternative := range []struct{ contentType, content string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		if alternative.content == "" {
+			continue
+		}
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(alternative.content)); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func writeAttachment(w *multipart.Writer, attachment Attachment) error {
+	data, err := os.ReadFile(attachment.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read attachment: %w", err)
+	}
+	filename := attachment.Filename
+	if filename == "" {
+		filename = filepath.Base(attachment.Path)
+	}
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = "application/pdf"
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": filename})},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Base64 lines may be at most 76 characters long
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = part.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+// newMessageID returns a Message-ID header value in the sender's domain
+func newMessageID(from string) string {
+	domain := "viskatera.com"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("<%d@%s>", time.Now().UnixNano(), domain)
+	}
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
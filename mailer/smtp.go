@@ -0,0 +1,139 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"time"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer sends email through the SMTP server in SMTP_HOST, using STARTTLS
+// when the server offers it. Without SMTP_HOST it uses MailHog on localhost:1025
+// for development.
+type SMTPMailer struct {
+	host string
+	addr string
+	auth smtp.Auth
+	from mail.Address
+}
+
+// NewSMTPMailer configures an SMTP mailer from the SMTP_* variables
+func NewSMTPMailer() *SMTPMailer {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	user := os.Getenv("SMTP_USER")
+	pass := os.Getenv("SMTP_PASS")
+
+	// Default to MailHog if SMTP not configured (development)
+	if host == "" {
+		host = "localhost"
+		port = "1025" // MailHog SMTP port
+		user = ""     // MailHog doesn't require auth
+		pass = ""
+		log.Printf("[EMAIL] Using MailHog at %s:%s", host, port)
+	}
+
+	if port == "" {
+		port = "1025" // Default MailHog port
+	}
+
+	m := &SMTPMailer{
+		host: host,
+		addr: net.JoinHostPort(host, port),
+		from: sender(),
+	}
+	if user != "" && pass != "" {
+		m.auth = smtp.PlainAuth("", user, pass, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Name() string { return TransportSMTP }
+
+// Send delivers msg and returns its Message-ID, which SMTP servers keep and
+// show in their logs
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) (string, error) {
+	messageID := newMessageID(m.from.Address)
+	data, err := msg.build(m.from, messageID)
+	if err != nil {
+		return "", err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return "", err
+	}
+
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return "", err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return "", err
+		}
+	}
+	if m.auth != nil {
+		if err := client.Auth(m.auth); err != nil {
+			return "", err
+		}
+	}
+	if err := client.Mail(m.from.Address); err != nil {
+		return "", err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return "", err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	// The server accepted the message once the data was closed; failing now
+	// would retry the job and send it twice
+	if err := client.Quit(); err != nil {
+		log.Printf("[EMAIL] Message %s accepted, but QUIT failed: %v", messageID, err)
+	}
+	return messageID, nil
+}
+
+// IsPermanentError reports whether the SMTP server refused the email for
+// good, e.g. because the recipient address does not exist. Authentication
+// failures are left out: they are fixed by configuration, not by the message.
+func IsPermanentError(err error) bool {
+	var smtpErr *textproto.Error
+	if !errors.As(err, &smtpErr) {
+		return false
+	}
+	switch smtpErr.Code {
+	case 530, 534, 535:
+		return false
+	}
+	return smtpErr.Code >= 500 && smtpErr.Code < 600
+}
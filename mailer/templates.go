@@ -0,0 +1,145 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"viskatera-api-go/i18n"
+)
+
+// Template names. Each has <name>.html, rendered inside layout.html, and a
+// plain-text <name>.txt that also defines the "subject". Their text comes
+// from the i18n catalog through the t function, e.g. {{t "email.otp.title"}}.
+const (
+	TemplateInvoice             = "invoice"
+	TemplatePaymentSuccess      = "payment_success"
+	TemplateInvite              = "invite"
+	TemplateRefund              = "refund"
+	TemplateOTP                 = "otp"
+	TemplatePasswordReset       = "password_reset"
+	TemplatePasswordResetForced = "password_reset_forced"
+)
+
+// InvoiceEmail is the data of the invoice template, sent when a purchase is created
+type InvoiceEmail struct {
+	Name       string
+	Country    string
+	VisaType   string
+	Total      string
+	Status     string // purchase status, already translated
+	PaymentURL string // empty when there is nothing to pay
+}
+
+// PaymentSuccessEmail is the data of the payment_success template
+type PaymentSuccessEmail struct {
+	Name          string
+	PurchaseID    uint
+	Country       string
+	VisaType      string
+	AmountPaid    string
+	PaymentMethod string
+	InvoiceNumber string
+}
+
+// InviteEmail is the data of the invite template
+type InviteEmail struct {
+	Name      string
+	InvitedBy string
+	Role      string
+	AcceptURL string
+	ExpiresAt string
+}
+
+// RefundEmail is the data of the refund template
+type RefundEmail struct {
+	Name           string
+	PurchaseID     uint
+	Country        string
+	VisaType       string
+	AmountRefunded string
+	AmountPaid     string
+	TotalRefunded  string
+	Reason         string
+}
+
+// OTPEmail is the data of the otp template
+type OTPEmail struct {
+	Code             string
+	ExpiresInMinutes int
+}
+
+// PasswordResetEmail is the data of the password_reset and password_reset_forced templates
+type PasswordResetEmail struct {
+	Name      string
+	ResetURL  string
+	ExpiresIn string // e.g. "30 minutes", in the language of the email
+}
+
+//go:embed templates/*.html templates/*.txt
+var templateFiles embed.FS
+
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+var templates = parseTemplates()
+
+// parseTemplates parses every template for every language at startup, so a
+// broken one stops the process instead of failing its first email
+func parseTemplates() map[i18n.Language]map[string]emailTemplate {
+	parsed := map[i18n.Language]map[string]emailTemplate{}
+	for _, lang := range i18n.Languages {
+		funcs := map[string]interface{}{
+			"t": func(key string, args ...interface{}) string {
+				return i18n.T(lang, key, args...)
+			},
+		}
+		parsed[lang] = map[string]emailTemplate{}
+		for _, name := range []string{
+			TemplateInvoice,
+			TemplatePaymentSuccess,
+			TemplateInvite,
+			TemplateRefund,
+			TemplateOTP,
+			TemplatePasswordReset,
+			TemplatePasswordResetForced,
+		} {
+			parsed[lang][name] = emailTemplate{
+				html: htmltemplate.Must(htmltemplate.New(name).Funcs(funcs).ParseFS(templateFiles, "templates/layout.html", "templates/"+name+".html")),
+				text: texttemplate.Must(texttemplate.New(name).Funcs(funcs).ParseFS(templateFiles, "templates/"+name+".txt")),
+			}
+		}
+	}
+	return parsed
+}
+
+// NewMessage renders the template name in lang with data into a message to to
+func NewMessage(to string, lang i18n.Language, name string, data interface{}) (Message, error) {
+	tmpl, ok := templates[lang][name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q in language %q", name, lang)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("rendering %s subject: %w", name, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, fmt.Errorf("rendering %s text: %w", name, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("rendering %s HTML: %w", name, err)
+	}
+
+	return Message{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		HTML:     html.String(),
+		Text:     strings.TrimSpace(text.String()) + "\n",
+		Template: name,
+	}, nil
+}
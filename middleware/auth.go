@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -92,7 +93,26 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Reject tokens whose session was logged out or revoked
+		sessionID, _ := claims["sid"].(string)
+		issuedAtMs, ok := claims["iat_ms"].(float64)
+		if !ok {
+			// Tokens issued before iat_ms was added
+			issuedAt, _ := claims["iat"].(float64)
+			issuedAtMs = issuedAt * 1000
+		}
+		if sessionID == "" || utils.IsSessionRevoked(c.Request.Context(), uint(userID), sessionID, int64(issuedAtMs)) {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+				"Session revoked",
+				"SESSION_REVOKED",
+				"Please login again to get a new token",
+			))
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", uint(userID))
+		c.Set("session_id", sessionID)
 		c.Next()
 	}
 }
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyHeader carries the client-generated key identifying a request across retries
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader is set on responses replayed from an earlier request
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotency makes retries of a request that carries an Idempotency-Key
+// header safe: the first request runs and its response is stored, identical
+// retries get the stored response, and reusing the key for a different
+// request is rejected. Requests without the header run as usual. Server
+// errors are not stored so the client can retry them. Must run after
+// AuthMiddleware; keys are scoped per user.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Invalid idempotency key",
+				"INVALID_IDEMPOTENCY_KEY",
+				"Idempotency-Key must be at most 255 characters",
+			))
+			c.Abort()
+			return
+		}
+
+		userID := c.GetUint("user_id")
+		if userID == 0 {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+				"User not authenticated",
+				"UNAUTHORIZED",
+				"Authentication required",
+			))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse(
+				"Invalid request body",
+				"VALIDATION_ERROR",
+				err.Error(),
+			))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		ctx := c.Request.Context()
+		existing, err := utils.ClaimIdempotencyKey(ctx, userID, key, fingerprint)
+		if err != nil {
+			log.Printf("Failed to claim idempotency key for user %d: %v", userID, err)
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse(
+				"Idempotency check unavailable",
+				"IDEMPOTENCY_UNAVAILABLE",
+				"Please retry the request shortly",
+			))
+			c.Abort()
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse(
+					"Idempotency key reused",
+					"IDEMPOTENCY_KEY_MISMATCH",
+					"This Idempotency-Key was already used for a different request",
+				))
+			case !existing.Completed():
+				c.Header("Retry-After", "1")
+				c.JSON(http.StatusConflict, models.ErrorResponse(
+					"Request in progress",
+					"IDEMPOTENCY_REQUEST_IN_PROGRESS",
+					"A request with this Idempotency-Key is still being processed",
+				))
+			default:
+				c.Header(IdempotencyReplayedHeader, "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		writer := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// Server errors may have left nothing behind, so let the client try again
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			if err := utils.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+				log.Printf("Failed to release idempotency key for user %d: %v", userID, err)
+			}
+			return
+		}
+
+		record := utils.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		if err := utils.CompleteIdempotencyKey(ctx, userID, key, record); err != nil {
+			log.Printf("Failed to store idempotent response for user %d: %v", userID, err)
+		}
+	}
+}
+
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body while writing it to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/testenv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// idempotencyBackends are the stores keys can live in. Each returns a prefix
+// that keeps the test's keys apart from earlier runs, and is skipped when its
+// server is not configured outside CI.
+var idempotencyBackends = []struct {
+	name  string
+	setup func(t *testing.T) string
+}{
+	{"redis", useTestRedis},
+	{"postgres fallback", useTestDB},
+}
+
+type idempotentRequest struct {
+	user uint
+	key  string
+	body string
+
+	wantStatus   int
+	wantCode     string // error code, for error responses
+	wantCall     int    // handler call that produced the response
+	wantReplayed bool
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int // returned by the handler on each call; the last one repeats
+		requests  []idempotentRequest
+		wantCalls int
+	}{
+		{
+			name:     "retry replays the first response",
+			statuses: []int{http.StatusCreated},
+			requests: []idempotentRequest{
+				{user: 1, key: "a", body: `{"purchase_id":1}`, wantStatus: http.StatusCreated, wantCall: 1},
+				{user: 1, key: "a", body: `{"purchase_id":1}`, wantStatus: http.StatusCreated, wantCall: 1, wantReplayed: true},
+				{user: 1, key: "a", body: `{"purchase_id":1}`, wantStatus: http.StatusCreated, wantCall: 1, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:     "key reused for a different body",
+			statuses: []int{http.StatusCreated},
+			requests: []idempotentRequest{
+				{user: 1, key: "a", body: `{"purchase_id":1}`, wantStatus: http.StatusCreated, wantCall: 1},
+				{user: 1, key: "a", body: `{"purchase_id":2}`, wantStatus: http.StatusUnprocessableEntity, wantCode: "IDEMPOTENCY_KEY_MISMATCH"},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:     "keys are scoped per user",
+			statuses: []int{http.StatusCreated},
+			requests: []idempotentRequest{
+				{user: 1, key: "a", body: `{}`, wantStatus: http.StatusCreated, wantCall: 1},
+				{user: 2, key: "a", body: `{}`, wantStatus: http.StatusCreated, wantCall: 2},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "server error releases the key",
+			statuses: []int{http.StatusInternalServerError, http.StatusCreated},
+			requests: []idempotentRequest{
+				{user: 1, key: "a", body: `{}`, wantStatus: http.StatusInternalServerError, wantCall: 1},
+				{user: 1, key: "a", body: `{}`, wantStatus: http.StatusCreated, wantCall: 2},
+				{user: 1, key: "a", body: `{}`, wantStatus: http.StatusCreated, wantCall: 2, wantReplayed: true},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "client error is stored",
+			statuses: []int{http.StatusConflict, http.StatusCreated},
+			requests: []idempotentRequest{
+				{user: 1, key: "a", body: `{}`, wantStatus: http.StatusConflict, wantCall: 1},
+				{user: 1, key: "a", body: `{}`, wantStatus: http.StatusConflict, wantCall: 1, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:     "requests without a key always run",
+			statuses: []int{http.StatusCreated},
+			requests: []idempotentRequest{
+				{user: 1, body: `{}`, wantStatus: http.StatusCreated, wantCall: 1},
+				{user: 1, body: `{}`, wantStatus: http.StatusCreated, wantCall: 2},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "key too long",
+			statuses: []int{http.StatusCreated},
+			requests: []idempotentRequest{
+				{user: 1, key: strings.Repeat("k", maxIdempotencyKeyLength+1), body: `{}`, wantStatus: http.StatusBadRequest, wantCode: "INVALID_IDEMPOTENCY_KEY"},
+			},
+			wantCalls: 0,
+		},
+		{
+			name:     "key without a signed-in user",
+			statuses: []int{http.StatusCreated},
+			requests: []idempotentRequest{
+				{key: "a", body: `{}`, wantStatus: http.StatusUnauthorized, wantCode: "UNAUTHORIZED"},
+			},
+			wantCalls: 0,
+		},
+	}
+
+	for _, backend := range idempotencyBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					prefix := backend.setup(t)
+					calls := 0
+					router := idempotencyRouter(func(c *gin.Context) {
+						calls++
+						status := tt.statuses[len(tt.statuses)-1]
+						if calls <= len(tt.statuses) {
+							status = tt.statuses[calls-1]
+						}
+						c.JSON(status, gin.H{"call": calls})
+					})
+
+					for i, req := range tt.requests {
+						if req.key != "" && len(req.key) <= maxIdempotencyKeyLength {
+							req.key = prefix + req.key
+						}
+						checkIdempotentResponse(t, i+1, req, sendIdempotent(router, req))
+					}
+					if calls != tt.wantCalls {
+						t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestIdempotencyRequestInProgress(t *testing.T) {
+	for _, backend := range idempotencyBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			key := backend.setup(t) + "slow"
+			entered := make(chan struct{})
+			release := make(chan struct{})
+			calls := 0
+			router := idempotencyRouter(func(c *gin.Context) {
+				calls++
+				if calls == 1 {
+					close(entered)
+					<-release
+				}
+				c.JSON(http.StatusCreated, gin.H{"call": calls})
+			})
+
+			first := idempotentRequest{user: 1, key: key, body: `{}`, wantStatus: http.StatusCreated, wantCall: 1}
+			var wg sync.WaitGroup
+			var firstResponse *httptest.ResponseRecorder
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				firstResponse = sendIdempotent(router, first)
+			}()
+			<-entered
+
+			retry := sendIdempotent(router, first)
+			checkIdempotentResponse(t, 2, idempotentRequest{wantStatus: http.StatusConflict, wantCode: "IDEMPOTENCY_REQUEST_IN_PROGRESS"}, retry)
+			if got := retry.Header().Get("Retry-After"); got == "" {
+				t.Errorf("in-progress response has no Retry-After header")
+			}
+
+			close(release)
+			wg.Wait()
+			checkIdempotentResponse(t, 1, first, firstResponse)
+
+			replay := first
+			replay.wantReplayed = true
+			checkIdempotentResponse(t, 3, replay, sendIdempotent(router, first))
+			if calls != 1 {
+				t.Errorf("handler ran %d times, want 1", calls)
+			}
+		})
+	}
+}
+
+func idempotencyRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// Stands in for AuthMiddleware
+	router.Use(func(c *gin.Context) {
+		if userID, err := strconv.Atoi(c.GetHeader("X-Test-User")); err == nil && userID > 0 {
+			c.Set("user_id", uint(userID))
+		}
+	})
+	router.POST("/payments", Idempotency(), handler)
+	return router
+}
+
+func sendIdempotent(router *gin.Engine, req idempotentRequest) *httptest.ResponseRecorder {
+	httpReq := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(req.body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	if req.user != 0 {
+		httpReq.Header.Set("X-Test-User", strconv.Itoa(int(req.user)))
+	}
+	if req.key != "" {
+		httpReq.Header.Set(IdempotencyHeader, req.key)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httpReq)
+	return recorder
+}
+
+func checkIdempotentResponse(t *testing.T, n int, want idempotentRequest, got *httptest.ResponseRecorder) {
+	t.Helper()
+	if got.Code != want.wantStatus {
+		t.Errorf("request %d: status = %d, want %d (body %s)", n, got.Code, want.wantStatus, got.Body.String())
+		return
+	}
+	var body struct {
+		Call  int              `json:"call"`
+		Error *models.APIError `json:"error"`
+	}
+	if err := json.Unmarshal(got.Body.Bytes(), &body); err != nil {
+		t.Fatalf("request %d: decoding %s: %v", n, got.Body.String(), err)
+	}
+	if want.wantCode != "" {
+		if body.Error == nil || body.Error.Code != want.wantCode {
+			t.Errorf("request %d: body = %s, want error code %s", n, got.Body.String(), want.wantCode)
+		}
+		return
+	}
+	if body.Call != want.wantCall {
+		t.Errorf("request %d: response of call %d, want call %d", n, body.Call, want.wantCall)
+	}
+	if replayed := got.Header().Get(IdempotencyReplayedHeader) == "true"; replayed != want.wantReplayed {
+		t.Errorf("request %d: replayed = %v, want %v", n, replayed, want.wantReplayed)
+	}
+}
+
+// useTestRedis stores keys in the test Redis server
+func useTestRedis(t *testing.T) string {
+	t.Helper()
+	config.RedisClient, config.CacheEnabled = testenv.Redis(t), true
+	t.Cleanup(func() { config.RedisClient, config.CacheEnabled = nil, false })
+	return fmt.Sprintf("test-%d-", time.Now().UnixNano())
+}
+
+// useTestDB stores keys in the test Postgres database, as when Redis is
+// unavailable. The schema keeps these tests apart from other packages.
+func useTestDB(t *testing.T) string {
+	t.Helper()
+	testenv.Postgres(t, "middleware_test", func(db *gorm.DB) error {
+		return db.AutoMigrate(&models.IdempotencyKey{}, &models.User{})
+	})
+	config.RedisClient, config.CacheEnabled = nil, false
+	return ""
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"viskatera-api-go/i18n"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Localize translates the message of JSON error responses into the client's
+// language. Signed-in users get their preferred language, other clients the
+// one asked for in Accept-Language; without one errors stay in English, which
+// API clients and webhooks expect. Error codes and details are left
+// untouched, and codes without a translation keep the English message.
+func Localize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang, ok := i18n.Match(c.GetHeader("Accept-Language"))
+		if ok {
+			c.Set(utils.LanguageContextKey, lang)
+		} else {
+			lang = i18n.English
+		}
+
+		writer := &errorBuffer{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if !writer.buffering {
+			return
+		}
+		body := writer.body.Bytes()
+
+		// Routes that check permissions have loaded the user already
+		if user, ok := c.Get("user"); ok {
+			lang = i18n.Of(user.(models.User).Language)
+		} else if userID := c.GetUint("user_id"); userID != 0 {
+			lang = utils.UserLanguage(c.Request.Context(), userID)
+		}
+		if lang != i18n.English {
+			if translated, ok := translateError(lang, body); ok {
+				body = translated
+			}
+		}
+		writer.ResponseWriter.Write(body)
+	}
+}
+
+// translateError replaces the message and error.message of an ErrorResponse
+// body with the translation of its error code
+func translateError(lang i18n.Language, body []byte) ([]byte, bool) {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, false
+	}
+	var apiError map[string]json.RawMessage
+	if err := json.Unmarshal(response["error"], &apiError); err != nil {
+		return nil, false
+	}
+	var code string
+	if err := json.Unmarshal(apiError["code"], &code); err != nil {
+		return nil, false
+	}
+	message, ok := i18n.Lookup(lang, "error."+code)
+	if !ok {
+		return nil, false
+	}
+
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return nil, false
+	}
+	apiError["message"] = encoded
+	if response["error"], err = json.Marshal(apiError); err != nil {
+		return nil, false
+	}
+	if _, ok := response["message"]; ok {
+		response["message"] = encoded
+	}
+	translated, err := json.Marshal(response)
+	if err != nil {
+		return nil, false
+	}
+	return translated, true
+}
+
+// errorBuffer holds back the body of JSON error responses so Localize can
+// rewrite it; everything else is written through
+type errorBuffer struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	buffering bool
+}
+
+func (w *errorBuffer) Write(data []byte) (int, error) {
+	if w.buffering || w.isJSONError() {
+		w.buffering = true
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorBuffer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *errorBuffer) isJSONError() bool {
+	return !w.Written() && w.Status() >= http.StatusBadRequest &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
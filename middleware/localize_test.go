@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLocalize(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		code           string
+		message        string
+		want           string
+	}{
+		{"indonesian", "id-ID,id;q=0.9", "PURCHASE_NOT_FOUND", "Purchase not found", "Pembelian tidak ditemukan"},
+		{"preferred by quality", "en;q=0.5,id", "PURCHASE_NOT_FOUND", "Purchase not found", "Pembelian tidak ditemukan"},
+		{"formatted message", "id", "VALIDATION_ERROR", "Invalid max_per_user", "Data yang dikirim tidak valid"},
+		{"english", "en-US", "PURCHASE_NOT_FOUND", "Purchase not found", "Purchase not found"},
+		{"no header", "", "PURCHASE_NOT_FOUND", "Purchase not found", "Purchase not found"},
+		{"unsupported language", "fr", "PURCHASE_NOT_FOUND", "Purchase not found", "Purchase not found"},
+		{"code without translation", "id", "SOMETHING_NEW", "Something new", "Something new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := localizeRouter(0, http.StatusNotFound, tt.code, tt.message)
+			checkLocalizedError(t, sendLocalized(router, tt.acceptLanguage), tt.code, tt.want)
+		})
+	}
+}
+
+func TestLocalizeSignedInUser(t *testing.T) {
+	useTestDB(t)
+	user := models.User{Email: "customer@example.com", Password: "x", Name: "Customer", Language: "id"}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	// The preferred language wins over Accept-Language
+	router := localizeRouter(user.ID, http.StatusNotFound, "PURCHASE_NOT_FOUND", "Purchase not found")
+	for _, acceptLanguage := range []string{"", "en"} {
+		checkLocalizedError(t, sendLocalized(router, acceptLanguage), "PURCHASE_NOT_FOUND", "Pembelian tidak ditemukan")
+	}
+}
+
+func TestLocalizeCachedLanguage(t *testing.T) {
+	useTestDB(t)
+	useTestRedis(t)
+	user := models.User{Email: "customer@example.com", Password: "x", Name: "Customer", Language: "id"}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	ctx := context.Background()
+	utils.ForgetUserLanguage(ctx, user.ID)
+	t.Cleanup(func() { utils.ForgetUserLanguage(ctx, user.ID) })
+
+	router := localizeRouter(user.ID, http.StatusNotFound, "PURCHASE_NOT_FOUND", "Purchase not found")
+	checkLocalizedError(t, sendLocalized(router, ""), "PURCHASE_NOT_FOUND", "Pembelian tidak ditemukan")
+
+	// Later errors use the cached language until it is forgotten
+	if err := config.DB.Model(&user).Update("language", "en").Error; err != nil {
+		t.Fatalf("updating user: %v", err)
+	}
+	checkLocalizedError(t, sendLocalized(router, ""), "PURCHASE_NOT_FOUND", "Pembelian tidak ditemukan")
+	utils.ForgetUserLanguage(ctx, user.ID)
+	checkLocalizedError(t, sendLocalized(router, ""), "PURCHASE_NOT_FOUND", "Purchase not found")
+}
+
+func TestLocalizeLeavesSuccessUntouched(t *testing.T) {
+	router := localizeRouter(0, http.StatusOK, "", "")
+	got := sendLocalized(router, "id")
+	if got.Code != http.StatusOK || got.Body.String() != `{"message":"ok"}` {
+		t.Errorf("response = %d %s, want 200 {\"message\":\"ok\"}", got.Code, got.Body.String())
+	}
+}
+
+// localizeRouter answers with an ErrorResponse, or a plain success without a
+// code. A userID other than 0 stands in for AuthMiddleware.
+func localizeRouter(userID uint, status int, code, message string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Localize())
+	router.GET("/test", func(c *gin.Context) {
+		if userID != 0 {
+			c.Set("user_id", userID)
+		}
+		if code == "" {
+			c.JSON(status, gin.H{"message": "ok"})
+			return
+		}
+		c.JSON(status, models.ErrorResponse(message, code, "details stay in English"))
+	})
+	return router
+}
+
+func sendLocalized(router *gin.Engine, acceptLanguage string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func checkLocalizedError(t *testing.T, got *httptest.ResponseRecorder, wantCode, wantMessage string) {
+	t.Helper()
+	var body models.APIResponse
+	if err := json.Unmarshal(got.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", got.Body.String(), err)
+	}
+	if body.Error == nil {
+		t.Fatalf("body %s has no error", got.Body.String())
+	}
+	if body.Message != wantMessage || body.Error.Message != wantMessage {
+		t.Errorf("messages = %q, %q, want %q", body.Message, body.Error.Message, wantMessage)
+	}
+	if body.Error.Code != wantCode || body.Error.Details != "details stay in English" {
+		t.Errorf("code and details = %s, %q, want them unchanged", body.Error.Code, body.Error.Details)
+	}
+}
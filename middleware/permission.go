@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission only lets the request through when the authenticated user
+// holds every listed permission. Must run after AuthMiddleware.
+func RequirePermission(perms ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user ID from context (set by AuthMiddleware)
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+				"User not authenticated",
+				"UNAUTHORIZED",
+				"Authentication required",
+			))
+			c.Abort()
+			return
+		}
+
+		// Resolve the user's permissions once per request
+		granted, ok := c.Get("permissions")
+		if !ok {
+			var user models.User
+			if err := config.DB.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+				c.JSON(http.StatusUnauthorized, models.ErrorResponse(
+					"User not found or inactive",
+					"USER_NOT_FOUND",
+					"User account not found or deactivated",
+				))
+				c.Abort()
+				return
+			}
+
+			userPerms, err := utils.UserPermissions(user)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse(
+					"Failed to resolve permissions",
+					"DATABASE_ERROR",
+					"Please try again later",
+				))
+				c.Abort()
+				return
+			}
+
+			// Set user info in context
+			c.Set("user", user)
+			c.Set("permissions", userPerms)
+			granted = userPerms
+		}
+
+		held := map[models.Permission]bool{}
+		for _, perm := range granted.([]models.Permission) {
+			held[perm] = true
+		}
+
+		var missing []string
+		for _, perm := range perms {
+			if !held[perm] {
+				missing = append(missing, string(perm))
+			}
+		}
+		if len(missing) > 0 {
+			c.JSON(http.StatusForbidden, models.ErrorResponse(
+				"Access denied",
+				"PERMISSION_DENIED",
+				"Missing permission: "+strings.Join(missing, ", "),
+			))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
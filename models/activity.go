@@ -19,10 +19,19 @@ const (
 type ActivityEntity string
 
 const (
-	EntityUser     ActivityEntity = "user"
-	EntityVisa     ActivityEntity = "visa"
-	EntityPurchase ActivityEntity = "purchase"
-	EntityPayment  ActivityEntity = "payment"
+	EntityUser         ActivityEntity = "user"
+	EntityVisa         ActivityEntity = "visa"
+	EntityPurchase     ActivityEntity = "purchase"
+	EntityPayment      ActivityEntity = "payment"
+	EntityApplicant    ActivityEntity = "applicant"
+	EntityDocument     ActivityEntity = "document"
+	EntityVisaOption   ActivityEntity = "visa_option"
+	EntityInvite       ActivityEntity = "invite"
+	EntityRefund       ActivityEntity = "refund"
+	EntityExchangeRate ActivityEntity = "exchange_rate"
+	EntityPromoCode    ActivityEntity = "promo_code"
+	EntityPricingRule  ActivityEntity = "pricing_rule"
+	EntityDeadLetter   ActivityEntity = "dead_letter"
 )
 
 // ActivityLog represents an audit log entry
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Applicant is a traveler profile a user can attach to visa purchases
+type Applicant struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	UserID         uint           `json:"user_id" gorm:"not null;index:idx_applicant_user"`
+	User           User           `json:"-" gorm:"foreignKey:UserID"`
+	FullName       string         `json:"full_name" gorm:"not null;size:255"`
+	PassportNumber string         `json:"passport_number" gorm:"not null;size:20;index:idx_applicant_passport"`
+	Nationality    string         `json:"nationality" gorm:"not null;size:100"`
+	DateOfBirth    time.Time      `json:"date_of_birth" gorm:"type:date;not null"`
+	PassportExpiry time.Time      `json:"passport_expiry" gorm:"type:date;not null"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// PassportValidityMonths is how long a passport must remain valid after the travel date
+const PassportValidityMonths = 6
+
+// PassportValidFor reports whether the applicant's passport is valid for
+// at least PassportValidityMonths after the travel date
+func (a Applicant) PassportValidFor(travelDate time.Time) bool {
+	return !a.PassportExpiry.Before(travelDate.AddDate(0, PassportValidityMonths, 0))
+}
+
+// PurchaseApplicant is an applicant as they were when the purchase was made.
+// The details are copied into the purchase_applicants row, so later edits to
+// the applicant profile do not change past purchases or their invoices.
+type PurchaseApplicant struct {
+	VisaPurchaseID uint      `json:"-" gorm:"primaryKey"`
+	ApplicantID    uint      `json:"id" gorm:"primaryKey"` // the applicant profile, as referenced by document uploads
+	FullName       string    `json:"full_name" gorm:"not null;size:255;default:''"`
+	PassportNumber string    `json:"passport_number" gorm:"not null;size:20;default:''"`
+	Nationality    string    `json:"nationality" gorm:"not null;size:100;default:''"`
+	DateOfBirth    time.Time `json:"date_of_birth" gorm:"type:date"`
+	PassportExpiry time.Time `json:"passport_expiry" gorm:"type:date"`
+}
+
+// NewPurchaseApplicant snapshots an applicant for a purchase
+func NewPurchaseApplicant(applicant Applicant) PurchaseApplicant {
+	return PurchaseApplicant{
+		ApplicantID:    applicant.ID,
+		FullName:       applicant.FullName,
+		PassportNumber: applicant.PassportNumber,
+		Nationality:    applicant.Nationality,
+		DateOfBirth:    applicant.DateOfBirth,
+		PassportExpiry: applicant.PassportExpiry,
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// PurchaseStatus represents a step in the visa application lifecycle
+type PurchaseStatus string
+
+const (
+	PurchaseStatusDraft              PurchaseStatus = "draft"
+	PurchaseStatusSubmitted          PurchaseStatus = "submitted"
+	PurchaseStatusDocumentsReview    PurchaseStatus = "documents_review"
+	PurchaseStatusSubmittedToEmbassy PurchaseStatus = "submitted_to_embassy"
+	PurchaseStatusApproved           PurchaseStatus = "approved"
+	PurchaseStatusRejected           PurchaseStatus = "rejected"
+	PurchaseStatusIssued             PurchaseStatus = "issued"
+	PurchaseStatusCancelled          PurchaseStatus = "cancelled"
+	PurchaseStatusRefunded           PurchaseStatus = "refunded"
+)
+
+// TransitionActor represents who triggered a status transition
+type TransitionActor string
+
+const (
+	ActorCustomer TransitionActor = "customer"
+	ActorAdmin    TransitionActor = "admin"
+	ActorWebhook  TransitionActor = "webhook"
+	ActorSystem   TransitionActor = "system" // scheduled background jobs
+)
+
+// purchaseTransitions lists, for every status, the statuses it may move to
+// and the actors allowed to trigger each move. Moves to refunded follow a
+// completed full refund of the payment and are only made by the gateway flow.
+var purchaseTransitions = map[PurchaseStatus]map[PurchaseStatus][]TransitionActor{
+	PurchaseStatusDraft: {
+		PurchaseStatusSubmitted: {ActorWebhook, ActorAdmin, ActorSystem}, // system: nothing to pay after discounts
+		PurchaseStatusCancelled: {ActorCustomer, ActorAdmin, ActorSystem},
+	},
+	PurchaseStatusSubmitted: {
+		PurchaseStatusDocumentsReview: {ActorAdmin},
+		PurchaseStatusCancelled:       {ActorAdmin},
+		PurchaseStatusRefunded:        {ActorWebhook},
+	},
+	PurchaseStatusDocumentsReview: {
+		PurchaseStatusSubmittedToEmbassy: {ActorAdmin},
+		PurchaseStatusRejected:           {ActorAdmin},
+		PurchaseStatusRefunded:           {ActorWebhook},
+	},
+	PurchaseStatusSubmittedToEmbassy: {
+		PurchaseStatusApproved: {ActorAdmin},
+		PurchaseStatusRejected: {ActorAdmin},
+		PurchaseStatusRefunded: {ActorWebhook},
+	},
+	PurchaseStatusApproved: {
+		PurchaseStatusIssued:   {ActorAdmin},
+		PurchaseStatusRefunded: {ActorWebhook},
+	},
+	PurchaseStatusRejected: {
+		PurchaseStatusRefunded: {ActorWebhook},
+	},
+	PurchaseStatusCancelled: {
+		PurchaseStatusRefunded: {ActorWebhook},
+	},
+}
+
+// IsValid reports whether the status is a known lifecycle status
+func (s PurchaseStatus) IsValid() bool {
+	switch s {
+	case PurchaseStatusDraft, PurchaseStatusSubmitted, PurchaseStatusDocumentsReview,
+		PurchaseStatusSubmittedToEmbassy, PurchaseStatusApproved, PurchaseStatusRejected,
+		PurchaseStatusIssued, PurchaseStatusCancelled, PurchaseStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// IsFinal reports whether no further transitions are possible from the status
+func (s PurchaseStatus) IsFinal() bool {
+	return len(purchaseTransitions[s]) == 0
+}
+
+// CanTransition reports whether actor may move a purchase from one status to another
+func CanTransition(from, to PurchaseStatus, actor TransitionActor) bool {
+	actors, ok := purchaseTransitions[from][to]
+	if !ok {
+		return false
+	}
+	for _, a := range actors {
+		if a == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedTransitions returns the statuses actor may move a purchase to from the given status
+func AllowedTransitions(from PurchaseStatus, actor TransitionActor) []PurchaseStatus {
+	allowed := []PurchaseStatus{}
+	for to := range purchaseTransitions[from] {
+		if CanTransition(from, to, actor) {
+			allowed = append(allowed, to)
+		}
+	}
+	sort.Slice(allowed, func(i, j int) bool { return allowed[i] < allowed[j] })
+	return allowed
+}
+
+// PurchaseStatusHistory records every status transition of a visa purchase
+type PurchaseStatusHistory struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	PurchaseID  uint            `json:"purchase_id" gorm:"not null;index:idx_status_history_purchase"`
+	FromStatus  PurchaseStatus  `json:"from_status" gorm:"type:varchar(30)"`
+	ToStatus    PurchaseStatus  `json:"to_status" gorm:"type:varchar(30);not null"`
+	Actor       TransitionActor `json:"actor" gorm:"type:varchar(20);not null"`
+	ActorUserID *uint           `json:"actor_user_id" gorm:"index"`
+	Note        string          `json:"note" gorm:"type:text"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"index:idx_status_history_purchase"`
+}
+
+// TableName specifies the table name for PurchaseStatusHistory
+func (PurchaseStatusHistory) TableName() string {
+	return "purchase_status_histories"
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from  PurchaseStatus
+		to    PurchaseStatus
+		actor TransitionActor
+		want  bool
+	}{
+		// Payment submits a draft; customers cannot submit it themselves
+		{PurchaseStatusDraft, PurchaseStatusSubmitted, ActorWebhook, true},
+		{PurchaseStatusDraft, PurchaseStatusSubmitted, ActorAdmin, true},
+		{PurchaseStatusDraft, PurchaseStatusSubmitted, ActorSystem, true},
+		{PurchaseStatusDraft, PurchaseStatusSubmitted, ActorCustomer, false},
+		{PurchaseStatusDraft, PurchaseStatusCancelled, ActorCustomer, true},
+		{PurchaseStatusDraft, PurchaseStatusRefunded, ActorWebhook, false},
+
+		// Review steps are made by staff, one at a time
+		{PurchaseStatusSubmitted, PurchaseStatusDocumentsReview, ActorAdmin, true},
+		{PurchaseStatusSubmitted, PurchaseStatusDocumentsReview, ActorCustomer, false},
+		{PurchaseStatusSubmitted, PurchaseStatusApproved, ActorAdmin, false},
+		{PurchaseStatusSubmitted, PurchaseStatusCancelled, ActorAdmin, true},
+		{PurchaseStatusSubmitted, PurchaseStatusCancelled, ActorCustomer, false},
+		{PurchaseStatusDocumentsReview, PurchaseStatusSubmittedToEmbassy, ActorAdmin, true},
+		{PurchaseStatusDocumentsReview, PurchaseStatusRejected, ActorAdmin, true},
+		{PurchaseStatusSubmittedToEmbassy, PurchaseStatusApproved, ActorAdmin, true},
+		{PurchaseStatusApproved, PurchaseStatusIssued, ActorAdmin, true},
+		{PurchaseStatusApproved, PurchaseStatusRejected, ActorAdmin, false},
+
+		// Only the refund flow refunds, and not once the visa is issued
+		{PurchaseStatusSubmitted, PurchaseStatusRefunded, ActorWebhook, true},
+		{PurchaseStatusSubmitted, PurchaseStatusRefunded, ActorAdmin, false},
+		{PurchaseStatusRejected, PurchaseStatusRefunded, ActorWebhook, true},
+		{PurchaseStatusCancelled, PurchaseStatusRefunded, ActorWebhook, true},
+		{PurchaseStatusIssued, PurchaseStatusRefunded, ActorWebhook, false},
+
+		// Final statuses stay final
+		{PurchaseStatusIssued, PurchaseStatusCancelled, ActorAdmin, false},
+		{PurchaseStatusRefunded, PurchaseStatusDraft, ActorAdmin, false},
+		{PurchaseStatusCancelled, PurchaseStatusDraft, ActorCustomer, false},
+
+		// Unknown statuses never move
+		{PurchaseStatus("pending"), PurchaseStatusSubmitted, ActorWebhook, false},
+		{PurchaseStatusDraft, PurchaseStatus("completed"), ActorAdmin, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to, tt.actor); got != tt.want {
+			t.Errorf("CanTransition(%s, %s, %s) = %v, want %v", tt.from, tt.to, tt.actor, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedTransitions(t *testing.T) {
+	tests := []struct {
+		from  PurchaseStatus
+		actor TransitionActor
+		want  []PurchaseStatus
+	}{
+		{PurchaseStatusDraft, ActorCustomer, []PurchaseStatus{PurchaseStatusCancelled}},
+		{PurchaseStatusDraft, ActorAdmin, []PurchaseStatus{PurchaseStatusCancelled, PurchaseStatusSubmitted}},
+		{PurchaseStatusSubmitted, ActorAdmin, []PurchaseStatus{PurchaseStatusCancelled, PurchaseStatusDocumentsReview}},
+		{PurchaseStatusSubmittedToEmbassy, ActorAdmin, []PurchaseStatus{PurchaseStatusApproved, PurchaseStatusRejected}},
+		{PurchaseStatusApproved, ActorWebhook, []PurchaseStatus{PurchaseStatusRefunded}},
+		{PurchaseStatusIssued, ActorAdmin, []PurchaseStatus{}},
+		{PurchaseStatusRefunded, ActorWebhook, []PurchaseStatus{}},
+	}
+	for _, tt := range tests {
+		if got := AllowedTransitions(tt.from, tt.actor); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AllowedTransitions(%s, %s) = %v, want %v", tt.from, tt.actor, got, tt.want)
+		}
+	}
+}
+
+func TestPurchaseStatusIsFinal(t *testing.T) {
+	tests := []struct {
+		status PurchaseStatus
+		want   bool
+	}{
+		{PurchaseStatusDraft, false},
+		{PurchaseStatusSubmitted, false},
+		{PurchaseStatusRejected, false}, // can still be refunded
+		{PurchaseStatusCancelled, false},
+		{PurchaseStatusIssued, true},
+		{PurchaseStatusRefunded, true},
+	}
+	for _, tt := range tests {
+		if got := tt.status.IsFinal(); got != tt.want {
+			t.Errorf("%s.IsFinal() = %v, want %v", tt.status, got, tt.want)
+		}
+		if !tt.status.IsValid() {
+			t.Errorf("%s.IsValid() = false, want true", tt.status)
+		}
+	}
+	if PurchaseStatus("pending").IsValid() {
+		t.Errorf(`"pending".IsValid() = true, want false`)
+	}
+}
@@ -32,6 +32,7 @@ type UpdateUserRequest struct {
 	Email           string `json:"email" binding:"omitempty,email"`
 	CurrentPassword string `json:"current_password" binding:"omitempty,min=6"`
 	NewPassword     string `json:"new_password" binding:"omitempty,min=6"`
+	Language        string `json:"language" binding:"omitempty,oneof=id en"`
 }
 
 // OTP Model for login authentication
@@ -55,3 +56,24 @@ type VerifyOTPRequest struct {
 	Email string `json:"email" binding:"required,email"`
 	Code  string `json:"code" binding:"required,len=6"`
 }
+
+// RefreshToken is a hashed, single-use refresh token. Every login starts a new
+// family (the session); each refresh rotates the token within that family.
+type RefreshToken struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index:idx_refresh_token_user"`
+	FamilyID     string     `json:"family_id" gorm:"size:64;not null;index:idx_refresh_token_family"`
+	TokenHash    string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null;index"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"` // set when the token was rotated rather than revoked
+	UserAgent    string     `json:"user_agent" gorm:"size:255"`
+	IPAddress    string     `json:"ip_address" gorm:"size:45"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Refresh token request model
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts stored before currencies were recorded
+const DefaultCurrency = "IDR"
+
+// currencyExponents lists the supported ISO 4217 currencies with the number of
+// minor-unit digits used for their amounts. IDR is kept without a minor unit,
+// the way Xendit charges it.
+var currencyExponents = map[string]int{
+	"IDR": 0,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"AUD": 2,
+	"SGD": 2,
+	"MYR": 2,
+	"CNY": 2,
+	"JPY": 0,
+	"KRW": 0,
+}
+
+// IsSupportedCurrency reports whether code is a known ISO currency code
+func IsSupportedCurrency(code string) bool {
+	_, ok := currencyExponents[code]
+	return ok
+}
+
+// CurrencyExponent returns how many minor-unit digits amounts in currency have
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// MinorToMajor converts an amount in minor units (e.g. cents) to major units (e.g. dollars)
+func MinorToMajor(amount int64, currency string) float64 {
+	return float64(amount) / math.Pow10(CurrencyExponent(currency))
+}
+
+// MajorToMinor converts an amount in major units to minor units, rounding to the nearest unit
+func MajorToMinor(amount float64, currency string) int64 {
+	return int64(math.Round(amount * math.Pow10(CurrencyExponent(currency))))
+}
+
+// FormatAmount renders a minor-unit amount for people, e.g. "IDR 1,500,000" or "USD 125.00"
+func FormatAmount(amount int64, currency string) string {
+	exp := CurrencyExponent(currency)
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-exp], digits[len(digits)-exp:]
+
+	var grouped strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(r)
+	}
+	if exp > 0 {
+		return fmt.Sprintf("%s %s%s.%s", currency, sign, grouped.String(), fraction)
+	}
+	return fmt.Sprintf("%s %s%s", currency, sign, grouped.String())
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DocumentType represents the kind of document a visa requires
+type DocumentType string
+
+const (
+	DocumentPassportScan  DocumentType = "passport_scan"
+	DocumentPhoto         DocumentType = "photo"
+	DocumentBankStatement DocumentType = "bank_statement"
+	DocumentOther         DocumentType = "other"
+)
+
+// DocumentStatus represents the review state of an uploaded document
+type DocumentStatus string
+
+const (
+	DocumentStatusPending  DocumentStatus = "pending"
+	DocumentStatusApproved DocumentStatus = "approved"
+	DocumentStatusRejected DocumentStatus = "rejected"
+)
+
+// VisaDocumentRequirement is one entry of a visa's required-documents checklist
+type VisaDocumentRequirement struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	VisaID       uint           `json:"visa_id" gorm:"not null;index:idx_requirement_visa"`
+	Name         string         `json:"name" gorm:"not null;size:255"`
+	DocumentType DocumentType   `json:"document_type" gorm:"type:varchar(30);not null"`
+	Description  string         `json:"description" gorm:"type:text"`
+	IsRequired   bool           `json:"is_required" gorm:"not null"`
+	PerApplicant bool           `json:"per_applicant" gorm:"not null"` // one upload per applicant instead of per purchase
+	SortOrder    int            `json:"sort_order" gorm:"not null;default:0"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// PurchaseDocument is a file uploaded by a customer against a purchase checklist entry
+type PurchaseDocument struct {
+	ID              uint                    `json:"id" gorm:"primaryKey"`
+	PurchaseID      uint                    `json:"purchase_id" gorm:"not null;index:idx_document_purchase"`
+	RequirementID   uint                    `json:"requirement_id" gorm:"not null;index:idx_document_requirement"`
+	Requirement     VisaDocumentRequirement `json:"requirement" gorm:"foreignKey:RequirementID"`
+	ApplicantID     *uint                   `json:"applicant_id" gorm:"index"`
+	FilePath        string                  `json:"-" gorm:"not null"`
+	FileName        string                  `json:"file_name" gorm:"not null"`
+	FileSize        int64                   `json:"file_size"`
+	Status          DocumentStatus          `json:"status" gorm:"type:varchar(20);default:'pending';index:idx_document_status"`
+	RejectionReason string                  `json:"rejection_reason" gorm:"type:text"`
+	ReviewedByID    *uint                   `json:"reviewed_by_id"`
+	ReviewedAt      *time.Time              `json:"reviewed_at"`
+	CreatedAt       time.Time               `json:"created_at"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt          `json:"-" gorm:"index"`
+}
+
+// DocumentChecklistItem is one slot of a purchase's document checklist together
+// with the document currently filling it, if any
+type DocumentChecklistItem struct {
+	Requirement   VisaDocumentRequirement `json:"requirement"`
+	ApplicantID   *uint                   `json:"applicant_id,omitempty"`
+	ApplicantName string                  `json:"applicant_name,omitempty"`
+	Document      *PurchaseDocument       `json:"document"`
+	Satisfied     bool                    `json:"satisfied"`
+}
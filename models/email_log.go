@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// EmailStatus is the outcome of one attempt to send an email
+type EmailStatus string
+
+const (
+	EmailStatusSent   EmailStatus = "sent"
+	EmailStatusFailed EmailStatus = "failed"
+)
+
+// EmailLog records every email handed to the mail transport, sent or not.
+// Retried emails get one row per attempt. Bodies are not stored, as they
+// may hold one-time codes and links.
+type EmailLog struct {
+	ID                uint        `json:"id" gorm:"primaryKey"`
+	Recipient         string      `json:"recipient" gorm:"size:255;not null;index"`
+	Subject           string      `json:"subject" gorm:"size:500;not null"`
+	Template          string      `json:"template" gorm:"size:50;not null;index"`
+	Transport         string      `json:"transport" gorm:"size:20;not null"`             // smtp, file or memory
+	Status            EmailStatus `json:"status" gorm:"type:varchar(20);not null;index"` // sent or failed
+	ProviderMessageID string      `json:"provider_message_id" gorm:"size:255;index"`     // Message-ID header, or the transport's own ID
+	Error             string      `json:"error" gorm:"type:text"`
+	Attachments       int         `json:"attachments" gorm:"not null;default:0"`
+	CreatedAt         time.Time   `json:"created_at" gorm:"index"`
+}
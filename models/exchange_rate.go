@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ExchangeRate is the rate from one currency to another, valid from EffectiveAt
+// until a newer rate for the same pair takes effect. Rates are never edited so
+// purchases can always be traced back to the rate they were charged at.
+type ExchangeRate struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	BaseCurrency  string    `json:"base_currency" gorm:"size:3;not null;index:idx_exchange_rate_pair"`
+	QuoteCurrency string    `json:"quote_currency" gorm:"size:3;not null;index:idx_exchange_rate_pair"`
+	Rate          float64   `json:"rate" gorm:"type:numeric(20,10);not null"` // units of quote currency per unit of base currency
+	EffectiveAt   time.Time `json:"effective_at" gorm:"not null;index:idx_exchange_rate_pair"`
+	Source        string    `json:"source" gorm:"size:255"` // "manual" or the imported file name
+	CreatedByID   *uint     `json:"created_by_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ExchangeRateRequest represents request body for adding an exchange rate
+type ExchangeRateRequest struct {
+	BaseCurrency  string  `json:"base_currency" binding:"required,len=3" example:"USD"`
+	QuoteCurrency string  `json:"quote_currency" binding:"required,len=3" example:"IDR"`
+	Rate          float64 `json:"rate" binding:"required,gt=0" example:"15750"`
+	EffectiveAt   string  `json:"effective_at" example:"2024-01-01T00:00:00+07:00"` // RFC 3339, defaults to now
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers a request sent with an Idempotency-Key header so
+// retries get the original response instead of running again. Only used when
+// Redis is unavailable; keys live in Redis otherwise.
+type IdempotencyKey struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_key"`
+	Key         string    `json:"key" gorm:"size:255;not null;uniqueIndex:idx_idempotency_key"`
+	Fingerprint string    `json:"fingerprint" gorm:"size:64;not null"`   // SHA-256 of method, path and body
+	StatusCode  int       `json:"status_code" gorm:"not null;default:0"` // 0 while the first request is still running
+	ContentType string    `json:"content_type" gorm:"size:100"`
+	Body        []byte    `json:"-"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
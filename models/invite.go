@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// InviteStatus is the derived state of a UserInvite
+type InviteStatus string
+
+const (
+	InviteStatusPending  InviteStatus = "pending"
+	InviteStatusAccepted InviteStatus = "accepted"
+	InviteStatusRevoked  InviteStatus = "revoked"
+	InviteStatusExpired  InviteStatus = "expired"
+)
+
+// UserInvite is an invitation for a staff member to create an account with a given role.
+// Only a hash of the signed invite token is stored.
+type UserInvite struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	Email          string     `json:"email" gorm:"not null;index:idx_invite_email"`
+	Name           string     `json:"name"`
+	Role           UserRole   `json:"role" gorm:"type:varchar(20);not null"`
+	TokenHash      string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null"`
+	InvitedByID    uint       `json:"invited_by_id" gorm:"not null"`
+	InvitedBy      User       `json:"invited_by" gorm:"foreignKey:InvitedByID"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedUserID *uint      `json:"accepted_user_id"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	RevokedByID    *uint      `json:"revoked_by_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Status derives the invite state from its timestamps
+func (i UserInvite) Status() InviteStatus {
+	switch {
+	case i.AcceptedAt != nil:
+		return InviteStatusAccepted
+	case i.RevokedAt != nil:
+		return InviteStatusRevoked
+	case time.Now().After(i.ExpiresAt):
+		return InviteStatusExpired
+	default:
+		return InviteStatusPending
+	}
+}
+
+// Invite request models
+type CreateInviteRequest struct {
+	Email string   `json:"email" binding:"required,email" example:"officer@viskatera.com"`
+	Name  string   `json:"name" example:"Siti Rahma"`
+	Role  UserRole `json:"role" binding:"required,oneof=admin visa_officer finance support" example:"visa_officer"`
+}
+
+type AcceptInviteRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name" binding:"omitempty,min=2"`
+	Password string `json:"password" binding:"required,min=6"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// InvoiceType distinguishes invoices from credit notes, which are numbered separately
+type InvoiceType string
+
+const (
+	InvoiceTypeInvoice    InvoiceType = "invoice"
+	InvoiceTypeCreditNote InvoiceType = "credit_note"
+)
+
+// Prefix is the number prefix of the type, e.g. INV in INV/2026/000123
+func (t InvoiceType) Prefix() string {
+	if t == InvoiceTypeCreditNote {
+		return "CN"
+	}
+	return "INV"
+}
+
+// Invoice is an issued invoice or credit note. Numbers run per type and
+// calendar year without gaps and are never reused; issued documents are
+// never edited. The rendered PDF is kept at FilePath.
+type Invoice struct {
+	ID         uint        `json:"id" gorm:"primaryKey"`
+	Type       InvoiceType `json:"type" gorm:"type:varchar(20);not null;uniqueIndex:idx_invoice_sequence"`
+	Year       int         `json:"year" gorm:"not null;uniqueIndex:idx_invoice_sequence"`
+	Sequence   int         `json:"sequence" gorm:"not null;uniqueIndex:idx_invoice_sequence"`
+	Number     string      `json:"number" gorm:"size:30;not null;uniqueIndex"`
+	PurchaseID uint        `json:"purchase_id" gorm:"not null;index"`
+	UserID     uint        `json:"user_id" gorm:"not null;index"`
+
+	PaymentID         *uint `json:"payment_id" gorm:"uniqueIndex"` // invoices: the payment, nil when nothing was due
+	RefundID          *uint `json:"refund_id" gorm:"uniqueIndex"`  // credit notes: the refund
+	CreditedInvoiceID *uint `json:"credited_invoice_id"`           // credit notes: the invoice reduced
+
+	Amount    int64     `json:"amount" gorm:"not null"` // minor units of Currency, positive for credit notes too
+	Currency  string    `json:"currency" gorm:"size:3;not null"`
+	IssuedAt  time.Time `json:"issued_at" gorm:"not null;index"`
+	FilePath  string    `json:"-" gorm:"size:500"` // empty until the PDF is first rendered
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InvoiceSequence holds the last number issued per invoice type and year
+type InvoiceSequence struct {
+	Type       InvoiceType `gorm:"type:varchar(20);primaryKey"`
+	Year       int         `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber int         `gorm:"not null"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// OutboxStatus is the delivery state of an outbox message
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "pending"
+	OutboxStatusSent    OutboxStatus = "sent"
+)
+
+// OutboxStuckAfter is how long a message may stay pending before it counts as stuck
+const OutboxStuckAfter = 5 * time.Minute
+
+// OutboxMessage is a queue job written in the same transaction as the change
+// that triggers it. The outbox relay publishes it to RabbitMQ after commit and
+// keeps retrying until the broker accepts it, so jobs are neither lost while
+// RabbitMQ is down nor sent for changes that rolled back.
+type OutboxMessage struct {
+	ID            uint         `json:"id" gorm:"primaryKey"`
+	Queue         string       `json:"queue" gorm:"size:100;not null;index"`
+	Payload       string       `json:"payload" gorm:"type:text;not null"` // JSON job body
+	Status        OutboxStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending';index:idx_outbox_due,priority:1"`
+	Attempts      int          `json:"attempts" gorm:"not null;default:0"`
+	LastError     string       `json:"last_error" gorm:"type:text"`
+	NextAttemptAt time.Time    `json:"next_attempt_at" gorm:"not null;index:idx_outbox_due,priority:2"`
+	SentAt        *time.Time   `json:"sent_at"`
+	CreatedAt     time.Time    `json:"created_at" gorm:"index"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
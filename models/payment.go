@@ -7,17 +7,24 @@ import (
 )
 
 type Payment struct {
-	ID            uint           `json:"id" gorm:"primaryKey"`
-	UserID        uint           `json:"user_id" gorm:"not null;index:idx_payment_user_status,idx_payment_user_created"`
-	User          User           `json:"user" gorm:"foreignKey:UserID"`
-	PurchaseID    uint           `json:"purchase_id" gorm:"not null;index:idx_payment_purchase"`
-	Purchase      VisaPurchase   `json:"purchase" gorm:"foreignKey:PurchaseID"`
-	PaymentMethod string         `json:"payment_method" gorm:"not null;index:idx_payment_method"`
-	Amount        float64        `json:"amount" gorm:"not null;index:idx_payment_amount"`
-	Status        string         `json:"status" gorm:"default:'pending';index:idx_payment_user_status,idx_payment_status_created"` // pending, paid, expired, failed
-	XenditID      string         `json:"xendit_id" gorm:"index:idx_payment_xendit,unique"`
-	PaymentURL    string         `json:"payment_url"`
-	CreatedAt     time.Time      `json:"created_at" gorm:"index:idx_payment_user_created,idx_payment_status_created"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	UserID         uint           `json:"user_id" gorm:"not null;index:idx_payment_user_status,idx_payment_user_created"`
+	User           User           `json:"user" gorm:"foreignKey:UserID"`
+	PurchaseID     uint           `json:"purchase_id" gorm:"not null;index:idx_payment_purchase"`
+	Purchase       VisaPurchase   `json:"purchase" gorm:"foreignKey:PurchaseID"`
+	PaymentMethod  string         `json:"payment_method" gorm:"not null;index:idx_payment_method"`
+	Amount         int64          `json:"amount" gorm:"not null;index:idx_payment_amount"` // minor units of Currency
+	Currency       string         `json:"currency" gorm:"size:3;not null;default:'IDR'"`
+	Status         string         `json:"status" gorm:"default:'pending';index:idx_payment_user_status,idx_payment_status_created,idx_payment_status_expires"` // pending, paid, expired, failed, refunded
+	RefundedAmount int64          `json:"refunded_amount" gorm:"not null;default:0"`                                                                           // sum of completed refunds
+	RefundRequired bool           `json:"refund_required" gorm:"not null;default:false;index"`                                                                 // paid after the purchase was cancelled, refunded or paid otherwise
+	Gateway        string         `json:"gateway" gorm:"size:30;not null;default:'xendit'"`                                                                    // payment gateway that issued the invoice
+	XenditID       string         `json:"xendit_id" gorm:"index:idx_payment_xendit,unique"`                                                                    // invoice ID at the gateway
+	ExternalID     string         `json:"external_id" gorm:"size:255;index:idx_payment_external"`                                                              // our reference sent to the gateway
+	PaymentURL     string         `json:"payment_url"`
+	ExpiresAt      *time.Time     `json:"expires_at" gorm:"index:idx_payment_status_expires"` // invoice expiry at the gateway
+	LastCheckedAt  *time.Time     `json:"-"`                                                  // last time the expiry job tried to settle it
+	CreatedAt      time.Time      `json:"created_at" gorm:"index:idx_payment_user_created,idx_payment_status_created"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 }
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Permission is a single capability checked by middleware.RequirePermission
+type Permission string
+
+const (
+	// Self-service: a user acting on their own account and applications
+	PermProfileManage      Permission = "profile.manage"
+	PermApplicationsManage Permission = "applications.manage"
+
+	// Back office
+	PermVisasManage        Permission = "visas.manage" // catalogue, prices, options and checklists
+	PermApplicationsRead   Permission = "applications.read"
+	PermApplicationsUpdate Permission = "applications.update" // move applications through the lifecycle
+	PermDocumentsReview    Permission = "documents.review"
+	PermPaymentsRead       Permission = "payments.read"
+	PermPaymentsManage     Permission = "payments.manage"
+	PermPromosManage       Permission = "promos.manage" // promo codes
+	PermUsersRead          Permission = "users.read"
+	PermUsersManage        Permission = "users.manage"
+	PermRolesManage        Permission = "roles.manage"
+	PermActivitiesRead     Permission = "activities.read"
+	PermReportsExport      Permission = "reports.export"
+	PermMonitoringRead     Permission = "monitoring.read"
+	PermMonitoringManage   Permission = "monitoring.manage" // requeue and purge dead-lettered jobs
+)
+
+// AllPermissions lists every permission known to the system
+var AllPermissions = []Permission{
+	PermProfileManage,
+	PermApplicationsManage,
+	PermVisasManage,
+	PermApplicationsRead,
+	PermApplicationsUpdate,
+	PermDocumentsReview,
+	PermPaymentsRead,
+	PermPaymentsManage,
+	PermPromosManage,
+	PermUsersRead,
+	PermUsersManage,
+	PermRolesManage,
+	PermActivitiesRead,
+	PermReportsExport,
+	PermMonitoringRead,
+	PermMonitoringManage,
+}
+
+var selfService = []Permission{PermProfileManage, PermApplicationsManage}
+
+// rolePermissions is the default permission set of each role. Per-user
+// overrides in UserPermission are applied on top of it.
+var rolePermissions = map[UserRole][]Permission{
+	RoleCustomer: selfService,
+	RoleAdmin:    AllPermissions,
+	RoleVisaOfficer: append([]Permission{
+		PermApplicationsRead,
+		PermApplicationsUpdate,
+		PermDocumentsReview,
+		PermActivitiesRead,
+		PermReportsExport,
+	}, selfService...),
+	RoleFinance: append([]Permission{
+		PermApplicationsRead,
+		PermPaymentsRead,
+		PermPaymentsManage,
+		PermActivitiesRead,
+		PermReportsExport,
+	}, selfService...),
+	RoleSupport: append([]Permission{
+		PermApplicationsRead,
+		PermPaymentsRead,
+		PermUsersRead,
+		PermActivitiesRead,
+		PermMonitoringRead,
+	}, selfService...),
+}
+
+// IsValid reports whether p is a known permission
+func (p Permission) IsValid() bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissions returns the default permissions of a role, sorted
+func RolePermissions(role UserRole) []Permission {
+	perms := append([]Permission{}, rolePermissions[role]...)
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+// AllRoles returns every known role, sorted
+func AllRoles() []UserRole {
+	roles := make([]UserRole, 0, len(rolePermissions))
+	for role := range rolePermissions {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	return roles
+}
+
+// UserPermission grants or revokes a single permission for one user,
+// overriding the default of the user's role
+type UserPermission struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_user_permission"`
+	Permission  Permission `json:"permission" gorm:"type:varchar(50);not null;uniqueIndex:idx_user_permission"`
+	Granted     bool       `json:"granted" gorm:"not null"` // false revokes a permission the role would grant
+	CreatedByID *uint      `json:"created_by_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// EffectivePermissions applies per-user overrides to the role defaults
+func EffectivePermissions(role UserRole, overrides []UserPermission) []Permission {
+	set := map[Permission]bool{}
+	for _, perm := range rolePermissions[role] {
+		set[perm] = true
+	}
+	for _, override := range overrides {
+		set[override.Permission] = override.Granted
+	}
+
+	perms := []Permission{}
+	for perm, granted := range set {
+		if granted {
+			perms = append(perms, perm)
+		}
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
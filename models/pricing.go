@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PricingRuleType is what a pricing rule adds to a purchase
+type PricingRuleType string
+
+const (
+	PricingServiceFee PricingRuleType = "service_fee" // platform fee, charged on the discounted subtotal
+	PricingTax        PricingRuleType = "tax"         // e.g. PPN, charged on the discounted subtotal plus service fee
+)
+
+// PricingRule adds a service fee or tax to purchases of matching visas. An
+// empty Country or VisaType matches every visa; of the matching active rules
+// of a type only the most specific one applies (country and type, then
+// country, then type, then the catch-all rule).
+type PricingRule struct {
+	ID           uint            `json:"id" gorm:"primaryKey"`
+	Name         string          `json:"name" gorm:"size:100;not null"` // shown on invoices, e.g. "PPN 11%"
+	Type         PricingRuleType `json:"type" gorm:"type:varchar(20);not null;index"`
+	Country      string          `json:"country" gorm:"size:100;not null;default:''"`
+	VisaType     string          `json:"visa_type" gorm:"size:100;not null;default:''"`
+	Percentage   float64         `json:"percentage" gorm:"type:numeric(7,4);not null;default:0"` // percent of the base amount
+	FixedAmount  int64           `json:"fixed_amount" gorm:"not null;default:0"`                 // minor units of Currency, added on top
+	Currency     string          `json:"currency" gorm:"size:3;not null;default:'IDR'"`
+	PerApplicant bool            `json:"per_applicant" gorm:"not null"` // FixedAmount is charged once per applicant
+	IsActive     bool            `json:"is_active" gorm:"not null;index"`
+	CreatedByID  *uint           `json:"created_by_id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt  `json:"-" gorm:"index"`
+}
+
+// PricingRuleRequest represents request body for creating or replacing a pricing rule
+type PricingRuleRequest struct {
+	Name         string          `json:"name" binding:"required,max=100" example:"PPN 11%"`
+	Type         PricingRuleType `json:"type" binding:"required,oneof=service_fee tax" example:"tax"`
+	Country      string          `json:"country" binding:"max=100" example:""` // empty for every country
+	VisaType     string          `json:"visa_type" binding:"max=100" example:""`
+	Percentage   float64         `json:"percentage" binding:"min=0,max=100" example:"11"`
+	FixedAmount  int64           `json:"fixed_amount" binding:"min=0" example:"0"`
+	Currency     string          `json:"currency" example:"IDR"` // defaults to the charge currency
+	PerApplicant bool            `json:"per_applicant" example:"false"`
+	IsActive     *bool           `json:"is_active" example:"true"`
+}
+
+// LineItemKind identifies a line of a purchase's price breakdown
+type LineItemKind string
+
+const (
+	LineItemVisa       LineItemKind = "visa"
+	LineItemOption     LineItemKind = "visa_option"
+	LineItemDiscount   LineItemKind = "discount"
+	LineItemServiceFee LineItemKind = "service_fee"
+	LineItemTax        LineItemKind = "tax"
+)
+
+// PurchaseLineItem is one line of the price breakdown snapshotted on a
+// purchase. Amounts are minor units of the purchase's charge currency and add
+// up to its TotalPrice; discounts are negative.
+type PurchaseLineItem struct {
+	ID            uint         `json:"id" gorm:"primaryKey"`
+	PurchaseID    uint         `json:"purchase_id" gorm:"not null;index"`
+	Position      int          `json:"position" gorm:"not null"`
+	Kind          LineItemKind `json:"kind" gorm:"type:varchar(20);not null"`
+	Description   string       `json:"description" gorm:"size:255;not null"`
+	Quantity      int          `json:"quantity" gorm:"not null"`
+	UnitPrice     int64        `json:"unit_price" gorm:"not null"`
+	Amount        int64        `json:"amount" gorm:"not null"`
+	Currency      string       `json:"currency" gorm:"size:3;not null"`
+	PricingRuleID *uint        `json:"pricing_rule_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// IsCharge reports whether the line is a fee or tax added after the subtotal
+func (i PurchaseLineItem) IsCharge() bool {
+	return i.Kind == LineItemServiceFee || i.Kind == LineItemTax
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PromoDiscountType is how a promo code reduces the price
+type PromoDiscountType string
+
+const (
+	PromoPercentage PromoDiscountType = "percentage" // DiscountValue is a percentage of the subtotal
+	PromoFixed      PromoDiscountType = "fixed"      // DiscountValue is an amount in minor units of Currency
+)
+
+// PromoCode is a discount customers can apply at checkout. Amounts (fixed
+// discount, minimum spend) are in minor units of Currency and only apply to
+// purchases charged in that currency.
+type PromoCode struct {
+	ID              uint              `json:"id" gorm:"primaryKey"`
+	Code            string            `json:"code" gorm:"size:50;not null;uniqueIndex"` // stored upper case
+	Description     string            `json:"description"`
+	DiscountType    PromoDiscountType `json:"discount_type" gorm:"type:varchar(20);not null"`
+	DiscountValue   int64             `json:"discount_value" gorm:"not null"`
+	Currency        string            `json:"currency" gorm:"size:3;not null;default:'IDR'"`
+	MinSpend        int64             `json:"min_spend" gorm:"not null;default:0"` // minimum subtotal, 0 for none
+	ValidFrom       *time.Time        `json:"valid_from"`
+	ValidUntil      *time.Time        `json:"valid_until"`
+	MaxRedemptions  int               `json:"max_redemptions" gorm:"not null;default:0"`    // across all customers, 0 for unlimited
+	MaxPerUser      int               `json:"max_per_user" gorm:"not null;default:0"`       // per customer, 0 for unlimited
+	RedemptionCount int               `json:"redemption_count" gorm:"not null;default:0"`   // current redemptions
+	Countries       []string          `json:"countries" gorm:"serializer:json;type:jsonb"`  // visa countries it applies to, empty for all
+	VisaTypes       []string          `json:"visa_types" gorm:"serializer:json;type:jsonb"` // visa types it applies to, empty for all
+	IsActive        bool              `json:"is_active" gorm:"not null;index"`
+	CreatedByID     *uint             `json:"created_by_id"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt    `json:"-" gorm:"index"`
+}
+
+// PromoRedemption records a promo code used on a purchase
+type PromoRedemption struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	PromoCodeID    uint      `json:"promo_code_id" gorm:"not null;index:idx_promo_redemption_user"`
+	UserID         uint      `json:"user_id" gorm:"not null;index:idx_promo_redemption_user"`
+	PurchaseID     uint      `json:"purchase_id" gorm:"not null;uniqueIndex"`
+	DiscountAmount int64     `json:"discount_amount" gorm:"not null"` // minor units of Currency
+	Currency       string    `json:"currency" gorm:"size:3;not null"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CreatePromoCodeRequest represents request body for creating a promo code
+type CreatePromoCodeRequest struct {
+	Code           string            `json:"code" binding:"required,min=3,max=50,alphanum" example:"HOLIDAY10"`
+	Description    string            `json:"description" example:"10% off for the holiday season"`
+	DiscountType   PromoDiscountType `json:"discount_type" binding:"required,oneof=percentage fixed" example:"percentage"`
+	DiscountValue  int64             `json:"discount_value" binding:"required,min=1" example:"10"` // percent, or minor units for fixed
+	Currency       string            `json:"currency" example:"IDR"`                               // defaults to the charge currency
+	MinSpend       int64             `json:"min_spend" binding:"min=0" example:"1000000"`
+	ValidFrom      string            `json:"valid_from" example:"2024-12-01T00:00:00+07:00"` // RFC 3339 or YYYY-MM-DD
+	ValidUntil     string            `json:"valid_until" example:"2025-01-01T00:00:00+07:00"`
+	MaxRedemptions int               `json:"max_redemptions" binding:"min=0" example:"500"`
+	MaxPerUser     int               `json:"max_per_user" binding:"min=0" example:"1"`
+	Countries      []string          `json:"countries" example:"Japan,Korea"`
+	VisaTypes      []string          `json:"visa_types" example:"Tourist"`
+	IsActive       *bool             `json:"is_active" example:"true"`
+}
+
+// UpdatePromoCodeRequest represents request body for updating a promo code. Omitted fields stay unchanged.
+type UpdatePromoCodeRequest struct {
+	Description    *string   `json:"description" example:"10% off for the holiday season"`
+	DiscountValue  *int64    `json:"discount_value" binding:"omitempty,min=1" example:"15"`
+	MinSpend       *int64    `json:"min_spend" binding:"omitempty,min=0" example:"0"`
+	ValidFrom      *string   `json:"valid_from" example:"2024-12-01"` // empty string clears it
+	ValidUntil     *string   `json:"valid_until" example:"2025-01-15"`
+	MaxRedemptions *int      `json:"max_redemptions" binding:"omitempty,min=0" example:"1000"`
+	MaxPerUser     *int      `json:"max_per_user" binding:"omitempty,min=0" example:"2"`
+	Countries      *[]string `json:"countries"`
+	VisaTypes      *[]string `json:"visa_types"`
+	IsActive       *bool     `json:"is_active" example:"false"`
+}
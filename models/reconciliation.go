@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// ReconciliationSource is where the gateway side of a reconciliation comes from
+type ReconciliationSource string
+
+const (
+	ReconciliationSourceSettlement ReconciliationSource = "settlement_file" // uploaded settlement report
+	ReconciliationSourceGateway    ReconciliationSource = "gateway"         // invoice statuses pulled from the gateway
+)
+
+// ReconciliationStatus is the state of a reconciliation run
+type ReconciliationStatus string
+
+const (
+	ReconciliationRunning   ReconciliationStatus = "running"
+	ReconciliationCompleted ReconciliationStatus = "completed"
+	ReconciliationFailed    ReconciliationStatus = "failed"
+)
+
+// ReconciliationIssue is the kind of disagreement found for one payment
+type ReconciliationIssue string
+
+const (
+	IssueAmountMismatch    ReconciliationIssue = "amount_mismatch"
+	IssueStatusMismatch    ReconciliationIssue = "status_mismatch"
+	IssueMissingInGateway  ReconciliationIssue = "missing_in_gateway"  // we have the payment, the gateway does not
+	IssueMissingInPayments ReconciliationIssue = "missing_in_payments" // the gateway has it, we do not
+)
+
+// ReconciliationRun is one comparison of our payments against gateway data
+type ReconciliationRun struct {
+	ID           uint                 `json:"id" gorm:"primaryKey"`
+	Source       ReconciliationSource `json:"source" gorm:"type:varchar(30);not null"`
+	Gateway      string               `json:"gateway" gorm:"size:30;not null"`
+	FileName     string               `json:"file_name" gorm:"size:255"`
+	PeriodFrom   *time.Time           `json:"period_from"`
+	PeriodTo     *time.Time           `json:"period_to"`
+	Status       ReconciliationStatus `json:"status" gorm:"type:varchar(20);not null;index"`
+	TotalRecords int                  `json:"total_records"`
+	MatchedCount int                  `json:"matched_count"`
+	IssueCount   int                  `json:"issue_count"`
+	Error        string               `json:"error" gorm:"type:text"`
+	CreatedByID  *uint                `json:"created_by_id"`
+	CompletedAt  *time.Time           `json:"completed_at"`
+	CreatedAt    time.Time            `json:"created_at" gorm:"index"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+// ReconciliationItem is one flagged disagreement of a reconciliation run
+type ReconciliationItem struct {
+	ID            uint                `json:"id" gorm:"primaryKey"`
+	RunID         uint                `json:"run_id" gorm:"not null;index:idx_reconciliation_item_run"`
+	Issue         ReconciliationIssue `json:"issue" gorm:"type:varchar(30);not null;index:idx_reconciliation_item_run"`
+	PaymentID     *uint               `json:"payment_id" gorm:"index"`
+	InvoiceID     string              `json:"invoice_id" gorm:"size:255"`
+	ExternalID    string              `json:"external_id" gorm:"size:255"`
+	Currency      string              `json:"currency" gorm:"size:3"`
+	OurAmount     *int64              `json:"our_amount"` // minor units of Currency
+	GatewayAmount *int64              `json:"gateway_amount"`
+	OurStatus     string              `json:"our_status" gorm:"size:30"`
+	GatewayStatus string              `json:"gateway_status" gorm:"size:30"`
+	Details       string              `json:"details" gorm:"type:text"`
+	CreatedAt     time.Time           `json:"created_at"`
+}
+
+// GatewayReconciliationRequest represents request body for reconciling against live gateway data
+type GatewayReconciliationRequest struct {
+	From    string `json:"from" binding:"required" example:"2024-01-01"` // YYYY-MM-DD, payments created on or after
+	To      string `json:"to" binding:"required" example:"2024-01-31"`   // YYYY-MM-DD, inclusive
+	Gateway string `json:"gateway" example:"xendit"`                     // defaults to the active gateway
+}
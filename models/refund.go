@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// RefundStatus is the state of a refund at the payment gateway
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// Refund returns all or part of a paid payment through its payment gateway
+type Refund struct {
+	ID              uint         `json:"id" gorm:"primaryKey"`
+	PaymentID       uint         `json:"payment_id" gorm:"not null;index:idx_refund_payment"`
+	Payment         Payment      `json:"-" gorm:"foreignKey:PaymentID"`
+	PurchaseID      uint         `json:"purchase_id" gorm:"not null;index:idx_refund_purchase"`
+	Amount          int64        `json:"amount" gorm:"not null"` // minor units of the payment currency
+	Reason          string       `json:"reason" gorm:"type:text;not null"`
+	Status          RefundStatus `json:"status" gorm:"type:varchar(20);not null;index:idx_refund_status_created"`
+	Gateway         string       `json:"gateway" gorm:"size:30;not null"`
+	GatewayRefundID string       `json:"gateway_refund_id" gorm:"size:255;index:idx_refund_gateway_ref"` // provider reference
+	FailureReason   string       `json:"failure_reason" gorm:"type:text"`
+	RequestedByID   *uint        `json:"requested_by_id"`
+	CompletedAt     *time.Time   `json:"completed_at"`
+	CreatedAt       time.Time    `json:"created_at" gorm:"index:idx_refund_status_created"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// CreateRefundRequest represents request body for refunding a payment
+type CreateRefundRequest struct {
+	Amount int64  `json:"amount" binding:"min=0" example:"250000"` // minor units; omit or 0 to refund the remaining amount
+	Reason string `json:"reason" binding:"required,max=1000" example:"Visa application rejected by embassy"`
+}
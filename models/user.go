@@ -9,16 +9,26 @@ import (
 type UserRole string
 
 const (
-	RoleCustomer UserRole = "customer"
-	RoleAdmin    UserRole = "admin"
+	RoleCustomer    UserRole = "customer"
+	RoleAdmin       UserRole = "admin"
+	RoleVisaOfficer UserRole = "visa_officer"
+	RoleFinance     UserRole = "finance"
+	RoleSupport     UserRole = "support"
 )
 
+// IsValid reports whether r is a known role
+func (r UserRole) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
 type User struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	Email       string         `json:"email" gorm:"unique;not null;index:idx_user_email_active"`
 	Password    string         `json:"-" gorm:"not null"`
 	Name        string         `json:"name" gorm:"not null;index:idx_user_name"`
 	AvatarURL   string         `json:"avatar_url"`
+	Language    string         `json:"language" gorm:"type:varchar(5);not null;default:'id'"` // "id" or "en", for emails, invoices and error messages
 	GoogleID    string         `json:"google_id" gorm:"index:idx_user_google"`
 	Role        UserRole       `json:"role" gorm:"type:varchar(20);default:'customer';index:idx_user_role_active"`
 	IsActive    bool           `json:"is_active" gorm:"default:true;index:idx_user_email_active,idx_user_role_active"`
@@ -37,5 +47,5 @@ type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 	Name     string `json:"name" binding:"required"`
-	Role     string `json:"role,omitempty" binding:"omitempty,oneof=customer admin"`
+	Language string `json:"language" binding:"omitempty,oneof=id en"` // defaults to the Accept-Language header
 }
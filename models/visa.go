@@ -11,7 +11,8 @@ type Visa struct {
 	Country         string         `json:"country" gorm:"not null;index:idx_visa_country_active"`
 	Type            string         `json:"type" gorm:"not null;index:idx_visa_type_active"`
 	Description     string         `json:"description"`
-	Price           float64        `json:"price" gorm:"not null;index:idx_visa_price"`
+	Price           int64          `json:"price" gorm:"not null;index:idx_visa_price"` // minor units of Currency
+	Currency        string         `json:"currency" gorm:"size:3;not null;default:'IDR'"`
 	Duration        int            `json:"duration" gorm:"not null"` // in days
 	VisaDocumentURL string         `json:"visa_document_url"`
 	IsActive        bool           `json:"is_active" gorm:"default:true;index:idx_visa_country_active,idx_visa_type_active"`
@@ -26,7 +27,8 @@ type VisaOption struct {
 	Visa        Visa           `json:"visa" gorm:"foreignKey:VisaID"`
 	Name        string         `json:"name" gorm:"not null"`
 	Description string         `json:"description"`
-	Price       float64        `json:"price" gorm:"not null;index:idx_visa_option_price"`
+	Price       int64          `json:"price" gorm:"not null;index:idx_visa_option_price"` // minor units of Currency
+	Currency    string         `json:"currency" gorm:"size:3;not null;default:'IDR'"`     // always the visa's currency
 	IsActive    bool           `json:"is_active" gorm:"default:true;index:idx_visa_option_visa_active"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
@@ -34,22 +36,57 @@ type VisaOption struct {
 }
 
 type VisaPurchase struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	UserID       uint           `json:"user_id" gorm:"not null;index:idx_purchase_user_status,idx_purchase_user_created"`
-	User         User           `json:"user" gorm:"foreignKey:UserID"`
-	VisaID       uint           `json:"visa_id" gorm:"not null;index:idx_purchase_visa"`
-	Visa         Visa           `json:"visa" gorm:"foreignKey:VisaID"`
-	VisaOptionID *uint          `json:"visa_option_id" gorm:"index:idx_purchase_option"`
-	VisaOption   *VisaOption    `json:"visa_option" gorm:"foreignKey:VisaOptionID"`
-	TotalPrice   float64        `json:"total_price" gorm:"not null;index:idx_purchase_price"`
-	Status       string         `json:"status" gorm:"default:'pending';index:idx_purchase_user_status,idx_purchase_status_created"` // pending, completed, cancelled
-	CreatedAt    time.Time      `json:"created_at" gorm:"index:idx_purchase_user_created,idx_purchase_status_created"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint                `json:"id" gorm:"primaryKey"`
+	UserID       uint                `json:"user_id" gorm:"not null;index:idx_purchase_user_status,idx_purchase_user_created"`
+	User         User                `json:"user" gorm:"foreignKey:UserID"`
+	VisaID       uint                `json:"visa_id" gorm:"not null;index:idx_purchase_visa"`
+	Visa         Visa                `json:"visa" gorm:"foreignKey:VisaID"`
+	VisaOptionID *uint               `json:"visa_option_id" gorm:"index:idx_purchase_option"`
+	VisaOption   *VisaOption         `json:"visa_option" gorm:"foreignKey:VisaOptionID"`
+	Applicants   []PurchaseApplicant `json:"applicants" gorm:"foreignKey:VisaPurchaseID"` // as they were at purchase time
+	TravelDate   *time.Time          `json:"travel_date" gorm:"type:date"`
+	TotalPrice   int64               `json:"total_price" gorm:"not null;index:idx_purchase_price"` // charged amount, minor units of Currency
+	Currency     string              `json:"currency" gorm:"size:3;not null;default:'IDR'"`
+
+	// Price in the visa's own currency and the rate snapshot used to convert it
+	OriginalTotal    int64   `json:"original_total" gorm:"not null;default:0"`
+	OriginalCurrency string  `json:"original_currency" gorm:"size:3;not null;default:'IDR'"`
+	ExchangeRate     float64 `json:"exchange_rate" gorm:"type:numeric(20,10);not null;default:1"`
+	ExchangeRateID   *uint   `json:"exchange_rate_id"`
+
+	// Price breakdown in the charge currency:
+	// TotalPrice = Subtotal - DiscountAmount + ServiceFee + TaxAmount
+	Subtotal       int64              `json:"subtotal" gorm:"not null;default:0"`
+	DiscountAmount int64              `json:"discount_amount" gorm:"not null;default:0"`
+	PromoCodeID    *uint              `json:"promo_code_id" gorm:"index"`
+	PromoCode      string             `json:"promo_code" gorm:"size:50"`
+	ServiceFee     int64              `json:"service_fee" gorm:"not null;default:0"`
+	TaxAmount      int64              `json:"tax_amount" gorm:"not null;default:0"`
+	LineItems      []PurchaseLineItem `json:"line_items,omitempty" gorm:"foreignKey:PurchaseID"`
+
+	Status PurchaseStatus `json:"status" gorm:"type:varchar(30);default:'draft';index:idx_purchase_user_status,idx_purchase_status_created"`
+
+	// Lifecycle timestamps, set when the purchase enters the matching status
+	SubmittedAt          *time.Time `json:"submitted_at"`
+	ReviewStartedAt      *time.Time `json:"review_started_at"`
+	SubmittedToEmbassyAt *time.Time `json:"submitted_to_embassy_at"`
+	ApprovedAt           *time.Time `json:"approved_at"`
+	RejectedAt           *time.Time `json:"rejected_at"`
+	IssuedAt             *time.Time `json:"issued_at"`
+	CancelledAt          *time.Time `json:"cancelled_at"`
+	RefundedAt           *time.Time `json:"refunded_at"`
+
+	LastCheckedAt *time.Time `json:"-"` // last time the payment expiry job tried to cancel it
+
+	CreatedAt time.Time      `json:"created_at" gorm:"index:idx_purchase_user_created,idx_purchase_status_created"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 type PurchaseRequest struct {
 	VisaID       uint   `json:"visa_id" binding:"required"`
 	VisaOptionID *uint  `json:"visa_option_id"`
-	Status       string `json:"status,omitempty"`
+	ApplicantIDs []uint `json:"applicant_ids" binding:"required,min=1,dive,required"`
+	TravelDate   string `json:"travel_date" binding:"required,datetime=2006-01-02" example:"2026-12-20"`
+	PromoCode    string `json:"promo_code" binding:"omitempty,max=50" example:"HOLIDAY10"`
 }
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// WebhookEventStatus is the processing state of a received webhook event
+type WebhookEventStatus string
+
+const (
+	WebhookEventReceived  WebhookEventStatus = "received"
+	WebhookEventProcessed WebhookEventStatus = "processed"
+	WebhookEventIgnored   WebhookEventStatus = "ignored" // valid but had nothing to change
+	WebhookEventFailed    WebhookEventStatus = "failed"
+)
+
+// WebhookEvent stores every webhook delivery we accepted, keyed by the
+// provider's event ID, so duplicates can be detected and events replayed
+type WebhookEvent struct {
+	ID               uint               `json:"id" gorm:"primaryKey"`
+	Provider         string             `json:"provider" gorm:"size:30;not null;uniqueIndex:idx_webhook_provider_event"`
+	EventID          string             `json:"event_id" gorm:"size:255;not null;uniqueIndex:idx_webhook_provider_event"`
+	EventType        string             `json:"event_type" gorm:"size:50"`
+	ResourceID       string             `json:"resource_id" gorm:"size:255;index:idx_webhook_resource"` // gateway invoice ID
+	Payload          string             `json:"payload" gorm:"type:text;not null"`
+	Status           WebhookEventStatus `json:"status" gorm:"type:varchar(20);not null;index:idx_webhook_status_created"`
+	Error            string             `json:"error" gorm:"type:text"`
+	Attempts         int                `json:"attempts" gorm:"not null;default:0"`
+	ProcessedAt      *time.Time         `json:"processed_at"`
+	LastReplayedAt   *time.Time         `json:"last_replayed_at"`
+	LastReplayedByID *uint              `json:"last_replayed_by_id"`
+	CreatedAt        time.Time          `json:"created_at" gorm:"index:idx_webhook_status_created"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,291 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvoiceNotPending is returned when simulating an outcome for an invoice that already has one
+var ErrInvoiceNotPending = errors.New("invoice is not pending")
+
+// fakeInvoiceDuration matches Xendit's default invoice duration
+const fakeInvoiceDuration = 24 * time.Hour
+
+// Fake invoice statuses, spelled like Xendit's
+const (
+	fakeStatusPending = "PENDING"
+	fakeStatusPaid    = "PAID"
+	fakeStatusExpired = "EXPIRED"
+	fakeStatusFailed  = "FAILED"
+)
+
+// fakeWebhookPayload is what the fake gateway posts to /webhooks/fake
+type fakeWebhookPayload struct {
+	EventID     string  `json:"event_id"`
+	Kind        string  `json:"kind"`
+	ID          string  `json:"id"`
+	ExternalID  string  `json:"external_id"`
+	Status      string  `json:"status"`
+	Amount      float64 `json:"amount"`
+	PaidAmount  float64 `json:"paid_amount"`
+	RefundID    string  `json:"refund_id,omitempty"`
+	ReferenceID string  `json:"reference_id,omitempty"`
+}
+
+// FakeGateway is an in-process payment gateway for local demos and end-to-end
+// tests. Invoices live in memory; after FAKE_GATEWAY_DELAY each new invoice
+// gets the FAKE_GATEWAY_OUTCOME (paid, expired, failed or manual) and a
+// webhook is posted back to our own /webhooks/fake endpoint, just like a real
+// gateway would. Refunds always succeed, also after FAKE_GATEWAY_DELAY.
+type FakeGateway struct {
+	mu       sync.Mutex
+	invoices map[string]*Invoice
+	refunded map[string]float64
+
+	baseURL       string
+	callbackToken string
+	outcome       string
+	delay         time.Duration
+	client        *http.Client
+}
+
+// NewFakeGateway configures the fake gateway from FAKE_GATEWAY_* variables
+func NewFakeGateway() *FakeGateway {
+	baseURL := os.Getenv("FAKE_GATEWAY_URL")
+	if baseURL == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		baseURL = "http://localhost:" + port + "/api/v1"
+	}
+
+	outcome := strings.ToLower(os.Getenv("FAKE_GATEWAY_OUTCOME"))
+	switch outcome {
+	case "paid", "expired", "failed", "manual":
+	default:
+		outcome = "paid"
+	}
+
+	delay, err := time.ParseDuration(os.Getenv("FAKE_GATEWAY_DELAY"))
+	if err != nil || delay < 0 {
+		delay = 3 * time.Second
+	}
+
+	// The webhook only travels back into this process, so a random token is enough
+	callbackToken := os.Getenv("FAKE_GATEWAY_CALLBACK_TOKEN")
+	if callbackToken == "" {
+		callbackToken = fakeID("tok")
+	}
+
+	return &FakeGateway{
+		invoices:      map[string]*Invoice{},
+		refunded:      map[string]float64{},
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		callbackToken: callbackToken,
+		outcome:       outcome,
+		delay:         delay,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *FakeGateway) Name() string {
+	return GatewayFake
+}
+
+func (g *FakeGateway) CreateInvoice(ctx context.Context, req InvoiceRequest) (*Invoice, error) {
+	id := fakeID("inv")
+	expiresAt := time.Now().Add(fakeInvoiceDuration)
+	invoice := &Invoice{
+		ID:         id,
+		ExternalID: req.ExternalID,
+		Status:     fakeStatusPending,
+		Amount:     req.Amount,
+		Currency:   req.Currency,
+		PaymentURL: g.baseURL + "/dev/fake-gateway/invoices/" + id,
+		ExpiresAt:  &expiresAt,
+	}
+
+	g.mu.Lock()
+	g.invoices[id] = invoice
+	g.mu.Unlock()
+
+	if g.outcome != "manual" {
+		status := strings.ToUpper(g.outcome)
+		time.AfterFunc(g.delay, func() {
+			if _, err := g.Simulate(id, status); err != nil && !errors.Is(err, ErrInvoiceNotPending) {
+				log.Printf("[FAKE GATEWAY] Failed to settle invoice %s: %v", id, err)
+			}
+		})
+	}
+
+	copied := *invoice
+	return &copied, nil
+}
+
+func (g *FakeGateway) GetInvoice(ctx context.Context, invoiceID string) (*Invoice, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	invoice, ok := g.invoices[invoiceID]
+	if !ok {
+		return nil, ErrInvoiceNotFound
+	}
+	copied := *invoice
+	return &copied, nil
+}
+
+func (g *FakeGateway) ExpireInvoice(ctx context.Context, invoiceID string) (*Invoice, error) {
+	invoice, err := g.Simulate(invoiceID, fakeStatusExpired)
+	if errors.Is(err, ErrInvoiceNotPending) {
+		return g.GetInvoice(ctx, invoiceID)
+	}
+	return invoice, err
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	invoice, ok := g.invoices[req.InvoiceID]
+	if !ok {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Body: "invoice not found"}
+	}
+	if invoice.Status != fakeStatusPaid {
+		return nil, &APIError{StatusCode: http.StatusBadRequest, Body: "invoice is not paid"}
+	}
+	if req.Amount <= 0 || g.refunded[invoice.ID]+req.Amount > invoice.Amount {
+		return nil, &APIError{StatusCode: http.StatusBadRequest, Body: "refund amount exceeds refundable amount"}
+	}
+
+	g.refunded[invoice.ID] += req.Amount
+	refund := &Refund{ID: fakeID("rfd"), Status: fakeStatusPending, Amount: req.Amount}
+
+	payload := fakeWebhookPayload{
+		EventID:     fakeID("evt"),
+		Kind:        WebhookKindRefund,
+		ID:          invoice.ID,
+		ExternalID:  invoice.ExternalID,
+		Status:      "SUCCEEDED",
+		Amount:      req.Amount,
+		RefundID:    refund.ID,
+		ReferenceID: req.ReferenceID,
+	}
+	time.AfterFunc(g.delay, func() { g.sendWebhook(payload) })
+
+	return refund, nil
+}
+
+func (g *FakeGateway) VerifyWebhook(header http.Header) error {
+	if subtle.ConstantTimeCompare([]byte(header.Get("x-callback-token")), []byte(g.callbackToken)) != 1 {
+		return ErrWebhookUnverified
+	}
+	return nil
+}
+
+func (g *FakeGateway) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	var payload fakeWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.EventID == "" || payload.ID == "" || payload.Status == "" {
+		return nil, fmt.Errorf("event_id, id and status are required")
+	}
+
+	kind := payload.Kind
+	if kind == "" {
+		kind = WebhookKindInvoice
+	}
+
+	return &WebhookEvent{
+		Kind:        kind,
+		EventID:     payload.EventID,
+		InvoiceID:   payload.ID,
+		ExternalID:  payload.ExternalID,
+		Status:      payload.Status,
+		PaidAmount:  payload.PaidAmount,
+		RefundID:    payload.RefundID,
+		ReferenceID: payload.ReferenceID,
+	}, nil
+}
+
+// Simulate settles a pending invoice with status (PAID, EXPIRED or FAILED)
+// and posts the matching webhook
+func (g *FakeGateway) Simulate(invoiceID, status string) (*Invoice, error) {
+	status = strings.ToUpper(status)
+	switch status {
+	case fakeStatusPaid, fakeStatusExpired, fakeStatusFailed:
+	default:
+		return nil, fmt.Errorf("unsupported outcome: %s", status)
+	}
+
+	g.mu.Lock()
+	invoice, ok := g.invoices[invoiceID]
+	if !ok {
+		g.mu.Unlock()
+		return nil, ErrInvoiceNotFound
+	}
+	if invoice.Status != fakeStatusPending {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrInvoiceNotPending, invoice.Status)
+	}
+	invoice.Status = status
+	if status == fakeStatusPaid {
+		invoice.PaidAmount = invoice.Amount
+	}
+	copied := *invoice
+	g.mu.Unlock()
+
+	payload := fakeWebhookPayload{
+		EventID:    fakeID("evt"),
+		Kind:       WebhookKindInvoice,
+		ID:         copied.ID,
+		ExternalID: copied.ExternalID,
+		Status:     status,
+		Amount:     copied.Amount,
+		PaidAmount: copied.PaidAmount,
+	}
+	go g.sendWebhook(payload)
+
+	return &copied, nil
+}
+
+// sendWebhook posts the event to our own webhook endpoint, like the real gateway would
+func (g *FakeGateway) sendWebhook(payload fakeWebhookPayload) {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequest(http.MethodPost, g.baseURL+"/webhooks/"+GatewayFake, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[FAKE GATEWAY] Failed to build webhook: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-callback-token", g.callbackToken)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		log.Printf("[FAKE GATEWAY] Failed to deliver webhook %s: %v", payload.EventID, err)
+		return
+	}
+	resp.Body.Close()
+	log.Printf("[FAKE GATEWAY] Webhook %s (%s %s) delivered: %d", payload.EventID, payload.ID, payload.Status, resp.StatusCode)
+}
+
+func fakeID(prefix string) string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("fake_%s_%d", prefix, time.Now().UnixNano())
+	}
+	return "fake_" + prefix + "_" + hex.EncodeToString(b)
+}
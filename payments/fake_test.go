@@ -0,0 +1,13 @@
+package payments
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFakeRefundUnknownInvoice(t *testing.T) {
+	gateway := NewFakeGateway()
+	if _, err := gateway.Refund(context.Background(), RefundRequest{InvoiceID: "inv-1", Amount: 1000}); !IsRejected(err) {
+		t.Errorf("Refund error = %v, want a rejection", err)
+	}
+}
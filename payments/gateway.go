@@ -0,0 +1,192 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Gateway names, stored on each payment so it is always handled by the gateway that created it
+const (
+	GatewayXendit = "xendit"
+	GatewayFake   = "fake"
+)
+
+var (
+	// ErrInvoiceNotFound is returned when the gateway does not know the invoice
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	// ErrUnknownGateway is returned for gateways that are not configured
+	ErrUnknownGateway = errors.New("payment gateway not configured")
+	// ErrWebhookUnverified is returned when a webhook fails the gateway's authenticity check
+	ErrWebhookUnverified = errors.New("webhook could not be verified")
+)
+
+// APIError is a non-success response from the gateway API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("payment gateway returned %d: %s", e.StatusCode, e.Body)
+}
+
+// IsRejected reports whether err is a definitive refusal by the gateway, a 4xx
+// response, so the request was not carried out. Timeouts, connection errors,
+// 5xx responses, 408 and 409 (duplicate reference) leave that open.
+func IsRejected(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict:
+		return false
+	}
+	return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500
+}
+
+// InvoiceRequest describes an invoice to create
+type InvoiceRequest struct {
+	ExternalID    string
+	Description   string
+	Amount        float64
+	Currency      string
+	PaymentMethod string // virtual_account or qris
+	BankCode      string
+	CustomerName  string
+	CustomerEmail string
+	Items         []InvoiceItem // price breakdown; prices add up to Amount
+}
+
+// InvoiceItem is one line of an invoice. Discounts have a negative price.
+type InvoiceItem struct {
+	Name     string
+	Quantity int
+	Price    float64 // per unit
+}
+
+// Invoice is the gateway's view of an invoice. Status is the gateway's own
+// value; utils.NormalizePaymentStatus maps it onto payment statuses.
+type Invoice struct {
+	ID         string     `json:"id"`
+	ExternalID string     `json:"external_id"`
+	Status     string     `json:"status"`
+	Amount     float64    `json:"amount"`
+	PaidAmount float64    `json:"paid_amount"` // zero until paid, or when the gateway does not report it
+	Currency   string     `json:"currency"`
+	PaymentURL string     `json:"payment_url"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// RefundRequest describes a refund of a paid invoice
+type RefundRequest struct {
+	InvoiceID   string
+	ReferenceID string
+	Amount      float64
+	Reason      string
+}
+
+// Refund is the gateway's view of a refund
+type Refund struct {
+	ID     string  `json:"id"`
+	Status string  `json:"status"`
+	Amount float64 `json:"amount"`
+}
+
+// Webhook event kinds
+const (
+	WebhookKindInvoice = "invoice"
+	WebhookKindRefund  = "refund"
+)
+
+// WebhookEvent is a parsed gateway notification about an invoice or a refund
+type WebhookEvent struct {
+	Kind       string
+	EventID    string
+	InvoiceID  string
+	ExternalID string
+	Status     string
+	PaidAmount float64 // zero when the gateway does not report it
+
+	// Refund events only
+	RefundID      string
+	ReferenceID   string // the reference we sent with the refund request
+	FailureReason string
+}
+
+// Gateway is a payment provider
+type Gateway interface {
+	Name() string
+	CreateInvoice(ctx context.Context, req InvoiceRequest) (*Invoice, error)
+	// GetInvoice returns ErrInvoiceNotFound for invoices the gateway does not know
+	GetInvoice(ctx context.Context, invoiceID string) (*Invoice, error)
+	ExpireInvoice(ctx context.Context, invoiceID string) (*Invoice, error)
+	// Refund returns an *APIError when the gateway refuses the refund, also
+	// for an unknown invoice, so IsRejected can tell it apart from an unknown outcome
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+	// VerifyWebhook checks that a webhook request really comes from the gateway
+	VerifyWebhook(header http.Header) error
+	// ParseWebhook decodes a webhook body. header may be nil when replaying a stored event.
+	ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error)
+}
+
+var (
+	gateways       = map[string]Gateway{}
+	defaultGateway Gateway
+)
+
+// InitGateways sets up the payment gateways. PAYMENT_GATEWAY selects the one
+// used for new payments (xendit or fake, default xendit). Xendit stays
+// available so existing payments can still be checked and refunded; the fake
+// gateway is only registered when selected.
+func InitGateways() {
+	xendit := NewXenditGateway()
+	gateways = map[string]Gateway{GatewayXendit: xendit}
+	defaultGateway = xendit
+
+	switch name := strings.ToLower(os.Getenv("PAYMENT_GATEWAY")); name {
+	case "", GatewayXendit:
+	case GatewayFake:
+		fake := NewFakeGateway()
+		gateways[GatewayFake] = fake
+		defaultGateway = fake
+		log.Println("Using fake payment gateway: no real payments will be made")
+	default:
+		log.Printf("Warning: unknown PAYMENT_GATEWAY %q, using xendit", name)
+	}
+}
+
+// Default returns the gateway used for new payments
+func Default() Gateway {
+	if defaultGateway == nil {
+		InitGateways()
+	}
+	return defaultGateway
+}
+
+// Get returns a configured gateway by name
+func Get(name string) (Gateway, error) {
+	if defaultGateway == nil {
+		InitGateways()
+	}
+	if gateway, ok := gateways[name]; ok {
+		return gateway, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownGateway, name)
+}
+
+// Fake returns the fake gateway when it is enabled
+func Fake() (*FakeGateway, bool) {
+	gateway, err := Get(GatewayFake)
+	if err != nil {
+		return nil, false
+	}
+	fake, ok := gateway.(*FakeGateway)
+	return fake, ok
+}
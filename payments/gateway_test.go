@@ -0,0 +1,34 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad request", &APIError{StatusCode: 400}, true},
+		{"not found", &APIError{StatusCode: 404}, true},
+		{"unprocessable", &APIError{StatusCode: 422}, true},
+		{"wrapped rejection", fmt.Errorf("refund: %w", &APIError{StatusCode: 400}), true},
+		{"request timeout", &APIError{StatusCode: 408}, false},
+		{"duplicate reference", &APIError{StatusCode: 409}, false},
+		{"server error", &APIError{StatusCode: 500}, false},
+		{"bad gateway", &APIError{StatusCode: 502}, false},
+		{"unavailable", &APIError{StatusCode: 503}, false},
+		{"timeout", context.DeadlineExceeded, false},
+		{"connection error", errors.New("connection reset by peer"), false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsRejected(tt.err); got != tt.want {
+			t.Errorf("%s: IsRejected(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type XenditInvoiceRequest struct {
+	ExternalID                     string                       `json:"external_id"`
+	Amount                         float64                      `json:"amount"`
+	Description                    string                       `json:"description"`
+	Currency                       string                       `json:"currency"`
+	Customer                       *XenditCustomer              `json:"customer,omitempty"`
+	CustomerNotificationPreference XenditNotificationPreference `json:"customer_notification_preference,omitempty"`
+	PaymentMethods                 []string                     `json:"payment_methods,omitempty"`
+	SuccessRedirectURL             string                       `json:"success_redirect_url,omitempty"`
+	FailureRedirectURL             string                       `json:"failure_redirect_url,omitempty"`
+	Items                          []XenditItem                 `json:"items,omitempty"`
+	Fees                           []XenditFee                  `json:"fees,omitempty"`
+}
+
+type XenditCustomer struct {
+	GivenNames   string `json:"given_names"`
+	Email        string `json:"email"`
+	MobileNumber string `json:"mobile_number,omitempty"`
+}
+
+type XenditNotificationPreference struct {
+	InvoiceCreated  []string `json:"invoice_created,omitempty"`
+	InvoiceReminder []string `json:"invoice_reminder,omitempty"`
+	InvoicePaid     []string `json:"invoice_paid,omitempty"`
+}
+
+type XenditItem struct {
+	Name     string  `json:"name"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
+
+// XenditFee is an invoice-level adjustment; Xendit shows discounts as negative fees
+type XenditFee struct {
+	Type  string  `json:"type"`
+	Value float64 `json:"value"`
+}
+
+type XenditResponse struct {
+	ID                string   `json:"id"`
+	ExternalID        string   `json:"external_id"`
+	UserID            string   `json:"user_id"`
+	Status            string   `json:"status"`
+	MerchantName      string   `json:"merchant_name"`
+	Amount            float64  `json:"amount"`
+	PaidAmount        float64  `json:"paid_amount"`
+	Currency          string   `json:"currency"`
+	InvoiceURL        string   `json:"invoice_url"`
+	ExpiryDate        string   `json:"expiry_date"`
+	AvailableBanks    []Bank   `json:"available_banks,omitempty"`
+	AvailableEwallets []Wallet `json:"available_ewallets,omitempty"`
+}
+
+type Bank struct {
+	BankCode string `json:"bank_code"`
+	Name     string `json:"name"`
+}
+
+type Wallet struct {
+	EWalletType string `json:"ewallet_type"`
+}
+
+// XenditWebhookPayload represents Xendit webhook payload
+type XenditWebhookPayload struct {
+	ID         string  `json:"id"`
+	ExternalID string  `json:"external_id"`
+	Status     string  `json:"status"`
+	Amount     float64 `json:"amount"`
+	PaidAmount float64 `json:"paid_amount"`
+	Currency   string  `json:"currency"`
+	Created    string  `json:"created"`
+	Updated    string  `json:"updated"`
+}
+
+// XenditRefundWebhookPayload represents Xendit refund webhook payload
+type XenditRefundWebhookPayload struct {
+	Event string `json:"event"` // refund.succeeded or refund.failed
+	Data  struct {
+		ID          string  `json:"id"`
+		InvoiceID   string  `json:"invoice_id"`
+		ReferenceID string  `json:"reference_id"`
+		Status      string  `json:"status"`
+		Amount      float64 `json:"amount"`
+		FailureCode string  `json:"failure_code"`
+	} `json:"data"`
+}
+
+type xenditRefundRequest struct {
+	InvoiceID   string            `json:"invoice_id"`
+	ReferenceID string            `json:"reference_id"`
+	Amount      float64           `json:"amount"`
+	Reason      string            `json:"reason"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// XenditGateway talks to the Xendit invoice API
+type XenditGateway struct {
+	secretKey     string
+	apiURL        string
+	callbackToken string
+	client        *http.Client
+}
+
+// NewXenditGateway configures Xendit from XENDIT_SECRET_KEY, XENDIT_API_URL and XENDIT_CALLBACK_TOKEN
+func NewXenditGateway() *XenditGateway {
+	apiURL := os.Getenv("XENDIT_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.xendit.co"
+	}
+	return &XenditGateway{
+		secretKey:     os.Getenv("XENDIT_SECRET_KEY"),
+		apiURL:        strings.TrimRight(apiURL, "/"),
+		callbackToken: os.Getenv("XENDIT_CALLBACK_TOKEN"),
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (g *XenditGateway) Name() string {
+	return GatewayXendit
+}
+
+func (g *XenditGateway) CreateInvoice(ctx context.Context, req InvoiceRequest) (*Invoice, error) {
+	paymentMethods := []string{}
+	if req.PaymentMethod == "virtual_account" {
+		paymentMethods = append(paymentMethods, "BANK_TRANSFER")
+		if req.BankCode != "" {
+			paymentMethods = append(paymentMethods, req.BankCode)
+		}
+	} else if req.PaymentMethod == "qris" {
+		paymentMethods = append(paymentMethods, "EWALLET")
+	}
+
+	invoiceReq := XenditInvoiceRequest{
+		ExternalID:  req.ExternalID,
+		Amount:      req.Amount,
+		Description: req.Description,
+		Currency:    req.Currency,
+		Customer: &XenditCustomer{
+			GivenNames: req.CustomerName,
+			Email:      req.CustomerEmail,
+		},
+		PaymentMethods: paymentMethods,
+	}
+	for _, item := range req.Items {
+		if item.Price < 0 {
+			invoiceReq.Fees = append(invoiceReq.Fees, XenditFee{
+				Type:  item.Name,
+				Value: item.Price * float64(item.Quantity),
+			})
+			continue
+		}
+		invoiceReq.Items = append(invoiceReq.Items, XenditItem{
+			Name:     item.Name,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+		})
+	}
+
+	var resp XenditResponse
+	if err := g.do(ctx, http.MethodPost, "/v2/invoices", invoiceReq, &resp); err != nil {
+		return nil, err
+	}
+	return resp.invoice(), nil
+}
+
+func (g *XenditGateway) GetInvoice(ctx context.Context, invoiceID string) (*Invoice, error) {
+	var resp XenditResponse
+	if err := g.do(ctx, http.MethodGet, "/v2/invoices/"+invoiceID, nil, &resp); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+	return resp.invoice(), nil
+}
+
+func (g *XenditGateway) ExpireInvoice(ctx context.Context, invoiceID string) (*Invoice, error) {
+	var resp XenditResponse
+	if err := g.do(ctx, http.MethodPost, "/invoices/"+invoiceID+"/expire!", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.invoice(), nil
+}
+
+func (g *XenditGateway) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	refundReq := xenditRefundRequest{
+		InvoiceID:   req.InvoiceID,
+		ReferenceID: req.ReferenceID,
+		Amount:      req.Amount,
+		Reason:      "REQUESTED_BY_CUSTOMER",
+	}
+	if req.Reason != "" {
+		refundReq.Metadata = map[string]string{"note": req.Reason}
+	}
+
+	var refund Refund
+	if err := g.do(ctx, http.MethodPost, "/refunds", refundReq, &refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// VerifyWebhook compares the x-callback-token header with XENDIT_CALLBACK_TOKEN
+// in constant time. Without a configured token every webhook is rejected.
+func (g *XenditGateway) VerifyWebhook(header http.Header) error {
+	if g.callbackToken == "" {
+		log.Printf("[WEBHOOK] XENDIT_CALLBACK_TOKEN is not set, rejecting webhook")
+		return ErrWebhookUnverified
+	}
+	if subtle.ConstantTimeCompare([]byte(header.Get("x-callback-token")), []byte(g.callbackToken)) != 1 {
+		return ErrWebhookUnverified
+	}
+	return nil
+}
+
+func (g *XenditGateway) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	var refund XenditRefundWebhookPayload
+	if err := json.Unmarshal(body, &refund); err == nil && strings.HasPrefix(refund.Event, "refund.") {
+		return parseXenditRefundWebhook(header, refund)
+	}
+
+	var payload XenditWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.ID == "" || payload.Status == "" {
+		return nil, fmt.Errorf("id and status are required")
+	}
+
+	// Invoice callbacks carry no event ID of their own; the invoice and status identify the event
+	eventID := header.Get("webhook-id")
+	if eventID == "" {
+		eventID = payload.ID + ":" + strings.ToUpper(payload.Status)
+	}
+
+	paidAmount := payload.PaidAmount
+	if paidAmount == 0 {
+		paidAmount = payload.Amount
+	}
+
+	return &WebhookEvent{
+		Kind:       WebhookKindInvoice,
+		EventID:    eventID,
+		InvoiceID:  payload.ID,
+		ExternalID: payload.ExternalID,
+		Status:     payload.Status,
+		PaidAmount: paidAmount,
+	}, nil
+}
+
+func parseXenditRefundWebhook(header http.Header, payload XenditRefundWebhookPayload) (*WebhookEvent, error) {
+	if payload.Data.ID == "" || payload.Data.Status == "" {
+		return nil, fmt.Errorf("data.id and data.status are required")
+	}
+
+	eventID := header.Get("webhook-id")
+	if eventID == "" {
+		eventID = payload.Data.ID + ":" + strings.ToUpper(payload.Data.Status)
+	}
+
+	return &WebhookEvent{
+		Kind:          WebhookKindRefund,
+		EventID:       eventID,
+		InvoiceID:     payload.Data.InvoiceID,
+		Status:        payload.Data.Status,
+		RefundID:      payload.Data.ID,
+		ReferenceID:   payload.Data.ReferenceID,
+		FailureReason: payload.Data.FailureCode,
+	}, nil
+}
+
+func (g *XenditGateway) do(ctx context.Context, method, path string, reqBody, out interface{}) error {
+	var body io.Reader
+	if reqBody != nil {
+		jsonData, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, g.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	if reqBody != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.SetBasicAuth(g.secretKey, "")
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+func (r XenditResponse) invoice() *Invoice {
+	invoice := &Invoice{
+		ID:         r.ID,
+		ExternalID: r.ExternalID,
+		Status:     r.Status,
+		Amount:     r.Amount,
+		PaidAmount: r.PaidAmount,
+		Currency:   r.Currency,
+		PaymentURL: r.InvoiceURL,
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, r.ExpiryDate); err == nil {
+		invoice.ExpiresAt = &expiresAt
+	}
+	return invoice
+}
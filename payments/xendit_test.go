@@ -0,0 +1,42 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestXenditNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error_code":"DATA_NOT_FOUND"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+	gateway := &XenditGateway{apiURL: server.URL, client: server.Client()}
+	ctx := context.Background()
+
+	// Only an unknown invoice is reported as such; a refused refund must settle as failed
+	if _, err := gateway.GetInvoice(ctx, "inv-1"); !errors.Is(err, ErrInvoiceNotFound) {
+		t.Errorf("GetInvoice error = %v, want %v", err, ErrInvoiceNotFound)
+	}
+	if _, err := gateway.Refund(ctx, RefundRequest{InvoiceID: "inv-1", ReferenceID: "rfd-1", Amount: 1000}); !IsRejected(err) {
+		t.Errorf("Refund error = %v, want a rejection", err)
+	}
+}
+
+func TestXenditGetInvoicePaidAmount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"inv-1","status":"PAID","amount":150000,"paid_amount":100000,"currency":"IDR"}`))
+	}))
+	defer server.Close()
+	gateway := &XenditGateway{apiURL: server.URL, client: server.Client()}
+
+	invoice, err := gateway.GetInvoice(context.Background(), "inv-1")
+	if err != nil {
+		t.Fatalf("GetInvoice: %v", err)
+	}
+	if invoice.PaidAmount != 100000 {
+		t.Errorf("PaidAmount = %v, want 100000", invoice.PaidAmount)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"viskatera-api-go/controllers"
 	"viskatera-api-go/middleware"
 	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -18,7 +19,8 @@ func SetupRoutes() *gin.Engine {
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, Accept-Language")
+		c.Header("Access-Control-Expose-Headers", "Idempotent-Replayed, Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -28,6 +30,9 @@ func SetupRoutes() *gin.Engine {
 		c.Next()
 	})
 
+	// Error messages in the client's language
+	r.Use(middleware.Localize())
+
 	// Health check
 	// @Summary Health check
 	// @Description Check API health and version
@@ -64,68 +69,192 @@ func SetupRoutes() *gin.Engine {
 		public.POST("/auth/verify-otp", controllers.VerifyOTP)
 		public.POST("/auth/forgot-password", controllers.ForgotPassword)
 		public.POST("/auth/reset-password", controllers.ResetPassword)
+		public.POST("/auth/refresh", controllers.RefreshToken)
+		public.POST("/auth/invites/accept", controllers.AcceptInvite)
 		public.GET("/auth/google/login", controllers.GoogleLogin)
 		public.GET("/auth/google/callback", controllers.GoogleCallback)
 
 		// Visa routes (public)
 		public.GET("/visas", controllers.GetVisas)
 		public.GET("/visas/:id", controllers.GetVisaByID)
+		public.GET("/visas/:id/requirements", controllers.GetVisaRequirements)
+		public.GET("/visas/:id/options", controllers.GetVisaOptions)
 
 		// Webhook routes (no authentication required)
-		public.POST("/webhooks/xendit", controllers.XenditWebhook)
+		public.POST("/webhooks/:provider", controllers.PaymentWebhook)
+
+		// Fake payment gateway, only with PAYMENT_GATEWAY=fake
+		if _, ok := payments.Fake(); ok {
+			public.GET("/dev/fake-gateway/invoices/:id", controllers.GetFakeInvoice)
+			public.POST("/dev/fake-gateway/invoices/:id/:outcome", controllers.SimulateFakeInvoice)
+		}
 	}
 
 	// Expose file uploads so they can be accessed in the browser, e.g. /uploads/visas/visa_1_1761988882.png
 	// This line makes files in ./uploads folder available at /uploads/*path on the server.
 	r.Static("/uploads", "./uploads")
 
+	// Shorthand for mapping a route to the permissions it requires
+	perm := middleware.RequirePermission
+	// Lets clients retry creating requests with an Idempotency-Key header
+	idempotent := middleware.Idempotency()
+
 	// Protected routes (authentication required)
 	protected := r.Group("/api/v1")
 	protected.Use(middleware.AuthMiddleware())
 	{
-		// Customer routes (both customer and admin can access)
-		protected.POST("/purchases", controllers.PurchaseVisa)
-		protected.GET("/purchases", controllers.GetUserPurchases)
-		protected.GET("/purchases/:id", controllers.GetPurchaseByID)
-		protected.PUT("/purchases/:id/status", controllers.UpdatePurchaseStatus)
+		// Customer routes (every role holds the self-service permissions)
+		protected.POST("/purchases", perm(models.PermApplicationsManage), idempotent, controllers.PurchaseVisa)
+		protected.GET("/purchases", perm(models.PermApplicationsManage), controllers.GetUserPurchases)
+		protected.GET("/purchases/:id", perm(models.PermApplicationsManage), controllers.GetPurchaseByID)
+		protected.PUT("/purchases/:id/status", perm(models.PermApplicationsManage), controllers.UpdatePurchaseStatus)
+		protected.GET("/purchases/:id/history", perm(models.PermApplicationsManage), controllers.GetPurchaseHistory)
+
+		// Application document routes
+		protected.GET("/purchases/:id/documents", perm(models.PermApplicationsManage), controllers.GetPurchaseDocuments)
+		protected.POST("/purchases/:id/documents", perm(models.PermApplicationsManage), controllers.UploadPurchaseDocument)
+		protected.GET("/purchases/:id/documents/:document_id/file", perm(models.PermApplicationsManage), controllers.DownloadPurchaseDocument)
+		protected.GET("/purchases/:id/invoice", perm(models.PermApplicationsManage), controllers.GetPurchaseInvoice)
+		protected.GET("/purchases/:id/invoices", perm(models.PermApplicationsManage), controllers.GetPurchaseInvoices)
+		protected.GET("/purchases/:id/invoices/:invoice_id/file", perm(models.PermApplicationsManage), controllers.DownloadPurchaseInvoice)
+
+		// Applicant routes
+		protected.GET("/applicants", perm(models.PermApplicationsManage), controllers.GetApplicants)
+		protected.POST("/applicants", perm(models.PermApplicationsManage), controllers.CreateApplicant)
+		protected.GET("/applicants/:id", perm(models.PermApplicationsManage), controllers.GetApplicantByID)
+		protected.PUT("/applicants/:id", perm(models.PermApplicationsManage), controllers.UpdateApplicant)
+		protected.DELETE("/applicants/:id", perm(models.PermApplicationsManage), controllers.DeleteApplicant)
 
 		// Payment routes
-		protected.POST("/payments", controllers.CreatePayment)
-		protected.GET("/payments/:id/status", controllers.GetPaymentStatus)
+		protected.POST("/payments", perm(models.PermApplicationsManage), idempotent, controllers.CreatePayment)
+		protected.GET("/payments/:id/status", perm(models.PermApplicationsManage), controllers.GetPaymentStatus)
 
 		// Upload routes
-		protected.POST("/uploads/avatar", controllers.UploadAvatar)
-		protected.POST("/uploads/visa/:visa_id", controllers.UploadVisaDocument)
+		protected.POST("/uploads/avatar", perm(models.PermProfileManage), controllers.UploadAvatar)
+		protected.POST("/uploads/visa/:visa_id", perm(models.PermVisasManage), controllers.UploadVisaDocument)
 		// No need: protected.Static("/uploads", "./uploads") // Handled globally above
 
 		// User profile
-		protected.PUT("/user", controllers.UpdateUser)
+		protected.PUT("/user", perm(models.PermProfileManage), controllers.UpdateUser)
+
+		// Session management (always allowed so a restricted user can still sign out)
+		protected.POST("/auth/logout", controllers.Logout)
+		protected.POST("/auth/logout-all", controllers.LogoutAll)
 
 		// Export routes
-		protected.GET("/exports/visas/excel", controllers.ExportVisasExcel)
-		protected.GET("/exports/visas/pdf", controllers.ExportVisasPDF)
-		protected.GET("/exports/purchases/excel", controllers.ExportPurchasesExcel)
-		protected.GET("/exports/purchases/pdf", controllers.ExportPurchasesPDF)
+		protected.GET("/exports/visas/excel", perm(models.PermReportsExport), controllers.ExportVisasExcel)
+		protected.GET("/exports/visas/pdf", perm(models.PermReportsExport), controllers.ExportVisasPDF)
+		protected.GET("/exports/purchases/excel", perm(models.PermReportsExport), controllers.ExportPurchasesExcel)
+		protected.GET("/exports/purchases/pdf", perm(models.PermReportsExport), controllers.ExportPurchasesPDF)
+		protected.GET("/exports/reconciliations/:id/excel", perm(models.PermReportsExport, models.PermPaymentsRead), controllers.ExportReconciliationExcel)
 
-		// Activity log routes
-		protected.GET("/activities", controllers.GetUserActivities)
-		protected.GET("/activities/visa/:visa_id", controllers.GetVisaActivities)
-		protected.GET("/activities/purchase/:purchase_id", controllers.GetPurchaseActivities)
-		protected.GET("/activities/payment/:payment_id", controllers.GetPaymentActivities)
+		// Activity log routes (own activities; viewing others is checked in the handlers)
+		protected.GET("/activities", perm(models.PermProfileManage), controllers.GetUserActivities)
+		protected.GET("/activities/visa/:visa_id", perm(models.PermActivitiesRead), controllers.GetVisaActivities)
+		protected.GET("/activities/purchase/:purchase_id", perm(models.PermApplicationsManage), controllers.GetPurchaseActivities)
+		protected.GET("/activities/payment/:payment_id", perm(models.PermApplicationsManage), controllers.GetPaymentActivities)
 
 		// Monitoring routes
-		protected.GET("/monitoring/queues", controllers.GetQueueStats)
-		protected.GET("/monitoring/queues/health", controllers.GetQueueHealth)
+		protected.GET("/monitoring/queues", perm(models.PermMonitoringRead), controllers.GetQueueStats)
+		protected.GET("/monitoring/queues/health", perm(models.PermMonitoringRead), controllers.GetQueueHealth)
+		protected.GET("/monitoring/dead-letters", perm(models.PermMonitoringRead), controllers.GetDeadLetterStats)
+		protected.GET("/monitoring/dead-letters/:queue", perm(models.PermMonitoringRead), controllers.GetDeadLetters)
+		protected.GET("/monitoring/dead-letters/:queue/:message_id", perm(models.PermMonitoringRead), controllers.GetDeadLetter)
+		protected.POST("/monitoring/dead-letters/:queue/requeue", perm(models.PermMonitoringManage), controllers.RequeueDeadLetters)
+		protected.DELETE("/monitoring/dead-letters/:queue", perm(models.PermMonitoringManage), controllers.PurgeDeadLetters)
 	}
 
-	// Admin routes (admin authentication required)
+	// Back-office routes (staff roles, each route checks its own permission)
 	admin := r.Group("/api/v1/admin")
-	admin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	admin.Use(middleware.AuthMiddleware())
 	{
-		// Visa management (admin only)
-		admin.POST("/visas", controllers.CreateVisa)
-		admin.PUT("/visas/:id", controllers.UpdateVisa)
-		admin.DELETE("/visas/:id", controllers.DeleteVisa)
+		// Visa catalogue management
+		admin.POST("/visas", perm(models.PermVisasManage), controllers.CreateVisa)
+		admin.PUT("/visas/:id", perm(models.PermVisasManage), controllers.UpdateVisa)
+		admin.DELETE("/visas/:id", perm(models.PermVisasManage), controllers.DeleteVisa)
+		admin.GET("/visas/:id/options", perm(models.PermVisasManage), controllers.AdminGetVisaOptions)
+		admin.POST("/visas/:id/options", perm(models.PermVisasManage), controllers.CreateVisaOption)
+		admin.PUT("/visas/:id/options/:option_id", perm(models.PermVisasManage), controllers.UpdateVisaOption)
+		admin.DELETE("/visas/:id/options/:option_id", perm(models.PermVisasManage), controllers.DeleteVisaOption)
+		admin.POST("/visas/:id/requirements", perm(models.PermVisasManage), controllers.CreateVisaRequirement)
+		admin.PUT("/visas/:id/requirements/:requirement_id", perm(models.PermVisasManage), controllers.UpdateVisaRequirement)
+		admin.DELETE("/visas/:id/requirements/:requirement_id", perm(models.PermVisasManage), controllers.DeleteVisaRequirement)
+
+		// Visa application workflow
+		admin.PUT("/purchases/:id/status", perm(models.PermApplicationsUpdate), controllers.AdminUpdatePurchaseStatus)
+
+		// Document review
+		admin.GET("/purchases/:id/documents", perm(models.PermApplicationsRead), controllers.AdminGetPurchaseDocuments)
+		admin.GET("/documents/:id/file", perm(models.PermApplicationsRead), controllers.AdminDownloadPurchaseDocument)
+		admin.PUT("/documents/:id/review", perm(models.PermDocumentsReview), controllers.ReviewPurchaseDocument)
+
+		// User management
+		admin.GET("/users", perm(models.PermUsersRead), controllers.GetUsers)
+		admin.GET("/users/:id", perm(models.PermUsersRead), controllers.GetUserByID)
+		admin.POST("/users/:id/deactivate", perm(models.PermUsersManage), controllers.DeactivateUser)
+		admin.POST("/users/:id/reactivate", perm(models.PermUsersManage), controllers.ReactivateUser)
+		admin.POST("/users/:id/reset-password", perm(models.PermUsersManage), controllers.ForceUserPasswordReset)
+		admin.DELETE("/users/:id", perm(models.PermUsersManage), controllers.DeleteUser)
+
+		// Staff invitations
+		admin.GET("/invites", perm(models.PermUsersRead), controllers.GetInvites)
+		admin.POST("/invites", perm(models.PermUsersManage, models.PermRolesManage), controllers.CreateInvite)
+		admin.DELETE("/invites/:id", perm(models.PermUsersManage), controllers.RevokeInvite)
+
+		// Refunds
+		admin.GET("/refunds", perm(models.PermPaymentsRead), controllers.GetRefunds)
+		admin.POST("/refunds/:id/retry", perm(models.PermPaymentsManage), controllers.RetryRefund)
+		admin.GET("/payments", perm(models.PermPaymentsRead), controllers.GetPayments)
+		admin.GET("/payments/:id/refunds", perm(models.PermPaymentsRead), controllers.GetPaymentRefunds)
+		admin.POST("/payments/:id/refunds", perm(models.PermPaymentsManage), controllers.CreateRefund)
+
+		// Queue jobs waiting in the transactional outbox
+		admin.GET("/outbox", perm(models.PermMonitoringRead), controllers.GetOutboxMessages)
+
+		// Emails handed to the mail transport
+		admin.GET("/email-logs", perm(models.PermMonitoringRead), controllers.GetEmailLogs)
+		admin.GET("/email-logs/:id", perm(models.PermMonitoringRead), controllers.GetEmailLog)
+
+		// Issued invoices and credit notes
+		admin.GET("/invoices", perm(models.PermPaymentsRead), controllers.GetInvoices)
+		admin.GET("/invoices/:id/file", perm(models.PermPaymentsRead), controllers.AdminDownloadInvoice)
+
+		// Payment gateway webhook events
+		admin.GET("/webhooks/events", perm(models.PermPaymentsRead), controllers.GetWebhookEvents)
+		admin.GET("/webhooks/events/:id", perm(models.PermPaymentsRead), controllers.GetWebhookEvent)
+		admin.POST("/webhooks/events/:id/replay", perm(models.PermPaymentsManage), controllers.ReplayWebhookEvent)
+
+		// Exchange rates for visas priced in other currencies
+		admin.GET("/exchange-rates", perm(models.PermVisasManage), controllers.GetExchangeRates)
+		admin.POST("/exchange-rates", perm(models.PermVisasManage), controllers.CreateExchangeRate)
+		admin.POST("/exchange-rates/import", perm(models.PermVisasManage), controllers.ImportExchangeRates)
+		admin.DELETE("/exchange-rates/:id", perm(models.PermVisasManage), controllers.DeleteExchangeRate)
+
+		// Service fee and tax rules
+		admin.GET("/pricing-rules", perm(models.PermVisasManage), controllers.GetPricingRules)
+		admin.POST("/pricing-rules", perm(models.PermVisasManage), controllers.CreatePricingRule)
+		admin.PUT("/pricing-rules/:id", perm(models.PermVisasManage), controllers.UpdatePricingRule)
+		admin.DELETE("/pricing-rules/:id", perm(models.PermVisasManage), controllers.DeletePricingRule)
+
+		// Promo codes
+		admin.GET("/promo-codes", perm(models.PermPromosManage), controllers.GetPromoCodes)
+		admin.GET("/promo-codes/:id", perm(models.PermPromosManage), controllers.GetPromoCode)
+		admin.POST("/promo-codes", perm(models.PermPromosManage), controllers.CreatePromoCode)
+		admin.PUT("/promo-codes/:id", perm(models.PermPromosManage), controllers.UpdatePromoCode)
+		admin.DELETE("/promo-codes/:id", perm(models.PermPromosManage), controllers.DeletePromoCode)
+
+		// Payment reconciliation
+		admin.GET("/reconciliations", perm(models.PermPaymentsRead), controllers.GetReconciliations)
+		admin.GET("/reconciliations/:id", perm(models.PermPaymentsRead), controllers.GetReconciliation)
+		admin.POST("/reconciliations/settlements", perm(models.PermPaymentsManage), controllers.CreateSettlementReconciliation)
+		admin.POST("/reconciliations/gateway", perm(models.PermPaymentsManage), controllers.CreateGatewayReconciliation)
+
+		// Roles and permissions
+		admin.GET("/roles", perm(models.PermUsersRead), controllers.GetRoles)
+		admin.GET("/users/:id/permissions", perm(models.PermUsersRead), controllers.GetUserPermissions)
+		admin.PUT("/users/:id/role", perm(models.PermRolesManage), controllers.AssignUserRole)
+		admin.PUT("/users/:id/permissions", perm(models.PermRolesManage), controllers.SetUserPermission)
+		admin.DELETE("/users/:id/permissions/:permission", perm(models.PermRolesManage), controllers.DeleteUserPermission)
 	}
 
 	return r
@@ -33,11 +33,33 @@ func main() {
 
 	// Drop tables in reverse order to respect foreign key constraints
 	tables := []string{
+		"email_logs",
+		"outbox_messages",
+		"idempotency_keys",
+		"invoice_sequences",
+		"invoices",
+		"purchase_line_items",
+		"pricing_rules",
+		"promo_redemptions",
+		"promo_codes",
+		"exchange_rates",
+		"reconciliation_items",
+		"reconciliation_runs",
+		"refunds",
+		"webhook_events",
+		"user_invites",
+		"user_permissions",
+		"refresh_tokens",
+		"purchase_documents",
+		"visa_document_requirements",
+		"purchase_status_histories",
 		"activity_logs",
 		"payments",
 		"password_reset_tokens",
 		"otps",
+		"purchase_applicants",
 		"visa_purchases",
+		"applicants",
 		"visa_options",
 		"visas",
 		"users",
@@ -54,11 +76,33 @@ func main() {
 	// Also drop tables using GORM's DropTable if they exist
 	fmt.Println("\nCleaning up with GORM...")
 	config.DB.Migrator().DropTable(
+		&models.EmailLog{},
+		&models.OutboxMessage{},
+		&models.IdempotencyKey{},
+		&models.InvoiceSequence{},
+		&models.Invoice{},
+		&models.PurchaseLineItem{},
+		&models.PricingRule{},
+		&models.PromoRedemption{},
+		&models.PromoCode{},
+		&models.ExchangeRate{},
+		&models.ReconciliationItem{},
+		&models.ReconciliationRun{},
+		&models.Refund{},
+		&models.WebhookEvent{},
+		&models.UserInvite{},
+		&models.UserPermission{},
+		&models.RefreshToken{},
+		&models.PurchaseDocument{},
+		&models.VisaDocumentRequirement{},
+		&models.PurchaseStatusHistory{},
 		&models.ActivityLog{},
 		&models.Payment{},
 		&models.PasswordResetToken{},
 		&models.OTP{},
+		&models.PurchaseApplicant{},
 		&models.VisaPurchase{},
+		&models.Applicant{},
 		&models.VisaOption{},
 		&models.Visa{},
 		&models.User{},
@@ -70,11 +114,33 @@ func main() {
 		&models.User{},
 		&models.Visa{},
 		&models.VisaOption{},
+		&models.Applicant{},
 		&models.VisaPurchase{},
+		&models.PurchaseApplicant{},
 		&models.PasswordResetToken{},
 		&models.OTP{},
 		&models.Payment{},
 		&models.ActivityLog{},
+		&models.PurchaseStatusHistory{},
+		&models.VisaDocumentRequirement{},
+		&models.PurchaseDocument{},
+		&models.RefreshToken{},
+		&models.UserPermission{},
+		&models.UserInvite{},
+		&models.WebhookEvent{},
+		&models.Refund{},
+		&models.ReconciliationRun{},
+		&models.ReconciliationItem{},
+		&models.ExchangeRate{},
+		&models.PromoCode{},
+		&models.PromoRedemption{},
+		&models.PricingRule{},
+		&models.PurchaseLineItem{},
+		&models.Invoice{},
+		&models.InvoiceSequence{},
+		&models.IdempotencyKey{},
+		&models.OutboxMessage{},
+		&models.EmailLog{},
 	)
 
 	if err != nil {
@@ -86,10 +152,33 @@ func main() {
 	fmt.Println("  - users")
 	fmt.Println("  - visas")
 	fmt.Println("  - visa_options")
+	fmt.Println("  - applicants")
 	fmt.Println("  - visa_purchases")
+	fmt.Println("  - purchase_applicants")
 	fmt.Println("  - password_reset_tokens")
 	fmt.Println("  - otps")
 	fmt.Println("  - payments")
+	fmt.Println("  - activity_logs")
+	fmt.Println("  - purchase_status_histories")
+	fmt.Println("  - visa_document_requirements")
+	fmt.Println("  - purchase_documents")
+	fmt.Println("  - refresh_tokens")
+	fmt.Println("  - user_permissions")
+	fmt.Println("  - user_invites")
+	fmt.Println("  - webhook_events")
+	fmt.Println("  - refunds")
+	fmt.Println("  - reconciliation_runs")
+	fmt.Println("  - reconciliation_items")
+	fmt.Println("  - exchange_rates")
+	fmt.Println("  - promo_codes")
+	fmt.Println("  - promo_redemptions")
+	fmt.Println("  - pricing_rules")
+	fmt.Println("  - purchase_line_items")
+	fmt.Println("  - invoices")
+	fmt.Println("  - invoice_sequences")
+	fmt.Println("  - idempotency_keys")
+	fmt.Println("  - outbox_messages")
+	fmt.Println("  - email_logs")
 
 	fmt.Println("\nDatabase is now in a fresh state and ready to use.")
 }
@@ -47,14 +47,33 @@ echo "5. Testing get visa by ID..."
 curl -s "$BASE_URL/api/v1/visas/1" | jq .
 echo ""
 
+# Test create applicant (protected)
+echo "6. Testing create applicant..."
+APPLICANT_RESPONSE=$(curl -s -X POST "$BASE_URL/api/v1/applicants" \
+  -H "Content-Type: application/json" \
+  -H "Authorization: Bearer $JWT_TOKEN" \
+  -d '{
+    "full_name": "Test User",
+    "passport_number": "C1234567",
+    "nationality": "Indonesia",
+    "date_of_birth": "1990-05-17",
+    "passport_expiry": "2035-01-31"
+  }')
+echo "$APPLICANT_RESPONSE" | jq .
+APPLICANT_ID=$(echo "$APPLICANT_RESPONSE" | jq -r '.data.id')
+TRAVEL_DATE=$(date -d "+30 days" +%Y-%m-%d 2>/dev/null || date -v+30d +%Y-%m-%d)
+echo ""
+
 # Test purchase visa (protected)
-echo "6. Testing purchase visa..."
+echo "6b. Testing purchase visa..."
 curl -s -X POST "$BASE_URL/api/v1/purchases" \
   -H "Content-Type: application/json" \
   -H "Authorization: Bearer $JWT_TOKEN" \
-  -d '{
-    "visa_id": 1
-  }' | jq .
+  -d "{
+    \"visa_id\": 1,
+    \"applicant_ids\": [$APPLICANT_ID],
+    \"travel_date\": \"$TRAVEL_DATE\"
+  }" | jq .
 echo ""
 
 # Test get user purchases
@@ -0,0 +1,113 @@
+// Package testenv connects tests to the Postgres and Redis servers named in
+// TEST_DATABASE_URL and TEST_REDIS_ADDR. Tests needing a server that is not
+// configured are skipped locally and fail in CI, where a skipped test would
+// pass unnoticed. make test-go starts both servers.
+package testenv
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"viskatera-api-go/config"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Require returns the environment variable name, skipping the test when it is
+// unset, or failing it when CI is set
+func Require(t testing.TB, name string) string {
+	t.Helper()
+	value := os.Getenv(name)
+	if value == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s must be set in CI (see make test-go)", name)
+		}
+		t.Skipf("%s not set", name)
+	}
+	return value
+}
+
+var (
+	openMu  sync.Mutex
+	openDBs = map[string]*gorm.DB{}
+)
+
+// Postgres points config.DB at schema in the TEST_DATABASE_URL database and
+// empties every table in it. The schema keeps packages tested in parallel
+// apart; migrate runs once, on the first call for the schema.
+func Postgres(t testing.TB, schema string, migrate func(db *gorm.DB) error) *gorm.DB {
+	t.Helper()
+	dsn := Require(t, "TEST_DATABASE_URL")
+
+	openMu.Lock()
+	db, ok := openDBs[schema]
+	if !ok {
+		var err error
+		if db, err = openSchema(dsn, schema); err == nil {
+			err = migrate(db)
+		}
+		if err != nil {
+			openMu.Unlock()
+			t.Fatalf("test database: %v", err)
+		}
+		openDBs[schema] = db
+	}
+	openMu.Unlock()
+
+	var tables []string
+	if err := db.Raw("SELECT tablename FROM pg_tables WHERE schemaname = ?", schema).Scan(&tables).Error; err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	if len(tables) > 0 {
+		if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+			t.Fatalf("emptying tables: %v", err)
+		}
+	}
+	config.DB = db
+	return db
+}
+
+func openSchema(dsn, schema string) (*gorm.DB, error) {
+	quiet := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), quiet)
+	if err != nil {
+		return nil, err
+	}
+	if err := admin.Exec("CREATE SCHEMA IF NOT EXISTS " + schema).Error; err != nil {
+		return nil, err
+	}
+	if sqlDB, err := admin.DB(); err == nil {
+		sqlDB.Close()
+	}
+	return gorm.Open(postgres.Open(withSearchPath(dsn, schema)), quiet)
+}
+
+// withSearchPath adds search_path to a URL or keyword/value connection string
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+// Redis connects to the server at TEST_REDIS_ADDR and closes the client when
+// the test ends
+func Redis(t testing.TB) *redis.Client {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: Require(t, "TEST_REDIS_ADDR")})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		t.Fatalf("test redis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
@@ -8,11 +8,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// LogActivity creates an activity log entry
+// LogActivity creates an activity log entry. c is nil for changes made by
+// background jobs, which have no client to record.
 func LogActivity(c *gin.Context, userID uint, action models.ActivityAction, entityType models.ActivityEntity, entityID uint, entityName string, description string, changes interface{}) error {
 	// Get IP address and user agent
-	ipAddress := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
+	var ipAddress, userAgent string
+	if c != nil {
+		ipAddress = c.ClientIP()
+		userAgent = c.GetHeader("User-Agent")
+	}
 
 	// Convert changes to JSON string if provided
 	var changesJSON string
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrExchangeRateNotFound is returned when no rate is known for a currency pair
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+// ChargeCurrency returns the currency customers are charged in (CHARGE_CURRENCY, default IDR)
+func ChargeCurrency() string {
+	if currency := strings.ToUpper(os.Getenv("CHARGE_CURRENCY")); models.IsSupportedCurrency(currency) {
+		return currency
+	}
+	return models.DefaultCurrency
+}
+
+// Conversion is an amount converted into another currency together with the rate used
+type Conversion struct {
+	Amount         int64
+	Currency       string
+	Rate           float64
+	ExchangeRateID *uint // nil when no conversion was needed
+}
+
+// ConvertAmount converts a minor-unit amount into currency at the newest rate
+// effective at the given time. A rate entered only for the opposite direction
+// is used inverted.
+func ConvertAmount(tx *gorm.DB, amount int64, from, to string, at time.Time) (Conversion, error) {
+	if from == to {
+		return Conversion{Amount: amount, Currency: to, Rate: 1}, nil
+	}
+
+	rate, err := findExchangeRate(tx, from, to, at)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	value := rate.Rate
+	if rate.BaseCurrency != from {
+		value = 1 / rate.Rate
+	}
+
+	id := rate.ID
+	return Conversion{
+		Amount:         models.MajorToMinor(models.MinorToMajor(amount, from)*value, to),
+		Currency:       to,
+		Rate:           math.Round(value*1e10) / 1e10,
+		ExchangeRateID: &id,
+	}, nil
+}
+
+func findExchangeRate(tx *gorm.DB, from, to string, at time.Time) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := tx.Where("((base_currency = ? AND quote_currency = ?) OR (base_currency = ? AND quote_currency = ?)) AND effective_at <= ?",
+		from, to, to, from, at).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "effective_at DESC, (base_currency = ?) DESC, id DESC",
+			Vars:               []interface{}{from},
+			WithoutParentheses: true,
+		}}).
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrExchangeRateNotFound, from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// ErrExchangeRateFormat is returned for exchange rate files that cannot be read
+var ErrExchangeRateFormat = errors.New("invalid exchange rate file")
+
+// ParseEffectiveAt reads an RFC 3339 timestamp or a YYYY-MM-DD date; empty means now
+func ParseEffectiveAt(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// ParseExchangeRateCSV reads rates from a CSV file with the columns
+// base_currency, quote_currency, rate and optionally effective_at
+func ParseExchangeRateCSV(r io.Reader) ([]models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeRateFormat, err)
+	}
+	columns := map[string]int{"effective at": -1}
+	for i, name := range header {
+		columns[normalizeHeader(name)] = i
+	}
+	for _, required := range []string{"base currency", "quote currency", "rate"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrExchangeRateFormat, strings.ReplaceAll(required, " ", "_"))
+		}
+	}
+
+	rates := []models.ExchangeRate{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrExchangeRateFormat, line, err)
+		}
+
+		rate := models.ExchangeRate{
+			BaseCurrency:  strings.ToUpper(csvField(row, columns["base currency"])),
+			QuoteCurrency: strings.ToUpper(csvField(row, columns["quote currency"])),
+		}
+		if !models.IsSupportedCurrency(rate.BaseCurrency) || !models.IsSupportedCurrency(rate.QuoteCurrency) || rate.BaseCurrency == rate.QuoteCurrency {
+			return nil, fmt.Errorf("%w: line %d: unsupported currency pair %s/%s", ErrExchangeRateFormat, line, rate.BaseCurrency, rate.QuoteCurrency)
+		}
+		if rate.Rate, err = strconv.ParseFloat(csvField(row, columns["rate"]), 64); err != nil || rate.Rate <= 0 {
+			return nil, fmt.Errorf("%w: line %d: rate must be a positive number", ErrExchangeRateFormat, line)
+		}
+		if rate.EffectiveAt, err = ParseEffectiveAt(csvField(row, columns["effective at"])); err != nil {
+			return nil, fmt.Errorf("%w: line %d: effective_at must be RFC 3339 or YYYY-MM-DD", ErrExchangeRateFormat, line)
+		}
+		rates = append(rates, rate)
+	}
+
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: no rates found", ErrExchangeRateFormat)
+	}
+	return rates, nil
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"errors"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrDocumentsIncomplete is returned when a purchase still lacks approved required documents
+var ErrDocumentsIncomplete = errors.New("required documents are not approved")
+
+// BuildDocumentChecklist expands a purchase's visa requirements into checklist
+// slots (one per applicant for per-applicant requirements) and matches each
+// slot with its current upload.
+func BuildDocumentChecklist(tx *gorm.DB, purchase models.VisaPurchase) ([]models.DocumentChecklistItem, error) {
+	var requirements []models.VisaDocumentRequirement
+	if err := tx.Where("visa_id = ?", purchase.VisaID).Order("sort_order ASC, id ASC").Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+
+	var applicants []models.PurchaseApplicant
+	if err := tx.Where("visa_purchase_id = ?", purchase.ID).
+		Order("applicant_id ASC").
+		Find(&applicants).Error; err != nil {
+		return nil, err
+	}
+
+	var documents []models.PurchaseDocument
+	if err := tx.Where("purchase_id = ?", purchase.ID).Order("created_at DESC").Find(&documents).Error; err != nil {
+		return nil, err
+	}
+
+	findDocument := func(requirementID uint, applicantID *uint) *models.PurchaseDocument {
+		for i := range documents {
+			doc := &documents[i]
+			if doc.RequirementID != requirementID {
+				continue
+			}
+			if (doc.ApplicantID == nil && applicantID == nil) ||
+				(doc.ApplicantID != nil && applicantID != nil && *doc.ApplicantID == *applicantID) {
+				return doc
+			}
+		}
+		return nil
+	}
+
+	items := []models.DocumentChecklistItem{}
+	for _, requirement := range requirements {
+		if requirement.PerApplicant && len(applicants) > 0 {
+			for _, applicant := range applicants {
+				applicantID := applicant.ApplicantID
+				doc := findDocument(requirement.ID, &applicantID)
+				items = append(items, models.DocumentChecklistItem{
+					Requirement:   requirement,
+					ApplicantID:   &applicantID,
+					ApplicantName: applicant.FullName,
+					Document:      doc,
+					Satisfied:     doc != nil && doc.Status == models.DocumentStatusApproved,
+				})
+			}
+			continue
+		}
+
+		doc := findDocument(requirement.ID, nil)
+		items = append(items, models.DocumentChecklistItem{
+			Requirement: requirement,
+			Document:    doc,
+			Satisfied:   doc != nil && doc.Status == models.DocumentStatusApproved,
+		})
+	}
+
+	return items, nil
+}
+
+// MissingRequiredDocuments returns a label for every required checklist slot
+// that does not have an approved document yet
+func MissingRequiredDocuments(tx *gorm.DB, purchase models.VisaPurchase) ([]string, error) {
+	items, err := BuildDocumentChecklist(tx, purchase)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for _, item := range items {
+		if !item.Requirement.IsRequired || item.Satisfied {
+			continue
+		}
+		label := item.Requirement.Name
+		if item.ApplicantName != "" {
+			label += " (" + item.ApplicantName + ")"
+		}
+		missing = append(missing, label)
+	}
+	return missing, nil
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyLockTTL bounds how long a key stays in progress if the
+	// instance handling the first request dies before storing its response
+	idempotencyLockTTL = time.Minute
+)
+
+// IdempotencyRecord is the stored outcome of the first request made with an idempotency key
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	StatusCode  int    `json:"status_code"` // 0 while the first request is still running
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// Completed reports whether the response of the first request has been stored
+func (r IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// IdempotencyTTL returns how long responses are kept for replay (IDEMPOTENCY_TTL, default 24h)
+func IdempotencyTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultIdempotencyTTL
+}
+
+// ClaimIdempotencyKey reserves a user's idempotency key for a new request.
+// It returns nil when the key was free and is now held by the caller, or the
+// record of the earlier request that holds it.
+func ClaimIdempotencyKey(ctx context.Context, userID uint, key, fingerprint string) (*IdempotencyRecord, error) {
+	if redisAvailable() {
+		return claimIdempotencyKeyRedis(ctx, userID, key, fingerprint)
+	}
+	return claimIdempotencyKeyDB(userID, key, fingerprint)
+}
+
+// CompleteIdempotencyKey stores the response of the request holding the key
+func CompleteIdempotencyKey(ctx context.Context, userID uint, key string, record IdempotencyRecord) error {
+	if redisAvailable() {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return config.RedisClient.Set(ctx, idempotencyRedisKey(userID, key), data, IdempotencyTTL()).Err()
+	}
+	return config.DB.Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND key = ?", userID, key).
+		Updates(map[string]interface{}{
+			"status_code":  record.StatusCode,
+			"content_type": record.ContentType,
+			"body":         record.Body,
+			"expires_at":   time.Now().Add(IdempotencyTTL()),
+		}).Error
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed, so the client can retry it
+func ReleaseIdempotencyKey(ctx context.Context, userID uint, key string) error {
+	if redisAvailable() {
+		return config.RedisClient.Del(ctx, idempotencyRedisKey(userID, key)).Err()
+	}
+	return config.DB.Where("user_id = ? AND key = ?", userID, key).Delete(&models.IdempotencyKey{}).Error
+}
+
+func claimIdempotencyKeyRedis(ctx context.Context, userID uint, key, fingerprint string) (*IdempotencyRecord, error) {
+	redisKey := idempotencyRedisKey(userID, key)
+	data, err := json.Marshal(IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	// The held key may expire between SETNX and GET, so try again once
+	for attempt := 0; attempt < 2; attempt++ {
+		claimed, err := config.RedisClient.SetNX(ctx, redisKey, data, idempotencyLockTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			return nil, nil
+		}
+
+		stored, err := config.RedisClient.Get(ctx, redisKey).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var record IdempotencyRecord
+		if err := json.Unmarshal(stored, &record); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+	return nil, fmt.Errorf("idempotency key %q could not be claimed", key)
+}
+
+func claimIdempotencyKeyDB(userID uint, key, fingerprint string) (*IdempotencyRecord, error) {
+	now := time.Now()
+	if err := config.DB.Where("user_id = ? AND expires_at < ?", userID, now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+
+	row := models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(idempotencyLockTTL),
+	}
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := config.DB.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &IdempotencyRecord{
+		Fingerprint: existing.Fingerprint,
+		StatusCode:  existing.StatusCode,
+		ContentType: existing.ContentType,
+		Body:        existing.Body,
+	}, nil
+}
+
+func idempotencyRedisKey(userID uint, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s", userID, key)
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"time"
+	"viskatera-api-go/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultInviteTTL  = 72 * time.Hour
+	invitePurposeName = "invite"
+)
+
+// ErrInviteTokenInvalid is returned for tampered, expired or foreign invite tokens
+var ErrInviteTokenInvalid = errors.New("invite token is invalid or expired")
+
+// InviteTTL returns how long invitations stay valid (INVITE_TTL, default 72h)
+func InviteTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("INVITE_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultInviteTTL
+}
+
+// GenerateInviteToken signs an invite token for the given email. The token
+// carries a random nonce so re-inviting the same address never repeats a token.
+func GenerateInviteToken(email string, expiresAt time.Time) (string, error) {
+	nonce, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose": invitePurposeName,
+		"email":   email,
+		"jti":     nonce,
+		"exp":     expiresAt.Unix(),
+		"iat":     time.Now().Unix(),
+	})
+	return token.SignedString(jwtSecret())
+}
+
+// ParseInviteToken verifies an invite token's signature and expiry and returns
+// the email it was issued for
+func ParseInviteToken(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return jwtSecret(), nil
+	}, jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return "", ErrInviteTokenInvalid
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != invitePurposeName {
+		return "", ErrInviteTokenInvalid
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return "", ErrInviteTokenInvalid
+	}
+	return email, nil
+}
+
+// HashInviteToken returns the digest stored for an invite token
+func HashInviteToken(token string) string {
+	return hashToken(token)
+}
+
+// ReissueInviteToken signs a new token for a pending invite and stores its
+// hash, so only the link in the latest invitation email can be accepted. The
+// plain token is returned to be emailed and is never stored.
+func ReissueInviteToken(db *gorm.DB, invite *models.UserInvite) (string, error) {
+	token, err := GenerateInviteToken(invite.Email, invite.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	tokenHash := HashInviteToken(token)
+	result := db.Model(&models.UserInvite{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invite.ID).
+		Update("token_hash", tokenHash)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrInviteTokenInvalid
+	}
+	invite.TokenHash = tokenHash
+	return token, nil
+}
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IssueInvoice issues the invoice for a purchase. payment is nil for purchases
+// with nothing to pay. The number is taken in tx, so if the transaction rolls
+// back the number is handed out again and the sequence has no gaps. Issuing
+// again for the same payment returns the existing invoice.
+func IssueInvoice(tx *gorm.DB, purchase models.VisaPurchase, payment *models.Payment) (*models.Invoice, error) {
+	invoice := models.Invoice{
+		Type:       models.InvoiceTypeInvoice,
+		PurchaseID: purchase.ID,
+		UserID:     purchase.UserID,
+		Amount:     purchase.TotalPrice,
+		Currency:   purchase.Currency,
+	}
+	if payment != nil {
+		var existing models.Invoice
+		err := tx.Where("payment_id = ?", payment.ID).First(&existing).Error
+		if err == nil {
+			return &existing, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		invoice.PaymentID = &payment.ID
+		invoice.Amount = payment.Amount
+		invoice.Currency = payment.Currency
+	}
+
+	if err := createInvoice(tx, &invoice); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// IssueCreditNote issues the credit note for a succeeded refund, referencing
+// the invoice of the refunded payment. Issuing again for the same refund
+// returns the existing credit note.
+func IssueCreditNote(tx *gorm.DB, refund models.Refund) (*models.Invoice, error) {
+	var existing models.Invoice
+	err := tx.Where("refund_id = ?", refund.ID).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var payment models.Payment
+	if err := tx.First(&payment, refund.PaymentID).Error; err != nil {
+		return nil, err
+	}
+
+	note := models.Invoice{
+		Type:       models.InvoiceTypeCreditNote,
+		PurchaseID: refund.PurchaseID,
+		UserID:     payment.UserID,
+		RefundID:   &refund.ID,
+		Amount:     refund.Amount,
+		Currency:   payment.Currency,
+	}
+
+	// Payments made before invoices were recorded have nothing to reference
+	var credited models.Invoice
+	err = tx.Where("payment_id = ?", payment.ID).First(&credited).Error
+	if err == nil {
+		note.CreditedInvoiceID = &credited.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err := createInvoice(tx, &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// createInvoice numbers and stores an invoice. The sequence row stays locked
+// until tx ends, so concurrent invoices are numbered one after the other.
+func createInvoice(tx *gorm.DB, invoice *models.Invoice) error {
+	invoice.IssuedAt = time.Now()
+	invoice.Year = invoice.IssuedAt.Year()
+
+	sequence := models.InvoiceSequence{Type: invoice.Type, Year: invoice.Year, LastNumber: 1}
+	if err := tx.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "type"}, {Name: "year"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"last_number": gorm.Expr("invoice_sequences.last_number + 1"),
+			}),
+		},
+		clause.Returning{},
+	).Create(&sequence).Error; err != nil {
+		return err
+	}
+
+	invoice.Sequence = sequence.LastNumber
+	invoice.Number = fmt.Sprintf("%s/%d/%06d", invoice.Type.Prefix(), invoice.Year, invoice.Sequence)
+	if err := tx.Create(invoice).Error; err != nil {
+		return err
+	}
+
+	// Render the PDF ahead of the first download or email
+	return EnqueueJob(tx, config.QueueGeneratePDF, map[string]interface{}{"invoice_id": invoice.ID})
+}
+
+// InvoicePDF returns the stored PDF of an invoice or credit note. It is
+// rendered and stored the first time, and again only if the file went missing,
+// in the customer's preferred language at that moment.
+func InvoicePDF(db *gorm.DB, invoice *models.Invoice) (string, error) {
+	if invoice.FilePath != "" {
+		if _, err := os.Stat(invoice.FilePath); err == nil {
+			return moveInvoicePDF(db, invoice)
+		}
+	}
+
+	var purchase models.VisaPurchase
+	if err := db.Preload("Visa").Preload("VisaOption", Unscoped).Preload("Applicants").
+		Preload("LineItems", LineItemsInOrder).
+		First(&purchase, invoice.PurchaseID).Error; err != nil {
+		return "", err
+	}
+	var user models.User
+	if err := db.First(&user, invoice.UserID).Error; err != nil {
+		return "", err
+	}
+
+	var path string
+	var err error
+	switch invoice.Type {
+	case models.InvoiceTypeCreditNote:
+		var refund models.Refund
+		if err := db.First(&refund, *invoice.RefundID).Error; err != nil {
+			return "", err
+		}
+		var credited *models.Invoice
+		if invoice.CreditedInvoiceID != nil {
+			credited = &models.Invoice{}
+			if err := db.First(credited, *invoice.CreditedInvoiceID).Error; err != nil {
+				return "", err
+			}
+		}
+		path, err = GenerateCreditNotePDF(*invoice, credited, refund, purchase, user)
+	default:
+		var payment *models.Payment
+		if invoice.PaymentID != nil {
+			payment = &models.Payment{}
+			if err := db.First(payment, *invoice.PaymentID).Error; err != nil {
+				return "", err
+			}
+		}
+		path, err = GeneratePurchaseInvoicePDF(*invoice, purchase, user, payment)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := db.Model(invoice).Update("file_path", path).Error; err != nil {
+		return "", err
+	}
+	invoice.FilePath = path
+	return path, nil
+}
+
+// moveInvoicePDF moves a PDF stored before invoices left the public upload
+// directory into InvoiceDir, so the stored document is kept as issued
+func moveInvoicePDF(db *gorm.DB, invoice *models.Invoice) (string, error) {
+	dir, err := filepath.Abs(InvoiceDir())
+	if err != nil {
+		return "", err
+	}
+	current, err := filepath.Abs(invoice.FilePath)
+	if err != nil {
+		return "", err
+	}
+	if filepath.Dir(current) == dir {
+		return invoice.FilePath, nil
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create invoices directory: %w", err)
+	}
+	path := filepath.Join(InvoiceDir(), filepath.Base(invoice.FilePath))
+	if err := moveFile(invoice.FilePath, path); err != nil {
+		return "", fmt.Errorf("failed to move invoice PDF: %w", err)
+	}
+	if err := db.Model(invoice).Update("file_path", path).Error; err != nil {
+		return "", err
+	}
+	invoice.FilePath = path
+	return path, nil
+}
+
+// moveFile renames src to dst, copying it when they are on different file
+// systems, e.g. separate Docker volumes
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+)
+
+var errRollback = errors.New("rolled back")
+
+func TestInvoiceNumbering(t *testing.T) {
+	db := testDB(t)
+	year := time.Now().Year()
+	purchase, payments := paidInvoicePurchase(t, db, 4)
+	refund := models.Refund{PaymentID: payments[0].ID, PurchaseID: purchase.ID, Amount: 100000, Reason: "test", Status: models.RefundStatusSucceeded, Gateway: "fake"}
+	insert(t, db, &refund)
+
+	// Steps run in order; a rolled back step must not leave a gap behind
+	steps := []struct {
+		name     string
+		issue    func(tx *gorm.DB) (*models.Invoice, error)
+		rollback bool
+		want     string
+	}{
+		{
+			name:  "first invoice",
+			issue: func(tx *gorm.DB) (*models.Invoice, error) { return IssueInvoice(tx, purchase, &payments[0]) },
+			want:  fmt.Sprintf("INV/%d/000001", year),
+		},
+		{
+			name:     "rolled back invoice",
+			issue:    func(tx *gorm.DB) (*models.Invoice, error) { return IssueInvoice(tx, purchase, &payments[1]) },
+			rollback: true,
+			want:     fmt.Sprintf("INV/%d/000002", year),
+		},
+		{
+			name:  "number of the rolled back invoice is issued again",
+			issue: func(tx *gorm.DB) (*models.Invoice, error) { return IssueInvoice(tx, purchase, &payments[2]) },
+			want:  fmt.Sprintf("INV/%d/000002", year),
+		},
+		{
+			name:  "invoice without payment",
+			issue: func(tx *gorm.DB) (*models.Invoice, error) { return IssueInvoice(tx, purchase, nil) },
+			want:  fmt.Sprintf("INV/%d/000003", year),
+		},
+		{
+			name:  "same payment returns the issued invoice",
+			issue: func(tx *gorm.DB) (*models.Invoice, error) { return IssueInvoice(tx, purchase, &payments[0]) },
+			want:  fmt.Sprintf("INV/%d/000001", year),
+		},
+		{
+			name:  "credit notes are numbered separately",
+			issue: func(tx *gorm.DB) (*models.Invoice, error) { return IssueCreditNote(tx, refund) },
+			want:  fmt.Sprintf("CN/%d/000001", year),
+		},
+		{
+			name:  "same refund returns the issued credit note",
+			issue: func(tx *gorm.DB) (*models.Invoice, error) { return IssueCreditNote(tx, refund) },
+			want:  fmt.Sprintf("CN/%d/000001", year),
+		},
+		{
+			name:  "invoices continue after credit notes",
+			issue: func(tx *gorm.DB) (*models.Invoice, error) { return IssueInvoice(tx, purchase, &payments[3]) },
+			want:  fmt.Sprintf("INV/%d/000004", year),
+		},
+	}
+	for _, step := range steps {
+		var invoice *models.Invoice
+		err := db.Transaction(func(tx *gorm.DB) (err error) {
+			if invoice, err = step.issue(tx); err != nil {
+				return err
+			}
+			if step.rollback {
+				return errRollback
+			}
+			return nil
+		})
+		if err != nil && !(step.rollback && errors.Is(err, errRollback)) {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if invoice.Number != step.want {
+			t.Errorf("%s: number = %s, want %s", step.name, invoice.Number, step.want)
+		}
+	}
+
+	var credited models.Invoice
+	db.Where("refund_id = ?", refund.ID).First(&credited)
+	if credited.CreditedInvoiceID == nil {
+		t.Errorf("credit note does not reference the invoice of the refunded payment")
+	}
+}
+
+func TestInvoiceNumberingUnderConcurrency(t *testing.T) {
+	db := testDB(t)
+	const invoices = 10
+	purchase, payments := paidInvoicePurchase(t, db, invoices)
+
+	var wg sync.WaitGroup
+	errs := make([]error, invoices)
+	for i := 0; i < invoices; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.Transaction(func(tx *gorm.DB) error {
+				if _, err := IssueInvoice(tx, purchase, &payments[i]); err != nil {
+					return err
+				}
+				// Every third transaction fails after taking its number
+				if i%3 == 0 {
+					return errRollback
+				}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, errRollback) {
+			t.Fatalf("invoice %d: %v", i+1, err)
+		}
+	}
+
+	var stored []int
+	db.Model(&models.Invoice{}).Order("sequence").Pluck("sequence", &stored)
+	if want := invoices - (invoices+2)/3; len(stored) != want {
+		t.Fatalf("stored %d invoices, want %d", len(stored), want)
+	}
+	for i, sequence := range stored {
+		if sequence != i+1 {
+			t.Fatalf("stored sequences %v have a gap", stored)
+		}
+	}
+}
+
+// paidInvoicePurchase stores a purchase with the given number of paid
+// payments, one per invoice to issue
+func paidInvoicePurchase(t *testing.T, db *gorm.DB, paymentCount int) (models.VisaPurchase, []models.Payment) {
+	t.Helper()
+	user := models.User{Email: "customer@example.com", Password: "x", Name: "Customer"}
+	visa := models.Visa{Country: "Japan", Type: "Tourist", Price: 100000, Currency: "IDR", Duration: 30}
+	insert(t, db, &user, &visa)
+	purchase := models.VisaPurchase{UserID: user.ID, VisaID: visa.ID, TotalPrice: 100000, Subtotal: 100000, Currency: "IDR", Status: models.PurchaseStatusSubmitted}
+	insert(t, db, &purchase)
+
+	payments := make([]models.Payment, paymentCount)
+	for i := range payments {
+		payments[i] = models.Payment{UserID: user.ID, PurchaseID: purchase.ID, PaymentMethod: "virtual_account", Amount: 100000, Currency: "IDR",
+			Status: "paid", Gateway: "fake", XenditID: fmt.Sprintf("inv-%d", i+1)}
+		insert(t, db, &payments[i])
+	}
+	return purchase, payments
+}
@@ -7,25 +7,46 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateJWT(userID uint) (string, error) {
+const defaultAccessTokenTTL = 15 * time.Minute
+
+// AccessTokenTTL returns how long access tokens stay valid (JWT_ACCESS_TTL, default 15m)
+func AccessTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("JWT_ACCESS_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultAccessTokenTTL
+}
+
+// GenerateJWT issues a short-lived access token bound to a login session.
+// The session ID lets AuthMiddleware reject the token once the session is revoked.
+func GenerateJWT(userID uint, sessionID string) (string, error) {
+	now := time.Now()
+
 	// Create token with claims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24 hours
-		"iat":     time.Now().Unix(),
+		"sid":     sessionID,
+		"exp":     now.Add(AccessTokenTTL()).Unix(),
+		"iat":     now.Unix(),
+		// iat has one-second resolution; session revocation compares milliseconds so a
+		// login right after a logout-all in the same second is not rejected
+		"iat_ms": now.UnixMilli(),
 	})
 
-	// Get JWT secret from environment
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-super-secret-jwt-key-here" // fallback
-	}
-
 	// Sign token with secret
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := token.SignedString(jwtSecret())
 	if err != nil {
 		return "", err
 	}
 
 	return tokenString, nil
 }
+
+// jwtSecret returns the HMAC key used to sign tokens
+func jwtSecret() []byte {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "your-super-secret-jwt-key-here" // fallback
+	}
+	return []byte(secret)
+}
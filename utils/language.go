@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"viskatera-api-go/config"
+	"viskatera-api-go/i18n"
+	"viskatera-api-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LanguageContextKey holds the language the Localize middleware matched from
+// Accept-Language; it is not set when the header asks for no supported language
+const LanguageContextKey = "language"
+
+// RequestLanguage returns the language negotiated from the request's
+// Accept-Language header, or i18n.Default. It is stored as the preferred
+// language of accounts created without one.
+func RequestLanguage(c *gin.Context) i18n.Language {
+	if lang, ok := c.Get(LanguageContextKey); ok {
+		return lang.(i18n.Language)
+	}
+	return i18n.Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// UserLanguage returns the preferred language of a user, or i18n.Default when
+// the user cannot be loaded. It is cached in Redis when caching is enabled;
+// call ForgetUserLanguage after changing it.
+func UserLanguage(ctx context.Context, userID uint) i18n.Language {
+	var language string
+	if err := config.CacheGet(ctx, userLanguageCacheKey(userID), &language); err == nil {
+		return i18n.Of(language)
+	}
+
+	var user models.User
+	if err := config.DB.Select("id", "language").First(&user, userID).Error; err != nil {
+		return i18n.Default
+	}
+	if err := config.CacheSet(ctx, userLanguageCacheKey(userID), user.Language, config.GetCacheTTL()); err != nil {
+		log.Printf("Failed to cache language of user %d: %v", userID, err)
+	}
+	return i18n.Of(user.Language)
+}
+
+// ForgetUserLanguage drops the cached preferred language of a user
+func ForgetUserLanguage(ctx context.Context, userID uint) {
+	if err := config.CacheDelete(ctx, userLanguageCacheKey(userID)); err != nil {
+		log.Printf("Failed to clear cached language of user %d: %v", userID, err)
+	}
+}
+
+func userLanguageCacheKey(userID uint) string {
+	return fmt.Sprintf("user:language:%d", userID)
+}
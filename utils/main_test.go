@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"viskatera-api-go/config"
+	"viskatera-api-go/testenv"
+
+	"gorm.io/gorm"
+)
+
+// testDB points config.DB at an emptied, fully migrated schema of the test
+// database. The schema keeps these tests apart from other packages.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return testenv.Postgres(t, "utils_test", func(db *gorm.DB) error {
+		config.DB = db
+		config.MigrateDB()
+		return nil
+	})
+}
+
+// insert creates the given rows, failing the test when one cannot be stored
+func insert(t *testing.T, db *gorm.DB, rows ...any) {
+	t.Helper()
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("creating %T: %v", row, err)
+		}
+	}
+}
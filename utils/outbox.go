@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"encoding/json"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+)
+
+// EnqueueJob writes a queue job to the outbox in tx. The outbox relay
+// publishes it once tx has committed, so the job goes out exactly when the
+// change it belongs to is saved, even if RabbitMQ is down at the time.
+func EnqueueJob(tx *gorm.DB, queue string, job interface{}) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxMessage{
+		Queue:         queue,
+		Payload:       string(payload),
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// enqueuePaymentSuccessEmail queues the payment success email with the invoice PDF
+func enqueuePaymentSuccessEmail(tx *gorm.DB, payment *models.Payment) error {
+	var user models.User
+	if err := tx.First(&user, payment.UserID).Error; err != nil {
+		return err
+	}
+	return EnqueueJob(tx, config.QueueEmailPaymentSuccess, map[string]interface{}{
+		"purchase_id": payment.PurchaseID,
+		"user_id":     user.ID,
+		"email":       user.Email,
+		"type":        "payment_success",
+	})
+}
+
+// enqueueRefundEmail queues the refund confirmation email with the credit note PDF
+func enqueueRefundEmail(tx *gorm.DB, refund *models.Refund, payment *models.Payment) error {
+	var user models.User
+	if err := tx.First(&user, payment.UserID).Error; err != nil {
+		return err
+	}
+	return EnqueueJob(tx, config.QueueEmailRefund, map[string]interface{}{
+		"refund_id": refund.ID,
+		"user_id":   user.ID,
+		"email":     user.Email,
+	})
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"viskatera-api-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPaymentAmountMismatch is returned when the gateway reports less money than the payment expects
+var ErrPaymentAmountMismatch = errors.New("paid amount does not match payment amount")
+
+// PaymentStatusChange describes what ApplyGatewayPaymentStatus changed
+type PaymentStatusChange struct {
+	OldStatus         string
+	NewStatus         string
+	Purchase          *models.VisaPurchase // set when the purchase moved to submitted
+	OldPurchaseStatus models.PurchaseStatus
+	RefundRequired    bool // paid, but the purchase could not take the payment
+}
+
+// Changed reports whether the payment status was updated
+func (c PaymentStatusChange) Changed() bool {
+	return c.OldStatus != c.NewStatus
+}
+
+// BecamePaid reports whether this change marked the payment as paid
+func (c PaymentStatusChange) BecamePaid() bool {
+	return c.Changed() && c.NewStatus == "paid"
+}
+
+// NormalizePaymentStatus maps a gateway status onto our payment statuses
+func NormalizePaymentStatus(gatewayStatus string) string {
+	status := strings.ToLower(gatewayStatus)
+	if status == "settled" {
+		return "paid"
+	}
+	return status
+}
+
+// ApplyGatewayPaymentStatus moves a payment to the status reported by the
+// gateway and submits the purchase once it is paid. It only moves forward:
+// paid payments are never downgraded and repeated reports change nothing, so
+// callers can apply the same report any number of times. Refunded payments
+// are left alone as well. paidAmount is in major units of the payment
+// currency, as gateways report it, and is checked against the payment when positive.
+func ApplyGatewayPaymentStatus(tx *gorm.DB, payment *models.Payment, gatewayStatus string, paidAmount float64, note string) (PaymentStatusChange, error) {
+	change := PaymentStatusChange{OldStatus: payment.Status, NewStatus: payment.Status}
+
+	status := NormalizePaymentStatus(gatewayStatus)
+	switch status {
+	case "paid", "expired", "failed":
+	default:
+		return change, nil
+	}
+	if status == payment.Status || payment.Status == "paid" || payment.Status == "refunded" {
+		return change, nil
+	}
+
+	if paid := models.MajorToMinor(paidAmount, payment.Currency); status == "paid" && paid > 0 && paid != payment.Amount {
+		return change, fmt.Errorf("%w: expected %s, got %s", ErrPaymentAmountMismatch,
+			models.FormatAmount(payment.Amount, payment.Currency), models.FormatAmount(paid, payment.Currency))
+	}
+
+	// Guard on the current status so concurrent reports cannot both apply
+	result := tx.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, payment.Status).
+		Update("status", status)
+	if result.Error != nil {
+		return change, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return change, tx.First(payment, payment.ID).Error
+	}
+	payment.Status = status
+	change.NewStatus = status
+
+	if status != "paid" {
+		return change, nil
+	}
+
+	// Payment confirms the application: invoice it, email the invoice and move the draft to submitted.
+	// The purchase stays locked so two payments for it cannot both be taken.
+	var purchase models.VisaPurchase
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&purchase, payment.PurchaseID).Error; err != nil {
+		return change, err
+	}
+	accepted, err := purchaseAcceptsPayment(tx, purchase, payment)
+	if err != nil {
+		return change, err
+	}
+	if !accepted {
+		// A late payment on a cancelled order, or a second paid invoice: the money
+		// was taken, so it has to go back rather than be invoiced
+		if err := tx.Model(&models.Payment{}).Where("id = ?", payment.ID).Update("refund_required", true).Error; err != nil {
+			return change, err
+		}
+		payment.RefundRequired = true
+		change.RefundRequired = true
+		log.Printf("Payment %d received for purchase %d in status %s, flagged for refund", payment.ID, purchase.ID, purchase.Status)
+		return change, nil
+	}
+	if _, err := IssueInvoice(tx, purchase, payment); err != nil {
+		return change, err
+	}
+	if err := enqueuePaymentSuccessEmail(tx, payment); err != nil {
+		return change, err
+	}
+	oldPurchaseStatus := purchase.Status
+	if err := TransitionPurchase(tx, &purchase, models.PurchaseStatusSubmitted, models.ActorWebhook, nil, note); err != nil {
+		if !errors.Is(err, ErrInvalidTransition) {
+			return change, err
+		}
+		log.Printf("Purchase %d not submitted after payment %d: %v", purchase.ID, payment.ID, err)
+		return change, nil
+	}
+	change.Purchase = &purchase
+	change.OldPurchaseStatus = oldPurchaseStatus
+
+	return change, nil
+}
+
+// purchaseAcceptsPayment reports whether a paid payment can submit the
+// purchase: it must still be a draft and not be paid by another payment
+func purchaseAcceptsPayment(tx *gorm.DB, purchase models.VisaPurchase, payment *models.Payment) (bool, error) {
+	if !models.CanTransition(purchase.Status, models.PurchaseStatusSubmitted, models.ActorWebhook) {
+		return false, nil
+	}
+	var otherPaid int64
+	if err := tx.Model(&models.Payment{}).
+		Where("purchase_id = ? AND id <> ? AND status IN ? AND refund_required = ?", purchase.ID, payment.ID, []string{"paid", "refunded"}, false).
+		Count(&otherPaid).Error; err != nil {
+		return false, err
+	}
+	return otherPaid == 0, nil
+}
+
+// RecordPaymentStatusChange logs a committed payment status change. Callers
+// pass the change returned by ApplyGatewayPaymentStatus, so repeated reports
+// of the same status do nothing here. c may be nil for background jobs.
+func RecordPaymentStatusChange(c *gin.Context, actorID uint, payment *models.Payment, change PaymentStatusChange) {
+	if !change.Changed() {
+		return
+	}
+
+	// Log payment status update
+	entityName := "Payment #" + strconv.Itoa(int(payment.ID)) + " - " + payment.PaymentMethod
+	LogUpdate(c, actorID, models.EntityPayment, payment.ID, entityName,
+		map[string]interface{}{"status": change.OldStatus},
+		map[string]interface{}{"status": change.NewStatus},
+	)
+	if change.RefundRequired {
+		LogUpdate(c, actorID, models.EntityPayment, payment.ID, entityName,
+			map[string]interface{}{"refund_required": false},
+			map[string]interface{}{"refund_required": true},
+		)
+	}
+
+	if change.Purchase != nil {
+		// Log purchase status update
+		purchaseEntityName := "Purchase #" + strconv.Itoa(int(change.Purchase.ID))
+		LogUpdate(c, actorID, models.EntityPurchase, change.Purchase.ID, purchaseEntityName,
+			map[string]interface{}{"status": change.OldPurchaseStatus},
+			map[string]interface{}{"status": change.Purchase.Status},
+		)
+	}
+}
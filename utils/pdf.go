@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+	"viskatera-api-go/i18n"
 	"viskatera-api-go/models"
 
 	"github.com/jung-kurt/gofpdf"
@@ -12,27 +15,64 @@ import (
 
 // InvoiceData represents data for invoice generation
 type InvoiceData struct {
+	Language      i18n.Language // labels and dates; defaults to i18n.Default
+	Title         string        // "Invoice" or "Credit Note", in Language
+	Reference     string        // e.g. the invoice a credit note reduces
 	InvoiceNumber string
 	Date          time.Time
 	CustomerName  string
 	CustomerEmail string
-	Items         []InvoiceItem
-	Subtotal      float64
-	Total         float64
+	Applicants    []InvoiceApplicant
+	TravelDate    *time.Time
+	Items         []InvoiceItem // visa and visa option lines
+	Currency      string        // currency charged
+	Subtotal      int64
+	Adjustments   []InvoiceItem // discount, service fee and tax lines after the subtotal
+	Total         int64
 	PaymentMethod string
-	Status        string
+	Status        string // in Language
+
+	// Set when the visa is priced in another currency than the one charged
+	OriginalCurrency string
+	ExchangeRate     float64
+}
+
+// InvoiceApplicant represents a traveler listed on the invoice
+type InvoiceApplicant struct {
+	FullName       string
+	PassportNumber string
+	Nationality    string
 }
 
 // InvoiceItem represents an item in the invoice
 type InvoiceItem struct {
 	Description string
 	Quantity    int
-	Price       float64
-	Total       float64
+	Price       int64
+	Total       int64
 }
 
-// GenerateInvoicePDF generates a PDF invoice
+// InvoiceDir is where invoice and credit note PDFs are stored (INVOICE_DIR,
+// default ./storage/invoices). It must not be publicly served: the PDFs carry
+// passport numbers and are only handed out after an ownership check.
+func InvoiceDir() string {
+	if dir := os.Getenv("INVOICE_DIR"); dir != "" {
+		return dir
+	}
+	return "./storage/invoices"
+}
+
+// GenerateInvoicePDF renders an invoice or credit note and stores it under
+// InvoiceDir, named after its number
 func GenerateInvoicePDF(data InvoiceData) (string, error) {
+	if data.Language == "" {
+		data.Language = i18n.Default
+	}
+	lang := data.Language
+	if data.Title == "" {
+		data.Title = i18n.T(lang, "pdf.invoice")
+	}
+
 	// Create PDF
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
@@ -43,19 +83,24 @@ func GenerateInvoicePDF(data InvoiceData) (string, error) {
 	pdf.Ln(10)
 
 	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(40, 10, "Invoice")
+	pdf.Cell(40, 10, data.Title)
 	pdf.Ln(5)
 
 	// Invoice details
 	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 8, fmt.Sprintf("Invoice Number: %s", data.InvoiceNumber))
+	pdf.Cell(40, 8, i18n.T(lang, "pdf.number", data.Title, data.InvoiceNumber))
+	pdf.Ln(5)
+	pdf.Cell(40, 8, i18n.T(lang, "pdf.date", i18n.FormatDate(lang, data.Date)))
+	pdf.Ln(5)
+	if data.Reference != "" {
+		pdf.Cell(40, 8, data.Reference)
+		pdf.Ln(5)
+	}
 	pdf.Ln(5)
-	pdf.Cell(40, 8, fmt.Sprintf("Date: %s", data.Date.Format("January 2, 2006")))
-	pdf.Ln(10)
 
 	// Customer details
 	pdf.SetFont("Arial", "B", 12)
-	pdf.Cell(40, 10, "Bill To:")
+	pdf.Cell(40, 10, i18n.T(lang, "pdf.bill_to"))
 	pdf.Ln(5)
 	pdf.SetFont("Arial", "", 10)
 	pdf.Cell(40, 8, data.CustomerName)
@@ -63,100 +108,200 @@ func GenerateInvoicePDF(data InvoiceData) (string, error) {
 	pdf.Cell(40, 8, data.CustomerEmail)
 	pdf.Ln(15)
 
+	// Applicants
+	if len(data.Applicants) > 0 {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(40, 10, i18n.T(lang, "pdf.applicants"))
+		pdf.Ln(10)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(90, 8, i18n.T(lang, "pdf.full_name"), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(50, 8, i18n.T(lang, "pdf.passport_number"), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(50, 8, i18n.T(lang, "pdf.nationality"), "1", 0, "L", false, 0, "")
+		pdf.Ln(8)
+		pdf.SetFont("Arial", "", 10)
+		for _, applicant := range data.Applicants {
+			pdf.CellFormat(90, 8, applicant.FullName, "1", 0, "L", false, 0, "")
+			pdf.CellFormat(50, 8, applicant.PassportNumber, "1", 0, "L", false, 0, "")
+			pdf.CellFormat(50, 8, applicant.Nationality, "1", 0, "L", false, 0, "")
+			pdf.Ln(8)
+		}
+		if data.TravelDate != nil {
+			pdf.Cell(40, 8, i18n.T(lang, "pdf.travel_date", i18n.FormatDate(lang, *data.TravelDate)))
+			pdf.Ln(5)
+		}
+		pdf.Ln(10)
+	}
+
 	// Items table
 	pdf.SetFont("Arial", "B", 10)
-	pdf.CellFormat(100, 8, "Description", "1", 0, "L", false, 0, "")
-	pdf.CellFormat(30, 8, "Quantity", "1", 0, "C", false, 0, "")
-	pdf.CellFormat(30, 8, "Price", "1", 0, "R", false, 0, "")
-	pdf.CellFormat(30, 8, "Total", "1", 0, "R", false, 0, "")
+	pdf.CellFormat(100, 8, i18n.T(lang, "pdf.description"), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(30, 8, i18n.T(lang, "pdf.quantity"), "1", 0, "C", false, 0, "")
+	pdf.CellFormat(30, 8, i18n.T(lang, "pdf.price"), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(30, 8, i18n.T(lang, "pdf.total"), "1", 0, "R", false, 0, "")
 	pdf.Ln(8)
 
 	pdf.SetFont("Arial", "", 10)
 	for _, item := range data.Items {
 		pdf.CellFormat(100, 8, item.Description, "1", 0, "L", false, 0, "")
 		pdf.CellFormat(30, 8, fmt.Sprintf("%d", item.Quantity), "1", 0, "C", false, 0, "")
-		pdf.CellFormat(30, 8, fmt.Sprintf("Rp %.2f", item.Price), "1", 0, "R", false, 0, "")
-		pdf.CellFormat(30, 8, fmt.Sprintf("Rp %.2f", item.Total), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(30, 8, models.FormatAmount(item.Price, data.Currency), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(30, 8, models.FormatAmount(item.Total, data.Currency), "1", 0, "R", false, 0, "")
 		pdf.Ln(8)
 	}
 
 	// Totals
 	pdf.Ln(5)
 	pdf.CellFormat(130, 8, "", "", 0, "", false, 0, "")
-	pdf.CellFormat(30, 8, "Subtotal:", "1", 0, "R", false, 0, "")
-	pdf.CellFormat(30, 8, fmt.Sprintf("Rp %.2f", data.Subtotal), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(30, 8, i18n.T(lang, "pdf.subtotal"), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(30, 8, models.FormatAmount(data.Subtotal, data.Currency), "1", 0, "R", false, 0, "")
 	pdf.Ln(8)
 
+	for _, adjustment := range data.Adjustments {
+		pdf.CellFormat(100, 8, "", "", 0, "", false, 0, "")
+		pdf.CellFormat(60, 8, adjustment.Description+":", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(30, 8, models.FormatAmount(adjustment.Total, data.Currency), "1", 0, "R", false, 0, "")
+		pdf.Ln(8)
+	}
+
 	pdf.CellFormat(130, 8, "", "", 0, "", false, 0, "")
-	pdf.CellFormat(30, 8, "Total:", "1", 0, "R", false, 0, "")
+	pdf.CellFormat(30, 8, i18n.T(lang, "pdf.grand_total"), "1", 0, "R", false, 0, "")
 	pdf.SetFont("Arial", "B", 10)
-	pdf.CellFormat(30, 8, fmt.Sprintf("Rp %.2f", data.Total), "1", 0, "R", false, 0, "")
-	pdf.Ln(15)
+	pdf.CellFormat(30, 8, models.FormatAmount(data.Total, data.Currency), "1", 0, "R", false, 0, "")
+	pdf.Ln(10)
+
+	// Prices in another currency are charged at the rate captured at purchase time
+	if data.OriginalCurrency != "" && data.OriginalCurrency != data.Currency {
+		pdf.SetFont("Arial", "I", 9)
+		pdf.Cell(40, 8, i18n.T(lang, "pdf.converted", data.OriginalCurrency,
+			data.OriginalCurrency, strconv.FormatFloat(data.ExchangeRate, 'f', -1, 64), data.Currency))
+		pdf.Ln(5)
+	}
+	pdf.Ln(5)
 
 	// Payment info
 	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 8, fmt.Sprintf("Payment Method: %s", data.PaymentMethod))
-	pdf.Ln(5)
-	pdf.Cell(40, 8, fmt.Sprintf("Status: %s", data.Status))
+	if data.PaymentMethod != "" {
+		pdf.Cell(40, 8, i18n.T(lang, "pdf.payment_method", data.PaymentMethod))
+		pdf.Ln(5)
+	}
+	pdf.Cell(40, 8, i18n.T(lang, "pdf.status", data.Status))
 	pdf.Ln(10)
 
 	// Footer
 	pdf.SetFont("Arial", "I", 8)
-	pdf.CellFormat(0, 10, "Thank you for your business!", "", 0, "C", false, 0, "")
+	pdf.CellFormat(0, 10, i18n.T(lang, "pdf.thanks"), "", 0, "C", false, 0, "")
 
 	// Save PDF
-	uploadDir := os.Getenv("UPLOAD_DIR")
-	if uploadDir == "" {
-		uploadDir = "./uploads"
-	}
-
-	invoicesDir := filepath.Join(uploadDir, "invoices")
-	if err := os.MkdirAll(invoicesDir, 0755); err != nil {
+	invoicesDir := InvoiceDir()
+	if err := os.MkdirAll(invoicesDir, 0750); err != nil {
 		return "", fmt.Errorf("failed to create invoices directory: %w", err)
 	}
 
-	filename := fmt.Sprintf("invoice_%s_%d.pdf", data.InvoiceNumber, time.Now().Unix())
-	filepath := filepath.Join(invoicesDir, filename)
-
-	if err := pdf.OutputFileAndClose(filepath); err != nil {
+	// Written under a unique temporary name first so a half-written file is
+	// never served and two workers rendering the same invoice do not clash
+	path := filepath.Join(invoicesDir, strings.ReplaceAll(data.InvoiceNumber, "/", "-")+".pdf")
+	tmp, err := os.CreateTemp(invoicesDir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to save PDF: %w", err)
+	}
+	if err := pdf.Output(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to save PDF: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to save PDF: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
 		return "", fmt.Errorf("failed to save PDF: %w", err)
 	}
 
-	return filepath, nil
+	return path, nil
 }
 
-// GeneratePurchaseInvoicePDF generates invoice PDF for a purchase
-func GeneratePurchaseInvoicePDF(purchase models.VisaPurchase, user models.User, payment models.Payment) (string, error) {
-	invoiceNumber := fmt.Sprintf("INV-%d-%d", purchase.ID, time.Now().Unix())
-
-	items := []InvoiceItem{
-		{
-			Description: fmt.Sprintf("%s Visa - %s", purchase.Visa.Country, purchase.Visa.Type),
-			Quantity:    1,
-			Price:       purchase.Visa.Price,
-			Total:       purchase.Visa.Price,
-		},
+// GeneratePurchaseInvoicePDF renders the invoice issued for a purchase.
+// payment is nil when nothing had to be paid.
+func GeneratePurchaseInvoicePDF(invoice models.Invoice, purchase models.VisaPurchase, user models.User, payment *models.Payment) (string, error) {
+	// Visa and option lines make up the subtotal, discounts, fees and taxes follow it
+	var items, adjustments []InvoiceItem
+	for _, line := range PurchaseLineItems(purchase) {
+		item := InvoiceItem{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			Price:       line.UnitPrice,
+			Total:       line.Amount,
+		}
+		if line.Kind == models.LineItemVisa || line.Kind == models.LineItemOption {
+			items = append(items, item)
+		} else {
+			adjustments = append(adjustments, item)
+		}
 	}
 
-	if purchase.VisaOption != nil {
-		items = append(items, InvoiceItem{
-			Description: purchase.VisaOption.Name,
-			Quantity:    1,
-			Price:       purchase.VisaOption.Price,
-			Total:       purchase.VisaOption.Price,
+	applicants := make([]InvoiceApplicant, 0, len(purchase.Applicants))
+	for _, applicant := range purchase.Applicants {
+		applicants = append(applicants, InvoiceApplicant{
+			FullName:       applicant.FullName,
+			PassportNumber: applicant.PassportNumber,
+			Nationality:    applicant.Nationality,
 		})
 	}
 
+	lang := i18n.Of(user.Language)
 	data := InvoiceData{
-		InvoiceNumber: invoiceNumber,
-		Date:          time.Now(),
+		Language:         lang,
+		Title:            i18n.T(lang, "pdf.invoice"),
+		InvoiceNumber:    invoice.Number,
+		Date:             invoice.IssuedAt,
+		CustomerName:     user.Name,
+		CustomerEmail:    user.Email,
+		Applicants:       applicants,
+		TravelDate:       purchase.TravelDate,
+		Items:            items,
+		Currency:         purchase.Currency,
+		Subtotal:         purchase.Subtotal,
+		Adjustments:      adjustments,
+		Total:            purchase.TotalPrice,
+		Status:           i18n.T(lang, "pdf.status.paid"),
+		OriginalCurrency: purchase.OriginalCurrency,
+		ExchangeRate:     purchase.ExchangeRate,
+	}
+
+	if payment != nil {
+		data.PaymentMethod = payment.PaymentMethod
+	}
+
+	return GenerateInvoicePDF(data)
+}
+
+// GenerateCreditNotePDF renders the credit note issued for a refund. credited
+// is the invoice it reduces, nil for payments invoiced before invoices were recorded.
+func GenerateCreditNotePDF(note models.Invoice, credited *models.Invoice, refund models.Refund, purchase models.VisaPurchase, user models.User) (string, error) {
+	lang := i18n.Of(user.Language)
+	description := i18n.T(lang, "pdf.refund_for", purchase.ID)
+	if refund.Reason != "" {
+		description += ": " + refund.Reason
+	}
+
+	data := InvoiceData{
+		Language:      lang,
+		Title:         i18n.T(lang, "pdf.credit_note"),
+		InvoiceNumber: note.Number,
+		Date:          note.IssuedAt,
 		CustomerName:  user.Name,
 		CustomerEmail: user.Email,
-		Items:         items,
-		Subtotal:      purchase.TotalPrice,
-		Total:         purchase.TotalPrice,
-		PaymentMethod: payment.PaymentMethod,
-		Status:        payment.Status,
+		Items: []InvoiceItem{
+			{Description: description, Quantity: 1, Price: note.Amount, Total: note.Amount},
+		},
+		Currency: note.Currency,
+		Subtotal: note.Amount,
+		Total:    note.Amount,
+		Status:   i18n.T(lang, "pdf.status.refunded"),
+	}
+	if credited != nil {
+		data.Reference = i18n.T(lang, "pdf.credits_invoice", credited.Number)
 	}
 
 	return GenerateInvoicePDF(data)
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserPermissions returns the effective permissions of a user: the defaults of
+// their role with any per-user overrides applied
+func UserPermissions(user models.User) ([]models.Permission, error) {
+	var overrides []models.UserPermission
+	if err := config.DB.Where("user_id = ?", user.ID).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	return models.EffectivePermissions(user.Role, overrides), nil
+}
+
+// HasPermission reports whether the user holds perm. Permissions already
+// resolved by middleware.RequirePermission for this request are reused.
+func HasPermission(c *gin.Context, user models.User, perm models.Permission) bool {
+	var perms []models.Permission
+	if cached, ok := c.Get("permissions"); ok && c.GetUint("user_id") == user.ID {
+		perms, _ = cached.([]models.Permission)
+	} else {
+		var err error
+		if perms, err = UserPermissions(user); err != nil {
+			return false
+		}
+	}
+
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
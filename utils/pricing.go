@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PriceBreakdown builds up a purchase's price line by line in the charge
+// currency: visa and option prices, then the promo discount, then the
+// service fee and tax from the matching pricing rules.
+type PriceBreakdown struct {
+	Currency   string
+	Conversion Conversion // rate the visa and option prices were converted at
+
+	OriginalTotal    int64 // visa and option prices in the visa's own currency
+	OriginalCurrency string
+
+	Lines      []models.PurchaseLineItem
+	Subtotal   int64
+	Discount   int64
+	ServiceFee int64
+	Tax        int64
+}
+
+// PriceVisa starts the breakdown for quantity applicants of a visa and an
+// optional visa option, converting their prices into the charge currency at
+// the rate effective at the given time
+func PriceVisa(tx *gorm.DB, visa models.Visa, option *models.VisaOption, quantity int, at time.Time) (*PriceBreakdown, error) {
+	breakdown := &PriceBreakdown{
+		Currency:         ChargeCurrency(),
+		OriginalCurrency: visa.Currency,
+	}
+
+	// Unit prices are converted so every line reads quantity x unit price
+	addItem := func(kind models.LineItemKind, description string, price int64) error {
+		unit, err := ConvertAmount(tx, price, visa.Currency, breakdown.Currency, at)
+		if err != nil {
+			return err
+		}
+		breakdown.Conversion = unit
+		breakdown.OriginalTotal += price * int64(quantity)
+		breakdown.addLine(models.PurchaseLineItem{
+			Kind:        kind,
+			Description: description,
+			Quantity:    quantity,
+			UnitPrice:   unit.Amount,
+			Amount:      unit.Amount * int64(quantity),
+		})
+		breakdown.Subtotal += unit.Amount * int64(quantity)
+		return nil
+	}
+
+	if err := addItem(models.LineItemVisa, fmt.Sprintf("%s Visa - %s", visa.Country, visa.Type), visa.Price); err != nil {
+		return nil, err
+	}
+	if option != nil {
+		if err := addItem(models.LineItemOption, option.Name, option.Price); err != nil {
+			return nil, err
+		}
+	}
+
+	return breakdown, nil
+}
+
+// ApplyDiscount adds a discount line, e.g. from a promo code
+func (b *PriceBreakdown) ApplyDiscount(amount int64, description string) {
+	if amount <= 0 {
+		return
+	}
+	b.addLine(models.PurchaseLineItem{
+		Kind:        models.LineItemDiscount,
+		Description: description,
+		Quantity:    1,
+		UnitPrice:   -amount,
+		Amount:      -amount,
+	})
+	b.Discount += amount
+}
+
+// ApplyPricingRules adds the service fee and then the tax of the most specific
+// active rules matching the visa. Fixed amounts in another currency are
+// converted at the rate effective at the given time.
+func (b *PriceBreakdown) ApplyPricingRules(tx *gorm.DB, visa models.Visa, quantity int, at time.Time) error {
+	for _, ruleType := range []models.PricingRuleType{models.PricingServiceFee, models.PricingTax} {
+		rule, err := findPricingRule(tx, ruleType, visa)
+		if err != nil {
+			return err
+		}
+		if rule == nil {
+			continue
+		}
+
+		// The service fee is still zero when it is calculated, so it is only part of the tax base
+		base := b.Subtotal - b.Discount + b.ServiceFee
+		amount := int64(math.Round(float64(base) * rule.Percentage / 100))
+		if rule.FixedAmount > 0 {
+			fixed := rule.FixedAmount
+			if rule.PerApplicant {
+				fixed *= int64(quantity)
+			}
+			converted, err := ConvertAmount(tx, fixed, rule.Currency, b.Currency, at)
+			if err != nil {
+				return err
+			}
+			amount += converted.Amount
+		}
+		if amount == 0 {
+			continue
+		}
+
+		kind := models.LineItemServiceFee
+		if ruleType == models.PricingTax {
+			kind = models.LineItemTax
+			b.Tax += amount
+		} else {
+			b.ServiceFee += amount
+		}
+		ruleID := rule.ID
+		b.addLine(models.PurchaseLineItem{
+			Kind:          kind,
+			Description:   rule.Name,
+			Quantity:      1,
+			UnitPrice:     amount,
+			Amount:        amount,
+			PricingRuleID: &ruleID,
+		})
+	}
+	return nil
+}
+
+// Total is the amount to charge
+func (b *PriceBreakdown) Total() int64 {
+	return b.Subtotal - b.Discount + b.ServiceFee + b.Tax
+}
+
+// ApplyTo copies the breakdown, its line items and the rate snapshot onto a purchase
+func (b *PriceBreakdown) ApplyTo(purchase *models.VisaPurchase) {
+	purchase.Currency = b.Currency
+	purchase.OriginalTotal = b.OriginalTotal
+	purchase.OriginalCurrency = b.OriginalCurrency
+	purchase.ExchangeRate = b.Conversion.Rate
+	purchase.ExchangeRateID = b.Conversion.ExchangeRateID
+	purchase.Subtotal = b.Subtotal
+	purchase.DiscountAmount = b.Discount
+	purchase.ServiceFee = b.ServiceFee
+	purchase.TaxAmount = b.Tax
+	purchase.TotalPrice = b.Total()
+	purchase.LineItems = b.Lines
+}
+
+func (b *PriceBreakdown) addLine(line models.PurchaseLineItem) {
+	line.Position = len(b.Lines) + 1
+	line.Currency = b.Currency
+	b.Lines = append(b.Lines, line)
+}
+
+// findPricingRule returns the most specific active rule of a type for the visa, or nil if none matches
+func findPricingRule(tx *gorm.DB, ruleType models.PricingRuleType, visa models.Visa) (*models.PricingRule, error) {
+	var rule models.PricingRule
+	err := tx.Where("type = ? AND is_active = ?", ruleType, true).
+		Where("(country = '' OR LOWER(country) = LOWER(?)) AND (visa_type = '' OR LOWER(visa_type) = LOWER(?))", visa.Country, visa.Type).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "(country <> '') DESC, (visa_type <> '') DESC, id DESC",
+			WithoutParentheses: true,
+		}}).
+		First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// PurchaseLineItems returns the price breakdown of a purchase. Purchases made
+// before line items were stored get a single line for the visa plus their
+// discount. LineItems, Visa and Applicants must be preloaded.
+func PurchaseLineItems(purchase models.VisaPurchase) []models.PurchaseLineItem {
+	if len(purchase.LineItems) > 0 {
+		return purchase.LineItems
+	}
+
+	quantity := len(purchase.Applicants)
+	if quantity == 0 || purchase.Subtotal%int64(quantity) != 0 {
+		quantity = 1
+	}
+	description := fmt.Sprintf("%s Visa - %s", purchase.Visa.Country, purchase.Visa.Type)
+	if purchase.VisaOption != nil {
+		description += " (" + purchase.VisaOption.Name + ")"
+	}
+
+	breakdown := &PriceBreakdown{Currency: purchase.Currency}
+	breakdown.addLine(models.PurchaseLineItem{
+		Kind:        models.LineItemVisa,
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   purchase.Subtotal / int64(quantity),
+		Amount:      purchase.Subtotal,
+	})
+	breakdown.ApplyDiscount(purchase.DiscountAmount, PromoDiscountDescription(purchase.PromoCode))
+	return breakdown.Lines
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPromoNotFound is returned for unknown or deleted promo codes
+	ErrPromoNotFound = errors.New("promo code not found")
+	// ErrPromoNotActive is returned for disabled codes and outside the validity window
+	ErrPromoNotActive = errors.New("promo code is not active")
+	// ErrPromoExhausted is returned once a code reached its global redemption limit
+	ErrPromoExhausted = errors.New("promo code has been fully redeemed")
+	// ErrPromoUserLimit is returned once a customer used a code as often as allowed
+	ErrPromoUserLimit = errors.New("promo code already used")
+	// ErrPromoMinSpend is returned when the subtotal is below the code's minimum spend
+	ErrPromoMinSpend = errors.New("minimum spend not reached")
+	// ErrPromoNotApplicable is returned when a code does not cover the visa or currency
+	ErrPromoNotApplicable = errors.New("promo code does not apply to this purchase")
+)
+
+// NormalizePromoCode returns the stored form of a promo code
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ReservePromoCode locks the promo code, checks that it applies to a purchase
+// of visa with the given charge subtotal and returns the discount. The lock is
+// held until tx ends, so checking the limits and the RedeemPromoCode that
+// follows cannot interleave with other checkouts using the same code.
+func ReservePromoCode(tx *gorm.DB, code string, userID uint, visa models.Visa, subtotal int64, currency string) (*models.PromoCode, int64, error) {
+	var promo models.PromoCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", NormalizePromoCode(code)).
+		First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrPromoNotFound
+		}
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	if !promo.IsActive || (promo.ValidFrom != nil && now.Before(*promo.ValidFrom)) || (promo.ValidUntil != nil && !now.Before(*promo.ValidUntil)) {
+		return nil, 0, ErrPromoNotActive
+	}
+	if promo.MaxRedemptions > 0 && promo.RedemptionCount >= promo.MaxRedemptions {
+		return nil, 0, ErrPromoExhausted
+	}
+	if promo.MaxPerUser > 0 {
+		var used int64
+		if err := tx.Model(&models.PromoRedemption{}).
+			Where("promo_code_id = ? AND user_id = ?", promo.ID, userID).
+			Count(&used).Error; err != nil {
+			return nil, 0, err
+		}
+		if used >= int64(promo.MaxPerUser) {
+			return nil, 0, ErrPromoUserLimit
+		}
+	}
+
+	if !matchesAny(promo.Countries, visa.Country) || !matchesAny(promo.VisaTypes, visa.Type) {
+		return nil, 0, fmt.Errorf("%w: not valid for %s %s visas", ErrPromoNotApplicable, visa.Country, visa.Type)
+	}
+	// Percentages work in any currency unless a minimum spend ties them to one
+	if promo.Currency != currency && (promo.DiscountType == models.PromoFixed || promo.MinSpend > 0) {
+		return nil, 0, fmt.Errorf("%w: only valid for payments in %s", ErrPromoNotApplicable, promo.Currency)
+	}
+	if subtotal < promo.MinSpend {
+		return nil, 0, fmt.Errorf("%w: spend at least %s", ErrPromoMinSpend, models.FormatAmount(promo.MinSpend, promo.Currency))
+	}
+
+	return &promo, PromoDiscount(promo, subtotal), nil
+}
+
+// PromoDiscount returns the discount a code gives on subtotal, never more than the subtotal
+func PromoDiscount(promo models.PromoCode, subtotal int64) int64 {
+	discount := promo.DiscountValue
+	if promo.DiscountType == models.PromoPercentage {
+		discount = subtotal * promo.DiscountValue / 100
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+	return discount
+}
+
+// RedeemPromoCode records the redemption of a code reserved with
+// ReservePromoCode in the same transaction
+func RedeemPromoCode(tx *gorm.DB, promo *models.PromoCode, purchase models.VisaPurchase) error {
+	if err := tx.Create(&models.PromoRedemption{
+		PromoCodeID:    promo.ID,
+		UserID:         purchase.UserID,
+		PurchaseID:     purchase.ID,
+		DiscountAmount: purchase.DiscountAmount,
+		Currency:       purchase.Currency,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.PromoCode{}).
+		Where("id = ?", promo.ID).
+		Update("redemption_count", gorm.Expr("redemption_count + 1")).Error
+}
+
+// ReleasePromoRedemption gives back the promo code used on a purchase, e.g.
+// when an unpaid purchase is cancelled
+func ReleasePromoRedemption(tx *gorm.DB, purchaseID uint) error {
+	var redemption models.PromoRedemption
+	err := tx.Clauses(clause.Returning{}).
+		Where("purchase_id = ?", purchaseID).
+		Delete(&redemption).Error
+	if err != nil || redemption.ID == 0 {
+		return err
+	}
+	return tx.Model(&models.PromoCode{}).
+		Where("id = ? AND redemption_count > 0", redemption.PromoCodeID).
+		Update("redemption_count", gorm.Expr("redemption_count - 1")).Error
+}
+
+// PromoDiscountDescription is the line item text for a promo code discount
+func PromoDiscountDescription(code string) string {
+	if code == "" {
+		return "Discount"
+	}
+	return fmt.Sprintf("Discount (%s)", code)
+}
+
+func matchesAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if strings.EqualFold(strings.TrimSpace(candidate), value) {
+			return true
+		}
+	}
+	return false
+}
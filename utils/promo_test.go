@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+)
+
+func TestPromoDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		promo    models.PromoCode
+		subtotal int64
+		want     int64
+	}{
+		{"percentage", models.PromoCode{DiscountType: models.PromoPercentage, DiscountValue: 10}, 1500000, 150000},
+		{"percentage rounds down", models.PromoCode{DiscountType: models.PromoPercentage, DiscountValue: 15}, 999, 149},
+		{"full percentage", models.PromoCode{DiscountType: models.PromoPercentage, DiscountValue: 100}, 1500000, 1500000},
+		{"fixed", models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 200000}, 1500000, 200000},
+		{"fixed above subtotal", models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 2000000}, 1500000, 1500000},
+	}
+	for _, tt := range tests {
+		if got := PromoDiscount(tt.promo, tt.subtotal); got != tt.want {
+			t.Errorf("%s: PromoDiscount = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReservePromoCode(t *testing.T) {
+	const userID, otherUserID = 1, 2
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	japan := models.Visa{Country: "Japan", Type: "Tourist"}
+
+	tests := []struct {
+		name        string
+		promo       models.PromoCode
+		disabled    bool
+		redemptions []uint // users who already redeemed the code
+		code        string // as typed by the customer, defaults to the promo code
+		subtotal    int64
+
+		wantDiscount int64
+		wantErr      error
+	}{
+		{
+			name:         "valid percentage",
+			promo:        models.PromoCode{DiscountType: models.PromoPercentage, DiscountValue: 10},
+			subtotal:     1000000,
+			wantDiscount: 100000,
+		},
+		{
+			name:         "typed in lower case with spaces",
+			promo:        models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000},
+			code:         "  promo  ",
+			subtotal:     1000000,
+			wantDiscount: 50000,
+		},
+		{
+			name:     "unknown code",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000},
+			code:     "NOSUCHCODE",
+			subtotal: 1000000,
+			wantErr:  ErrPromoNotFound,
+		},
+		{
+			name:     "disabled",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000},
+			disabled: true,
+			subtotal: 1000000,
+			wantErr:  ErrPromoNotActive,
+		},
+		{
+			name:     "not valid yet",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, ValidFrom: &future},
+			subtotal: 1000000,
+			wantErr:  ErrPromoNotActive,
+		},
+		{
+			name:     "expired",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, ValidUntil: &past},
+			subtotal: 1000000,
+			wantErr:  ErrPromoNotActive,
+		},
+		{
+			name:         "within validity window",
+			promo:        models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, ValidFrom: &past, ValidUntil: &future},
+			subtotal:     1000000,
+			wantDiscount: 50000,
+		},
+		{
+			name:     "global limit reached",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, MaxRedemptions: 2, RedemptionCount: 2},
+			subtotal: 1000000,
+			wantErr:  ErrPromoExhausted,
+		},
+		{
+			name:         "global limit not reached",
+			promo:        models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, MaxRedemptions: 2, RedemptionCount: 1},
+			subtotal:     1000000,
+			wantDiscount: 50000,
+		},
+		{
+			name:        "per-user limit reached",
+			promo:       models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, MaxPerUser: 1},
+			redemptions: []uint{userID},
+			subtotal:    1000000,
+			wantErr:     ErrPromoUserLimit,
+		},
+		{
+			name:         "per-user limit only counts the customer's own redemptions",
+			promo:        models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, MaxPerUser: 1},
+			redemptions:  []uint{otherUserID, otherUserID},
+			subtotal:     1000000,
+			wantDiscount: 50000,
+		},
+		{
+			name:     "other country",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, Countries: []string{"Korea"}},
+			subtotal: 1000000,
+			wantErr:  ErrPromoNotApplicable,
+		},
+		{
+			name:     "other visa type",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, VisaTypes: []string{"Business"}},
+			subtotal: 1000000,
+			wantErr:  ErrPromoNotApplicable,
+		},
+		{
+			name:         "country matched case-insensitively",
+			promo:        models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, Countries: []string{" japan "}},
+			subtotal:     1000000,
+			wantDiscount: 50000,
+		},
+		{
+			name:     "fixed discount in another currency",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 5000, Currency: "USD"},
+			subtotal: 1000000,
+			wantErr:  ErrPromoNotApplicable,
+		},
+		{
+			name:         "percentage in another currency",
+			promo:        models.PromoCode{DiscountType: models.PromoPercentage, DiscountValue: 10, Currency: "USD"},
+			subtotal:     1000000,
+			wantDiscount: 100000,
+		},
+		{
+			name:     "minimum spend not reached",
+			promo:    models.PromoCode{DiscountType: models.PromoFixed, DiscountValue: 50000, MinSpend: 2000000},
+			subtotal: 1000000,
+			wantErr:  ErrPromoMinSpend,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			promo := tt.promo
+			promo.Code = "PROMO"
+			if promo.Currency == "" {
+				promo.Currency = "IDR"
+			}
+			promo.IsActive = !tt.disabled
+			insert(t, db, &promo)
+			for i, redeemer := range tt.redemptions {
+				insert(t, db, &models.PromoRedemption{PromoCodeID: promo.ID, UserID: redeemer, PurchaseID: uint(1000 + i), DiscountAmount: 1, Currency: "IDR"})
+			}
+			code := tt.code
+			if code == "" {
+				code = promo.Code
+			}
+
+			var discount int64
+			err := db.Transaction(func(tx *gorm.DB) (err error) {
+				_, discount, err = ReservePromoCode(tx, code, userID, japan, tt.subtotal, "IDR")
+				return err
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReservePromoCode: %v", err)
+			}
+			if discount != tt.wantDiscount {
+				t.Errorf("discount = %d, want %d", discount, tt.wantDiscount)
+			}
+		})
+	}
+}
+
+func TestPromoRedemptionLimitUnderConcurrency(t *testing.T) {
+	db := testDB(t)
+	promo := models.PromoCode{Code: "LIMITED", DiscountType: models.PromoFixed, DiscountValue: 50000, Currency: "IDR", MaxRedemptions: 3, IsActive: true}
+	insert(t, db, &promo)
+	visa := models.Visa{Country: "Japan", Type: "Tourist"}
+
+	const checkouts = 8
+	var wg sync.WaitGroup
+	errs := make([]error, checkouts)
+	for i := 0; i < checkouts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.Transaction(func(tx *gorm.DB) error {
+				reserved, discount, err := ReservePromoCode(tx, promo.Code, uint(i+1), visa, 1000000, "IDR")
+				if err != nil {
+					return err
+				}
+				purchase := models.VisaPurchase{ID: uint(i + 1), UserID: uint(i + 1), DiscountAmount: discount, Currency: "IDR"}
+				return RedeemPromoCode(tx, reserved, purchase)
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	redeemed := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			redeemed++
+		case !errors.Is(err, ErrPromoExhausted):
+			t.Errorf("checkout %d: %v", i+1, err)
+		}
+	}
+	if redeemed != 3 {
+		t.Errorf("redeemed %d times, want 3", redeemed)
+	}
+	db.First(&promo, promo.ID)
+	if promo.RedemptionCount != 3 {
+		t.Errorf("redemption count = %d, want 3", promo.RedemptionCount)
+	}
+}
+
+func TestReleasePromoRedemption(t *testing.T) {
+	db := testDB(t)
+	promo := models.PromoCode{Code: "ONCE", DiscountType: models.PromoFixed, DiscountValue: 50000, Currency: "IDR", MaxRedemptions: 1, MaxPerUser: 1, IsActive: true}
+	insert(t, db, &promo)
+	visa := models.Visa{Country: "Japan", Type: "Tourist"}
+	checkout := func(purchaseID uint) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			reserved, discount, err := ReservePromoCode(tx, promo.Code, 1, visa, 1000000, "IDR")
+			if err != nil {
+				return err
+			}
+			return RedeemPromoCode(tx, reserved, models.VisaPurchase{ID: purchaseID, UserID: 1, DiscountAmount: discount, Currency: "IDR"})
+		})
+	}
+
+	if err := checkout(1); err != nil {
+		t.Fatalf("first checkout: %v", err)
+	}
+	if err := checkout(2); !errors.Is(err, ErrPromoExhausted) {
+		t.Fatalf("second checkout: error = %v, want %v", err, ErrPromoExhausted)
+	}
+
+	// Cancelling the unpaid purchase gives the code back, also to the same customer
+	if err := ReleasePromoRedemption(db, 1); err != nil {
+		t.Fatalf("ReleasePromoRedemption: %v", err)
+	}
+	if err := checkout(2); err != nil {
+		t.Fatalf("checkout after release: %v", err)
+	}
+
+	// Releasing twice or a purchase without a code changes nothing
+	for _, purchaseID := range []uint{1, 99} {
+		if err := ReleasePromoRedemption(db, purchaseID); err != nil {
+			t.Fatalf("ReleasePromoRedemption(%d): %v", purchaseID, err)
+		}
+	}
+	db.First(&promo, promo.ID)
+	if promo.RedemptionCount != 1 {
+		t.Errorf("redemption count = %d, want 1", promo.RedemptionCount)
+	}
+}
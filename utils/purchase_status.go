@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTransition is returned when a purchase status change is not allowed
+var ErrInvalidTransition = errors.New("invalid purchase status transition")
+
+// TransitionPurchase moves a purchase to a new status, stamps the matching
+// lifecycle timestamp and records the change in the status history.
+// The caller controls the transaction by passing tx.
+func TransitionPurchase(tx *gorm.DB, purchase *models.VisaPurchase, to models.PurchaseStatus, actor models.TransitionActor, actorUserID *uint, note string) error {
+	from := purchase.Status
+	if !models.CanTransition(from, to, actor) {
+		return fmt.Errorf("%w: %s cannot move purchase from %s to %s", ErrInvalidTransition, actor, from, to)
+	}
+
+	// Review is only complete once every required document is approved
+	if from == models.PurchaseStatusDocumentsReview && to == models.PurchaseStatusSubmittedToEmbassy {
+		missing, err := MissingRequiredDocuments(tx, *purchase)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: %s", ErrDocumentsIncomplete, strings.Join(missing, ", "))
+		}
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": to}
+	switch to {
+	case models.PurchaseStatusSubmitted:
+		purchase.SubmittedAt = &now
+		updates["submitted_at"] = now
+	case models.PurchaseStatusDocumentsReview:
+		purchase.ReviewStartedAt = &now
+		updates["review_started_at"] = now
+	case models.PurchaseStatusSubmittedToEmbassy:
+		purchase.SubmittedToEmbassyAt = &now
+		updates["submitted_to_embassy_at"] = now
+	case models.PurchaseStatusApproved:
+		purchase.ApprovedAt = &now
+		updates["approved_at"] = now
+	case models.PurchaseStatusRejected:
+		purchase.RejectedAt = &now
+		updates["rejected_at"] = now
+	case models.PurchaseStatusIssued:
+		purchase.IssuedAt = &now
+		updates["issued_at"] = now
+	case models.PurchaseStatusCancelled:
+		purchase.CancelledAt = &now
+		updates["cancelled_at"] = now
+	case models.PurchaseStatusRefunded:
+		purchase.RefundedAt = &now
+		updates["refunded_at"] = now
+	}
+
+	// Guard on the current status so concurrent transitions cannot both win
+	result := tx.Model(&models.VisaPurchase{}).
+		Where("id = ? AND status = ?", purchase.ID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: purchase %d is no longer %s", ErrInvalidTransition, purchase.ID, from)
+	}
+	purchase.Status = to
+
+	// An unpaid purchase that is abandoned gives its promo code back
+	if from == models.PurchaseStatusDraft && to == models.PurchaseStatusCancelled {
+		if err := ReleasePromoRedemption(tx, purchase.ID); err != nil {
+			return err
+		}
+	}
+
+	history := models.PurchaseStatusHistory{
+		PurchaseID:  purchase.ID,
+		FromStatus:  from,
+		ToStatus:    to,
+		Actor:       actor,
+		ActorUserID: actorUserID,
+		Note:        note,
+	}
+	return tx.Create(&history).Error
+}
@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+)
+
+// Unscoped is a preload condition that includes soft-deleted records, so
+// historical purchases keep showing applicants that were deleted later
+func Unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// LineItemsInOrder is a preload condition that returns a purchase's line
+// items in invoice order
+func LineItemsInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
@@ -0,0 +1,364 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
+)
+
+// maxGatewayReconciliationPayments caps how many invoices one gateway run looks up
+const maxGatewayReconciliationPayments = 2000
+
+// ErrSettlementFormat is returned for settlement files that cannot be read
+var ErrSettlementFormat = errors.New("invalid settlement file")
+
+// SettlementRecord is the gateway's side of one payment
+type SettlementRecord struct {
+	InvoiceID  string
+	ExternalID string
+	Status     string
+	Amount     float64 // major units, as gateway reports show it
+	Currency   string  // empty when the report has no currency column
+}
+
+// settlementColumns maps our fields to the header names used in Xendit reports, in order of preference
+var settlementColumns = map[string][]string{
+	"invoice_id":  {"invoice id", "id", "transaction id"},
+	"external_id": {"external id", "reference", "reference id"},
+	"status":      {"status", "transaction status", "invoice status"},
+	"amount":      {"amount", "paid amount", "transaction amount"},
+	"currency":    {"currency"},
+}
+
+// ParseSettlementCSV reads a Xendit settlement or invoice report. Columns are
+// matched by header name; status, amount and an invoice or external ID are required.
+func ParseSettlementCSV(r io.Reader) ([]SettlementRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSettlementFormat, err)
+	}
+
+	positions := map[string]int{}
+	for i, name := range header {
+		positions[normalizeHeader(name)] = i
+	}
+	columns := map[string]int{}
+	for field, names := range settlementColumns {
+		columns[field] = -1
+		for _, name := range names {
+			if i, ok := positions[name]; ok {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	if columns["status"] < 0 || columns["amount"] < 0 || (columns["invoice_id"] < 0 && columns["external_id"] < 0) {
+		return nil, fmt.Errorf("%w: status, amount and invoice id or external id columns are required", ErrSettlementFormat)
+	}
+
+	records := []SettlementRecord{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrSettlementFormat, line, err)
+		}
+
+		record := SettlementRecord{
+			InvoiceID:  csvField(row, columns["invoice_id"]),
+			ExternalID: csvField(row, columns["external_id"]),
+			Status:     csvField(row, columns["status"]),
+			Currency:   strings.ToUpper(csvField(row, columns["currency"])),
+		}
+		if record.InvoiceID == "" && record.ExternalID == "" {
+			continue // blank or summary line
+		}
+
+		amount, err := parseSettlementAmount(csvField(row, columns["amount"]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid amount", ErrSettlementFormat, line)
+		}
+		record.Amount = amount
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// ReconcileSettlement compares settlement records with our payments and
+// completes the run with the flagged differences. Payments of the run period
+// that are settled on our side but absent from the file are flagged too.
+func ReconcileSettlement(run *models.ReconciliationRun, records []SettlementRecord) {
+	var items []models.ReconciliationItem
+	matched := 0
+	seen := map[uint]bool{}
+
+	byInvoice, byExternal, err := loadPaymentsForRecords(run.Gateway, records)
+	if err != nil {
+		finishReconciliation(run, 0, nil, err)
+		return
+	}
+
+	for _, record := range records {
+		payment, ok := byInvoice[record.InvoiceID]
+		if !ok {
+			payment, ok = byExternal[record.ExternalID]
+		}
+		if !ok {
+			currency := record.Currency
+			if currency == "" {
+				currency = ChargeCurrency()
+			}
+			amount := models.MajorToMinor(record.Amount, currency)
+			items = append(items, models.ReconciliationItem{
+				Issue:         models.IssueMissingInPayments,
+				InvoiceID:     record.InvoiceID,
+				ExternalID:    record.ExternalID,
+				Currency:      currency,
+				GatewayAmount: &amount,
+				GatewayStatus: record.Status,
+				Details:       "Gateway reports a payment we have no record of",
+			})
+			continue
+		}
+
+		seen[payment.ID] = true
+		issues := comparePayment(payment, record)
+		if len(issues) == 0 {
+			matched++
+		}
+		items = append(items, issues...)
+	}
+
+	if run.PeriodFrom != nil && run.PeriodTo != nil {
+		var settled []models.Payment
+		if err := config.DB.
+			Where("gateway = ? AND status IN ? AND created_at >= ? AND created_at < ?",
+				run.Gateway, []string{"paid", "refunded"}, *run.PeriodFrom, *run.PeriodTo).
+			Find(&settled).Error; err != nil {
+			finishReconciliation(run, 0, nil, err)
+			return
+		}
+		for _, payment := range settled {
+			if !seen[payment.ID] {
+				items = append(items, missingInGateway(payment, "Settled payment is not in the settlement file"))
+			}
+		}
+	}
+
+	run.TotalRecords = len(records)
+	finishReconciliation(run, matched, items, nil)
+}
+
+// ReconcileGateway looks up every payment of the run period at the gateway
+// and completes the run with the flagged differences
+func ReconcileGateway(ctx context.Context, run *models.ReconciliationRun) {
+	gateway, err := payments.Get(run.Gateway)
+	if err != nil {
+		finishReconciliation(run, 0, nil, err)
+		return
+	}
+
+	var list []models.Payment
+	if err := config.DB.
+		Where("gateway = ? AND created_at >= ? AND created_at < ?", run.Gateway, *run.PeriodFrom, *run.PeriodTo).
+		Order("id ASC").
+		Limit(maxGatewayReconciliationPayments + 1).
+		Find(&list).Error; err != nil {
+		finishReconciliation(run, 0, nil, err)
+		return
+	}
+	if len(list) > maxGatewayReconciliationPayments {
+		finishReconciliation(run, 0, nil, fmt.Errorf("more than %d payments in period, choose a shorter period", maxGatewayReconciliationPayments))
+		return
+	}
+
+	var items []models.ReconciliationItem
+	matched := 0
+	for _, payment := range list {
+		invoice, err := gateway.GetInvoice(ctx, payment.XenditID)
+		if errors.Is(err, payments.ErrInvoiceNotFound) {
+			items = append(items, missingInGateway(payment, "Gateway does not know this invoice"))
+			continue
+		}
+		if err != nil {
+			finishReconciliation(run, 0, nil, fmt.Errorf("looking up payment %d: %w", payment.ID, err))
+			return
+		}
+
+		issues := comparePayment(payment, SettlementRecord{
+			InvoiceID:  invoice.ID,
+			ExternalID: invoice.ExternalID,
+			Status:     invoice.Status,
+			Amount:     invoice.Amount,
+			Currency:   invoice.Currency,
+		})
+		if len(issues) == 0 {
+			matched++
+		}
+		items = append(items, issues...)
+	}
+
+	run.TotalRecords = len(list)
+	finishReconciliation(run, matched, items, nil)
+}
+
+// comparePayment flags amount and status differences between a payment and the gateway's record of it
+func comparePayment(payment models.Payment, record SettlementRecord) []models.ReconciliationItem {
+	issues := []models.ReconciliationItem{}
+	paymentID := payment.ID
+	ourAmount, gatewayAmount := payment.Amount, models.MajorToMinor(record.Amount, payment.Currency)
+
+	base := models.ReconciliationItem{
+		PaymentID:     &paymentID,
+		InvoiceID:     payment.XenditID,
+		ExternalID:    payment.ExternalID,
+		Currency:      payment.Currency,
+		OurAmount:     &ourAmount,
+		GatewayAmount: &gatewayAmount,
+		OurStatus:     payment.Status,
+		GatewayStatus: record.Status,
+	}
+
+	if record.Currency != "" && !strings.EqualFold(record.Currency, payment.Currency) {
+		item := base
+		item.Issue = models.IssueAmountMismatch
+		item.Details = fmt.Sprintf("Gateway charged in %s, payment is in %s", strings.ToUpper(record.Currency), payment.Currency)
+		issues = append(issues, item)
+	} else if ourAmount != gatewayAmount {
+		item := base
+		item.Issue = models.IssueAmountMismatch
+		item.Details = "Amount differs by " + models.FormatAmount(gatewayAmount-ourAmount, payment.Currency)
+		issues = append(issues, item)
+	}
+
+	gatewayStatus := NormalizePaymentStatus(record.Status)
+	// Refunds are separate gateway transactions; the invoice itself stays paid
+	sameStatus := gatewayStatus == payment.Status || (payment.Status == "refunded" && gatewayStatus == "paid")
+	if !sameStatus {
+		item := base
+		item.Issue = models.IssueStatusMismatch
+		item.Details = fmt.Sprintf("We have %s, gateway has %s", payment.Status, gatewayStatus)
+		issues = append(issues, item)
+	}
+
+	return issues
+}
+
+func missingInGateway(payment models.Payment, details string) models.ReconciliationItem {
+	paymentID := payment.ID
+	amount := payment.Amount
+	return models.ReconciliationItem{
+		Issue:      models.IssueMissingInGateway,
+		PaymentID:  &paymentID,
+		InvoiceID:  payment.XenditID,
+		ExternalID: payment.ExternalID,
+		Currency:   payment.Currency,
+		OurAmount:  &amount,
+		OurStatus:  payment.Status,
+		Details:    details,
+	}
+}
+
+// loadPaymentsForRecords fetches the payments referenced by settlement records, indexed by invoice and external ID
+func loadPaymentsForRecords(gateway string, records []SettlementRecord) (map[string]models.Payment, map[string]models.Payment, error) {
+	byInvoice := map[string]models.Payment{}
+	byExternal := map[string]models.Payment{}
+
+	const chunk = 500
+	for start := 0; start < len(records); start += chunk {
+		end := start + chunk
+		if end > len(records) {
+			end = len(records)
+		}
+
+		invoiceIDs, externalIDs := []string{}, []string{}
+		for _, record := range records[start:end] {
+			if record.InvoiceID != "" {
+				invoiceIDs = append(invoiceIDs, record.InvoiceID)
+			}
+			if record.ExternalID != "" {
+				externalIDs = append(externalIDs, record.ExternalID)
+			}
+		}
+
+		var found []models.Payment
+		if err := config.DB.
+			Where("gateway = ? AND (xendit_id IN ? OR external_id IN ?)", gateway, append(invoiceIDs, ""), append(externalIDs, "")).
+			Find(&found).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, payment := range found {
+			if payment.XenditID != "" {
+				byInvoice[payment.XenditID] = payment
+			}
+			if payment.ExternalID != "" {
+				byExternal[payment.ExternalID] = payment
+			}
+		}
+	}
+
+	return byInvoice, byExternal, nil
+}
+
+// finishReconciliation stores the flagged items and marks the run completed, or failed when err is set
+func finishReconciliation(run *models.ReconciliationRun, matched int, items []models.ReconciliationItem, err error) {
+	now := time.Now()
+	updates := map[string]interface{}{"completed_at": now}
+
+	if err == nil && len(items) > 0 {
+		for i := range items {
+			items[i].RunID = run.ID
+		}
+		err = config.DB.CreateInBatches(&items, 200).Error
+	}
+
+	if err != nil {
+		log.Printf("Reconciliation run %d failed: %v", run.ID, err)
+		updates["status"] = models.ReconciliationFailed
+		updates["error"] = err.Error()
+	} else {
+		updates["status"] = models.ReconciliationCompleted
+		updates["total_records"] = run.TotalRecords
+		updates["matched_count"] = matched
+		updates["issue_count"] = len(items)
+	}
+
+	if err := config.DB.Model(&models.ReconciliationRun{}).Where("id = ?", run.ID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update reconciliation run %d: %v", run.ID, err)
+	}
+}
+
+func normalizeHeader(name string) string {
+	name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	return strings.Join(strings.Fields(strings.ReplaceAll(name, "_", " ")), " ")
+}
+
+func csvField(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// parseSettlementAmount accepts amounts like "500000", "500,000.00" or "IDR 500000"
+func parseSettlementAmount(value string) (float64, error) {
+	value = strings.TrimLeft(strings.ToUpper(value), "ABCDEFGHIJKLMNOPQRSTUVWXYZ ")
+	value = strings.ReplaceAll(value, ",", "")
+	return strconv.ParseFloat(strings.TrimSpace(value), 64)
+}
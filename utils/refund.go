@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPaymentNotRefundable is returned when refunding a payment that is not paid
+	ErrPaymentNotRefundable = errors.New("payment is not refundable")
+	// ErrRefundAmountExceeded is returned when a refund is larger than what is left to refund
+	ErrRefundAmountExceeded = errors.New("refund amount exceeds refundable amount")
+)
+
+// RefundChange describes what ApplyRefundStatus changed
+type RefundChange struct {
+	Refund            *models.Refund
+	OldStatus         models.RefundStatus
+	Payment           *models.Payment // set when the refund succeeded
+	OldPaymentStatus  string
+	Purchase          *models.VisaPurchase // set when the purchase moved to refunded
+	OldPurchaseStatus models.PurchaseStatus
+}
+
+// Changed reports whether the refund status was updated
+func (c RefundChange) Changed() bool {
+	return c.Refund != nil && c.OldStatus != c.Refund.Status
+}
+
+// Succeeded reports whether this change completed the refund
+func (c RefundChange) Succeeded() bool {
+	return c.Changed() && c.Refund.Status == models.RefundStatusSucceeded
+}
+
+// RefundableAmount returns how much of a payment can still be refunded,
+// counting refunds that are still pending at the gateway
+func RefundableAmount(tx *gorm.DB, payment models.Payment) (int64, error) {
+	var pending int64
+	if err := tx.Model(&models.Refund{}).
+		Where("payment_id = ? AND status = ?", payment.ID, models.RefundStatusPending).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&pending).Error; err != nil {
+		return 0, err
+	}
+	return payment.Amount - payment.RefundedAmount - pending, nil
+}
+
+// ReserveRefund locks the payment and creates a pending refund for it. An
+// amount of zero refunds whatever is left. The caller sends the refund to the
+// gateway afterwards and applies the result with ApplyRefundStatus.
+func ReserveRefund(tx *gorm.DB, paymentID uint, amount int64, reason string, requestedByID *uint) (*models.Refund, error) {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error; err != nil {
+		return nil, err
+	}
+	if payment.Status != "paid" {
+		return nil, fmt.Errorf("%w: payment is %s", ErrPaymentNotRefundable, payment.Status)
+	}
+
+	refundable, err := RefundableAmount(tx, payment)
+	if err != nil {
+		return nil, err
+	}
+	if amount == 0 {
+		amount = refundable
+	}
+	if amount <= 0 || amount > refundable {
+		return nil, fmt.Errorf("%w: %s left to refund", ErrRefundAmountExceeded, models.FormatAmount(refundable, payment.Currency))
+	}
+
+	refund := models.Refund{
+		PaymentID:     payment.ID,
+		PurchaseID:    payment.PurchaseID,
+		Amount:        amount,
+		Reason:        reason,
+		Status:        models.RefundStatusPending,
+		Gateway:       payment.Gateway,
+		RequestedByID: requestedByID,
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// NormalizeRefundStatus maps a gateway refund status onto refund statuses
+func NormalizeRefundStatus(gatewayStatus string) models.RefundStatus {
+	switch strings.ToLower(gatewayStatus) {
+	case "succeeded", "completed":
+		return models.RefundStatusSucceeded
+	case "failed", "cancelled":
+		return models.RefundStatusFailed
+	}
+	return models.RefundStatusPending
+}
+
+// ApplyRefundStatus settles a pending refund with the status reported by the
+// gateway. A successful refund adds to the payment's refunded amount; once the
+// payment is fully refunded the payment becomes refunded and the purchase
+// moves to refunded. Settled refunds are never changed again, so the same
+// report can be applied any number of times.
+func ApplyRefundStatus(tx *gorm.DB, refund *models.Refund, gatewayStatus, failureReason string, actorUserID *uint) (RefundChange, error) {
+	change := RefundChange{Refund: refund, OldStatus: refund.Status}
+
+	status := NormalizeRefundStatus(gatewayStatus)
+	if status == models.RefundStatusPending || refund.Status != models.RefundStatusPending {
+		return change, nil
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status, "completed_at": now}
+	if status == models.RefundStatusFailed {
+		updates["failure_reason"] = failureReason
+	}
+
+	// Guard on the current status so concurrent reports cannot both apply
+	result := tx.Model(&models.Refund{}).
+		Where("id = ? AND status = ?", refund.ID, models.RefundStatusPending).
+		Updates(updates)
+	if result.Error != nil {
+		return change, result.Error
+	}
+	if result.RowsAffected == 0 {
+		err := tx.First(refund, refund.ID).Error
+		change.OldStatus = refund.Status
+		return change, err
+	}
+	refund.Status = status
+	refund.CompletedAt = &now
+	if status == models.RefundStatusFailed {
+		refund.FailureReason = failureReason
+		return change, nil
+	}
+
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.PaymentID).Error; err != nil {
+		return change, err
+	}
+	change.Payment = &payment
+	change.OldPaymentStatus = payment.Status
+	// A payment the purchase never took does not refund the purchase itself
+	unaccepted := payment.RefundRequired
+
+	paymentUpdates := map[string]interface{}{"refunded_amount": payment.RefundedAmount + refund.Amount}
+	fullyRefunded := payment.RefundedAmount+refund.Amount >= payment.Amount
+	if fullyRefunded {
+		paymentUpdates["status"] = "refunded"
+		paymentUpdates["refund_required"] = false
+	}
+	if err := tx.Model(&models.Payment{}).Where("id = ?", payment.ID).Updates(paymentUpdates).Error; err != nil {
+		return change, err
+	}
+	payment.RefundedAmount += refund.Amount
+	if fullyRefunded {
+		payment.Status = "refunded"
+		payment.RefundRequired = false
+	}
+	if _, err := IssueCreditNote(tx, *refund); err != nil {
+		return change, err
+	}
+	if err := enqueueRefundEmail(tx, refund, &payment); err != nil {
+		return change, err
+	}
+	if !fullyRefunded || unaccepted {
+		return change, nil
+	}
+
+	var purchase models.VisaPurchase
+	if err := tx.First(&purchase, payment.PurchaseID).Error; err != nil {
+		return change, err
+	}
+	oldPurchaseStatus := purchase.Status
+	note := fmt.Sprintf("Payment fully refunded (refund #%d)", refund.ID)
+	if err := TransitionPurchase(tx, &purchase, models.PurchaseStatusRefunded, models.ActorWebhook, actorUserID, note); err != nil {
+		if !errors.Is(err, ErrInvalidTransition) {
+			return change, err
+		}
+		log.Printf("Purchase %d not marked refunded after refund %d: %v", purchase.ID, refund.ID, err)
+		return change, nil
+	}
+	change.Purchase = &purchase
+	change.OldPurchaseStatus = oldPurchaseStatus
+
+	return change, nil
+}
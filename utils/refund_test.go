@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+)
+
+func TestNormalizeRefundStatus(t *testing.T) {
+	tests := []struct {
+		gatewayStatus string
+		want          models.RefundStatus
+	}{
+		{"SUCCEEDED", models.RefundStatusSucceeded},
+		{"completed", models.RefundStatusSucceeded},
+		{"FAILED", models.RefundStatusFailed},
+		{"cancelled", models.RefundStatusFailed},
+		{"PENDING", models.RefundStatusPending},
+		{"", models.RefundStatusPending},
+		{"unknown", models.RefundStatusPending},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRefundStatus(tt.gatewayStatus); got != tt.want {
+			t.Errorf("NormalizeRefundStatus(%q) = %s, want %s", tt.gatewayStatus, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRefundStatus(t *testing.T) {
+	const total = 100000
+
+	tests := []struct {
+		name           string
+		purchaseStatus models.PurchaseStatus
+		refundRequired bool
+		amounts        []int64 // refunds made one after the other
+		gatewayStatus  string
+
+		wantRefundStatus   models.RefundStatus
+		wantPaymentStatus  string
+		wantRefunded       int64
+		wantPurchaseStatus models.PurchaseStatus
+		wantCreditNotes    int64
+	}{
+		{
+			name:               "full refund refunds payment and purchase",
+			purchaseStatus:     models.PurchaseStatusSubmitted,
+			amounts:            []int64{total},
+			gatewayStatus:      "SUCCEEDED",
+			wantRefundStatus:   models.RefundStatusSucceeded,
+			wantPaymentStatus:  "refunded",
+			wantRefunded:       total,
+			wantPurchaseStatus: models.PurchaseStatusRefunded,
+			wantCreditNotes:    1,
+		},
+		{
+			name:               "partial refund keeps payment paid",
+			purchaseStatus:     models.PurchaseStatusDocumentsReview,
+			amounts:            []int64{40000},
+			gatewayStatus:      "SUCCEEDED",
+			wantRefundStatus:   models.RefundStatusSucceeded,
+			wantPaymentStatus:  "paid",
+			wantRefunded:       40000,
+			wantPurchaseStatus: models.PurchaseStatusDocumentsReview,
+			wantCreditNotes:    1,
+		},
+		{
+			name:               "partial refunds adding up to the total refund the purchase",
+			purchaseStatus:     models.PurchaseStatusRejected,
+			amounts:            []int64{40000, 60000},
+			gatewayStatus:      "SUCCEEDED",
+			wantRefundStatus:   models.RefundStatusSucceeded,
+			wantPaymentStatus:  "refunded",
+			wantRefunded:       total,
+			wantPurchaseStatus: models.PurchaseStatusRefunded,
+			wantCreditNotes:    2,
+		},
+		{
+			name:               "failed refund leaves payment untouched",
+			purchaseStatus:     models.PurchaseStatusSubmitted,
+			amounts:            []int64{total},
+			gatewayStatus:      "FAILED",
+			wantRefundStatus:   models.RefundStatusFailed,
+			wantPaymentStatus:  "paid",
+			wantRefunded:       0,
+			wantPurchaseStatus: models.PurchaseStatusSubmitted,
+			wantCreditNotes:    0,
+		},
+		{
+			name:               "pending report changes nothing",
+			purchaseStatus:     models.PurchaseStatusSubmitted,
+			amounts:            []int64{total},
+			gatewayStatus:      "PENDING",
+			wantRefundStatus:   models.RefundStatusPending,
+			wantPaymentStatus:  "paid",
+			wantRefunded:       0,
+			wantPurchaseStatus: models.PurchaseStatusSubmitted,
+			wantCreditNotes:    0,
+		},
+		{
+			name:               "payment the purchase never took does not refund the purchase",
+			purchaseStatus:     models.PurchaseStatusCancelled,
+			refundRequired:     true,
+			amounts:            []int64{total},
+			gatewayStatus:      "SUCCEEDED",
+			wantRefundStatus:   models.RefundStatusSucceeded,
+			wantPaymentStatus:  "refunded",
+			wantRefunded:       total,
+			wantPurchaseStatus: models.PurchaseStatusCancelled,
+			wantCreditNotes:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			user := models.User{Email: "customer@example.com", Password: "x", Name: "Customer"}
+			visa := models.Visa{Country: "Japan", Type: "Tourist", Price: total, Currency: "IDR", Duration: 30}
+			insert(t, db, &user, &visa)
+			purchase := models.VisaPurchase{UserID: user.ID, VisaID: visa.ID, TotalPrice: total, Subtotal: total, Currency: "IDR", Status: tt.purchaseStatus}
+			insert(t, db, &purchase)
+			payment := models.Payment{UserID: user.ID, PurchaseID: purchase.ID, PaymentMethod: "virtual_account", Amount: total, Currency: "IDR",
+				Status: "paid", RefundRequired: tt.refundRequired, Gateway: "fake", XenditID: "inv-1"}
+			insert(t, db, &payment)
+
+			var refund *models.Refund
+			for _, amount := range tt.amounts {
+				err := db.Transaction(func(tx *gorm.DB) error {
+					var err error
+					if refund, err = ReserveRefund(tx, payment.ID, amount, "test", nil); err != nil {
+						return err
+					}
+					_, err = ApplyRefundStatus(tx, refund, tt.gatewayStatus, "declined by bank", nil)
+					return err
+				})
+				if err != nil {
+					t.Fatalf("refunding %d: %v", amount, err)
+				}
+			}
+
+			if refund.Status != tt.wantRefundStatus {
+				t.Errorf("refund status = %s, want %s", refund.Status, tt.wantRefundStatus)
+			}
+			db.First(&payment, payment.ID)
+			if payment.Status != tt.wantPaymentStatus {
+				t.Errorf("payment status = %s, want %s", payment.Status, tt.wantPaymentStatus)
+			}
+			if payment.RefundedAmount != tt.wantRefunded {
+				t.Errorf("refunded amount = %d, want %d", payment.RefundedAmount, tt.wantRefunded)
+			}
+			if payment.RefundRequired && payment.Status == "refunded" {
+				t.Errorf("refunded payment is still flagged for refund")
+			}
+			db.First(&purchase, purchase.ID)
+			if purchase.Status != tt.wantPurchaseStatus {
+				t.Errorf("purchase status = %s, want %s", purchase.Status, tt.wantPurchaseStatus)
+			}
+			var creditNotes int64
+			db.Model(&models.Invoice{}).Where("type = ?", models.InvoiceTypeCreditNote).Count(&creditNotes)
+			if creditNotes != tt.wantCreditNotes {
+				t.Errorf("credit notes = %d, want %d", creditNotes, tt.wantCreditNotes)
+			}
+		})
+	}
+}
+
+func TestApplyRefundStatusSettlesOnce(t *testing.T) {
+	db := testDB(t)
+	user := models.User{Email: "customer@example.com", Password: "x", Name: "Customer"}
+	visa := models.Visa{Country: "Japan", Type: "Tourist", Price: 100000, Currency: "IDR", Duration: 30}
+	insert(t, db, &user, &visa)
+	purchase := models.VisaPurchase{UserID: user.ID, VisaID: visa.ID, TotalPrice: 100000, Subtotal: 100000, Currency: "IDR", Status: models.PurchaseStatusSubmitted}
+	insert(t, db, &purchase)
+	payment := models.Payment{UserID: user.ID, PurchaseID: purchase.ID, PaymentMethod: "virtual_account", Amount: 100000, Currency: "IDR",
+		Status: "paid", Gateway: "fake", XenditID: "inv-1"}
+	insert(t, db, &payment)
+
+	var refund *models.Refund
+	err := db.Transaction(func(tx *gorm.DB) (err error) {
+		refund, err = ReserveRefund(tx, payment.ID, 30000, "test", nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("reserving refund: %v", err)
+	}
+
+	// The refund response and the webhook both report the outcome, possibly more than once
+	for i, status := range []string{"SUCCEEDED", "SUCCEEDED", "FAILED"} {
+		stale := *refund
+		stale.Status = models.RefundStatusPending
+		var change RefundChange
+		err := db.Transaction(func(tx *gorm.DB) (err error) {
+			change, err = ApplyRefundStatus(tx, &stale, status, "", nil)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("report %d: %v", i+1, err)
+		}
+		if got, want := change.Succeeded(), i == 0; got != want {
+			t.Errorf("report %d: Succeeded() = %v, want %v", i+1, got, want)
+		}
+	}
+
+	db.First(&payment, payment.ID)
+	if payment.RefundedAmount != 30000 {
+		t.Errorf("refunded amount = %d, want 30000", payment.RefundedAmount)
+	}
+	db.First(refund, refund.ID)
+	if refund.Status != models.RefundStatusSucceeded {
+		t.Errorf("refund status = %s, want succeeded", refund.Status)
+	}
+}
+
+func TestReserveRefund(t *testing.T) {
+	db := testDB(t)
+	user := models.User{Email: "customer@example.com", Password: "x", Name: "Customer"}
+	visa := models.Visa{Country: "Japan", Type: "Tourist", Price: 100000, Currency: "IDR", Duration: 30}
+	insert(t, db, &user, &visa)
+	purchase := models.VisaPurchase{UserID: user.ID, VisaID: visa.ID, TotalPrice: 100000, Subtotal: 100000, Currency: "IDR", Status: models.PurchaseStatusSubmitted}
+	insert(t, db, &purchase)
+	paid := models.Payment{UserID: user.ID, PurchaseID: purchase.ID, PaymentMethod: "virtual_account", Amount: 100000, Currency: "IDR",
+		Status: "paid", Gateway: "fake", XenditID: "inv-1"}
+	pending := models.Payment{UserID: user.ID, PurchaseID: purchase.ID, PaymentMethod: "virtual_account", Amount: 100000, Currency: "IDR",
+		Status: "pending", Gateway: "fake", XenditID: "inv-2"}
+	insert(t, db, &paid, &pending)
+
+	// Steps run in order against the same payments; pending refunds count against what is left
+	steps := []struct {
+		name       string
+		paymentID  uint
+		amount     int64
+		wantAmount int64
+		wantErr    error
+	}{
+		{"unpaid payment", pending.ID, 1000, 0, ErrPaymentNotRefundable},
+		{"partial", paid.ID, 60000, 60000, nil},
+		{"more than what is left", paid.ID, 50000, 0, ErrRefundAmountExceeded},
+		{"negative", paid.ID, -1, 0, ErrRefundAmountExceeded},
+		{"rest", paid.ID, 0, 40000, nil},
+		{"nothing left", paid.ID, 0, 0, ErrRefundAmountExceeded},
+	}
+	for _, step := range steps {
+		var refund *models.Refund
+		err := db.Transaction(func(tx *gorm.DB) (err error) {
+			refund, err = ReserveRefund(tx, step.paymentID, step.amount, "test", nil)
+			return err
+		})
+		if step.wantErr != nil {
+			if !errors.Is(err, step.wantErr) {
+				t.Errorf("%s: error = %v, want %v", step.name, err, step.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if refund.Amount != step.wantAmount || refund.Status != models.RefundStatusPending {
+			t.Errorf("%s: refund = %d %s, want %d pending", step.name, refund.Amount, refund.Status, step.wantAmount)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again.
+	// The whole token family is revoked when this happens.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// TokenPair is the access/refresh token pair handed to clients on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+// RefreshTokenTTL returns how long refresh tokens stay valid (JWT_REFRESH_TTL, default 720h)
+func RefreshTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("JWT_REFRESH_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultRefreshTokenTTL
+}
+
+// IssueSession starts a new login session for the user and returns its first token pair
+func IssueSession(c *gin.Context, userID uint) (*TokenPair, error) {
+	familyID, err := GenerateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, _, err := createRefreshToken(config.DB, c, userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTokenPair(userID, familyID, raw)
+}
+
+// RotateRefreshToken exchanges a refresh token for a new token pair in the same
+// session. Presenting a token that was already rotated revokes the whole session.
+func RotateRefreshToken(c *gin.Context, rawToken string) (*TokenPair, uint, error) {
+	var pair *TokenPair
+	var userID uint
+	var reusedFamily string
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(rawToken)).
+			First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			if current.ReplacedByID == nil {
+				return ErrRefreshTokenInvalid
+			}
+			// A rotated token came back: either the client or an attacker holds a stale copy
+			if err := revokeFamily(tx, current.FamilyID); err != nil {
+				return err
+			}
+			reusedFamily = current.FamilyID
+			userID = current.UserID
+			return nil
+		}
+
+		if time.Now().After(current.ExpiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+
+		var user models.User
+		if err := tx.Where("id = ? AND is_active = ?", current.UserID, true).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		raw, next, err := createRefreshToken(tx, c, current.UserID, current.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": next.ID,
+		}).Error; err != nil {
+			return err
+		}
+
+		pair, err = newTokenPair(current.UserID, current.FamilyID, raw)
+		userID = current.UserID
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if reusedFamily != "" {
+		denySession(c.Request.Context(), reusedFamily)
+		log.Printf("Refresh token reuse detected for user %d, session %s revoked", userID, reusedFamily)
+		return nil, userID, ErrRefreshTokenReused
+	}
+
+	return pair, userID, nil
+}
+
+// RevokeSession ends a single login session, invalidating its refresh tokens
+// and any access tokens already issued for it
+func RevokeSession(ctx context.Context, sessionID string) error {
+	if err := revokeFamily(config.DB, sessionID); err != nil {
+		return err
+	}
+	denySession(ctx, sessionID)
+	return nil
+}
+
+// RevokeAllSessions ends every login session of a user
+func RevokeAllSessions(ctx context.Context, userID uint) error {
+	if err := config.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	// Access tokens issued up to now are rejected until they would have expired anyway
+	if redisAvailable() {
+		key := fmt.Sprintf("auth:revoked:user:%d", userID)
+		if err := config.RedisClient.Set(ctx, key, time.Now().UnixMilli(), AccessTokenTTL()).Err(); err != nil {
+			log.Printf("Failed to add user %d to session denylist: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// IsSessionRevoked reports whether an access token with the given session and
+// issue time (Unix milliseconds) must be rejected. Redis holds the denylist;
+// without Redis the session's refresh tokens in Postgres are checked instead.
+func IsSessionRevoked(ctx context.Context, userID uint, sessionID string, issuedAtMs int64) bool {
+	if redisAvailable() {
+		revoked, err := config.RedisClient.Exists(ctx, "auth:revoked:session:"+sessionID).Result()
+		if err == nil {
+			if revoked > 0 {
+				return true
+			}
+
+			revokedBefore, err := config.RedisClient.Get(ctx, fmt.Sprintf("auth:revoked:user:%d", userID)).Result()
+			if err == nil {
+				if ts, err := strconv.ParseInt(revokedBefore, 10, 64); err == nil {
+					// Entries written before millisecond timestamps hold Unix seconds
+					if ts < 1e12 {
+						ts = ts*1000 + 999
+					}
+					if issuedAtMs <= ts {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		log.Printf("Session denylist lookup failed, falling back to database: %v", err)
+	}
+
+	var active int64
+	config.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Count(&active)
+	return active == 0
+}
+
+func createRefreshToken(tx *gorm.DB, c *gin.Context, userID uint, familyID string) (string, *models.RefreshToken, error) {
+	raw, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	userAgent := c.GetHeader("User-Agent")
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	token := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+		UserAgent: userAgent,
+		IPAddress: c.ClientIP(),
+	}
+	if err := tx.Create(&token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, &token, nil
+}
+
+func newTokenPair(userID uint, familyID, refreshToken string) (*TokenPair, error) {
+	accessToken, err := GenerateJWT(userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+func revokeFamily(tx *gorm.DB, familyID string) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// denySession keeps a revoked session on the denylist for as long as its
+// access tokens could still be valid
+func denySession(ctx context.Context, sessionID string) {
+	if !redisAvailable() {
+		return
+	}
+	if err := config.RedisClient.Set(ctx, "auth:revoked:session:"+sessionID, 1, AccessTokenTTL()).Err(); err != nil {
+		log.Printf("Failed to add session %s to denylist: %v", sessionID, err)
+	}
+}
+
+func redisAvailable() bool {
+	return config.CacheEnabled && config.RedisClient != nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Background runs everything besides the HTTP API: the queue consumers, the
+// outbox relay and the scheduled payment expiry and reconciliation jobs
+type Background struct {
+	runner  *Runner
+	health  *http.Server
+	started atomic.Bool
+
+	// The outbox relay and scheduled jobs run in loops that end once stop is
+	// closed; abort cancels the pass they are in when shutdown runs out of time
+	stop      chan struct{}
+	abort     context.CancelFunc
+	scheduled sync.WaitGroup
+}
+
+// NewBackground returns background work that has not been started yet
+func NewBackground() *Background {
+	return &Background{}
+}
+
+// Start starts the queue consumers, the outbox relay and the scheduled jobs
+func (b *Background) Start() {
+	// Consumers wait for RabbitMQ and resume after it reconnects
+	b.runner = StartWorkers()
+
+	var ctx context.Context
+	ctx, b.abort = context.WithCancel(context.Background())
+	b.stop = make(chan struct{})
+
+	// Payment expiry and reconciliation need no RabbitMQ; only one instance runs each at a time
+	b.schedule(func() { StartPaymentExpiryJob(ctx, b.stop) })
+	b.schedule(func() { StartReconciliationJob(ctx, b.stop) })
+
+	// Queue jobs are written to the outbox and published from here, retrying while RabbitMQ is down
+	b.schedule(func() { StartOutboxRelay(b.stop) })
+
+	b.started.Store(true)
+}
+
+// schedule runs loop in a goroutine Shutdown waits for
+func (b *Background) schedule(loop func()) {
+	b.scheduled.Add(1)
+	go func() {
+		defer b.scheduled.Done()
+		loop()
+	}()
+}
+
+// ServeHealth serves GET /health (the process is up) and GET /ready (the
+// database and RabbitMQ are reachable and every queue is consumed) on addr
+func (b *Background) ServeHealth(addr string) {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, models.SuccessResponse("Worker is running", gin.H{"status": "ok"}))
+	})
+	r.GET("/ready", func(c *gin.Context) {
+		if err := b.Ready(c.Request.Context()); err != nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse(
+				"Worker is not ready",
+				"NOT_READY",
+				err.Error(),
+			))
+			return
+		}
+		c.JSON(http.StatusOK, models.SuccessResponse("Worker is ready", gin.H{"status": "ready"}))
+	})
+
+	b.health = &http.Server{
+		Addr:         addr,
+		Handler:      r,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+	go func() {
+		log.Printf("[WORKERS] Health endpoints listening on %s", addr)
+		if err := b.health.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[WORKERS] Health server failed: %v", err)
+		}
+	}()
+}
+
+// Ready reports why the background work cannot process jobs, or nil
+func (b *Background) Ready(ctx context.Context) error {
+	if !b.started.Load() {
+		return errors.New("workers are starting")
+	}
+
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(pingCtx); err != nil {
+		return errors.New("database unreachable: " + err.Error())
+	}
+
+	if !config.RabbitMQConnected() {
+		return config.ErrRabbitMQUnavailable
+	}
+	return b.runner.Ready()
+}
+
+// Shutdown stops the health endpoints, the consumers, the outbox relay and
+// the scheduled jobs, and waits for running jobs and passes to finish, at most
+// until ctx ends. Database and RabbitMQ connections can be closed afterwards.
+func (b *Background) Shutdown(ctx context.Context) error {
+	if b.health != nil {
+		if err := b.health.Shutdown(ctx); err != nil {
+			log.Printf("[WORKERS] Failed to stop health server: %v", err)
+		}
+	}
+	if !b.started.Load() {
+		return nil
+	}
+
+	defer b.abort()
+	close(b.stop)
+	err := b.runner.Shutdown(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		b.scheduled.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Println("[WORKERS] Scheduled jobs stopped")
+	case <-ctx.Done():
+		b.abort()
+		<-done
+		err = ctx.Err()
+	}
+	return err
+}
@@ -1,14 +1,19 @@
 package workers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
 	"viskatera-api-go/config"
+	"viskatera-api-go/i18n"
+	"viskatera-api-go/mailer"
 	"viskatera-api-go/models"
 	"viskatera-api-go/utils"
 
-	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
 )
 
 // EmailInvoiceJob represents job data for sending invoice email
@@ -19,280 +24,234 @@ type EmailInvoiceJob struct {
 	Type       string `json:"type"` // "invoice" or "payment_success"
 }
 
-// StartEmailWorker starts the email worker with parallel processing
-func StartEmailWorker(concurrency int) error {
-	if concurrency < 1 {
-		concurrency = 10 // Default to 10 parallel workers
-	}
+// InviteEmailJob represents job data for sending a staff invitation email
+type InviteEmailJob struct {
+	InviteID uint   `json:"invite_id"`
+	Email    string `json:"email"`
+}
+
+// RefundEmailJob represents job data for sending a refund confirmation email
+type RefundEmailJob struct {
+	RefundID uint   `json:"refund_id"`
+	UserID   uint   `json:"user_id"`
+	Email    string `json:"email"`
+}
 
-	log.Printf("[EMAIL-WORKER] Starting with %d parallel workers", concurrency)
-
-	// Start workers for invoice email queue
-	go func() {
-		ch, err := config.RabbitMQConn.Channel()
-		if err != nil {
-			log.Printf("[EMAIL-WORKER] Failed to create channel for invoice queue: %v", err)
-			return
-		}
-		defer ch.Close()
-
-		// Set QoS to allow parallel processing
-		err = ch.Qos(
-			concurrency, // prefetch count - number of unacknowledged messages per worker
-			0,           // prefetch size
-			false,       // global
-		)
-		if err != nil {
-			log.Printf("[EMAIL-WORKER] Failed to set QoS: %v", err)
-			return
-		}
-
-		// Consume from email_invoice queue
-		msgs, err := ch.Consume(
-			config.QueueEmailInvoice,
-			"",    // consumer tag
-			false, // auto-ack
-			false, // exclusive
-			false, // no-local
-			false, // no-wait
-			nil,   // args
-		)
-		if err != nil {
-			log.Printf("[EMAIL-WORKER] Failed to consume from invoice queue: %v", err)
-			return
-		}
-
-		// Process messages from invoice queue in parallel
-		for i := 0; i < concurrency; i++ {
-			go func(workerID int) {
-				for msg := range msgs {
-					processInvoiceEmail(msg, workerID)
-				}
-			}(i)
-		}
-	}()
-
-	// Start workers for payment success email queue
-	go func() {
-		ch, err := config.RabbitMQConn.Channel()
-		if err != nil {
-			log.Printf("[EMAIL-WORKER] Failed to create channel for payment success queue: %v", err)
-			return
-		}
-		defer ch.Close()
-
-		// Set QoS to allow parallel processing
-		err = ch.Qos(
-			concurrency, // prefetch count
-			0,           // prefetch size
-			false,       // global
-		)
-		if err != nil {
-			log.Printf("[EMAIL-WORKER] Failed to set QoS: %v", err)
-			return
-		}
-
-		// Consume from email_payment_success queue
-		msgsSuccess, err := ch.Consume(
-			config.QueueEmailPaymentSuccess,
-			"",    // consumer tag
-			false, // auto-ack
-			false, // exclusive
-			false, // no-local
-			false, // no-wait
-			nil,   // args
-		)
-		if err != nil {
-			log.Printf("[EMAIL-WORKER] Failed to consume from payment success queue: %v", err)
-			return
-		}
-
-		// Process messages from payment success queue in parallel
-		for i := 0; i < concurrency; i++ {
-			go func(workerID int) {
-				for msg := range msgsSuccess {
-					processPaymentSuccessEmail(msg, workerID)
-				}
-			}(i)
-		}
-	}()
-
-	log.Printf("[EMAIL-WORKER] All workers started successfully")
-
-	// Keep worker running
-	select {}
+// registerEmailJobs registers the email jobs, each handling concurrency emails at a time
+func registerEmailJobs(r *Runner, concurrency int) {
+	opts := JobOptions{Concurrency: concurrency}
+	Register(r, config.QueueEmailInvoice, opts, processInvoiceEmail)
+	Register(r, config.QueueEmailPaymentSuccess, opts, processPaymentSuccessEmail)
+	Register(r, config.QueueEmailInvite, opts, processInviteEmail)
+	Register(r, config.QueueEmailRefund, opts, processRefundEmail)
 }
 
 // processInvoiceEmail processes invoice email job
-func processInvoiceEmail(msg amqp.Delivery, workerID int) {
-	var job EmailInvoiceJob
-	if err := json.Unmarshal(msg.Body, &job); err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to unmarshal job: %v", workerID, err)
-		msg.Nack(false, false) // Reject and don't requeue
-		return
-	}
+func processInvoiceEmail(ctx context.Context, job EmailInvoiceJob, info JobInfo) error {
+	db := config.DB.WithContext(ctx)
 
-	log.Printf("[EMAIL-WORKER-%d] Processing invoice email for purchase ID: %d", workerID, job.PurchaseID)
+	log.Printf("[EMAIL-WORKER] Processing invoice email for purchase ID: %d (attempt %d)", job.PurchaseID, info.Attempt)
 
 	// Get purchase and user data
 	var purchase models.VisaPurchase
-	if err := config.DB.Preload("Visa").Preload("VisaOption").First(&purchase, job.PurchaseID).Error; err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to get purchase: %v", workerID, err)
-		msg.Nack(false, true) // Reject and requeue
-		return
+	if err := db.Preload("Visa").Preload("VisaOption", utils.Unscoped).Preload("Applicants").Preload("LineItems", utils.LineItemsInOrder).First(&purchase, job.PurchaseID).Error; err != nil {
+		return fmt.Errorf("loading purchase: %w", err)
 	}
 
 	var user models.User
-	if err := config.DB.First(&user, job.UserID).Error; err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to get user: %v", workerID, err)
-		msg.Nack(false, true) // Reject and requeue
-		return
+	if err := db.First(&user, job.UserID).Error; err != nil {
+		return fmt.Errorf("loading user: %w", err)
 	}
 
 	// Get payment if exists
 	var payment models.Payment
-	config.DB.Where("purchase_id = ?", job.PurchaseID).Order("created_at DESC").First(&payment)
-
-	// Generate invoice email body
-	subject := fmt.Sprintf("Invoice for Visa Purchase - %s", purchase.Visa.Country)
-	body := generateInvoiceEmailBody(purchase, user, payment)
-
-	// Send email
-	if err := utils.SendEmail(job.Email, subject, body); err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to send email: %v", workerID, err)
-		msg.Nack(false, true) // Reject and requeue
-		return
+	db.Where("purchase_id = ?", job.PurchaseID).Order("created_at DESC").First(&payment)
+
+	// Send email in the customer's language
+	lang := i18n.Of(user.Language)
+	if err := mailer.SendTemplate(ctx, job.Email, lang, mailer.TemplateInvoice, mailer.InvoiceEmail{
+		Name:       user.Name,
+		Country:    purchase.Visa.Country,
+		VisaType:   purchase.Visa.Type,
+		Total:      purchaseTotalText(lang, purchase),
+		Status:     i18n.T(lang, "purchase.status."+string(purchase.Status)),
+		PaymentURL: payment.PaymentURL,
+	}); err != nil {
+		return fmt.Errorf("sending email: %w", err)
 	}
 
-	log.Printf("[EMAIL-WORKER-%d] Invoice email sent successfully to %s", workerID, job.Email)
-	msg.Ack(false) // Acknowledge message
+	log.Printf("[EMAIL-WORKER] Invoice email sent successfully to %s", job.Email)
+	return nil
 }
 
 // processPaymentSuccessEmail processes payment success email with PDF
-func processPaymentSuccessEmail(msg amqp.Delivery, workerID int) {
-	var job EmailInvoiceJob
-	if err := json.Unmarshal(msg.Body, &job); err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to unmarshal job: %v", workerID, err)
-		msg.Nack(false, false) // Reject and don't requeue
-		return
-	}
+func processPaymentSuccessEmail(ctx context.Context, job EmailInvoiceJob, info JobInfo) error {
+	db := config.DB.WithContext(ctx)
 
-	log.Printf("[EMAIL-WORKER-%d] Processing payment success email for purchase ID: %d", workerID, job.PurchaseID)
+	log.Printf("[EMAIL-WORKER] Processing payment success email for purchase ID: %d (attempt %d)", job.PurchaseID, info.Attempt)
 
 	// Get purchase and user data
 	var purchase models.VisaPurchase
-	if err := config.DB.Preload("Visa").Preload("VisaOption").First(&purchase, job.PurchaseID).Error; err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to get purchase: %v", workerID, err)
-		msg.Nack(false, true) // Reject and requeue
-		return
+	if err := db.Preload("Visa").Preload("VisaOption", utils.Unscoped).Preload("Applicants").Preload("LineItems", utils.LineItemsInOrder).First(&purchase, job.PurchaseID).Error; err != nil {
+		return fmt.Errorf("loading purchase: %w", err)
 	}
 
 	var user models.User
-	if err := config.DB.First(&user, job.UserID).Error; err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to get user: %v", workerID, err)
-		msg.Nack(false, true) // Reject and requeue
-		return
+	if err := db.First(&user, job.UserID).Error; err != nil {
+		return fmt.Errorf("loading user: %w", err)
 	}
 
 	// Get payment
 	var payment models.Payment
-	if err := config.DB.Where("purchase_id = ?", job.PurchaseID).Order("created_at DESC").First(&payment).Error; err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to get payment: %v", workerID, err)
-		msg.Nack(false, true) // Reject and requeue
-		return
+	if err := db.Where("purchase_id = ?", job.PurchaseID).Order("created_at DESC").First(&payment).Error; err != nil {
+		return fmt.Errorf("loading payment: %w", err)
 	}
 
-	// Generate PDF invoice
-	pdfPath, err := utils.GeneratePurchaseInvoicePDF(purchase, user, payment)
-	if err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to generate PDF: %v", workerID, err)
-		msg.Nack(false, true) // Reject and requeue
-		return
+	// The invoice is normally issued with the payment status change already;
+	// this returns it, or issues it for payments confirmed before invoices were recorded
+	var invoice *models.Invoice
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = utils.IssueInvoice(tx, purchase, &payment)
+		return err
+	}); err != nil {
+		return fmt.Errorf("issuing invoice: %w", err)
 	}
 
-	// Generate email body
-	subject := fmt.Sprintf("Payment Successful - Invoice #%d", purchase.ID)
-	body := generatePaymentSuccessEmailBody(purchase, user, payment)
+	pdfPath, err := utils.InvoicePDF(db, invoice)
+	if err != nil {
+		return fmt.Errorf("generating PDF: %w", err)
+	}
 
 	// Send email with PDF attachment
-	if err := utils.SendEmailWithAttachment(job.Email, subject, body, pdfPath); err != nil {
-		log.Printf("[EMAIL-WORKER-%d] Failed to send email: %v", workerID, err)
-		msg.Nack(false, true) // Reject and requeue
-		return
+	if err := mailer.SendTemplate(ctx, job.Email, i18n.Of(user.Language), mailer.TemplatePaymentSuccess, mailer.PaymentSuccessEmail{
+		Name:          user.Name,
+		PurchaseID:    purchase.ID,
+		Country:       purchase.Visa.Country,
+		VisaType:      purchase.Visa.Type,
+		AmountPaid:    models.FormatAmount(payment.Amount, payment.Currency),
+		PaymentMethod: payment.PaymentMethod,
+		InvoiceNumber: invoice.Number,
+	}, mailer.Attachment{Path: pdfPath}); err != nil {
+		return fmt.Errorf("sending email: %w", err)
 	}
 
-	log.Printf("[EMAIL-WORKER-%d] Payment success email with PDF sent to %s", workerID, job.Email)
-	msg.Ack(false) // Acknowledge message
+	log.Printf("[EMAIL-WORKER] Payment success email with PDF sent to %s", job.Email)
+	return nil
 }
 
-// generateInvoiceEmailBody generates HTML email body for invoice
-func generateInvoiceEmailBody(purchase models.VisaPurchase, user models.User, payment models.Payment) string {
-	body := fmt.Sprintf(`
-		<html>
-		<body style="font-family: Arial, sans-serif; padding: 20px;">
-			<h2>Invoice for Visa Purchase</h2>
-			<p>Dear %s,</p>
-			<p>Thank you for your purchase. Here are your order details:</p>
-			<table style="border-collapse: collapse; width: 100%%; margin: 20px 0;">
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Visa</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">%s - %s</td>
-				</tr>
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Total Price</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">Rp %.2f</td>
-				</tr>
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Status</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">%s</td>
-				</tr>
-			</table>
-	`, user.Name, purchase.Visa.Country, purchase.Visa.Type, purchase.TotalPrice, purchase.Status)
-
-	if payment.PaymentURL != "" {
-		body += fmt.Sprintf(`
-			<p><strong>Payment Link:</strong> <a href="%s">Click here to pay</a></p>
-		`, payment.PaymentURL)
+// processInviteEmail sends the invitation link for a pending staff invite
+func processInviteEmail(ctx context.Context, job InviteEmailJob, info JobInfo) error {
+	db := config.DB.WithContext(ctx)
+
+	log.Printf("[EMAIL-WORKER] Processing invite email for invite ID: %d (attempt %d)", job.InviteID, info.Attempt)
+
+	var invite models.UserInvite
+	if err := db.Preload("InvitedBy").First(&invite, job.InviteID).Error; err != nil {
+		return fmt.Errorf("loading invite: %w", err)
+	}
+
+	// Revoked or expired while queued: nothing to send
+	if invite.Status() != models.InviteStatusPending {
+		log.Printf("[EMAIL-WORKER] Invite %d is %s, skipping email", invite.ID, invite.Status())
+		return nil
+	}
+
+	// Issue the link now, so its token never sits in the outbox or the queue
+	token, err := utils.ReissueInviteToken(db, &invite)
+	if errors.Is(err, utils.ErrInviteTokenInvalid) {
+		log.Printf("[EMAIL-WORKER] Invite %d is no longer pending, skipping email", invite.ID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("issuing invite token: %w", err)
 	}
 
-	body += `
-			<p>Best regards,<br>Viskatera Team</p>
-		</body>
-		</html>
-	`
+	greeting := invite.Name
+	if greeting == "" {
+		greeting = invite.Email
+	}
+	// The invitee has no account, and so no preferred language, yet
+	lang := i18n.Default
+	if err := mailer.SendTemplate(ctx, job.Email, lang, mailer.TemplateInvite, mailer.InviteEmail{
+		Name:      greeting,
+		InvitedBy: invite.InvitedBy.Name,
+		Role:      string(invite.Role),
+		AcceptURL: fmt.Sprintf("%s/accept-invite?token=%s", os.Getenv("APP_BASE_URL"), token),
+		ExpiresAt: i18n.FormatDateTime(lang, invite.ExpiresAt),
+	}); err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
 
-	return body
+	log.Printf("[EMAIL-WORKER] Invite email sent successfully to %s", job.Email)
+	return nil
 }
 
-// generatePaymentSuccessEmailBody generates HTML email body for payment success
-func generatePaymentSuccessEmailBody(purchase models.VisaPurchase, user models.User, payment models.Payment) string {
-	return fmt.Sprintf(`
-		<html>
-		<body style="font-family: Arial, sans-serif; padding: 20px;">
-			<h2>Payment Successful!</h2>
-			<p>Dear %s,</p>
-			<p>Your payment has been successfully processed. Please find your invoice attached.</p>
-			<table style="border-collapse: collapse; width: 100%%; margin: 20px 0;">
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Purchase ID</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">#%d</td>
-				</tr>
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Visa</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">%s - %s</td>
-				</tr>
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Amount Paid</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">Rp %.2f</td>
-				</tr>
-				<tr>
-					<td style="padding: 10px; border: 1px solid #ddd;"><strong>Payment Method</strong></td>
-					<td style="padding: 10px; border: 1px solid #ddd;">%s</td>
-				</tr>
-			</table>
-			<p>Your visa application is now being processed. We will notify you once it's ready.</p>
-			<p>Best regards,<br>Viskatera Team</p>
-		</body>
-		</html>
-	`, user.Name, purchase.ID, purchase.Visa.Country, purchase.Visa.Type, payment.Amount, payment.PaymentMethod)
+// processRefundEmail sends the confirmation of a completed refund
+func processRefundEmail(ctx context.Context, job RefundEmailJob, info JobInfo) error {
+	db := config.DB.WithContext(ctx)
+
+	log.Printf("[EMAIL-WORKER] Processing refund email for refund ID: %d (attempt %d)", job.RefundID, info.Attempt)
+
+	var refund models.Refund
+	if err := db.Preload("Payment").First(&refund, job.RefundID).Error; err != nil {
+		return fmt.Errorf("loading refund: %w", err)
+	}
+
+	var purchase models.VisaPurchase
+	if err := db.Preload("Visa").First(&purchase, refund.PurchaseID).Error; err != nil {
+		return fmt.Errorf("loading purchase: %w", err)
+	}
+
+	var user models.User
+	if err := db.First(&user, job.UserID).Error; err != nil {
+		return fmt.Errorf("loading user: %w", err)
+	}
+
+	// Like invoices, credit notes are normally issued with the refund status change already
+	var note *models.Invoice
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		note, err = utils.IssueCreditNote(tx, refund)
+		return err
+	}); err != nil {
+		return fmt.Errorf("issuing credit note: %w", err)
+	}
+	pdfPath, err := utils.InvoicePDF(db, note)
+	if err != nil {
+		return fmt.Errorf("generating PDF: %w", err)
+	}
+
+	currency := refund.Payment.Currency
+	if err := mailer.SendTemplate(ctx, job.Email, i18n.Of(user.Language), mailer.TemplateRefund, mailer.RefundEmail{
+		Name:           user.Name,
+		PurchaseID:     purchase.ID,
+		Country:        purchase.Visa.Country,
+		VisaType:       purchase.Visa.Type,
+		AmountRefunded: models.FormatAmount(refund.Amount, currency),
+		AmountPaid:     models.FormatAmount(refund.Payment.Amount, currency),
+		TotalRefunded:  models.FormatAmount(refund.Payment.RefundedAmount, currency),
+		Reason:         refund.Reason,
+	}, mailer.Attachment{Path: pdfPath}); err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+
+	log.Printf("[EMAIL-WORKER] Refund email sent successfully to %s", job.Email)
+	return nil
+}
+
+// purchaseTotalText shows the charged total, followed by any promo discount and
+// the original price when the visa is priced in another currency
+func purchaseTotalText(lang i18n.Language, purchase models.VisaPurchase) string {
+	total := models.FormatAmount(purchase.TotalPrice, purchase.Currency)
+	if purchase.DiscountAmount > 0 {
+		total = i18n.T(lang, "email.invoice.discount", total,
+			models.FormatAmount(purchase.DiscountAmount, purchase.Currency), purchase.PromoCode)
+	}
+	if purchase.OriginalCurrency == "" || purchase.OriginalCurrency == purchase.Currency {
+		return total
+	}
+	return i18n.T(lang, "email.invoice.converted", total,
+		models.FormatAmount(purchase.OriginalTotal, purchase.OriginalCurrency),
+		purchase.OriginalCurrency, strconv.FormatFloat(purchase.ExchangeRate, 'f', -1, 64), purchase.Currency)
 }
@@ -0,0 +1,45 @@
+package workers
+
+import (
+	"strings"
+	"testing"
+	"viskatera-api-go/i18n"
+	"viskatera-api-go/models"
+)
+
+func TestPurchaseStatusTranslated(t *testing.T) {
+	statuses := []models.PurchaseStatus{
+		models.PurchaseStatusDraft, models.PurchaseStatusSubmitted, models.PurchaseStatusDocumentsReview,
+		models.PurchaseStatusSubmittedToEmbassy, models.PurchaseStatusApproved, models.PurchaseStatusRejected,
+		models.PurchaseStatusIssued, models.PurchaseStatusCancelled, models.PurchaseStatusRefunded,
+	}
+	for _, lang := range i18n.Languages {
+		for _, status := range statuses {
+			if _, ok := i18n.Lookup(lang, "purchase.status."+string(status)); !ok {
+				t.Errorf("no %s text for purchase status %s", lang, status)
+			}
+		}
+	}
+}
+
+func TestPurchaseTotalText(t *testing.T) {
+	purchase := models.VisaPurchase{
+		TotalPrice:       1650000,
+		Currency:         "IDR",
+		OriginalTotal:    10000,
+		OriginalCurrency: "USD",
+		ExchangeRate:     16500,
+	}
+	tests := []struct {
+		lang i18n.Language
+		want string
+	}{
+		{i18n.English, " at 1 USD = 16500 IDR)"},
+		{i18n.Indonesian, " dengan kurs 1 USD = 16500 IDR)"},
+	}
+	for _, tt := range tests {
+		if got := purchaseTotalText(tt.lang, purchase); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("purchaseTotalText(%s) = %q, want it to end with %q", tt.lang, got, tt.want)
+		}
+	}
+}
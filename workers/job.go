@@ -0,0 +1,309 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/mailer"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultJobTimeout     = 2 * time.Minute
+	consumerRetryMin      = time.Second
+	consumerRetryMax      = 30 * time.Second
+	defaultJobConcurrency = 10
+)
+
+// JobOptions configures how the jobs of one queue are processed
+type JobOptions struct {
+	Concurrency int           // jobs handled in parallel; defaults to 10
+	Timeout     time.Duration // per attempt, after which the handler's context is cancelled; defaults to 2m
+	MaxAttempts int           // attempts before the job is dead-lettered; defaults to QUEUE_MAX_ATTEMPTS
+}
+
+// JobInfo describes the delivery a handler is working on
+type JobInfo struct {
+	MessageID string
+	Queue     string
+	Attempt   int
+}
+
+// permanentError marks a job failure that retrying cannot fix
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// Permanent marks err as a failure retrying cannot fix, so the job is dead-lettered at once
+func Permanent(err error) error { return permanentError{err} }
+
+// IsPermanent reports whether a job failed for good: the handler said so, a
+// record the job refers to is gone, or the mail server refused the recipient
+func IsPermanent(err error) bool {
+	var perm permanentError
+	return errors.As(err, &perm) || errors.Is(err, gorm.ErrRecordNotFound) || mailer.IsPermanentError(err)
+}
+
+// Runner consumes the queues of the registered jobs. Failed jobs are retried
+// through the retry queues and dead-lettered once they fail permanently or run
+// out of attempts. Consumers reopen their channel when it closes.
+type Runner struct {
+	jobs []*registeredJob
+
+	// stopping is cancelled when Shutdown starts: consumers stop taking new jobs
+	stopping context.Context
+	stop     context.CancelFunc
+	// aborting is cancelled when the drain deadline passes: running handlers are cancelled
+	aborting context.Context
+	abort    context.CancelFunc
+
+	consumers sync.WaitGroup
+	// consuming counts the queues currently being consumed
+	consuming atomic.Int32
+}
+
+type registeredJob struct {
+	queue  string
+	opts   JobOptions
+	handle func(ctx context.Context, msg amqp.Delivery, info JobInfo) error
+}
+
+// NewRunner returns a runner without jobs
+func NewRunner() *Runner {
+	r := &Runner{}
+	r.stopping, r.stop = context.WithCancel(context.Background())
+	r.aborting, r.abort = context.WithCancel(context.Background())
+	return r
+}
+
+// Register adds a handler for the jobs of queue. Job bodies are JSON decoded
+// into T; a body that does not decode is dead-lettered. A handler returning
+// an error has the job retried, unless the error is permanent.
+func Register[T any](r *Runner, queue string, opts JobOptions, handle func(ctx context.Context, job T, info JobInfo) error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = defaultJobConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultJobTimeout
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = config.QueueMaxAttempts()
+	}
+
+	r.jobs = append(r.jobs, &registeredJob{
+		queue: queue,
+		opts:  opts,
+		handle: func(ctx context.Context, msg amqp.Delivery, info JobInfo) error {
+			var job T
+			if err := json.Unmarshal(msg.Body, &job); err != nil {
+				return Permanent(fmt.Errorf("invalid job: %w", err))
+			}
+			return handle(ctx, job, info)
+		},
+	})
+}
+
+// Start starts consuming every registered queue and returns immediately
+func (r *Runner) Start() {
+	for _, job := range r.jobs {
+		log.Printf("[JOBS] Consuming %s with %d workers", job.queue, job.opts.Concurrency)
+		r.consumers.Add(1)
+		go func(job *registeredJob) {
+			defer r.consumers.Done()
+			r.consume(job)
+		}(job)
+	}
+}
+
+// Shutdown stops taking new jobs and waits for running ones to finish. When
+// ctx ends first, running handlers are cancelled and ctx's error is returned;
+// their jobs are redelivered later.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.stop()
+
+	done := make(chan struct{})
+	go func() {
+		r.consumers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("[JOBS] All jobs drained")
+		return nil
+	case <-ctx.Done():
+		r.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Ready reports an error unless every registered queue is being consumed
+func (r *Runner) Ready() error {
+	if consuming := int(r.consuming.Load()); consuming < len(r.jobs) {
+		return fmt.Errorf("%d of %d queues are being consumed", consuming, len(r.jobs))
+	}
+	return nil
+}
+
+// consume keeps a consumer for job's queue running until shutdown, opening a
+// new channel with backoff whenever the current one fails or the connection
+// is re-established
+func (r *Runner) consume(job *registeredJob) {
+	backoff := consumerRetryMin
+	for {
+		// While RabbitMQ is reconnecting there is nothing to consume from
+		select {
+		case <-config.RabbitMQReady():
+		case <-r.stopping.Done():
+			return
+		}
+
+		started := time.Now()
+		err := r.consumeChannel(job)
+		if r.stopping.Err() != nil {
+			return
+		}
+
+		// A consumer that ran for a while starts over with a short backoff
+		if time.Since(started) > consumerRetryMax {
+			backoff = consumerRetryMin
+		}
+		log.Printf("[JOBS] Consumer for %s stopped: %v. Retrying in %s", job.queue, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-r.stopping.Done():
+			return
+		}
+		backoff *= 2
+		if backoff > consumerRetryMax {
+			backoff = consumerRetryMax
+		}
+	}
+}
+
+// consumeChannel consumes job's queue on a channel of its own until the
+// channel closes or shutdown starts. It returns once every job it took has
+// been settled.
+func (r *Runner) consumeChannel(job *registeredJob) error {
+	ch, err := config.RabbitMQChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	// Prefetch only as many jobs as can run at once
+	if err := ch.Qos(job.opts.Concurrency, 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	consumerTag := fmt.Sprintf("%s-%d", job.queue, time.Now().UnixNano())
+	msgs, err := ch.Consume(
+		job.queue,
+		consumerTag,
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to consume: %w", err)
+	}
+	r.consuming.Add(1)
+	defer r.consuming.Add(-1)
+
+	var workers sync.WaitGroup
+	for i := 0; i < job.opts.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for msg := range msgs {
+				r.process(job, msg)
+			}
+		}()
+	}
+
+	select {
+	case <-r.stopping.Done():
+		// Stop deliveries and let running jobs finish; prefetched jobs go back to the queue
+		if err := ch.Cancel(consumerTag, false); err != nil {
+			log.Printf("[JOBS] Failed to cancel consumer for %s: %v", job.queue, err)
+		}
+		workers.Wait()
+		return nil
+	case amqpErr := <-closed:
+		workers.Wait()
+		if amqpErr == nil {
+			return errors.New("channel closed")
+		}
+		return amqpErr
+	}
+}
+
+// process runs the handler for one delivery and settles it
+func (r *Runner) process(job *registeredJob, msg amqp.Delivery) {
+	info := JobInfo{
+		MessageID: msg.MessageId,
+		Queue:     job.queue,
+		Attempt:   config.MessageAttempt(msg),
+	}
+
+	ctx, cancel := context.WithTimeout(r.aborting, job.opts.Timeout)
+	defer cancel()
+
+	err := runHandler(ctx, job, msg, info)
+	if err != nil && r.aborting.Err() != nil {
+		// Shutdown cut the job short: leave it to be redelivered as it is
+		msg.Nack(false, true)
+		return
+	}
+	settleJob(job, msg, info, err)
+}
+
+func runHandler(ctx context.Context, job *registeredJob, msg amqp.Delivery, info JobInfo) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("[JOBS] Job %s from %s panicked: %v\n%s", info.MessageID, info.Queue, p, debug.Stack())
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return job.handle(ctx, msg, info)
+}
+
+// settleJob acknowledges a handled job. A failed one is retried after a
+// backoff, or moved to the dead-letter queue when the failure is permanent or
+// the job has run out of attempts. The delivery is only acknowledged once
+// RabbitMQ has confirmed the retry or dead-letter copy.
+func settleJob(job *registeredJob, msg amqp.Delivery, info JobInfo, err error) {
+	if err == nil {
+		msg.Ack(false)
+		return
+	}
+
+	var handOff error
+	if IsPermanent(err) || info.Attempt >= job.opts.MaxAttempts {
+		log.Printf("[JOBS] Dead-lettering job %s from %s after attempt %d: %v", info.MessageID, info.Queue, info.Attempt, err)
+		handOff = config.DeadLetterMessage(job.queue, msg, err)
+	} else {
+		log.Printf("[JOBS] Attempt %d of job %s from %s failed, retrying: %v", info.Attempt, info.MessageID, info.Queue, err)
+		handOff = config.RetryMessage(job.queue, msg, err)
+	}
+	if handOff != nil {
+		// Reject without requeue so the queue dead-letters it instead of redelivering it at once
+		log.Printf("[JOBS] Failed to hand off job %s: %v", info.MessageID, handOff)
+		msg.Nack(false, false)
+		return
+	}
+	msg.Ack(false)
+}
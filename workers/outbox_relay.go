@@ -0,0 +1,142 @@
+package workers
+
+import (
+	"log"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultOutboxInterval  = 2 * time.Second
+	defaultOutboxRetention = 7 * 24 * time.Hour
+	outboxBatchSize        = 100
+	outboxMaxBackoff       = 5 * time.Minute
+	outboxPruneEvery       = time.Hour
+)
+
+// StartOutboxRelay publishes pending outbox messages to RabbitMQ every
+// OUTBOX_INTERVAL. Messages RabbitMQ does not accept are retried with
+// exponential backoff until it does. Every instance may run the relay; rows
+// are claimed with SKIP LOCKED so each message is published by one instance.
+// Sent messages are deleted after OUTBOX_RETENTION. It returns once stop is
+// closed and the batch being published is committed.
+func StartOutboxRelay(stop <-chan struct{}) {
+	interval := envDuration("OUTBOX_INTERVAL", defaultOutboxInterval)
+	if interval <= 0 {
+		log.Println("[OUTBOX] Disabled")
+		return
+	}
+	retention := envDuration("OUTBOX_RETENTION", defaultOutboxRetention)
+
+	log.Printf("[OUTBOX] Relaying every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastPrune time.Time
+	for {
+		RunOutboxRelay(stop)
+		if time.Since(lastPrune) >= outboxPruneEvery {
+			pruneOutbox(retention)
+			lastPrune = time.Now()
+		}
+		if !waitTick(ticker, stop) {
+			return
+		}
+	}
+}
+
+// RunOutboxRelay publishes every due outbox message, batch by batch, until
+// none are left, RabbitMQ stops accepting them or stop is closed
+func RunOutboxRelay(stop <-chan struct{}) {
+	for {
+		sent, more, err := relayOutboxBatch()
+		if sent > 0 {
+			log.Printf("[OUTBOX] Published %d messages", sent)
+		}
+		if err != nil {
+			log.Printf("[OUTBOX] Publishing paused: %v", err)
+			return
+		}
+		if !more {
+			return
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// relayOutboxBatch publishes one batch of due messages. It stops at the first
+// message RabbitMQ rejects, since the rest would most likely fail the same way.
+func relayOutboxBatch() (sent int, more bool, publishErr error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var messages []models.OutboxMessage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, time.Now()).
+			Order("id ASC").
+			Limit(outboxBatchSize).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+		more = len(messages) == outboxBatchSize
+
+		for _, message := range messages {
+			now := time.Now()
+			if err := config.PublishMessage(message.Queue, []byte(message.Payload)); err != nil {
+				publishErr = err
+				more = false
+				return tx.Model(&message).Updates(map[string]interface{}{
+					"attempts":        message.Attempts + 1,
+					"last_error":      err.Error(),
+					"next_attempt_at": now.Add(outboxBackoff(message.Attempts + 1)),
+				}).Error
+			}
+
+			if err := tx.Model(&message).Updates(map[string]interface{}{
+				"status":     models.OutboxStatusSent,
+				"attempts":   message.Attempts + 1,
+				"last_error": "",
+				"sent_at":    now,
+			}).Error; err != nil {
+				return err
+			}
+			sent++
+		}
+		return nil
+	})
+	if err != nil {
+		// The batch rolled back; messages already published will be sent again
+		return 0, false, err
+	}
+	return sent, more, publishErr
+}
+
+// outboxBackoff doubles the wait after every failed attempt, from 2s up to outboxMaxBackoff
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 10 {
+		return outboxMaxBackoff
+	}
+	backoff := time.Second << attempts
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
+
+func pruneOutbox(retention time.Duration) {
+	result := config.DB.Where("status = ? AND sent_at < ?", models.OutboxStatusSent, time.Now().Add(-retention)).
+		Delete(&models.OutboxMessage{})
+	if result.Error != nil {
+		log.Printf("[OUTBOX] Failed to prune sent messages: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[OUTBOX] Pruned %d sent messages", result.RowsAffected)
+	}
+}
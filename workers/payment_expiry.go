@@ -0,0 +1,255 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
+	"viskatera-api-go/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// paymentExpiryLockKey identifies the payment expiry job among Postgres advisory locks
+	paymentExpiryLockKey int64 = 7_301_001
+
+	defaultPaymentExpiryInterval = 5 * time.Minute
+	defaultPaymentInvoiceTTL     = 24 * time.Hour
+	defaultPurchaseCancelGrace   = 24 * time.Hour
+	paymentExpiryBatchSize       = 100
+
+	// expiryBatchOrder puts rows the job has never tried first, then the ones tried longest ago
+	expiryBatchOrder = "last_checked_at ASC NULLS FIRST, id ASC"
+)
+
+// StartPaymentExpiryJob periodically expires stale pending payments and
+// cancels the draft purchases they leave behind. Every instance may start it;
+// a Postgres advisory lock makes sure only one of them runs it at a time.
+// PAYMENT_EXPIRY_INTERVAL=0 disables the job. It returns once stop is closed
+// and the running pass has finished; ctx aborts the pass.
+func StartPaymentExpiryJob(ctx context.Context, stop <-chan struct{}) {
+	interval := envDuration("PAYMENT_EXPIRY_INTERVAL", defaultPaymentExpiryInterval)
+	if interval <= 0 {
+		log.Println("[PAYMENT-EXPIRY] Disabled")
+		return
+	}
+
+	log.Printf("[PAYMENT-EXPIRY] Running every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		RunPaymentExpiry(ctx)
+		if !waitTick(ticker, stop) {
+			return
+		}
+	}
+}
+
+// RunPaymentExpiry performs one pass of the payment expiry job
+func RunPaymentExpiry(ctx context.Context) {
+	ran, err := config.WithAdvisoryLock(ctx, paymentExpiryLockKey, func() error {
+		expired := expireStalePayments(ctx)
+		cancelled := cancelAbandonedPurchases()
+		if expired > 0 || cancelled > 0 {
+			log.Printf("[PAYMENT-EXPIRY] Expired %d payments, cancelled %d purchases", expired, cancelled)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[PAYMENT-EXPIRY] Failed to run: %v", err)
+		return
+	}
+	if !ran {
+		log.Println("[PAYMENT-EXPIRY] Another instance is running the job, skipping")
+	}
+}
+
+// expireStalePayments reconciles pending payments whose invoice has expired
+// with their gateway and returns how many changed status
+func expireStalePayments(ctx context.Context) int {
+	invoiceTTL := envDuration("PAYMENT_INVOICE_TTL", defaultPaymentInvoiceTTL)
+	now := time.Now()
+
+	// Payments created before expiry was recorded fall back to created_at + PAYMENT_INVOICE_TTL
+	var stale []models.Payment
+	if err := config.DB.
+		Where("status = ?", "pending").
+		Where("(expires_at IS NOT NULL AND expires_at < ?) OR (expires_at IS NULL AND created_at < ?)", now, now.Add(-invoiceTTL)).
+		Order(expiryBatchOrder).
+		Limit(paymentExpiryBatchSize).
+		Find(&stale).Error; err != nil {
+		log.Printf("[PAYMENT-EXPIRY] Failed to load stale payments: %v", err)
+		return 0
+	}
+	ids := make([]uint, len(stale))
+	for i, payment := range stale {
+		ids[i] = payment.ID
+	}
+	markExpiryChecked(&models.Payment{}, ids, now)
+
+	changed := 0
+	for _, payment := range stale {
+		ok, err := reconcileStalePayment(ctx, payment)
+		if err != nil {
+			log.Printf("[PAYMENT-EXPIRY] Payment %d: %v", payment.ID, err)
+			continue
+		}
+		if ok {
+			changed++
+		}
+	}
+	return changed
+}
+
+// reconcileStalePayment asks the gateway for the real outcome of an expired
+// invoice, expiring it at the gateway if it is somehow still open, and applies
+// that outcome. An invoice that was paid after all is recorded as paid, unless
+// the gateway reports a different paid amount.
+func reconcileStalePayment(ctx context.Context, payment models.Payment) (bool, error) {
+	invoice, err := staleInvoice(ctx, payment)
+	if err != nil {
+		return false, err
+	}
+
+	var change utils.PaymentStatusChange
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, payment.ID).Error; err != nil {
+			return err
+		}
+		change, err = utils.ApplyGatewayPaymentStatus(tx, &payment, invoice.Status, invoice.PaidAmount, "Payment reconciled by expiry job")
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	utils.RecordPaymentStatusChange(nil, 0, &payment, change)
+	return change.Changed(), nil
+}
+
+// staleInvoice returns the gateway's final view of the payment's invoice. An
+// invoice the gateway no longer knows about is treated as expired.
+func staleInvoice(ctx context.Context, payment models.Payment) (*payments.Invoice, error) {
+	expired := &payments.Invoice{ID: payment.XenditID, Status: "EXPIRED"}
+
+	gateway, err := payments.Get(payment.Gateway)
+	if err != nil {
+		// The gateway is no longer configured, e.g. fake payments after switching back
+		log.Printf("[PAYMENT-EXPIRY] Payment %d: %v, expiring locally", payment.ID, err)
+		return expired, nil
+	}
+
+	invoice, err := gateway.GetInvoice(ctx, payment.XenditID)
+	if errors.Is(err, payments.ErrInvoiceNotFound) {
+		return expired, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking invoice: %w", err)
+	}
+
+	if utils.NormalizePaymentStatus(invoice.Status) == "pending" {
+		invoice, err = gateway.ExpireInvoice(ctx, payment.XenditID)
+		if err != nil {
+			return nil, fmt.Errorf("expiring invoice: %w", err)
+		}
+	}
+	return invoice, nil
+}
+
+// cancelAbandonedPurchases cancels draft purchases whose last payment expired
+// or failed more than PURCHASE_CANCEL_GRACE ago without a new payment attempt
+func cancelAbandonedPurchases() int {
+	grace := envDuration("PURCHASE_CANCEL_GRACE", defaultPurchaseCancelGrace)
+	cutoff := time.Now().Add(-grace)
+
+	var purchases []models.VisaPurchase
+	if err := config.DB.
+		Where("status = ?", models.PurchaseStatusDraft).
+		Where(`EXISTS (
+			SELECT 1 FROM payments p
+			WHERE p.purchase_id = visa_purchases.id AND p.deleted_at IS NULL
+			AND p.status IN ('expired', 'failed') AND COALESCE(p.expires_at, p.updated_at) < ?
+		)`, cutoff).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM payments p
+			WHERE p.purchase_id = visa_purchases.id AND p.deleted_at IS NULL
+			AND (p.status NOT IN ('expired', 'failed') OR COALESCE(p.expires_at, p.updated_at) >= ?)
+		)`, cutoff).
+		Order(expiryBatchOrder).
+		Limit(paymentExpiryBatchSize).
+		Find(&purchases).Error; err != nil {
+		log.Printf("[PAYMENT-EXPIRY] Failed to load abandoned purchases: %v", err)
+		return 0
+	}
+	ids := make([]uint, len(purchases))
+	for i, purchase := range purchases {
+		ids[i] = purchase.ID
+	}
+	markExpiryChecked(&models.VisaPurchase{}, ids, time.Now())
+
+	cancelled := 0
+	for i := range purchases {
+		purchase := &purchases[i]
+		oldStatus := purchase.Status
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			return utils.TransitionPurchase(tx, purchase, models.PurchaseStatusCancelled, models.ActorSystem, nil, "Payment expired; cancelled automatically")
+		})
+		if err != nil {
+			log.Printf("[PAYMENT-EXPIRY] Purchase %d not cancelled: %v", purchase.ID, err)
+			continue
+		}
+
+		// Log purchase status update
+		entityName := "Purchase #" + strconv.Itoa(int(purchase.ID))
+		utils.LogUpdate(nil, 0, models.EntityPurchase, purchase.ID, entityName,
+			map[string]interface{}{"status": oldStatus},
+			map[string]interface{}{"status": purchase.Status},
+		)
+		cancelled++
+	}
+	return cancelled
+}
+
+// markExpiryChecked records that the rows are being tried now. Batches take the
+// rows tried longest ago first, so rows that keep failing make way for the rest
+// instead of filling every batch. updated_at is left alone: the job reads it.
+func markExpiryChecked(model interface{}, ids []uint, now time.Time) {
+	if len(ids) == 0 {
+		return
+	}
+	if err := config.DB.Model(model).Where("id IN ?", ids).UpdateColumn("last_checked_at", now).Error; err != nil {
+		log.Printf("[PAYMENT-EXPIRY] Failed to record checked rows: %v", err)
+	}
+}
+
+// waitTick waits for the next tick and reports false once stop is closed instead
+func waitTick(ticker *time.Ticker, stop <-chan struct{}) bool {
+	select {
+	case <-ticker.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,39 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/utils"
+)
+
+// GeneratePDFJob represents job data for rendering the PDF of an issued invoice or credit note
+type GeneratePDFJob struct {
+	InvoiceID uint `json:"invoice_id"`
+}
+
+// registerPDFJobs registers the PDF generation job. PDFs are rendered ahead
+// of time so downloads and emails find them stored already.
+func registerPDFJobs(r *Runner, concurrency int) {
+	Register(r, config.QueueGeneratePDF, JobOptions{Concurrency: concurrency}, processGeneratePDF)
+}
+
+// processGeneratePDF renders and stores the PDF of an invoice unless it is stored already
+func processGeneratePDF(ctx context.Context, job GeneratePDFJob, info JobInfo) error {
+	db := config.DB.WithContext(ctx)
+
+	var invoice models.Invoice
+	if err := db.First(&invoice, job.InvoiceID).Error; err != nil {
+		return fmt.Errorf("loading invoice: %w", err)
+	}
+
+	path, err := utils.InvoicePDF(db, &invoice)
+	if err != nil {
+		return fmt.Errorf("generating PDF: %w", err)
+	}
+
+	log.Printf("[PDF-WORKER] PDF of %s stored at %s", invoice.Number, path)
+	return nil
+}
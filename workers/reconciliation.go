@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+	"viskatera-api-go/config"
+	"viskatera-api-go/models"
+	"viskatera-api-go/payments"
+	"viskatera-api-go/utils"
+)
+
+// reconciliationLockKey identifies the scheduled reconciliation among Postgres advisory locks
+const reconciliationLockKey int64 = 7_301_002
+
+// StartReconciliationJob periodically reconciles the previous day's payments
+// against the active gateway. It is off by default because it looks up every
+// invoice of the day; set RECONCILIATION_INTERVAL (e.g. 24h) to enable it.
+// It returns once stop is closed and the running pass has finished; ctx
+// aborts the pass.
+func StartReconciliationJob(ctx context.Context, stop <-chan struct{}) {
+	interval := envDuration("RECONCILIATION_INTERVAL", 0)
+	if interval <= 0 {
+		log.Println("[RECONCILIATION] Disabled")
+		return
+	}
+
+	log.Printf("[RECONCILIATION] Running every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		RunScheduledReconciliation(ctx)
+		if !waitTick(ticker, stop) {
+			return
+		}
+	}
+}
+
+// RunScheduledReconciliation reconciles yesterday's payments unless a
+// scheduled run for that day already exists
+func RunScheduledReconciliation(ctx context.Context) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, -1)
+	gateway := payments.Default().Name()
+
+	ran, err := config.WithAdvisoryLock(ctx, reconciliationLockKey, func() error {
+		var existing int64
+		if err := config.DB.Model(&models.ReconciliationRun{}).
+			Where("source = ? AND gateway = ? AND period_from = ? AND created_by_id IS NULL", models.ReconciliationSourceGateway, gateway, from).
+			Where("status <> ?", models.ReconciliationFailed).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		run := models.ReconciliationRun{
+			Source:     models.ReconciliationSourceGateway,
+			Gateway:    gateway,
+			PeriodFrom: &from,
+			PeriodTo:   &to,
+			Status:     models.ReconciliationRunning,
+		}
+		if err := config.DB.Create(&run).Error; err != nil {
+			return err
+		}
+
+		utils.ReconcileGateway(ctx, &run)
+		log.Printf("[RECONCILIATION] Finished run %d for %s", run.ID, from.Format("2006-01-02"))
+		return nil
+	})
+	if err != nil {
+		log.Printf("[RECONCILIATION] Failed to run: %v", err)
+		return
+	}
+	if !ran {
+		log.Println("[RECONCILIATION] Another instance is running the job, skipping")
+	}
+}
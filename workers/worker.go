@@ -4,11 +4,11 @@ import (
 	"log"
 	"os"
 	"strconv"
-	"viskatera-api-go/config"
 )
 
-// StartWorkers starts all background workers
-func StartWorkers() {
+// StartWorkers registers every job and starts consuming. The returned runner
+// is shut down with the process so running jobs can finish.
+func StartWorkers() *Runner {
 	// Get concurrency from environment or use default
 	concurrencyStr := os.Getenv("WORKER_CONCURRENCY")
 	concurrency := 10 // Default to 10 parallel workers
@@ -20,25 +20,11 @@ func StartWorkers() {
 
 	log.Printf("[WORKERS] Starting workers with concurrency: %d", concurrency)
 
-	// Start email worker
-	go func() {
-		if err := StartEmailWorker(concurrency); err != nil {
-			log.Fatalf("[WORKERS] Failed to start email worker: %v", err)
-		}
-	}()
+	runner := NewRunner()
+	registerEmailJobs(runner, concurrency)
+	registerPDFJobs(runner, concurrency)
+	runner.Start()
 
 	log.Println("[WORKERS] All workers started successfully")
-}
-
-// InitializeWorkers initializes workers (called from main.go)
-func InitializeWorkers() {
-	// Connect to RabbitMQ if not already connected
-	if config.RabbitMQConn == nil {
-		if err := config.ConnectRabbitMQ(); err != nil {
-			log.Fatalf("[WORKERS] Failed to connect to RabbitMQ: %v", err)
-		}
-	}
-
-	// Start workers
-	StartWorkers()
+	return runner
 }